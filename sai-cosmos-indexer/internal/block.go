@@ -39,6 +39,32 @@ func (is *InternalService) getLatestBlock() (int64, error) {
 	return int64(blockHeight), err
 }
 
+func (is *InternalService) getBlock(height int64) (*model.Block, error) {
+	res, err := is.client.Get(fmt.Sprintf("%s/cosmos/base/tendermint/v1beta1/blocks/%d", is.config.NodeAddress, height))
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getBlock res status: %v, %s", res.StatusCode, bodyBytes)
+	}
+
+	block := model.Block{}
+	err = jsoniter.Unmarshal(bodyBytes, &block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &block, nil
+}
+
 func (is *InternalService) getBlockTxs() ([]model.TxResponse, error) {
 	const (
 		urlTemplateGetTxs = "%s/cosmos/tx/v1beta1/txs?pagination.limit=100&pagination.offset=%v&events=tx.height=%v&events=message.action='%s'"
@@ -0,0 +1,222 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/saiset-co/saiCosmosIndexer/internal/model"
+	"github.com/saiset-co/saiCosmosIndexer/metrics"
+)
+
+// notified blocks kept to find the fork block on reorg
+const blockHistory = 128
+
+// pending blocks are handled only by the Process loop, so they are not locked
+
+func (is *InternalService) loadPendingBlocks() error {
+	fileBytes, err := os.ReadFile(filePathPendingBlocks)
+	if err != nil {
+		return err
+	}
+
+	return jsoniter.Unmarshal(fileBytes, &is.pendingBlocks)
+}
+
+// file is written to temp file and renamed, so it is never half-written
+func (is *InternalService) rewritePendingBlocksFile() error {
+	jsonBytes, err := jsoniter.Marshal(&is.pendingBlocks)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(filePathPendingBlocks+".tmp", jsonBytes, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(filePathPendingBlocks+".tmp", filePathPendingBlocks)
+}
+
+func (is *InternalService) getPendingBlock(height int64) (model.PendingBlock, bool) {
+	for _, blk := range is.pendingBlocks {
+		if blk.Height == height {
+			return blk, true
+		}
+	}
+
+	return model.PendingBlock{}, false
+}
+
+// blocks above the height are replaced by the handled block, the same block handled again keeps its status
+func (is *InternalService) addPendingBlock(block model.PendingBlock) error {
+	if stored, ok := is.getPendingBlock(block.Height); ok && stored.Hash == block.Hash {
+		return nil
+	}
+
+	is.pendingBlocks = append(is.pendingBlocks[:is.countPendingBlocks(block.Height-1)], block)
+
+	return is.rewritePendingBlocksFile()
+}
+
+// remove blocks above the fork block, removed blocks are returned
+func (is *InternalService) rollbackPendingBlocks(fork int64) ([]model.PendingBlock, error) {
+	n := is.countPendingBlocks(fork)
+	orphaned := append([]model.PendingBlock{}, is.pendingBlocks[n:]...)
+	is.pendingBlocks = is.pendingBlocks[:n]
+
+	return orphaned, is.rewritePendingBlocksFile()
+}
+
+// notify the bridge about transactions from blocks with enough confirmations in block order,
+// notifying stops on the first failed block, so it is retried with the next block.
+// Pending retractions are sent first, so the bridge never gets a transaction of the new chain before the retraction of the orphaned one
+func (is *InternalService) releaseConfirmed(head int64) error {
+	defer func() {
+		metrics.PendingEvents.Set(float64(is.countPendingTxs()))
+	}()
+
+	err := is.sendRetractions()
+	if err != nil {
+		return err
+	}
+
+	for i := range is.pendingBlocks {
+		blk := &is.pendingBlocks[i]
+		if blk.Notified {
+			continue
+		}
+
+		if head-blk.Height < is.confirmations {
+			break
+		}
+
+		err = is.notifyBlockTxs(blk)
+		if err != nil {
+			break
+		}
+
+		blk.Notified = true
+	}
+
+	// notified blocks out of the history are dropped
+	i := 0
+	for i < len(is.pendingBlocks) && is.pendingBlocks[i].Notified && head-is.pendingBlocks[i].Height >= is.confirmations+blockHistory {
+		i++
+	}
+	is.pendingBlocks = is.pendingBlocks[i:]
+
+	if rewriteErr := is.rewritePendingBlocksFile(); err == nil {
+		err = rewriteErr
+	}
+
+	return err
+}
+
+func (is *InternalService) notifyBlockTxs(blk *model.PendingBlock) error {
+	for _, tx := range blk.Txs {
+		err := is.notifier.SendTx(tx)
+		metrics.NotificationResult(err)
+		if err != nil {
+			return fmt.Errorf("block %d : SendTx : %w", blk.Height, err)
+		}
+	}
+
+	return nil
+}
+
+func (is *InternalService) loadRetractions() error {
+	fileBytes, err := os.ReadFile(filePathRetractions)
+	if err != nil {
+		return err
+	}
+
+	return jsoniter.Unmarshal(fileBytes, &is.retractions)
+}
+
+// file is written to temp file and renamed, so it is never half-written
+func (is *InternalService) rewriteRetractionsFile() error {
+	jsonBytes, err := jsoniter.Marshal(&is.retractions)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(filePathRetractions+".tmp", jsonBytes, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(filePathRetractions+".tmp", filePathRetractions)
+}
+
+// queue retractions of the orphaned transactions which were sent to the bridge
+func (is *InternalService) addRetractions(txs []map[string]interface{}) error {
+	if len(txs) == 0 {
+		return nil
+	}
+
+	n := len(is.retractions)
+	is.retractions = append(is.retractions, txs...)
+
+	// retractions which are not saved are dropped, so the reorg is handled again
+	err := is.rewriteRetractionsFile()
+	if err != nil {
+		is.retractions = is.retractions[:n]
+	}
+	metrics.PendingRetractions.Set(float64(len(is.retractions)))
+
+	return err
+}
+
+// send queued retractions in order, sending stops on the first failed one, so it is retried with the next block
+func (is *InternalService) sendRetractions() error {
+	defer func() {
+		metrics.PendingRetractions.Set(float64(len(is.retractions)))
+	}()
+
+	sent := 0
+	var err error
+	for _, tx := range is.retractions {
+		err = is.notifier.RetractTx(tx)
+		metrics.RetractionResult(err)
+		if err != nil {
+			err = fmt.Errorf("RetractTx %v : %w", tx["Hash"], err)
+			break
+		}
+		sent++
+	}
+
+	if sent == 0 {
+		return err
+	}
+
+	is.retractions = is.retractions[sent:]
+	if rewriteErr := is.rewriteRetractionsFile(); err == nil {
+		err = rewriteErr
+	}
+
+	return err
+}
+
+// number of blocks up to the height (inclusive)
+func (is *InternalService) countPendingBlocks(height int64) int {
+	for i, blk := range is.pendingBlocks {
+		if blk.Height > height {
+			return i
+		}
+	}
+
+	return len(is.pendingBlocks)
+}
+
+// number of transactions waiting for confirmations
+func (is *InternalService) countPendingTxs() int {
+	pending := 0
+	for _, blk := range is.pendingBlocks {
+		if !blk.Notified {
+			pending += len(blk.Txs)
+		}
+	}
+
+	return pending
+}
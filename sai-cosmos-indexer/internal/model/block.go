@@ -7,3 +7,26 @@ type LatestBlock struct {
 		} `json:"header"`
 	} `json:"block"`
 }
+
+type Block struct {
+	BlockID struct {
+		Hash string `json:"hash"`
+	} `json:"block_id"`
+	Block struct {
+		Header struct {
+			Height      string `json:"height"`
+			LastBlockID struct {
+				Hash string `json:"hash"`
+			} `json:"last_block_id"`
+		} `json:"header"`
+	} `json:"block"`
+}
+
+// PendingBlock - handled block with the transactions waiting for confirmations
+type PendingBlock struct {
+	Height     int64                    `json:"height"`
+	Hash       string                   `json:"hash"`
+	ParentHash string                   `json:"parent_hash"`
+	Txs        []map[string]interface{} `json:"txs"`
+	Notified   bool                     `json:"notified"` // transactions were sent to the bridge
+}
@@ -8,6 +8,7 @@ import (
 
 type Notifier interface {
 	SendTx(data interface{}) error
+	RetractTx(data interface{}) error
 }
 
 type notifier struct {
@@ -44,8 +45,17 @@ func NewNotifier(senderID, email, password, token, address string) Notifier {
 }
 
 func (n *notifier) SendTx(tx interface{}) error {
+	return n.send("notify", tx)
+}
+
+// RetractTx - notify the bridge that transaction was in the orphaned block
+func (n *notifier) RetractTx(tx interface{}) error {
+	return n.send("retract", tx)
+}
+
+func (n *notifier) send(method string, tx interface{}) error {
 	req := notificationRequest{
-		Method: "notify",
+		Method: method,
 		Data: notificationData{
 			From: n.senderID,
 			Tx:   tx,
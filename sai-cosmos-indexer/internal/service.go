@@ -3,7 +3,7 @@ package internal
 import (
 	"bytes"
 	"errors"
-	"github.com/saiset-co/saiCosmosIndexer/utils"
+	"fmt"
 	"net/http"
 	"os"
 	"strconv"
@@ -11,18 +11,22 @@ import (
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
-	"github.com/saiset-co/sai-storage-mongo/external/adapter"
-
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/saiset-co/sai-service-crud-plus/logger"
+	"github.com/saiset-co/sai-storage-mongo/external/adapter"
 	"github.com/saiset-co/saiCosmosIndexer/internal/model"
+	"github.com/saiset-co/saiCosmosIndexer/metrics"
+	"github.com/saiset-co/saiCosmosIndexer/utils"
 	"github.com/saiset-co/saiService"
 	"github.com/spf13/cast"
 	"go.uber.org/zap"
 )
 
 const (
-	filePathAddresses   = "./addresses.json"
-	filePathLatestBlock = "./latest_handled_block"
+	filePathAddresses     = "./addresses.json"
+	filePathLatestBlock   = "./latest_handled_block"
+	filePathPendingBlocks = "./pending_blocks.json"
+	filePathRetractions   = "./pending_retractions.json"
 )
 
 type InternalService struct {
@@ -34,6 +38,9 @@ type InternalService struct {
 	addresses     map[string]struct{}
 	storageConfig model.StorageConfig
 	notifier      Notifier
+	confirmations int64
+	pendingBlocks []model.PendingBlock
+	retractions   []map[string]interface{} // transactions of the orphaned blocks which were not retracted yet
 }
 
 func (is *InternalService) Init() {
@@ -62,7 +69,21 @@ func (is *InternalService) Init() {
 		cast.ToString(is.Context.GetConfig("notifier.url", "")),
 	)
 
-	err := is.loadAddresses()
+	is.confirmations = cast.ToInt64(is.Context.GetConfig("confirmations", 0))
+	is.pendingBlocks = make([]model.PendingBlock, 0)
+
+	err := is.loadPendingBlocks()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Logger.Fatal("loadPendingBlocks", zap.Error(err))
+	}
+
+	err = is.loadRetractions()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Logger.Fatal("loadRetractions", zap.Error(err))
+	}
+	metrics.PendingRetractions.Set(float64(len(is.retractions)))
+
+	err = is.loadAddresses()
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		logger.Logger.Error("loadAddresses", zap.Error(err))
 	}
@@ -85,6 +106,8 @@ func (is *InternalService) Init() {
 	if is.currentBlock < startBlock {
 		is.currentBlock = startBlock
 	}
+
+	http.Handle("/metrics", promhttp.Handler())
 }
 
 func (is *InternalService) Process() {
@@ -108,30 +131,52 @@ func (is *InternalService) Process() {
 				continue
 			}
 
+			metrics.SetHead(latestBlockHeight, is.currentBlock)
+
 			if is.currentBlock >= latestBlockHeight {
 				time.Sleep(time.Second * sleepDuration)
 				continue
 			}
 
-			err = is.handleBlockTxs()
+			err = is.handleBlockTxs(latestBlockHeight)
 			if err != nil {
 				logger.Logger.Error("handleBlockTxs", zap.Error(err))
 				time.Sleep(time.Second * sleepDuration)
 				continue
 			}
 
+			metrics.BlockProcessed(is.currentBlock)
 			is.currentBlock += 1
 		}
 	}
 }
 
-func (is *InternalService) handleBlockTxs() error {
+func (is *InternalService) handleBlockTxs(head int64) error {
+	block, err := is.getBlock(is.currentBlock)
+	if err != nil {
+		return err
+	}
+
+	if prev, ok := is.getPendingBlock(is.currentBlock - 1); ok && prev.Hash != block.Block.Header.LastBlockID.Hash {
+		logger.Logger.Warn("reorg detected", zap.Int64("block", is.currentBlock), zap.String("parent_hash", block.Block.Header.LastBlockID.Hash))
+
+		fork, err := is.handleReorg(is.currentBlock)
+		if err != nil {
+			return err
+		}
+
+		// loop continues from the block after the fork
+		is.currentBlock = fork
+
+		return is.rewriteLastHandledBlock(is.currentBlock)
+	}
+
 	blockTxs, err := is.getBlockTxs()
 	if err != nil {
 		return err
 	}
 
-	var txArray []interface{}
+	var txArray []map[string]interface{}
 	for _, txRes := range blockTxs {
 		if is.config.SkipFailedTxs && txRes.Code != 0 {
 			continue
@@ -164,8 +209,6 @@ func (is *InternalService) handleBlockTxs() error {
 		}
 
 		txArray = append(txArray, txTmp)
-
-		go is.sendTxNotification(txTmp)
 	}
 
 	//err = is.sendTxsToStorage(txArray)
@@ -173,11 +216,88 @@ func (is *InternalService) handleBlockTxs() error {
 	//	return err
 	//}
 
+	err = is.addPendingBlock(model.PendingBlock{
+		Height:     is.currentBlock,
+		Hash:       block.BlockID.Hash,
+		ParentHash: block.Block.Header.LastBlockID.Hash,
+		Txs:        txArray,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = is.releaseConfirmed(head)
+	if err != nil {
+		logger.Logger.Error("releaseConfirmed", zap.Error(err))
+	}
+
 	err = is.rewriteLastHandledBlock(is.currentBlock)
 
 	return err
 }
 
+// find the last stored block which is still in the canonical chain, roll back blocks above it
+// and retract transactions of the orphaned blocks which were already sent to the bridge
+func (is *InternalService) handleReorg(height int64) (int64, error) {
+	fork := height - 1
+	found := false
+
+	for i := len(is.pendingBlocks) - 1; i >= 0; i-- {
+		stored := is.pendingBlocks[i]
+		if stored.Height >= height {
+			continue
+		}
+
+		canonical, err := is.getBlock(stored.Height)
+		if err != nil {
+			return 0, fmt.Errorf("getBlock : %w", err)
+		}
+
+		if canonical.BlockID.Hash == stored.Hash {
+			found = true
+			break
+		}
+
+		fork = stored.Height - 1
+	}
+
+	if !found {
+		logger.Logger.Error("reorg is deeper than stored block history", zap.Int64("fork", fork))
+	}
+
+	// retractions are saved before the orphaned blocks are dropped, failed ones are retried before the next notifications
+	var txs []map[string]interface{}
+	for _, blk := range is.pendingBlocks[is.countPendingBlocks(fork):] {
+		if blk.Notified {
+			txs = append(txs, blk.Txs...)
+		}
+	}
+
+	err := is.addRetractions(txs)
+	if err != nil {
+		return 0, fmt.Errorf("addRetractions : %w", err)
+	}
+
+	orphaned, err := is.rollbackPendingBlocks(fork)
+	metrics.Reorg(len(orphaned))
+	metrics.PendingEvents.Set(float64(is.countPendingTxs()))
+
+	for _, blk := range orphaned {
+		logger.Logger.Warn("orphaned block", zap.Int64("height", blk.Height), zap.String("hash", blk.Hash), zap.Bool("notified", blk.Notified))
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("rollbackPendingBlocks : %w", err)
+	}
+
+	err = is.sendRetractions()
+	if err != nil {
+		logger.Logger.Error("sendRetractions", zap.Error(err))
+	}
+
+	return fork, nil
+}
+
 func (is *InternalService) sendTxsToStorage(txs []interface{}) error {
 	storageRequest := adapter.Request{
 		Method: "create",
@@ -196,10 +316,3 @@ func (is *InternalService) sendTxsToStorage(txs []interface{}) error {
 
 	return err
 }
-
-func (is *InternalService) sendTxNotification(tx interface{}) {
-	err := is.notifier.SendTx(tx)
-	if err != nil {
-		logger.Logger.Error("is.notifier.SendTx", zap.Error(err))
-	}
-}
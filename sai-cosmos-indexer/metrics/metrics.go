@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "cosmos_indexer"
+
+var (
+	// HeadBlock is the latest block height reported by the node
+	HeadBlock = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "head_block",
+		Help:      "Latest block height reported by the node.",
+	})
+
+	// LastProcessedBlock is the last block handled by the indexer
+	LastProcessedBlock = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_processed_block",
+		Help:      "Last block processed by the indexer.",
+	})
+
+	// HeadLag is the distance in blocks between the node head and the indexer
+	HeadLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "head_lag_blocks",
+		Help:      "Blocks between the node head and the last processed block.",
+	})
+
+	// BlocksProcessed counts processed blocks
+	BlocksProcessed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blocks_processed_total",
+		Help:      "Blocks processed by the indexer.",
+	})
+
+	// NotificationsSent counts notifications sent to the bridge by result
+	NotificationsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_total",
+		Help:      "Transaction notifications sent by result.",
+	}, []string{"result"})
+
+	// RetractionsSent counts retractions of orphaned transactions sent to the bridge by result
+	RetractionsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retractions_total",
+		Help:      "Orphaned transaction retractions sent by result.",
+	}, []string{"result"})
+
+	// PendingEvents is the number of events waiting for confirmations
+	PendingEvents = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pending_events",
+		Help:      "Events waiting for confirmations.",
+	})
+
+	// PendingRetractions is the number of retractions waiting to be sent to the bridge
+	PendingRetractions = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pending_retractions",
+		Help:      "Orphaned transaction retractions waiting to be sent.",
+	})
+
+	// ReorgsDetected counts detected chain reorganizations
+	ReorgsDetected = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reorgs_total",
+		Help:      "Chain reorganizations detected by the indexer.",
+	})
+
+	// OrphanedBlocks counts blocks removed by reorganizations
+	OrphanedBlocks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orphaned_blocks_total",
+		Help:      "Blocks removed by chain reorganizations.",
+	})
+)
+
+// SetHead updates head and lag gauges
+func SetHead(head, current int64) {
+	HeadBlock.Set(float64(head))
+	HeadLag.Set(float64(head - current))
+}
+
+// BlockProcessed updates processed block counter
+func BlockProcessed(id int64) {
+	BlocksProcessed.Inc()
+	LastProcessedBlock.Set(float64(id))
+}
+
+// NotificationResult records the outcome of a notification
+func NotificationResult(err error) {
+	if err != nil {
+		NotificationsSent.WithLabelValues("failed").Inc()
+		return
+	}
+	NotificationsSent.WithLabelValues("sent").Inc()
+}
+
+// RetractionResult records the outcome of a retraction
+func RetractionResult(err error) {
+	if err != nil {
+		RetractionsSent.WithLabelValues("failed").Inc()
+		return
+	}
+	RetractionsSent.WithLabelValues("sent").Inc()
+}
+
+// Reorg records detected reorganization with the number of orphaned blocks
+func Reorg(orphaned int) {
+	ReorgsDetected.Inc()
+	OrphanedBlocks.Add(float64(orphaned))
+}
@@ -7,6 +7,7 @@ require (
 	github.com/cosmos/cosmos-sdk v0.47.7
 	github.com/google/uuid v1.3.0
 	github.com/json-iterator/go v1.1.12
+	github.com/prometheus/client_golang v1.14.0
 	github.com/saiset-co/sai-service-crud-plus v0.0.0-20240126102508-5bab343d26fe
 	github.com/saiset-co/saiService v1.0.1
 	github.com/spf13/cast v1.5.0
@@ -92,7 +93,6 @@ require (
 	github.com/petermattis/goid v0.0.0-20230317030725-371a4b8eda08 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/prometheus/client_golang v1.14.0 // indirect
 	github.com/prometheus/client_model v0.3.0 // indirect
 	github.com/prometheus/common v0.42.0 // indirect
 	github.com/prometheus/procfs v0.9.0 // indirect
@@ -7,6 +7,7 @@ import (
 	"os"
 
 	"github.com/saiset-co/saiCosmosInteraction/internal/model"
+	"github.com/saiset-co/saiCosmosInteraction/metrics"
 	"github.com/saiset-co/saiCosmosInteraction/utils"
 	"github.com/saiset-co/saiService"
 )
@@ -41,53 +42,35 @@ func (is *InternalService) makeTx(data, meta interface{}) (interface{}, int, err
 		return "", http.StatusBadRequest, err
 	}
 
-	fileBytes, err := os.ReadFile(body.Sender)
-	if err != nil {
-		return "", http.StatusInternalServerError, fmt.Errorf("don't have private key for %s", body.From)
-	}
-
-	txMaker, err := NewTransactionMaker(
-		body.Sender,
-		body.NodeAddress,
-		body.ChainID,
-		body.From,
-		body.To,
-		body.Signature,
-		fileBytes,
-	)
-
+	txHash, err := is.submitTx(body)
+	metrics.TxResult(body.ChainID, err)
 	if err != nil {
 		return "", http.StatusInternalServerError, err
 	}
 
-	err = txMaker.BuildTx(uint64(body.GasLimit), body.Amount, body.FeeAmount, body.Memo)
-	if err != nil {
-		return "", http.StatusInternalServerError, err
-	}
+	return txHash, http.StatusOK, nil
+}
 
-	err = txMaker.SignTx()
+func (is *InternalService) makeTxSigned(data, meta interface{}) (interface{}, int, error) {
+	body, err := is.validateBody(data)
 	if err != nil {
-		return "", http.StatusInternalServerError, err
+		return "", http.StatusBadRequest, err
 	}
 
-	txHash, err := txMaker.BroadcastTx()
+	txHash, err := is.submitTx(body)
+	metrics.TxResult(body.ChainID, err)
 	if err != nil {
-		//logger.Logger.Error(body.From, zap.Error(err))
 		return "", http.StatusInternalServerError, err
 	}
 
 	return txHash, http.StatusOK, nil
 }
 
-func (is *InternalService) makeTxSigned(data, meta interface{}) (interface{}, int, error) {
-	body, err := is.validateBody(data)
-	if err != nil {
-		return "", http.StatusBadRequest, err
-	}
-
+// build, sign and broadcast transaction described by body
+func (is *InternalService) submitTx(body model.MakeTxRequestBody) (string, error) {
 	fileBytes, err := os.ReadFile(body.Sender)
 	if err != nil {
-		return "", http.StatusInternalServerError, fmt.Errorf("don't have private key for %s", body.From)
+		return "", fmt.Errorf("don't have private key for %s", body.From)
 	}
 
 	txMaker, err := NewTransactionMaker(
@@ -101,26 +84,20 @@ func (is *InternalService) makeTxSigned(data, meta interface{}) (interface{}, in
 	)
 
 	if err != nil {
-		return "", http.StatusInternalServerError, err
+		return "", err
 	}
 
 	err = txMaker.BuildTx(uint64(body.GasLimit), body.Amount, body.FeeAmount, body.Memo)
 	if err != nil {
-		return "", http.StatusInternalServerError, err
+		return "", err
 	}
 
 	err = txMaker.SignTx()
 	if err != nil {
-		return "", http.StatusInternalServerError, err
+		return "", err
 	}
 
-	txHash, err := txMaker.BroadcastTx()
-	if err != nil {
-		//logger.Logger.Error(body.From, zap.Error(err))
-		return "", http.StatusInternalServerError, err
-	}
-
-	return txHash, http.StatusOK, nil
+	return txMaker.BroadcastTx()
 }
 
 func (is *InternalService) validateBody(data interface{}) (model.MakeTxRequestBody, error) {
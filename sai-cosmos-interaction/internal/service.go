@@ -1,6 +1,9 @@
 package internal
 
 import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/saiset-co/saiService"
 )
 
@@ -9,5 +12,5 @@ type InternalService struct {
 }
 
 func (is *InternalService) Init() {
-
+	http.Handle("/metrics", promhttp.Handler())
 }
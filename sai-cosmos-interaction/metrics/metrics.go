@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "cosmos_interaction"
+
+var (
+	// TxSubmitted counts transactions sent to the node per chain id
+	TxSubmitted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tx_submitted_total",
+		Help:      "Transactions submitted per chain.",
+	}, []string{"chain"})
+
+	// TxFailed counts transactions which could not be submitted per chain id
+	TxFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tx_failed_total",
+		Help:      "Transactions failed per chain.",
+	}, []string{"chain"})
+)
+
+// TxResult records the outcome of a transaction submission to chain
+func TxResult(chain string, err error) {
+	if err != nil {
+		TxFailed.WithLabelValues(chain).Inc()
+		return
+	}
+	TxSubmitted.WithLabelValues(chain).Inc()
+}
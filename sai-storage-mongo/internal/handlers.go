@@ -10,11 +10,12 @@ import (
 	"github.com/saiset-co/sai-service/service"
 	"github.com/saiset-co/sai-storage-mongo/internal/actions"
 	"github.com/saiset-co/sai-storage-mongo/logger"
+	"github.com/saiset-co/sai-storage-mongo/metrics"
 	"github.com/saiset-co/sai-storage-mongo/types"
 )
 
 func (is InternalService) NewHandler() service.Handler {
-	return service.Handler{
+	handler := service.Handler{
 		"create": service.HandlerElement{
 			Name:        "Create documents",
 			Description: "Create documents",
@@ -124,6 +125,14 @@ func (is InternalService) NewHandler() service.Handler {
 			},
 		},
 	}
+
+	// measure latency of every method
+	for method, element := range handler {
+		element.Middlewares = append(element.Middlewares, metrics.Middleware(method))
+		handler[method] = element
+	}
+
+	return handler
 }
 
 func (is InternalService) convertRequest(data interface{}, requestType string) (types.IRequest, error) {
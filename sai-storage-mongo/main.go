@@ -3,7 +3,9 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/saiset-co/sai-service/service"
 	"github.com/saiset-co/sai-storage-mongo/internal"
 	"github.com/saiset-co/sai-storage-mongo/logger"
@@ -44,6 +46,8 @@ func main() {
 		is.NewHandler(),
 	)
 
+	http.Handle("/metrics", promhttp.Handler())
+
 	svc.Start()
 }
 
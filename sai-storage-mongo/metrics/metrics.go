@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/saiset-co/sai-service/service"
+)
+
+const namespace = "sai_storage"
+
+// RequestDuration measures handler latency per method and status code
+var RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "request_duration_seconds",
+	Help:      "Storage request latency per method.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"method", "status"})
+
+// Middleware measures latency of the handler registered as method
+func Middleware(method string) service.Middleware {
+	return func(next service.HandlerFunc, data interface{}, metadata interface{}) (interface{}, int, error) {
+		start := time.Now()
+		result, status, err := next(data, metadata)
+		RequestDuration.WithLabelValues(method, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+		return result, status, err
+	}
+}
@@ -145,7 +145,7 @@ func (c Client) Find(collectionName string, selector map[string]interface{}, inp
 	if includeFields != nil {
 		projection := bson.D{}
 		for _, v := range includeFields {
-			projection = append(projection, bson.E{v, 1})
+			projection = append(projection, bson.E{Key: v, Value: 1})
 		}
 		requestOptions.SetProjection(projection)
 	}
@@ -5,20 +5,22 @@ import (
 	"crypto/ecdsa"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math/big"
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/saiset-co/sai-eth-interaction/metrics"
 	"github.com/saiset-co/sai-eth-interaction/models"
 	"go.uber.org/zap"
 )
 
 var mux sync.Mutex
-var nonceList = map[string]map[uint64]bool{}
 
 type resTX struct {
 	Transaction *types.Transaction
@@ -38,102 +40,196 @@ func response(tx *types.Transaction, res string) (resTX, error) {
 	return result, err
 }
 
-func (is *InternalService) RawTransaction(client *ethclient.Client, value *big.Int, data []byte, contract *models.Contract) (string, error) {
+func (is *InternalService) RawTransaction(client *ethclient.Client, value *big.Int, data []byte, contract *models.Contract, reference string) (hash string, err error) {
+	chain := "unknown"
+	defer func() {
+		metrics.TxResult(chain, err)
+	}()
+
 	d := time.Now().Add(20 * time.Second)
 	ctx, cancel := context.WithDeadline(context.Background(), d)
 	defer cancel()
 
-	privateKey, err := crypto.HexToECDSA(contract.Private)
+	privateKey, fromAddress, err := contractKey(contract)
 	if err != nil {
-		is.Logger.Error("handlers - api - RawTransaction - HexToECDSA", zap.Error(err))
+		is.Logger.Error("handlers - api - RawTransaction - contractKey", zap.Error(err))
 		return "", err
 	}
 
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		is.Logger.Error("handlers - api - RawTransaction - cast publicKey to ecdsa", zap.Error(err))
+	toAddress := common.HexToAddress(contract.Address)
+
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		is.Logger.Error("handlers - api - RawTransaction - get chainID", zap.Error(err))
 		return "", err
 	}
-
-	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
-	s := fromAddress.String()
-	is.Logger.Debug("1", zap.String("", s))
-	nonce, err := client.PendingNonceAt(ctx, fromAddress)
+	chain = chainID.String()
+
+	gas, err := is.estimateGas(ctx, client, ethereum.CallMsg{
+		From:  fromAddress,
+		To:    &toAddress,
+		Value: value,
+		Data:  data,
+	}, contract)
 	if err != nil {
+		is.Logger.Error("handlers - api - RawTransaction - estimate gas", zap.Error(err))
 		return "", err
 	}
 
-	toAddress := common.HexToAddress(contract.Address)
+	// nonce is locked until transaction is sent
+	mux.Lock()
+	defer mux.Unlock()
 
-	gasPrice, err := client.SuggestGasPrice(ctx)
+	nonce, err := client.PendingNonceAt(ctx, fromAddress)
 	if err != nil {
-		is.Logger.Error("handlers - api - RawTransaction - get suggested gas price", zap.Error(err))
 		return "", err
 	}
 
-	is.Logger.Sugar().Debugf("GAS PRICE : %v", gasPrice)
-
-	tx := types.NewTx(&types.LegacyTx{
-		Nonce:    nonce,
-		To:       &toAddress,
-		Value:    value,
-		Gas:      contract.GasLimit,
-		GasPrice: gasPrice,
-		Data:     data,
-	})
-
-	chainID, err := client.NetworkID(ctx)
+	tx, err := is.buildTx(ctx, client, nonce, gas, toAddress, value, data)
 	if err != nil {
-		res, err := response(tx, err.Error())
-		is.Logger.Error("handlers - api - RawTransaction - get networkID", zap.Any("TX", res))
+		is.Logger.Error("handlers - api - RawTransaction - build tx", zap.Error(err))
 		return "", err
 	}
 
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privateKey)
+	is.Logger.Debug("handlers - api - RawTransaction - fees",
+		zap.Uint64("gas", tx.Gas()),
+		zap.String("max fee", tx.GasFeeCap().String()),
+		zap.String("max priority fee", tx.GasTipCap().String()))
+
+	signedTx, err := types.SignTx(tx, types.LatestSignerForChainID(chainID), privateKey)
 	if err != nil {
 		res, err := response(tx, err.Error())
 		is.Logger.Error("handlers - api - RawTransaction - signTx", zap.Any("TX", res))
 		return "", err
 	}
 
-	hash := signedTx.Hash().String()
+	pending := &PendingTx{
+		Reference: reference,
+		Contract:  contract.Name,
+		From:      fromAddress,
+		Nonce:     nonce,
+		Hashes:    []common.Hash{signedTx.Hash()},
+		Tx:        signedTx,
+		SentAt:    time.Now(),
+	}
+
+	// transaction is not sent if it can't be saved
+	err = is.Pending.Add(pending)
+	if err != nil {
+		is.Logger.Error("handlers - api - RawTransaction - save pending transaction", zap.Error(err))
+		return "", err
+	}
 
-	mux.Lock()
 	err = client.SendTransaction(ctx, signedTx)
 	if err != nil {
 		res, err := response(tx, err.Error())
-		mux.Unlock()
 		is.Logger.Error("handlers - api - RawTransaction - sendTx", zap.Any("TX", res))
+
+		if removeErr := is.Pending.Remove(pending); removeErr != nil {
+			is.Logger.Error("handlers - api - RawTransaction - remove pending transaction", zap.Error(removeErr))
+		}
 		return "", err
 	}
 
-	res, _ := response(tx, hash)
-	//resS, _ := json.Marshal(res)
+	hash = signedTx.Hash().String()
+	is.Logger.Debug("handlers - api - RawTransaction - sendTx - tx sent", zap.String("hash", hash), zap.Uint64("nonce", nonce))
 
-	for {
-		resTx, _, err := client.TransactionByHash(ctx, signedTx.Hash())
-		if err != nil {
-			res, err := response(tx, err.Error())
-			is.Logger.Error("handlers - api - RawTransaction - sendTx", zap.Any("TX", res))
-			mux.Unlock()
-			return "", err
-		}
+	return hash, nil
+}
 
-		if resTx == nil {
-			is.Logger.Debug("handlers - api - RawTransaction - sendTx - tx was not created", zap.Any("TX", res))
-			mux.Unlock()
-			goto done
-		} else if resTx != nil {
-			is.Logger.Debug("handlers - api - RawTransaction - sendTx - tx done", zap.Any("TX", res))
-			mux.Unlock()
-			goto done
-		}
+// SpeedUp - replace pending transaction by the same nonce with bumped fees
+func (is *InternalService) SpeedUp(client *ethclient.Client, pending *PendingTx, contract *models.Contract) (string, error) {
+	unlock := is.Pending.LockNonce(pending.From, pending.Nonce)
+	defer unlock()
+
+	return is.speedUp(client, pending, contract)
+}
 
-		is.Logger.Debug("handlers - api - RawTransaction - sendTx - pending, sleep 2 sec")
-		time.Sleep(2 * time.Second)
+// replace the latest sent transaction, nonce of the transaction must be locked
+func (is *InternalService) speedUp(client *ethclient.Client, pending *PendingTx, contract *models.Contract) (string, error) {
+	current, ok := is.Pending.Snapshot(pending.From, pending.Nonce)
+	if !ok {
+		return "", ErrNotPending
 	}
 
-done:
-	return hash, nil
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	privateKey, _, err := contractKey(contract)
+	if err != nil {
+		return "", fmt.Errorf("contractKey : %w", err)
+	}
+
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("ChainID : %w", err)
+	}
+
+	tx, err := is.bumpTx(current.Tx)
+	if err != nil {
+		return "", fmt.Errorf("bumpTx : %w", err)
+	}
+
+	signedTx, err := types.SignTx(tx, types.LatestSignerForChainID(chainID), privateKey)
+	if err != nil {
+		return "", fmt.Errorf("SignTx : %w", err)
+	}
+
+	err = is.Pending.Track(pending, signedTx.Hash())
+	if err != nil {
+		return "", fmt.Errorf("Track : %w", err)
+	}
+
+	err = client.SendTransaction(ctx, signedTx)
+	metrics.TxResult(chainID.String(), err)
+	if err != nil {
+		return "", fmt.Errorf("SendTransaction : %w", err)
+	}
+
+	err = is.Pending.Replace(pending, signedTx)
+	if err != nil {
+		is.Logger.Error("handlers - speed_up - save pending transaction", zap.String("hash", signedTx.Hash().String()), zap.Error(err))
+	}
+
+	is.Logger.Info("handlers - speed_up - transaction replaced",
+		zap.Uint64("nonce", pending.Nonce),
+		zap.String("hash", signedTx.Hash().String()),
+		zap.String("max fee", signedTx.GasFeeCap().String()),
+		zap.String("max priority fee", signedTx.GasTipCap().String()))
+
+	return signedTx.Hash().String(), nil
+}
+
+// get cached client for eth server
+func (is *InternalService) GetClient(url string) (*ethclient.Client, error) {
+	is.clientsMu.Lock()
+	defer is.clientsMu.Unlock()
+
+	if client, ok := is.clients[url]; ok {
+		return client, nil
+	}
+
+	client, err := ethclient.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+
+	is.clients[url] = client
+
+	return client, nil
+}
+
+// private key and address of the contract signer
+func contractKey(contract *models.Contract) (*ecdsa.PrivateKey, common.Address, error) {
+	privateKey, err := crypto.HexToECDSA(contract.Private)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+
+	publicKeyECDSA, ok := privateKey.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return nil, common.Address{}, errors.New("cast publicKey to ecdsa")
+	}
+
+	return privateKey, crypto.PubkeyToAddress(*publicKeyECDSA), nil
 }
@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/saiset-co/sai-eth-interaction/models"
+	"github.com/spf13/cast"
+	"go.uber.org/zap"
+)
+
+// minimal price bump accepted by nodes for the replacement transactions
+const minBumpPercent = 10
+
+// FeeConfig - fee settings for the outgoing transactions
+type FeeConfig struct {
+	MaxFeePerGas         *big.Int // cap for maxFeePerGas (gasPrice for legacy chains), wei
+	MaxPriorityFeePerGas *big.Int // cap for maxPriorityFeePerGas, wei
+	GasMultiplier        float64  // estimated gas multiplier
+	BumpPercent          int64    // fee bump for the replacement transactions
+}
+
+// read fee settings from config, caps are set in gwei
+func (is *InternalService) loadFeeConfig() FeeConfig {
+	feeConfig := FeeConfig{
+		MaxFeePerGas:         gweiToWei(cast.ToFloat64(is.Context.GetConfig("fees.max_fee_per_gas", 0))),
+		MaxPriorityFeePerGas: gweiToWei(cast.ToFloat64(is.Context.GetConfig("fees.max_priority_fee_per_gas", 0))),
+		GasMultiplier:        cast.ToFloat64(is.Context.GetConfig("fees.gas_multiplier", 1.2)),
+		BumpPercent:          cast.ToInt64(is.Context.GetConfig("fees.bump_percent", 15)),
+	}
+
+	if feeConfig.GasMultiplier < 1 {
+		feeConfig.GasMultiplier = 1
+	}
+
+	if feeConfig.BumpPercent < minBumpPercent {
+		feeConfig.BumpPercent = minBumpPercent
+	}
+
+	return feeConfig
+}
+
+// estimate gas for the call, estimated value is multiplied and capped by contract gas limit
+// contract gas limit is used if node can't estimate the call
+func (is *InternalService) estimateGas(ctx context.Context, client *ethclient.Client, msg ethereum.CallMsg, contract *models.Contract) (uint64, error) {
+	estimated, err := client.EstimateGas(ctx, msg)
+	if err != nil {
+		if contract.GasLimit == 0 {
+			return 0, fmt.Errorf("EstimateGas : %w", err)
+		}
+		is.Logger.Warn("handlers - api - estimateGas - use contract gas limit", zap.String("contract", contract.Name), zap.Error(err))
+		return contract.GasLimit, nil
+	}
+
+	gas := uint64(float64(estimated) * is.Fees.GasMultiplier)
+	if contract.GasLimit != 0 && gas > contract.GasLimit {
+		if estimated > contract.GasLimit {
+			return 0, fmt.Errorf("estimated gas %d exceeds contract gas limit %d", estimated, contract.GasLimit)
+		}
+		gas = contract.GasLimit
+	}
+
+	return gas, nil
+}
+
+// build unsigned transaction with fees suggested by the node and limited by the configured caps
+// legacy transaction is built if chain has no base fee
+func (is *InternalService) buildTx(ctx context.Context, client *ethclient.Client, nonce, gas uint64, to common.Address, value *big.Int, data []byte) (*types.Transaction, error) {
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("HeaderByNumber : %w", err)
+	}
+
+	if head.BaseFee == nil {
+		gasPrice, err := client.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("SuggestGasPrice : %w", err)
+		}
+
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    nonce,
+			To:       &to,
+			Value:    value,
+			Gas:      gas,
+			GasPrice: capValue(gasPrice, is.Fees.MaxFeePerGas),
+			Data:     data,
+		}), nil
+	}
+
+	tip, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("SuggestGasTipCap : %w", err)
+	}
+	tip = capValue(tip, is.Fees.MaxPriorityFeePerGas)
+
+	// leave room for two full blocks of base fee growth
+	feeCap := new(big.Int).Add(new(big.Int).Mul(head.BaseFee, big.NewInt(2)), tip)
+	feeCap = capValue(feeCap, is.Fees.MaxFeePerGas)
+
+	if feeCap.Cmp(head.BaseFee) < 0 {
+		return nil, fmt.Errorf("max fee per gas cap %s is lower than current base fee %s", feeCap, head.BaseFee)
+	}
+
+	if tip.Cmp(feeCap) > 0 {
+		tip = new(big.Int).Set(feeCap)
+	}
+
+	return types.NewTx(&types.DynamicFeeTx{
+		Nonce:     nonce,
+		To:        &to,
+		Value:     value,
+		Gas:       gas,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Data:      data,
+	}), nil
+}
+
+// copy of the transaction with the same nonce and bumped fees to replace stuck one
+func (is *InternalService) bumpTx(tx *types.Transaction) (*types.Transaction, error) {
+	switch tx.Type() {
+	case types.LegacyTxType:
+		gasPrice, err := is.bumpValue(tx.GasPrice(), is.Fees.MaxFeePerGas)
+		if err != nil {
+			return nil, fmt.Errorf("gas price : %w", err)
+		}
+
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			To:       tx.To(),
+			Value:    tx.Value(),
+			Gas:      tx.Gas(),
+			GasPrice: gasPrice,
+			Data:     tx.Data(),
+		}), nil
+	case types.DynamicFeeTxType:
+		tip, err := is.bumpValue(tx.GasTipCap(), is.Fees.MaxPriorityFeePerGas)
+		if err != nil {
+			return nil, fmt.Errorf("max priority fee : %w", err)
+		}
+
+		feeCap, err := is.bumpValue(tx.GasFeeCap(), is.Fees.MaxFeePerGas)
+		if err != nil {
+			return nil, fmt.Errorf("max fee : %w", err)
+		}
+
+		return types.NewTx(&types.DynamicFeeTx{
+			Nonce:     tx.Nonce(),
+			To:        tx.To(),
+			Value:     tx.Value(),
+			Gas:       tx.Gas(),
+			GasTipCap: tip,
+			GasFeeCap: feeCap,
+			Data:      tx.Data(),
+		}), nil
+	}
+
+	return nil, fmt.Errorf("unsupported transaction type %d", tx.Type())
+}
+
+// increase value by bump percent, error if cap does not allow the minimal bump
+func (is *InternalService) bumpValue(value, limit *big.Int) (*big.Int, error) {
+	bumped := new(big.Int).Mul(value, big.NewInt(100+is.Fees.BumpPercent))
+	bumped.Div(bumped, big.NewInt(100))
+
+	// zero values can't be bumped by percent
+	if bumped.Cmp(value) <= 0 {
+		bumped = new(big.Int).Add(value, big.NewInt(params.GWei))
+	}
+
+	bumped = capValue(bumped, limit)
+
+	minimal := new(big.Int).Mul(value, big.NewInt(100+minBumpPercent))
+	minimal.Div(minimal, big.NewInt(100))
+	if bumped.Cmp(minimal) < 0 || bumped.Cmp(value) <= 0 {
+		return nil, errors.New("fee cap reached, can't bump transaction")
+	}
+
+	return bumped, nil
+}
+
+// limit value by cap, zero or nil cap means no limit
+func capValue(value, limit *big.Int) *big.Int {
+	if limit == nil || limit.Sign() == 0 || value.Cmp(limit) <= 0 {
+		return value
+	}
+	return new(big.Int).Set(limit)
+}
+
+func gweiToWei(gwei float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(gwei), big.NewFloat(params.GWei)).Int(nil)
+	return wei
+}
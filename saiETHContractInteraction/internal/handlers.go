@@ -13,7 +13,6 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/saiset-co/sai-eth-interaction/models"
 	saiService "github.com/saiset-co/sai-service/service"
 	"go.uber.org/zap"
@@ -69,12 +68,7 @@ func (is *InternalService) NewHandler() saiService.Handler {
 					return nil, 500, err
 				}
 
-				ethURL := contract.Server
-				if !ok {
-					Service.Logger.Sugar().Fatalf("wrong type of eth_server value in config, type : %+v", reflect.TypeOf(Service.Context.GetConfig("specific.eth_server", "")))
-				}
-
-				ethClient, err := ethclient.Dial(ethURL)
+				ethClient, err := Service.GetClient(contract.Server)
 				if err != nil {
 					Service.Logger.Error("handlers - api - dial eth server", zap.Error(err))
 					return nil, 500, err
@@ -203,7 +197,7 @@ func (is *InternalService) NewHandler() saiService.Handler {
 					}
 				}
 
-				res, err := Service.RawTransaction(ethClient, value, input, contract)
+				res, err := Service.RawTransaction(ethClient, value, input, contract, req.Reference)
 				if err != nil {
 					return nil, 500, err
 				}
@@ -215,6 +209,88 @@ func (is *InternalService) NewHandler() saiService.Handler {
 			},
 		},
 
+		"speed_up": saiService.HandlerElement{
+			Name:        "speed_up",
+			Description: "replace pending transaction by nonce with bumped fees",
+			Function: func(data interface{}, metadata interface{}) (interface{}, int, error) {
+				tokenIsValid, err := is.validateToken(metadata)
+				if err != nil {
+					return "", http.StatusInternalServerError, err
+				}
+
+				if !tokenIsValid {
+					return "", http.StatusInternalServerError, errors.New("token doe not valid")
+				}
+
+				b, err := json.Marshal(data)
+				if err != nil {
+					Service.Logger.Error("handlers - speed_up - marshal incoming data", zap.Error(err))
+					return nil, 400, err
+				}
+
+				req := models.SpeedUpRequest{}
+				err = json.Unmarshal(b, &req)
+				if err != nil {
+					Service.Logger.Error("handlers - speed_up - unmarshal data to struct", zap.Error(err))
+					return nil, 400, err
+				}
+
+				contract, err := Service.GetContractByName(req.Contract)
+				if err != nil {
+					Service.Logger.Error("handlers - speed_up - GetContractByName", zap.Error(err))
+					return nil, 500, err
+				}
+
+				_, from, err := contractKey(contract)
+				if err != nil {
+					Service.Logger.Error("handlers - speed_up - contractKey", zap.Error(err))
+					return nil, 500, err
+				}
+
+				pending, ok := Service.Pending.Get(from, req.Nonce)
+				if !ok {
+					return nil, 404, fmt.Errorf("pending transaction with nonce %d was not found", req.Nonce)
+				}
+
+				ethClient, err := Service.GetClient(contract.Server)
+				if err != nil {
+					Service.Logger.Error("handlers - speed_up - dial eth server", zap.Error(err))
+					return nil, 500, err
+				}
+
+				res, err := Service.SpeedUp(ethClient, pending, contract)
+				if errors.Is(err, ErrNotPending) {
+					return nil, 404, fmt.Errorf("pending transaction with nonce %d was not found", req.Nonce)
+				}
+				if err != nil {
+					Service.Logger.Error("handlers - speed_up - SpeedUp", zap.Error(err))
+					return nil, 500, err
+				}
+
+				return map[string]interface{}{
+					"Status": "OK",
+					"Result": res,
+				}, 200, nil
+			},
+		},
+
+		"pending": saiService.HandlerElement{
+			Name:        "pending",
+			Description: "list transactions waiting for receipts",
+			Function: func(data interface{}, metadata interface{}) (interface{}, int, error) {
+				tokenIsValid, err := is.validateToken(metadata)
+				if err != nil {
+					return "", http.StatusInternalServerError, err
+				}
+
+				if !tokenIsValid {
+					return "", http.StatusInternalServerError, errors.New("token doe not valid")
+				}
+
+				return Service.Pending.List(), 200, nil
+			},
+		},
+
 		"add": saiService.HandlerElement{
 			Name:        "add",
 			Description: "add contract to contracts",
@@ -2,10 +2,14 @@ package internal
 
 import (
 	"fmt"
+	"net/http"
 	"sync"
 
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/saiset-co/sai-eth-interaction/models"
 	saiService "github.com/saiset-co/sai-service/service"
+	"github.com/spf13/cast"
 	"go.uber.org/zap"
 )
 
@@ -15,6 +19,11 @@ type InternalService struct {
 	Mutex     *sync.RWMutex
 	Context   *saiService.Context
 	Logger    *zap.Logger
+	Fees      FeeConfig    // fee caps for outgoing transactions
+	Pending   *PendingPool // transactions waiting for receipts
+
+	clientsMu sync.Mutex
+	clients   map[string]*ethclient.Client // eth clients by server url
 }
 
 // global handler for registering handlers
@@ -22,11 +31,22 @@ var Service = &InternalService{
 	Handler:   saiService.Handler{},
 	Contracts: make([]models.Contract, 0),
 	Mutex:     new(sync.RWMutex),
+	Pending:   NewPendingPool(),
+	clients:   make(map[string]*ethclient.Client),
 }
 
 func (is *InternalService) Init() {
 	fmt.Println(is.Context)
+	Service.Context = is.Context
 	Service.Logger = is.Context.Context.Value("logger").(*zap.Logger)
+	Service.Fees = Service.loadFeeConfig()
+
+	err := Service.Pending.Load(cast.ToString(is.Context.GetConfig("watcher.pending_file", "pending.json")))
+	if err != nil {
+		Service.Logger.Fatal("main - init - load pending transactions", zap.Error(err))
+	}
 
 	Service.getInitialContracts("contracts.json")
+
+	http.Handle("/metrics", promhttp.Handler())
 }
@@ -0,0 +1,458 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/saiset-co/sai-eth-interaction/utils"
+	"github.com/spf13/cast"
+	"go.uber.org/zap"
+)
+
+// statuses reported to the bridge
+const (
+	TxStatusConfirmed = "confirmed"
+	TxStatusFailed    = "failed"
+	TxStatusDropped   = "dropped"
+)
+
+// PendingTx - transaction sent to the node and waiting for the receipt
+type PendingTx struct {
+	Reference string             `json:"reference"` // bridge transfer reference
+	Contract  string             `json:"contract"`  // contract name
+	From      common.Address     `json:"from"`
+	Nonce     uint64             `json:"nonce"`
+	Hashes    []common.Hash      `json:"hashes"` // original transaction and all replacements
+	Tx        *types.Transaction `json:"-"`      // latest sent transaction
+	SentAt    time.Time          `json:"sent_at"`
+	Bumps     int                `json:"bumps"`
+}
+
+// ErrNotPending - transaction is confirmed, dropped or unknown
+var ErrNotPending = errors.New("transaction is not pending")
+
+// PendingPool - pending transactions by sender and nonce
+type PendingPool struct {
+	sync.RWMutex
+	txs    map[string]*PendingTx
+	nonces map[string]*sync.Mutex // replacements of the transaction are serialized by sender and nonce
+	path   string                 // file of the pending transactions, pool is not saved if empty
+}
+
+// pending transaction in the file with the latest sent transaction
+type pendingRecord struct {
+	PendingTx
+	RawTx hexutil.Bytes `json:"raw_tx"`
+}
+
+// TxStatusReport - receipt status sent to the bridge
+type TxStatusReport struct {
+	Reference   string `json:"reference"`
+	Contract    string `json:"contract"`
+	Hash        string `json:"hash"`
+	Nonce       uint64 `json:"nonce"`
+	Status      string `json:"status"`
+	BlockNumber uint64 `json:"block_number"`
+	GasUsed     uint64 `json:"gas_used"`
+}
+
+type txStatusRequest struct {
+	Method   string            `json:"method"`
+	Data     TxStatusReport    `json:"data"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+func NewPendingPool() *PendingPool {
+	return &PendingPool{
+		txs:    make(map[string]*PendingTx),
+		nonces: make(map[string]*sync.Mutex),
+	}
+}
+
+func pendingKey(from common.Address, nonce uint64) string {
+	return fmt.Sprintf("%s:%d", from.Hex(), nonce)
+}
+
+// Add - watch the transaction, it is saved before it is sent, so it is not lost on restart
+func (p *PendingPool) Add(tx *PendingTx) error {
+	p.Lock()
+	defer p.Unlock()
+
+	p.txs[pendingKey(tx.From, tx.Nonce)] = tx
+	err := p.save()
+	if err != nil {
+		delete(p.txs, pendingKey(tx.From, tx.Nonce))
+	}
+	return err
+}
+
+func (p *PendingPool) Get(from common.Address, nonce uint64) (*PendingTx, bool) {
+	p.RLock()
+	defer p.RUnlock()
+	tx, ok := p.txs[pendingKey(from, nonce)]
+	return tx, ok
+}
+
+// Snapshot - copy of the pending transaction, latest sent transaction is read under the lock
+func (p *PendingPool) Snapshot(from common.Address, nonce uint64) (PendingTx, bool) {
+	p.RLock()
+	defer p.RUnlock()
+
+	tx, ok := p.txs[pendingKey(from, nonce)]
+	if !ok {
+		return PendingTx{}, false
+	}
+
+	txCopy := *tx
+	txCopy.Hashes = append([]common.Hash{}, tx.Hashes...)
+	return txCopy, true
+}
+
+func (p *PendingPool) Remove(tx *PendingTx) error {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.txs, pendingKey(tx.From, tx.Nonce))
+	delete(p.nonces, pendingKey(tx.From, tx.Nonce))
+	return p.save()
+}
+
+// LockNonce - lock replacements of the transaction by sender and nonce, returns unlock
+func (p *PendingPool) LockNonce(from common.Address, nonce uint64) func() {
+	p.Lock()
+	mu, ok := p.nonces[pendingKey(from, nonce)]
+	if !ok {
+		mu = &sync.Mutex{}
+		p.nonces[pendingKey(from, nonce)] = mu
+	}
+	p.Unlock()
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+// Track - save hash of the replacement before it is sent, so the mined replacement is found after restart.
+// Hash of the replacement which was not sent is never mined, the same replacement is built again on the next bump
+func (p *PendingPool) Track(tx *PendingTx, hash common.Hash) error {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, h := range tx.Hashes {
+		if h == hash {
+			return nil
+		}
+	}
+
+	tx.Hashes = append(tx.Hashes, hash)
+	err := p.save()
+	if err != nil {
+		tx.Hashes = tx.Hashes[:len(tx.Hashes)-1]
+	}
+	return err
+}
+
+// register replacement transaction for the pending one, its hash is tracked already
+func (p *PendingPool) Replace(tx *PendingTx, replacement *types.Transaction) error {
+	p.Lock()
+	defer p.Unlock()
+	tx.Tx = replacement
+	tx.SentAt = time.Now()
+	tx.Bumps++
+	return p.save()
+}
+
+// sorted copy of the pending transactions
+func (p *PendingPool) List() []PendingTx {
+	p.RLock()
+	defer p.RUnlock()
+
+	list := make([]PendingTx, 0, len(p.txs))
+	for _, tx := range p.txs {
+		txCopy := *tx
+		txCopy.Hashes = append([]common.Hash{}, tx.Hashes...)
+		list = append(list, txCopy)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].From != list[j].From {
+			return list[i].From.Hex() < list[j].From.Hex()
+		}
+		return list[i].Nonce < list[j].Nonce
+	})
+
+	return list
+}
+
+// Load - restore pending transactions from the file, pool is saved to the file on every change
+func (p *PendingPool) Load(path string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	p.path = path
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ReadFile : %w", err)
+	}
+
+	var records []pendingRecord
+	err = json.Unmarshal(b, &records)
+	if err != nil {
+		return fmt.Errorf("Unmarshal : %w", err)
+	}
+
+	for i := range records {
+		tx := records[i].PendingTx
+		tx.Tx = new(types.Transaction)
+		err = tx.Tx.UnmarshalBinary(records[i].RawTx)
+		if err != nil {
+			return fmt.Errorf("UnmarshalBinary %s : %w", pendingKey(tx.From, tx.Nonce), err)
+		}
+		p.txs[pendingKey(tx.From, tx.Nonce)] = &tx
+	}
+
+	return nil
+}
+
+// write pending transactions to temp file and rename it, pool must be locked
+func (p *PendingPool) save() error {
+	if p.path == "" {
+		return nil
+	}
+
+	records := make([]pendingRecord, 0, len(p.txs))
+	for _, tx := range p.txs {
+		raw, err := tx.Tx.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("MarshalBinary : %w", err)
+		}
+		records = append(records, pendingRecord{PendingTx: *tx, RawTx: raw})
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return pendingKey(records[i].From, records[i].Nonce) < pendingKey(records[j].From, records[j].Nonce)
+	})
+
+	b, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("MarshalIndent : %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p.path), filepath.Base(p.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("CreateTemp : %w", err)
+	}
+
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write : %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), p.path)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Rename : %w", err)
+	}
+
+	// rename is durable when the directory entry is flushed
+	dir, err := os.Open(filepath.Dir(p.path))
+	if err != nil {
+		return fmt.Errorf("open dir : %w", err)
+	}
+	err = dir.Sync()
+	if closeErr := dir.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("sync dir : %w", err)
+	}
+
+	return nil
+}
+
+// WatchReceipts - check pending transactions receipts, report final statuses to the bridge
+// and speed up transactions which are pending too long
+func (is *InternalService) WatchReceipts() {
+	interval := time.Duration(cast.ToInt(is.Context.GetConfig("watcher.interval", 5))) * time.Second
+
+	for {
+		select {
+		case <-is.Context.Context.Done():
+			return
+		case <-time.After(interval):
+			for _, tx := range is.Pending.List() {
+				err := is.checkPending(tx)
+				if err != nil {
+					is.Logger.Error("watcher - checkPending", zap.String("from", tx.From.Hex()), zap.Uint64("nonce", tx.Nonce), zap.Error(err))
+				}
+			}
+		}
+	}
+}
+
+func (is *InternalService) checkPending(tx PendingTx) error {
+	confirmations := cast.ToUint64(is.Context.GetConfig("watcher.confirmations", 1))
+	stuckAfter := time.Duration(cast.ToInt(is.Context.GetConfig("watcher.stuck_after", 120))) * time.Second
+	maxBumps := cast.ToInt(is.Context.GetConfig("watcher.max_bumps", 3))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	pending, ok := is.Pending.Get(tx.From, tx.Nonce)
+	if !ok {
+		return nil
+	}
+
+	contract, err := is.GetContractByName(tx.Contract)
+	if err != nil {
+		return err
+	}
+
+	client, err := is.GetClient(contract.Server)
+	if err != nil {
+		return fmt.Errorf("GetClient : %w", err)
+	}
+
+	// any of the attempts could be mined
+	for _, hash := range tx.Hashes {
+		receipt, err := client.TransactionReceipt(ctx, hash)
+		if err != nil {
+			if errors.Is(err, ethereum.NotFound) {
+				continue
+			}
+			return fmt.Errorf("TransactionReceipt : %w", err)
+		}
+
+		head, err := client.BlockNumber(ctx)
+		if err != nil {
+			return fmt.Errorf("BlockNumber : %w", err)
+		}
+
+		if head+1 < receipt.BlockNumber.Uint64()+confirmations {
+			return nil
+		}
+
+		status := TxStatusConfirmed
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			status = TxStatusFailed
+		}
+
+		err = is.reportTxStatus(TxStatusReport{
+			Reference:   tx.Reference,
+			Contract:    tx.Contract,
+			Hash:        hash.Hex(),
+			Nonce:       tx.Nonce,
+			Status:      status,
+			BlockNumber: receipt.BlockNumber.Uint64(),
+			GasUsed:     receipt.GasUsed,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = is.Pending.Remove(pending)
+		if err != nil {
+			return fmt.Errorf("Remove : %w", err)
+		}
+		return nil
+	}
+
+	// nonce was used by a transaction we don't know about
+	nonce, err := client.NonceAt(ctx, tx.From, nil)
+	if err != nil {
+		return fmt.Errorf("NonceAt : %w", err)
+	}
+
+	if nonce > tx.Nonce {
+		err = is.reportTxStatus(TxStatusReport{
+			Reference: tx.Reference,
+			Contract:  tx.Contract,
+			Hash:      tx.Hashes[len(tx.Hashes)-1].Hex(),
+			Nonce:     tx.Nonce,
+			Status:    TxStatusDropped,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = is.Pending.Remove(pending)
+		if err != nil {
+			return fmt.Errorf("Remove : %w", err)
+		}
+		return nil
+	}
+
+	if time.Since(tx.SentAt) < stuckAfter || tx.Bumps >= maxBumps {
+		return nil
+	}
+
+	unlock := is.Pending.LockNonce(tx.From, tx.Nonce)
+	defer unlock()
+
+	// transaction could be replaced by speed_up request meanwhile
+	current, ok := is.Pending.Snapshot(tx.From, tx.Nonce)
+	if !ok || time.Since(current.SentAt) < stuckAfter || current.Bumps >= maxBumps {
+		return nil
+	}
+
+	is.Logger.Info("watcher - transaction is stuck, speed up", zap.String("hash", current.Hashes[len(current.Hashes)-1].Hex()), zap.Uint64("nonce", tx.Nonce))
+
+	_, err = is.speedUp(client, pending, contract)
+	if err != nil {
+		return fmt.Errorf("SpeedUp : %w", err)
+	}
+
+	return nil
+}
+
+// send transaction status to the bridge
+func (is *InternalService) reportTxStatus(report TxStatusReport) error {
+	is.Logger.Info("watcher - transaction finished",
+		zap.String("reference", report.Reference),
+		zap.String("hash", report.Hash),
+		zap.String("status", report.Status))
+
+	url := cast.ToString(is.Context.GetConfig("bridge.url", ""))
+	if url == "" {
+		return nil
+	}
+
+	token := cast.ToString(is.Context.GetConfig("bridge.token", ""))
+
+	payload, err := json.Marshal(txStatusRequest{
+		Method:   "tx_status",
+		Data:     report,
+		Metadata: map[string]string{"token": token},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = utils.SaiQuerySender(bytes.NewReader(payload), url, token)
+	if err != nil {
+		return fmt.Errorf("SaiQuerySender : %w", err)
+	}
+
+	return nil
+}
@@ -14,6 +14,10 @@ func main() {
 
 	svc.RegisterInitTask(is.Init)
 
+	svc.RegisterTasks([]func(){
+		internal.Service.WatchReceipts,
+	})
+
 	svc.RegisterHandlers(
 		is.NewHandler())
 
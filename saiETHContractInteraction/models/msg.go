@@ -10,10 +10,16 @@ type Parameter struct {
 }
 
 type EthRequest struct {
-	Contract string       `json:"contract"`
-	Method   string       `json:"method"`
-	Value    string       `json:"value"`
-	Params   []*Parameter `json:"params"`
+	Contract  string       `json:"contract"`
+	Method    string       `json:"method"`
+	Value     string       `json:"value"`
+	Params    []*Parameter `json:"params"`
+	Reference string       `json:"reference"` // caller reference, returned with the receipt status
+}
+
+type SpeedUpRequest struct {
+	Contract string `json:"contract" valid:",required"`
+	Nonce    uint64 `json:"nonce"`
 }
 
 type Contract struct {
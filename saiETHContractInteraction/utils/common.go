@@ -1,6 +1,13 @@
 package utils
 
-import "github.com/saiset-co/sai-eth-interaction/models"
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/saiset-co/sai-eth-interaction/models"
+)
 
 func RemoveContract(slice []models.Contract, s int) []models.Contract {
 	return append(slice[:s], slice[s+1:]...)
@@ -15,3 +22,43 @@ func GetMaxKey(m map[uint64]bool) uint64 {
 	}
 	return maxKey
 }
+
+func SaiQuerySender(body io.Reader, address, token string) ([]byte, error) {
+	const failedResponseStatus = "NOK"
+
+	type responseWrapper struct {
+		Status string `json:"Status"`
+		Error  string `json:"Error"`
+	}
+
+	req, err := http.NewRequest(http.MethodPost, address, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Token", token)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	resBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resBytes)
+	}
+
+	result := responseWrapper{}
+	if err = json.Unmarshal(resBytes, &result); err == nil && result.Status == failedResponseStatus {
+		return nil, fmt.Errorf("%s", result.Error)
+	}
+
+	return resBytes, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/saiset-co/saiEthIndexer/config"
+	"github.com/saiset-co/saiEthIndexer/tasks"
+)
+
+// replay of the block range by the running indexer, its status is polled until the replay is finished
+func main() {
+	configPath := flag.String("config", "./config.json", "config of the indexer, url and token are taken from http_server")
+	url := flag.String("url", "", "url of the indexer, http://localhost:<http_server.port> if empty")
+	token := flag.String("token", "", "token of the admin endpoints, http_server.token if empty")
+	network := flag.String("network", "", "network name, first network if empty")
+	contracts := flag.String("contracts", "", "comma separated contract addresses, all contracts of the network if empty")
+	from := flag.Int("from", 0, "first block")
+	to := flag.Int("to", 0, "last block")
+	dryRun := flag.Bool("dry-run", false, "report notifications without sending them")
+	interval := flag.Duration("interval", 2*time.Second, "interval of the status requests")
+	flag.Parse()
+
+	if *url == "" || *token == "" {
+		cfg := config.Configuration{}
+		b, err := os.ReadFile(*configPath)
+		if err != nil {
+			log.Fatalf("config read error: %s", err)
+		}
+		err = json.Unmarshal(b, &cfg)
+		if err != nil {
+			log.Fatalf("config unmarshal error: %s", err)
+		}
+
+		if *url == "" {
+			*url = "http://localhost:" + cfg.Common.HttpServer.Port
+		}
+		if *token == "" {
+			*token = cfg.Common.HttpServer.Token
+		}
+	}
+
+	req := tasks.ReplayRequest{
+		Network:   *network,
+		FromBlock: *from,
+		ToBlock:   *to,
+		DryRun:    *dryRun,
+	}
+	if *contracts != "" {
+		req.Contracts = strings.Split(*contracts, ",")
+	}
+
+	body, err := json.Marshal(&req)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	job := tasks.ReplayJob{}
+	err = call(http.MethodPost, *url+"/v1/replay", *token, body, &job)
+	if err != nil {
+		log.Fatalf("start replay: %s", err)
+	}
+	log.Printf("replay %s of blocks %d-%d of %s is started", job.ID, job.FromBlock, job.ToBlock, job.Network)
+
+	for job.Status == tasks.ReplayRunning {
+		time.Sleep(*interval)
+
+		err = call(http.MethodGet, *url+"/v1/replay/"+job.ID, *token, nil, &job)
+		if err != nil {
+			log.Fatalf("replay status: %s", err)
+		}
+		log.Printf("block %d of %d, %d notifications found, %d sent", job.Block, job.ToBlock, job.Found, job.Emitted)
+	}
+
+	report, err := json.MarshalIndent(&job, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(report))
+
+	if job.Status != tasks.ReplayDone {
+		os.Exit(1)
+	}
+}
+
+// request to the admin endpoint of the indexer
+func call(method, url, token string, body []byte, result interface{}) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Token", token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("status %d : %s", resp.StatusCode, data)
+	}
+
+	return json.Unmarshal(data, result)
+}
@@ -1,12 +1,22 @@
 package config
 
 import (
+	"fmt"
+	"strings"
 	"sync"
 
 	valid "github.com/asaskevich/govalidator"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
 	configinternal "github.com/saiset-co/saiEthIndexer/internal/config-internal"
 )
 
+// indexing modes
+const (
+	ModeBlocks = "blocks" // every block is fetched with transactions and their receipts
+	ModeLogs   = "logs"   // logs of the contracts are fetched by eth_getLogs over block ranges
+)
+
 type Configuration struct {
 	Common   configinternal.Common `json:"common"` // built-in framework config
 	Specific `json:"specific"`
@@ -18,15 +28,83 @@ type Specific struct {
 	GethServer             string `json:"geth_server"`
 	Storage                `json:"storage"`
 	StartBlock             int      `json:"start_block"`
-	Operations             []string `json:"operations"`
+	Operations             []string `json:"operations"` // methods to notify about, contracts may have their own
 	Sleep                  int      `json:"sleep"`
 	WebSocket              `json:"websocket"`
-	SkipFailedTransactions bool     `json:"skipFailedTransactions"`
-	Notifier               Notifier `json:"notifier"`
+	SkipFailedTransactions bool         `json:"skipFailedTransactions"`
+	Notifier               Notifier     `json:"notifier"`
+	Confirmations          int          `json:"confirmations"` // blocks on top of the transaction block before the bridge is notified
+	Mode                   string       `json:"mode"`          // blocks (default) or logs
+	Logs                   Logs         `json:"logs"`
+	Subscription           Subscription `json:"subscription"`
+	Networks               []Network    `json:"networks"` // geth_server, confirmations, sleep and start_block are used if empty
+	RPC                    RPC          `json:"rpc"`
+}
+
+// EVM network indexed by the instance, contracts without network belong to the first one
+type Network struct {
+	Name          string   `json:"name"`
+	ChainID       int64    `json:"chain_id"`      // requested from the node if empty
+	RPC           []string `json:"rpc"`           // node urls
+	WebSocket     string   `json:"ws"`            // websocket url of the node for subscription
+	Quorum        int      `json:"quorum"`        // providers which must return the same blocks and logs, rpc.quorum if empty
+	Confirmations int      `json:"confirmations"` // blocks on top of the transaction block before the bridge is notified
+	BlockTime     int      `json:"block_time"`    // seconds between polls of the node, sleep is used if empty
+	StartBlock    int      `json:"start_block"`
+}
+
+// EthNetworks - configured networks, single network of the common settings if there are none
+func (s Specific) EthNetworks() []Network {
+	if len(s.Networks) == 0 {
+		return []Network{{
+			Name:          "default",
+			RPC:           []string{s.GethServer},
+			WebSocket:     s.Subscription.URL,
+			Confirmations: s.Confirmations,
+			BlockTime:     s.Sleep,
+			StartBlock:    s.StartBlock,
+			Quorum:        s.RPC.Quorum,
+		}}
+	}
+
+	networks := make([]Network, 0, len(s.Networks))
+	for _, network := range s.Networks {
+		if network.BlockTime <= 0 {
+			network.BlockTime = s.Sleep
+		}
+		if network.Quorum <= 0 {
+			network.Quorum = s.RPC.Quorum
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// settings of the node providers pool
+type RPC struct {
+	Quorum      int `json:"quorum"`       // providers which must return the same blocks and logs before events are emitted, 1 if empty
+	MaxLag      int `json:"max_lag"`      // providers behind the best head by more blocks are skipped, 0 - no limit
+	Timeout     int `json:"timeout"`      // request timeout in seconds (default 30)
+	HealthCheck int `json:"health_check"` // interval of providers health check in seconds (default 15)
+}
+
+// settings for logs indexing mode
+type Logs struct {
+	BlockRange    int      `json:"block_range"`     // blocks per eth_getLogs request at start, range is adapted to provider limits
+	MaxBlockRange int      `json:"max_block_range"` // upper bound of the adapted range
+	Events        []string `json:"events"`          // event names to index, transactions with these events are notified regardless of operations. Contracts may have their own
+}
+
+// settings for eth_subscribe notifications, indexing loop is woken up by new heads and logs instead of waiting for sleep
+type Subscription struct {
+	Enabled   bool   `json:"enabled"`
+	URL       string `json:"url"`       // websocket url of the node
+	Reconnect int    `json:"reconnect"` // reconnect delay in seconds, doubled on failures up to a minute
 }
 
 // settings for saiStorage
 type Storage struct {
+	Enabled    bool   `json:"enabled"` // store indexed events for the events api
 	Collection string `json:"collection"`
 	Token      string `json:"token"`
 	URL        string `json:"url"`
@@ -44,14 +122,59 @@ type Notifier struct {
 }
 
 type Contract struct {
-	Address    string `json:"address" valid:",required"`
-	ABI        string `json:"abi" valid:",required"`
-	StartBlock int    `json:"start_block" valid:",required"`
+	Name       string   `json:"name,omitempty"`
+	Address    string   `json:"address" valid:",required"`
+	ABI        string   `json:"abi" valid:",required"`
+	StartBlock int      `json:"start_block" valid:",required"`
+	Network    string   `json:"network,omitempty"`    // name of the network, first network if empty
+	Operations []string `json:"operations,omitempty"` // methods to notify about, common operations if empty
+	Events     []string `json:"events,omitempty"`     // events to index, logs.events if empty
 }
 
+// Validate - required fields, address and abi of the contract, operations and events must be in the abi
 func (r *Contract) Validate() error {
 	_, err := valid.ValidateStruct(r)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// address without prefix is a valid hex address too, but contracts and checkpoints are found by the prefixed one
+	if !strings.HasPrefix(r.Address, "0x") || !common.IsHexAddress(r.Address) {
+		return fmt.Errorf("invalid address %s", r.Address)
+	}
+
+	_abi, err := abi.JSON(strings.NewReader(r.ABI))
+	if err != nil {
+		return fmt.Errorf("invalid abi : %w", err)
+	}
+
+	for _, name := range r.Operations {
+		if _, ok := _abi.Methods[name]; !ok {
+			return fmt.Errorf("method %s is not in abi", name)
+		}
+	}
+	for _, name := range r.Events {
+		if _, ok := _abi.Events[name]; !ok {
+			return fmt.Errorf("event %s is not in abi", name)
+		}
+	}
+	return nil
+}
+
+// ContractOperations - methods of the contract to notify about
+func (s Specific) ContractOperations(contract Contract) []string {
+	if len(contract.Operations) != 0 {
+		return contract.Operations
+	}
+	return s.Operations
+}
+
+// ContractEvents - events of the contract to index, all events if empty
+func (s Specific) ContractEvents(contract Contract) []string {
+	if len(contract.Events) != 0 {
+		return contract.Events
+	}
+	return s.Logs.Events
 }
 
 type EthContracts struct {
@@ -15,4 +15,9 @@ var (
 		Code:    "BAD_REQUEST",
 		Message: "bad request",
 	}
+
+	errStorageDisabled = &ServiceErr{
+		Code:    "STORAGE_DISABLED",
+		Message: "events storage is disabled",
+	}
 )
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	valid "github.com/asaskevich/govalidator"
@@ -36,27 +37,69 @@ type addContractResponse struct {
 type deleteContractResponse struct {
 	Created bool `json:"is_deleted" example:"true"`
 }
+type updateContractResponse struct {
+	Updated bool `json:"is_updated" example:"true"`
+}
+type contractsResponse struct {
+	Contracts []config.Contract `json:"contracts"`
+}
+type replaysResponse struct {
+	Replays []tasks.ReplayJob `json:"replays"`
+}
 
-func HandleHTTP(g *gin.RouterGroup, logger *zap.Logger, t *tasks.TaskManager) {
+// HandleHTTP - routes of the service, contract management requires auth
+func HandleHTTP(g *gin.RouterGroup, logger *zap.Logger, t *tasks.TaskManager, auth gin.HandlerFunc) {
 	handler := &HttpHandler{
 		Logger:      logger,
 		TaskManager: t,
 	}
 	{
-		g.POST("/add_contract", handler.addContract)
-		g.POST("/delete_contract", handler.deleteContracts)
+		g.GET("/events", handler.events)
+	}
+
+	admin := g.Group("", auth)
+	{
+		admin.POST("/add_contract", handler.addContract)
+		admin.POST("/delete_contract", handler.deleteContracts)
+		admin.GET("/contracts", handler.contracts)
+		admin.GET("/contracts/:address", handler.contract)
+		admin.PUT("/contracts/:address", handler.updateContract)
+		admin.POST("/replay", handler.replay)
+		admin.GET("/replay", handler.replays)
+		admin.GET("/replay/:id", handler.replayJob)
 	}
 }
 
+// status and error of the contract management and replays
+func contractError(err error) (int, *ServiceErr) {
+	switch {
+	case errors.Is(err, tasks.ErrInvalidContract):
+		return http.StatusBadRequest, &ServiceErr{Code: "INVALID_CONTRACT", Message: err.Error()}
+	case errors.Is(err, tasks.ErrContractExists):
+		return http.StatusConflict, &ServiceErr{Code: "CONTRACT_EXISTS", Message: err.Error()}
+	case errors.Is(err, tasks.ErrContractNotFound):
+		return http.StatusNotFound, &ServiceErr{Code: "CONTRACT_NOT_FOUND", Message: err.Error()}
+	case errors.Is(err, tasks.ErrInvalidReplay):
+		return http.StatusBadRequest, &ServiceErr{Code: "INVALID_REPLAY", Message: err.Error()}
+	case errors.Is(err, tasks.ErrReplayRunning):
+		return http.StatusConflict, &ServiceErr{Code: "REPLAY_RUNNING", Message: err.Error()}
+	case errors.Is(err, tasks.ErrReplayNotFound):
+		return http.StatusNotFound, &ServiceErr{Code: "REPLAY_NOT_FOUND", Message: err.Error()}
+	}
+	return http.StatusInternalServerError, errInternalServer
+}
+
 // @Summary     add contract
-// @Description add contract
+// @Description add contracts, abi is parsed and operations and events must be in it. Nothing is added if any contract is invalid or exists
 // @ID          add contract
 // @Tags  	    Contract
 // @Accept      json
 // @Produce     json
 // @Success     200 {object} addContractResponse
 // @Failure     500 {object} errInternalServer
-// @Failure     400 {object} errBadRequest
+// @Failure     400 {object} ServiceErr
+// @Failure     401 {object} ServiceErr
+// @Failure     409 {object} ServiceErr
 // @Router      /add_contract [post]
 func (h *HttpHandler) addContract(c *gin.Context) {
 	dto := addContractsRequest{}
@@ -64,20 +107,20 @@ func (h *HttpHandler) addContract(c *gin.Context) {
 	if err != nil {
 		h.Logger.Error("http  - add contract - bind", zap.Error(err))
 		c.JSON(http.StatusBadRequest, errBadRequest)
+		return
 	}
 
-	for _, contract := range dto.Contracts {
-		err = contract.Validate()
-		if err != nil {
-			h.Logger.Error("http  - add contract - validate", zap.Error(err))
-			c.JSON(http.StatusBadRequest, errBadRequest)
-			return
-		}
+	err = dto.validate()
+	if err != nil {
+		h.Logger.Error("http  - add contract - validate", zap.Error(err))
+		c.JSON(http.StatusBadRequest, errBadRequest)
+		return
 	}
+
 	err = h.TaskManager.AddContract(dto.Contracts)
 	if err != nil {
 		h.Logger.Error("http - add contract", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, errInternalServer)
+		c.JSON(contractError(err))
 		return
 	}
 
@@ -85,7 +128,7 @@ func (h *HttpHandler) addContract(c *gin.Context) {
 }
 
 // @Summary     delete contract
-// @Description delete contract
+// @Description delete contracts, nothing is deleted if any contract is not found
 // @ID          delete contract
 // @Tags  	    Contract
 // @Accept      json
@@ -93,26 +136,207 @@ func (h *HttpHandler) addContract(c *gin.Context) {
 // @Success     200 {object} deleteContractResponse
 // @Failure     500 {object} errInternalServer
 // @Failure     400 {object} errBadRequest
-// @Router      /add_contract [post]
+// @Failure     401 {object} ServiceErr
+// @Failure     404 {object} ServiceErr
+// @Router      /delete_contract [post]
 func (h *HttpHandler) deleteContracts(c *gin.Context) {
 	dto := deleteContractsRequest{}
 	err := c.ShouldBindJSON(&dto)
 	if err != nil {
 		h.Logger.Error("http  - delete contract - bind", zap.Error(err))
 		c.JSON(http.StatusBadRequest, errBadRequest)
+		return
 	}
 
 	if len(dto.Addreses) == 0 {
 		h.Logger.Error("http  - delete contract - zero request length")
 		c.JSON(http.StatusBadRequest, errBadRequest)
+		return
 	}
 
 	err = h.TaskManager.DeleteContract(dto.Addreses)
 	if err != nil {
 		h.Logger.Error("http - delete contract ", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, errInternalServer)
+		c.JSON(contractError(err))
 		return
 	}
 
 	c.JSON(http.StatusOK, &deleteContractResponse{Created: true})
 }
+
+// @Summary     contracts
+// @Description contracts of the config
+// @ID          contracts
+// @Tags  	    Contract
+// @Produce     json
+// @Success     200 {object} contractsResponse
+// @Failure     401 {object} ServiceErr
+// @Router      /contracts [get]
+func (h *HttpHandler) contracts(c *gin.Context) {
+	c.JSON(http.StatusOK, &contractsResponse{Contracts: h.TaskManager.Contracts()})
+}
+
+// @Summary     contract
+// @Description contract of the config by address
+// @ID          contract
+// @Tags  	    Contract
+// @Produce     json
+// @Param       address path string true "contract address"
+// @Success     200 {object} config.Contract
+// @Failure     401 {object} ServiceErr
+// @Failure     404 {object} ServiceErr
+// @Router      /contracts/{address} [get]
+func (h *HttpHandler) contract(c *gin.Context) {
+	contract, err := h.TaskManager.Contract(c.Param("address"))
+	if err != nil {
+		c.JSON(contractError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, contract)
+}
+
+// @Summary     update contract
+// @Description replace abi, start block, network, operations and events of the contract, address can't be changed
+// @ID          update contract
+// @Tags  	    Contract
+// @Accept      json
+// @Produce     json
+// @Param       address path string true "contract address"
+// @Success     200 {object} updateContractResponse
+// @Failure     500 {object} errInternalServer
+// @Failure     400 {object} ServiceErr
+// @Failure     401 {object} ServiceErr
+// @Failure     404 {object} ServiceErr
+// @Router      /contracts/{address} [put]
+func (h *HttpHandler) updateContract(c *gin.Context) {
+	dto := config.Contract{}
+	err := c.ShouldBindJSON(&dto)
+	if err != nil {
+		h.Logger.Error("http  - update contract - bind", zap.Error(err))
+		c.JSON(http.StatusBadRequest, errBadRequest)
+		return
+	}
+
+	err = h.TaskManager.UpdateContract(c.Param("address"), dto)
+	if err != nil {
+		h.Logger.Error("http - update contract", zap.Error(err))
+		c.JSON(contractError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, &updateContractResponse{Updated: true})
+}
+
+// @Summary     replay
+// @Description scan confirmed blocks for the contracts again without moving cursors of the indexer. Dry run reports notifications,
+// @Description otherwise they are sent again flagged as replays. Status of the replay is returned by /replay/{id}
+// @ID          replay
+// @Tags  	    Replay
+// @Accept      json
+// @Produce     json
+// @Param       request body tasks.ReplayRequest true "block range and contracts"
+// @Success     202 {object} tasks.ReplayJob
+// @Failure     500 {object} errInternalServer
+// @Failure     400 {object} ServiceErr
+// @Failure     401 {object} ServiceErr
+// @Failure     404 {object} ServiceErr
+// @Failure     409 {object} ServiceErr
+// @Router      /replay [post]
+func (h *HttpHandler) replay(c *gin.Context) {
+	dto := tasks.ReplayRequest{}
+	err := c.ShouldBindJSON(&dto)
+	if err != nil {
+		h.Logger.Error("http  - replay - bind", zap.Error(err))
+		c.JSON(http.StatusBadRequest, errBadRequest)
+		return
+	}
+
+	job, err := h.TaskManager.Replay(dto)
+	if err != nil {
+		h.Logger.Error("http - replay", zap.Error(err))
+		c.JSON(contractError(err))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// @Summary     replays
+// @Description started replays without notifications, oldest first
+// @ID          replays
+// @Tags  	    Replay
+// @Produce     json
+// @Success     200 {object} replaysResponse
+// @Failure     401 {object} ServiceErr
+// @Router      /replay [get]
+func (h *HttpHandler) replays(c *gin.Context) {
+	c.JSON(http.StatusOK, &replaysResponse{Replays: h.TaskManager.ReplayJobs()})
+}
+
+// @Summary     replay status
+// @Description status and report of the replay
+// @ID          replay status
+// @Tags  	    Replay
+// @Produce     json
+// @Param       id path string true "replay id"
+// @Success     200 {object} tasks.ReplayJob
+// @Failure     401 {object} ServiceErr
+// @Failure     404 {object} ServiceErr
+// @Router      /replay/{id} [get]
+func (h *HttpHandler) replayJob(c *gin.Context) {
+	job, err := h.TaskManager.ReplayJob(c.Param("id"))
+	if err != nil {
+		c.JSON(contractError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// @Summary     events
+// @Description indexed events by contract, event name, participant address, block range and transaction hash with cursor pagination
+// @ID          events
+// @Tags  	    Events
+// @Produce     json
+// @Param       network    query string false "network name"
+// @Param       contract   query string false "contract address"
+// @Param       event      query string false "event name"
+// @Param       address    query string false "participant address"
+// @Param       tx_hash    query string false "transaction hash"
+// @Param       from_block query int    false "first block"
+// @Param       to_block   query int    false "last block"
+// @Param       retracted  query bool   false "include events of orphaned blocks"
+// @Param       desc       query bool   false "newest first"
+// @Param       limit      query int    false "page size, 100 by default, 1000 at most"
+// @Param       cursor     query string false "next_cursor of the previous page"
+// @Success     200 {object} tasks.EventsPage
+// @Failure     500 {object} errInternalServer
+// @Failure     503 {object} errStorageDisabled
+// @Failure     400 {object} errBadRequest
+// @Router      /events [get]
+func (h *HttpHandler) events(c *gin.Context) {
+	query := tasks.EventsQuery{}
+	err := c.ShouldBindQuery(&query)
+	if err != nil {
+		h.Logger.Error("http - events - bind", zap.Error(err))
+		c.JSON(http.StatusBadRequest, errBadRequest)
+		return
+	}
+
+	page, err := h.TaskManager.FindEvents(query)
+	switch {
+	case errors.Is(err, tasks.ErrStorageDisabled):
+		c.JSON(http.StatusServiceUnavailable, errStorageDisabled)
+		return
+	case errors.Is(err, tasks.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, errBadRequest)
+		return
+	case err != nil:
+		h.Logger.Error("http - events", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errInternalServer)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saiset-co/saiEthIndexer/config"
+	"github.com/saiset-co/saiEthIndexer/tasks"
+	"go.uber.org/zap"
+)
+
+const (
+	testABI      = `[{"anonymous":false,"inputs":[{"indexed":false,"name":"value","type":"uint256"}],"name":"Ping","type":"event"},{"inputs":[],"name":"ping","outputs":[],"stateMutability":"nonpayable","type":"function"}]`
+	testContract = "0x00000000000000000000000000000000000000aa"
+	newContract  = "0x00000000000000000000000000000000000000bb"
+)
+
+// router of the manager with one indexed contract, contracts.json is written to the temp dir
+func newTestRouter(t *testing.T) (*gin.Engine, *tasks.TaskManager) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(dir) })
+
+	cfg := &config.Configuration{
+		EthContracts: config.EthContracts{
+			Mutex:     &sync.RWMutex{},
+			Contracts: []config.Contract{{Address: testContract, ABI: testABI, StartBlock: 1, Network: "main", Events: []string{"Ping"}}},
+		},
+	}
+	manager := &tasks.TaskManager{
+		Config: cfg,
+		Logger: zap.NewNop(),
+		Chains: []*tasks.Chain{{Network: config.Network{Name: "main"}}},
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	HandleHTTP(router.Group("/v1"), zap.NewNop(), manager, func(c *gin.Context) { c.Next() })
+
+	return router, manager
+}
+
+func contractJSON(address, abi, network string, events ...string) string {
+	raw, _ := json.Marshal(config.Contract{Address: address, ABI: abi, StartBlock: 5, Network: network, Events: events})
+	return string(raw)
+}
+
+func TestContractsAPI(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		status    int
+		code      string   // error code of the response
+		contracts []string // addresses of the config after the request
+		events    []string // events of the indexed contract after the request
+	}{
+		{
+			name: "add", method: http.MethodPost, path: "/v1/add_contract",
+			body:   `{"contracts":[` + contractJSON(newContract, testABI, "main", "Ping") + `]}`,
+			status: http.StatusOK, contracts: []string{testContract, newContract}, events: []string{"Ping"},
+		},
+		{
+			name: "add invalid abi", method: http.MethodPost, path: "/v1/add_contract",
+			body:   `{"contracts":[` + contractJSON(newContract, `[{"type":"unknown"`, "main") + `]}`,
+			status: http.StatusBadRequest, code: "INVALID_CONTRACT", contracts: []string{testContract}, events: []string{"Ping"},
+		},
+		{
+			name: "add event out of abi", method: http.MethodPost, path: "/v1/add_contract",
+			body:   `{"contracts":[` + contractJSON(newContract, testABI, "main", "Transfer") + `]}`,
+			status: http.StatusBadRequest, code: "INVALID_CONTRACT", contracts: []string{testContract}, events: []string{"Ping"},
+		},
+		{
+			name: "add unknown network", method: http.MethodPost, path: "/v1/add_contract",
+			body:   `{"contracts":[` + contractJSON(newContract, testABI, "other") + `]}`,
+			status: http.StatusBadRequest, code: "INVALID_CONTRACT", contracts: []string{testContract}, events: []string{"Ping"},
+		},
+		{
+			name: "add batch with invalid contract", method: http.MethodPost, path: "/v1/add_contract",
+			body:   `{"contracts":[` + contractJSON(newContract, testABI, "main") + `,` + contractJSON("0x01", testABI, "main") + `]}`,
+			status: http.StatusBadRequest, code: "INVALID_CONTRACT", contracts: []string{testContract}, events: []string{"Ping"},
+		},
+		{
+			name: "add without address prefix", method: http.MethodPost, path: "/v1/add_contract",
+			body:   `{"contracts":[` + contractJSON(testContract[2:], testABI, "main") + `]}`,
+			status: http.StatusBadRequest, code: "INVALID_CONTRACT", contracts: []string{testContract}, events: []string{"Ping"},
+		},
+		{
+			name: "add existing", method: http.MethodPost, path: "/v1/add_contract",
+			body:   `{"contracts":[` + contractJSON("0x"+strings.ToUpper(testContract[2:]), testABI, "main") + `]}`,
+			status: http.StatusConflict, code: "CONTRACT_EXISTS", contracts: []string{testContract}, events: []string{"Ping"},
+		},
+		{
+			name: "add without contracts", method: http.MethodPost, path: "/v1/add_contract",
+			body:   `{}`,
+			status: http.StatusBadRequest, code: "BAD_REQUEST", contracts: []string{testContract}, events: []string{"Ping"},
+		},
+		{
+			name: "update", method: http.MethodPut, path: "/v1/contracts/" + testContract,
+			body:   contractJSON("", testABI, "main"),
+			status: http.StatusOK, contracts: []string{testContract}, events: nil,
+		},
+		{
+			name: "update invalid abi", method: http.MethodPut, path: "/v1/contracts/" + testContract,
+			body:   contractJSON(testContract, `not json`, "main"),
+			status: http.StatusBadRequest, code: "INVALID_CONTRACT", contracts: []string{testContract}, events: []string{"Ping"},
+		},
+		{
+			name: "update address", method: http.MethodPut, path: "/v1/contracts/" + testContract,
+			body:   contractJSON(newContract, testABI, "main"),
+			status: http.StatusBadRequest, code: "INVALID_CONTRACT", contracts: []string{testContract}, events: []string{"Ping"},
+		},
+		{
+			name: "update unknown", method: http.MethodPut, path: "/v1/contracts/" + newContract,
+			body:   contractJSON(newContract, testABI, "main"),
+			status: http.StatusNotFound, code: "CONTRACT_NOT_FOUND", contracts: []string{testContract}, events: []string{"Ping"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, manager := newTestRouter(t)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d : %s", w.Code, tt.status, w.Body)
+			}
+			if tt.code != "" {
+				response := ServiceErr{}
+				if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Code != tt.code {
+					t.Fatalf("response = %s, want code %s", w.Body, tt.code)
+				}
+			}
+
+			var contracts []string
+			for _, contract := range manager.Contracts() {
+				contracts = append(contracts, contract.Address)
+			}
+			if !reflect.DeepEqual(contracts, tt.contracts) {
+				t.Fatalf("contracts = %v, want %v", contracts, tt.contracts)
+			}
+
+			contract, err := manager.Contract(testContract)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(contract.Events, tt.events) {
+				t.Fatalf("events = %v, want %v", contract.Events, tt.events)
+			}
+
+			// config is saved only if it is changed
+			_, err = os.Stat("contracts.json")
+			if saved := err == nil; saved != (tt.status == http.StatusOK) {
+				t.Fatalf("contracts.json saved = %v", saved)
+			}
+		})
+	}
+}
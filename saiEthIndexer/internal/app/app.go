@@ -106,7 +106,7 @@ func (a *App) Run() error {
 		httpServer = httpserver.New(a.handlers.Http, a.Cfg)
 	}
 
-	go a.taskManager.ProcessBlocks()
+	go a.taskManager.Run()
 
 	// Waiting signal
 	interrupt := make(chan os.Signal, 1)
@@ -118,6 +118,9 @@ func (a *App) Run() error {
 	case err := <-errChan:
 		a.Logger.Error("app - Run - server notifier: ", zap.Error(err))
 	}
+	a.taskManager.Stop()
+	a.Logger.Info("indexing workers stopped")
+
 	if a.Cfg.Common.HttpServer.Enabled {
 		err := httpServer.Shutdown()
 		if err != nil {
@@ -17,6 +17,7 @@ type HttpServer struct {
 	Enabled bool   `json:"enabled"`
 	Host    string `json:"host"`
 	Port    string `json:"port"`
+	Token   string `json:"token"` // token of the admin endpoints, they are rejected if empty
 }
 
 type SocketServer struct {
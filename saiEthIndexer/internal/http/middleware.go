@@ -1,6 +1,7 @@
 package http
 
 import (
+	"crypto/subtle"
 	"net"
 	"net/http"
 	"net/http/httputil"
@@ -82,9 +83,25 @@ func GinRecovery(logger *zap.Logger, stack bool) gin.HandlerFunc {
 	}
 }
 
-// todo:dummy auth middleware for now
-func AuthRequired(logger *zap.Logger) gin.HandlerFunc {
+// AuthRequired - requests must have the token in Token header or as Authorization bearer,
+// all requests are rejected if the token is not configured
+func AuthRequired(token string, logger *zap.Logger) gin.HandlerFunc {
+	if token == "" {
+		logger.Warn("http - AuthRequired - token is not configured, admin endpoints are disabled")
+	}
+
 	return func(c *gin.Context) {
+		requestToken := c.GetHeader("Token")
+		if requestToken == "" {
+			requestToken = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+
+		if token == "" || subtle.ConstantTimeCompare([]byte(requestToken), []byte(token)) != 1 {
+			logger.Warn("http - AuthRequired - unauthorized", zap.String("path", c.Request.URL.Path), zap.String("ip", c.ClientIP()))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": "UNAUTHORIZED", "message": "unauthorized"})
+			return
+		}
+
 		c.Next()
 	}
 }
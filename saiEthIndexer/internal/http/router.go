@@ -2,6 +2,7 @@ package http
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/saiset-co/saiEthIndexer/handlers"
 	"github.com/saiset-co/saiEthIndexer/tasks"
 	swaggerFiles "github.com/swaggo/files"
@@ -17,14 +18,17 @@ import (
 // @host        localhost:8081
 // @BasePath    /v1
 func NewRouter(handler *gin.Engine, l *zap.Logger, t *tasks.TaskManager) {
-	//handler.Use(GinLogger(l), GinRecovery(l, false), AuthRequired(l))
+	//handler.Use(GinLogger(l), GinRecovery(l, false))
 
 	// Swagger
 	swaggerHandler := ginSwagger.DisablingWrapHandler(swaggerFiles.Handler, "DISABLE_SWAGGER_HTTP_HANDLER")
 	handler.GET("/swagger/*any", swaggerHandler)
 
+	// Prometheus metrics
+	handler.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	g := handler.Group("/v1")
 
 	// func to realize in handlers package
-	handlers.HandleHTTP(g, l, t)
+	handlers.HandleHTTP(g, l, t, AuthRequired(t.Config.Common.HttpServer.Token, l))
 }
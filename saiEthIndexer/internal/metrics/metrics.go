@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "eth_indexer"
+
+var (
+	// metrics are labeled by the network name
+
+	// HeadBlock is the latest block number reported by the geth-server
+	HeadBlock = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "head_block",
+		Help:      "Latest block number reported by the node.",
+	}, []string{"network"})
+
+	// LastProcessedBlock is the last block handled by the indexer
+	LastProcessedBlock = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_processed_block",
+		Help:      "Last block processed by the indexer.",
+	}, []string{"network"})
+
+	// HeadLag is the distance in blocks between the node head and the indexer
+	HeadLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "head_lag_blocks",
+		Help:      "Blocks between the node head and the last processed block.",
+	}, []string{"network"})
+
+	// BlocksProcessed counts processed blocks
+	BlocksProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blocks_processed_total",
+		Help:      "Blocks processed by the indexer.",
+	}, []string{"network"})
+
+	// NotificationsSent counts notifications sent to the bridge by result
+	NotificationsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_total",
+		Help:      "Transaction notifications sent by result.",
+	}, []string{"network", "result"})
+
+	// RetractionsSent counts retractions of orphaned transactions sent to the bridge by result
+	RetractionsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retractions_total",
+		Help:      "Orphaned transaction retractions sent by result.",
+	}, []string{"network", "result"})
+
+	// OutboxPending is the number of notifications and retractions waiting for delivery to the bridge or the storage
+	OutboxPending = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "outbox_pending",
+		Help:      "Notifications and retractions waiting for delivery.",
+	}, []string{"network", "sink"})
+
+	// StorageWrites counts writes of the events to the storage by result
+	StorageWrites = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_writes_total",
+		Help:      "Events and retractions written to the storage by result.",
+	}, []string{"network", "result"})
+
+	// PendingEvents is the number of events waiting for confirmations
+	PendingEvents = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pending_events",
+		Help:      "Events waiting for confirmations.",
+	}, []string{"network"})
+
+	// ReorgsDetected counts detected chain reorganizations
+	ReorgsDetected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reorgs_total",
+		Help:      "Chain reorganizations detected by the indexer.",
+	}, []string{"network"})
+
+	// BackfillWorkers is the number of contracts indexed by backfill workers
+	BackfillWorkers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "backfill_workers",
+		Help:      "Contracts indexed by backfill workers.",
+	}, []string{"network"})
+
+	// SubscriptionReconnects counts reconnections of eth_subscribe websocket
+	SubscriptionReconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "subscription_reconnects_total",
+		Help:      "Reconnections of the node websocket subscription.",
+	}, []string{"network"})
+
+	// OrphanedBlocks counts blocks removed by reorganizations
+	OrphanedBlocks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orphaned_blocks_total",
+		Help:      "Blocks removed by chain reorganizations.",
+	}, []string{"network"})
+
+	// ProviderUp is 1 for node providers which are used for requests
+	ProviderUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "provider_up",
+		Help:      "Node provider is healthy and used for requests.",
+	}, []string{"network", "provider"})
+
+	// ProviderLatency is the average request latency of the node provider
+	ProviderLatency = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "provider_latency_seconds",
+		Help:      "Average request latency of the node provider.",
+	}, []string{"network", "provider"})
+
+	// ProviderHead is the latest block number reported by the node provider
+	ProviderHead = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "provider_head_block",
+		Help:      "Latest block number reported by the node provider.",
+	}, []string{"network", "provider"})
+)
+
+// ProviderStatus updates health gauges of the node provider
+func ProviderStatus(network, provider string, up bool, latency time.Duration, head int) {
+	value := 0.0
+	if up {
+		value = 1
+	}
+	ProviderUp.WithLabelValues(network, provider).Set(value)
+	ProviderLatency.WithLabelValues(network, provider).Set(latency.Seconds())
+	ProviderHead.WithLabelValues(network, provider).Set(float64(head))
+}
+
+// last processed blocks by network, lag grows with the head while the indexer is stuck
+var processed = struct {
+	sync.Mutex
+	blocks map[string]int
+}{blocks: map[string]int{}}
+
+// SetHead updates head gauge of the network and lag of the last processed block behind it
+func SetHead(network string, head int) {
+	HeadBlock.WithLabelValues(network).Set(float64(head))
+
+	processed.Lock()
+	id, ok := processed.blocks[network]
+	processed.Unlock()
+
+	if ok {
+		HeadLag.WithLabelValues(network).Set(float64(head - id))
+	}
+}
+
+// BlockProcessed updates processed block counter and lag gauges
+func BlockProcessed(network string, id, head int) {
+	processed.Lock()
+	processed.blocks[network] = id
+	processed.Unlock()
+
+	BlocksProcessed.WithLabelValues(network).Inc()
+	LastProcessedBlock.WithLabelValues(network).Set(float64(id))
+	HeadLag.WithLabelValues(network).Set(float64(head - id))
+}
+
+// NotificationResult records the outcome of a notification
+func NotificationResult(network string, err error) {
+	if err != nil {
+		NotificationsSent.WithLabelValues(network, "failed").Inc()
+		return
+	}
+	NotificationsSent.WithLabelValues(network, "sent").Inc()
+}
+
+// RetractionResult records the outcome of a retraction
+func RetractionResult(network string, err error) {
+	if err != nil {
+		RetractionsSent.WithLabelValues(network, "failed").Inc()
+		return
+	}
+	RetractionsSent.WithLabelValues(network, "sent").Inc()
+}
+
+// StorageResult records the outcome of a storage write
+func StorageResult(network string, err error) {
+	if err != nil {
+		StorageWrites.WithLabelValues(network, "failed").Inc()
+		return
+	}
+	StorageWrites.WithLabelValues(network, "stored").Inc()
+}
+
+// Reorg records detected reorganization with the number of orphaned blocks
+func Reorg(network string, orphaned int) {
+	ReorgsDetected.WithLabelValues(network).Inc()
+	OrphanedBlocks.WithLabelValues(network).Add(float64(orphaned))
+}
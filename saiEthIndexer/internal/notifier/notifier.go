@@ -7,7 +7,8 @@ import (
 )
 
 type Notifier interface {
-	SendTx(data interface{}) error
+	SendTx(key string, data interface{}) error
+	RetractTx(key string, data interface{}) error
 }
 
 type notifier struct {
@@ -19,8 +20,9 @@ type notifier struct {
 }
 
 type notificationData struct {
-	From string      `json:"from"`
-	Tx   interface{} `json:"tx"`
+	From           string      `json:"from"`
+	Tx             interface{} `json:"tx"`
+	IdempotencyKey string      `json:"idempotency_key"` // same for every delivery attempt of the event
 }
 
 type Metadata struct {
@@ -43,12 +45,22 @@ func NewNotifier(senderID, email, password, token, address string) Notifier {
 	}
 }
 
-func (n *notifier) SendTx(tx interface{}) error {
+func (n *notifier) SendTx(key string, tx interface{}) error {
+	return n.send("notify", key, tx)
+}
+
+// RetractTx - notify the bridge that transaction was in the orphaned block
+func (n *notifier) RetractTx(key string, tx interface{}) error {
+	return n.send("retract", key, tx)
+}
+
+func (n *notifier) send(method, key string, tx interface{}) error {
 	req := notificationRequest{
-		Method: "notify",
+		Method: method,
 		Data: notificationData{
-			From: n.senderID,
-			Tx:   tx,
+			From:           n.senderID,
+			Tx:             tx,
+			IdempotencyKey: key,
 		},
 		Metadata: Metadata{
 			Token: n.token,
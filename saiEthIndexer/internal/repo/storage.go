@@ -9,6 +9,24 @@ import (
 
 type Repo interface {
 	Create(data interface{}) error
+	Read(selector map[string]interface{}, options *adapter.Options) ([]map[string]interface{}, error)
+	Update(selector map[string]interface{}, document interface{}) error
+	CreateIndexes(indexes []Index) error
+}
+
+// Index - index of the collection, keys are field names with 1 for ascending and -1 for descending order
+type Index struct {
+	Keys   []map[string]int `json:"keys"`
+	Unique bool             `json:"unique"`
+}
+
+// create_indexes request, it is not in the adapter
+type indexesRequest struct {
+	Method string `json:"method"`
+	Data   struct {
+		Collection string  `json:"collection"`
+		Indexes    []Index `json:"index_data"`
+	} `json:"data"`
 }
 
 type MongoRepo struct {
@@ -47,3 +65,68 @@ func (mr *MongoRepo) Create(data interface{}) error {
 
 	return err
 }
+
+func (mr *MongoRepo) Read(selector map[string]interface{}, options *adapter.Options) ([]map[string]interface{}, error) {
+	req := adapter.Request{
+		Method: "read",
+		Data: adapter.ReadRequest{
+			Collection: mr.collection,
+			Select:     selector,
+			Options:    options,
+		},
+	}
+
+	payload, err := jsoniter.Marshal(&req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := utils.SaiQuerySender(bytes.NewReader(payload), mr.address, mr.token)
+	if err != nil {
+		return nil, err
+	}
+
+	response := adapter.SaiStorageResponse{}
+	err = jsoniter.Unmarshal(res, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Result, nil
+}
+
+func (mr *MongoRepo) Update(selector map[string]interface{}, document interface{}) error {
+	req := adapter.Request{
+		Method: "update",
+		Data: adapter.UpdateRequest{
+			Collection: mr.collection,
+			Select:     selector,
+			Document:   document,
+		},
+	}
+
+	payload, err := jsoniter.Marshal(&req)
+	if err != nil {
+		return err
+	}
+
+	_, err = utils.SaiQuerySender(bytes.NewReader(payload), mr.address, mr.token)
+
+	return err
+}
+
+// CreateIndexes - create indexes of the collection, existing indexes are kept
+func (mr *MongoRepo) CreateIndexes(indexes []Index) error {
+	req := indexesRequest{Method: "create_indexes"}
+	req.Data.Collection = mr.collection
+	req.Data.Indexes = indexes
+
+	payload, err := jsoniter.Marshal(&req)
+	if err != nil {
+		return err
+	}
+
+	_, err = utils.SaiQuerySender(bytes.NewReader(payload), mr.address, mr.token)
+
+	return err
+}
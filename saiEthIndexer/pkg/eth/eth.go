@@ -1,28 +1,28 @@
 package eth
 
 import (
-	"github.com/onrik/ethrpc"
-	"go.uber.org/zap"
+	"encoding/json"
+	"strconv"
+	"strings"
 )
 
-func GetClient(address string, logger *zap.Logger) (*ethrpc.EthRPC, error) {
-	ethClient := ethrpc.New(address)
-
-	// got -32000 context cancelled error when get web3clientVersion
-
-	// version, err := ethClient.Web3ClientVersion()
-	// if err != nil {
-	// 	logger.Error("get version", zap.Error(err))
-	// 	return nil, err
-	// }
+// Caller - json-rpc client
+type Caller interface {
+	Call(method string, params ...interface{}) (json.RawMessage, error)
+}
 
-	version, err := ethClient.NetVersion()
+// ChainID - chain id of the node by eth_chainId
+func ChainID(client Caller) (int64, error) {
+	response, err := client.Call("eth_chainId")
 	if err != nil {
-		logger.Error("get net version", zap.Error(err))
-		return nil, err
+		return 0, err
 	}
 
-	logger.Sugar().Debugf("Connected to geth server  : %s,  client net version : %s", ethClient.URL(), version)
+	var hex string
+	err = json.Unmarshal(response, &hex)
+	if err != nil {
+		return 0, err
+	}
 
-	return ethClient, nil
+	return strconv.ParseInt(strings.TrimPrefix(hex, "0x"), 16, 64)
 }
@@ -0,0 +1,551 @@
+package eth
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onrik/ethrpc"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTimeout    = 30 * time.Second
+	latencyWeight     = 0.2              // weight of the last request in the average latency
+	maxDownTime       = time.Minute      // failed provider is skipped for 2^failures seconds up to this
+	rateLimitDownTime = 10 * time.Second // rate limited provider is skipped for this
+)
+
+var (
+	ErrRateLimited = errors.New("rate limited")
+	ErrNoQuorum    = errors.New("providers don't agree")
+	errNotFound    = errors.New("not found")
+)
+
+// messages of providers which reject eth_getLogs over too many blocks or results
+var rangeLimitErrors = []string{
+	"block range",
+	"range is too large",
+	"range too large",
+	"more than",
+	"limit exceeded",
+	"too many",
+	"response size",
+	"query timeout",
+}
+
+// messages of providers which throttle requests
+var rateLimitErrors = []string{
+	"rate limit",
+	"too many requests",
+	"requests per second",
+	"exceeded the quota",
+	"capacity exceeded",
+}
+
+// ProviderStatus - health of the provider
+type ProviderStatus struct {
+	Name    string // host of the provider, url may contain api key
+	Up      bool
+	Latency time.Duration
+	Head    int
+}
+
+type provider struct {
+	name      string
+	client    *ethrpc.EthRPC
+	latency   time.Duration // average latency of the requests
+	head      int           // head of the last health check
+	failures  int           // failures in a row
+	downUntil time.Time
+}
+
+// Pool - json-rpc providers of the network. Requests go to healthy providers weighted by latency and fail over
+// to the next one on errors. With quorum, blocks, receipts and logs must be the same at quorum of the providers
+type Pool struct {
+	sync.Mutex
+	providers []*provider
+	quorum    int
+	maxLag    int // providers behind the best head by more blocks are skipped, 0 - no limit
+	logger    *zap.Logger
+}
+
+func NewPool(urls []string, quorum, maxLag int, timeout time.Duration, logger *zap.Logger) (*Pool, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("rpc url is not set")
+	}
+	if quorum < 1 {
+		quorum = 1
+	}
+	if quorum > len(urls) {
+		return nil, fmt.Errorf("quorum %d is more than %d providers", quorum, len(urls))
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	client := &httpClient{client: &http.Client{Timeout: timeout}}
+
+	p := &Pool{
+		quorum: quorum,
+		maxLag: maxLag,
+		logger: logger,
+	}
+	names := map[string]bool{}
+	for i, u := range urls {
+		name := providerName(u)
+		if names[name] {
+			name = fmt.Sprintf("%s#%d", name, i)
+		}
+		names[name] = true
+
+		p.providers = append(p.providers, &provider{
+			name:   name,
+			client: ethrpc.New(u, ethrpc.WithHttpClient(client)),
+		})
+	}
+
+	p.Check()
+
+	up := 0
+	for _, status := range p.Status() {
+		if status.Up {
+			up++
+		}
+	}
+	if up == 0 {
+		return nil, errors.New("no provider is available")
+	}
+
+	logger.Sugar().Debugf("rpc pool : %d of %d providers are available, quorum %d", up, len(p.providers), quorum)
+
+	return p, nil
+}
+
+// Check - request head of every provider, failed providers are skipped until they answer again
+func (p *Pool) Check() {
+	wg := sync.WaitGroup{}
+	for _, pr := range p.providers {
+		wg.Add(1)
+		go func(pr *provider) {
+			defer wg.Done()
+
+			start := time.Now()
+			head, err := pr.client.EthBlockNumber()
+			p.report(pr, start, err)
+			if err != nil {
+				p.logger.Warn("eth - pool - health check", zap.String("provider", pr.name), zap.Error(err))
+				return
+			}
+
+			p.Lock()
+			pr.head = head
+			p.Unlock()
+		}(pr)
+	}
+	wg.Wait()
+}
+
+// Status - health of the providers
+func (p *Pool) Status() []ProviderStatus {
+	p.Lock()
+	defer p.Unlock()
+
+	best := p.bestHead()
+	statuses := make([]ProviderStatus, 0, len(p.providers))
+	for _, pr := range p.providers {
+		statuses = append(statuses, ProviderStatus{
+			Name:    pr.name,
+			Up:      p.healthy(pr, best, time.Now()),
+			Latency: pr.latency,
+			Head:    pr.head,
+		})
+	}
+	return statuses
+}
+
+// EthBlockNumber - head of the chain. With quorum it is the highest block which quorum of the providers have
+func (p *Pool) EthBlockNumber() (int, error) {
+	if p.quorum == 1 {
+		var head int
+		err := p.do("eth_blockNumber", func(client *ethrpc.EthRPC) (err error) {
+			head, err = client.EthBlockNumber()
+			return err
+		})
+		return head, err
+	}
+
+	var (
+		mutex sync.Mutex
+		heads []int
+		last  error
+	)
+	wg := sync.WaitGroup{}
+	for _, pr := range p.candidates() {
+		wg.Add(1)
+		go func(pr *provider) {
+			defer wg.Done()
+
+			start := time.Now()
+			head, err := pr.client.EthBlockNumber()
+			p.report(pr, start, err)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				last = err
+				return
+			}
+			heads = append(heads, head)
+		}(pr)
+	}
+	wg.Wait()
+
+	if len(heads) < p.quorum {
+		return 0, fmt.Errorf("%w : %d of %d providers answered, last error : %v", ErrNoQuorum, len(heads), p.quorum, last)
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(heads)))
+	return heads[p.quorum-1], nil
+}
+
+// EthGetBlockByNumber - block by number, with quorum its hash must be the same at quorum of the providers
+func (p *Pool) EthGetBlockByNumber(number int, withTransactions bool) (*ethrpc.Block, error) {
+	value, err := p.agree("eth_getBlockByNumber", func(client *ethrpc.EthRPC) (string, interface{}, error) {
+		blk, err := client.EthGetBlockByNumber(number, withTransactions)
+		if err != nil {
+			return "", nil, err
+		}
+		if blk == nil {
+			return "", nil, errNotFound
+		}
+		return blk.Hash, blk, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*ethrpc.Block), nil
+}
+
+// EthGetTransactionReceipt - receipt of the transaction, with quorum its block, status, created contract and logs must be the same
+func (p *Pool) EthGetTransactionReceipt(hash string) (*ethrpc.TransactionReceipt, error) {
+	value, err := p.agree("eth_getTransactionReceipt", func(client *ethrpc.EthRPC) (string, interface{}, error) {
+		receipt, err := client.EthGetTransactionReceipt(hash)
+		if err != nil {
+			return "", nil, err
+		}
+		if receipt == nil {
+			return "", nil, errNotFound
+		}
+		return fmt.Sprintf("%s:%s:%s:%s", receipt.BlockHash, receipt.Status, strings.ToLower(receipt.ContractAddress), logsKey(receipt.Logs)), receipt, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*ethrpc.TransactionReceipt), nil
+}
+
+// EthGetLogs - logs by filter, with quorum the same logs must be returned by quorum of the providers
+func (p *Pool) EthGetLogs(params ethrpc.FilterParams) ([]ethrpc.Log, error) {
+	value, err := p.agree("eth_getLogs", func(client *ethrpc.EthRPC) (string, interface{}, error) {
+		logs, err := client.EthGetLogs(params)
+		if err != nil {
+			return "", nil, err
+		}
+		return logsKey(logs), logs, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]ethrpc.Log), nil
+}
+
+// EthGetTransactionByHash - transaction by hash, it is identified by its content so quorum is not required
+func (p *Pool) EthGetTransactionByHash(hash string) (*ethrpc.Transaction, error) {
+	var tr *ethrpc.Transaction
+	err := p.do("eth_getTransactionByHash", func(client *ethrpc.EthRPC) (err error) {
+		tr, err = client.EthGetTransactionByHash(hash)
+		if err == nil && tr == nil {
+			return errNotFound
+		}
+		return err
+	})
+	return tr, err
+}
+
+// Call - raw json-rpc call with failover
+func (p *Pool) Call(method string, params ...interface{}) (json.RawMessage, error) {
+	var result json.RawMessage
+	err := p.do(method, func(client *ethrpc.EthRPC) (err error) {
+		result, err = client.Call(method, params...)
+		return err
+	})
+	return result, err
+}
+
+// request providers in order until one of them answers, range limit errors are returned at once
+func (p *Pool) do(method string, f func(client *ethrpc.EthRPC) error) error {
+	var err error
+	for _, pr := range p.candidates() {
+		start := time.Now()
+		err = f(pr.client)
+		p.report(pr, start, err)
+		if err == nil || IsRangeLimit(err) {
+			return err
+		}
+		p.logger.Warn("eth - pool - failover", zap.String("method", method), zap.String("provider", pr.name), zap.Error(err))
+	}
+	return err
+}
+
+// request providers until quorum of them return the same result, key identifies the result
+func (p *Pool) agree(method string, f func(client *ethrpc.EthRPC) (string, interface{}, error)) (interface{}, error) {
+	type answer struct {
+		provider *provider
+		key      string
+		value    interface{}
+		err      error
+	}
+
+	candidates := p.candidates()
+	votes := map[string]int{}
+	var last error
+
+	for len(candidates) > 0 {
+		need := p.quorum
+		for _, count := range votes {
+			if p.quorum-count < need {
+				need = p.quorum - count
+			}
+		}
+		if need > len(candidates) {
+			need = len(candidates)
+		}
+
+		batch := candidates[:need]
+		candidates = candidates[need:]
+
+		answers := make([]answer, len(batch))
+		wg := sync.WaitGroup{}
+		for i, pr := range batch {
+			wg.Add(1)
+			go func(i int, pr *provider) {
+				defer wg.Done()
+
+				start := time.Now()
+				key, value, err := f(pr.client)
+				p.report(pr, start, err)
+				answers[i] = answer{provider: pr, key: key, value: value, err: err}
+			}(i, pr)
+		}
+		wg.Wait()
+
+		for _, a := range answers {
+			if a.err != nil {
+				if IsRangeLimit(a.err) {
+					return nil, a.err
+				}
+				p.logger.Warn("eth - pool - failover", zap.String("method", method), zap.String("provider", a.provider.name), zap.Error(a.err))
+				last = a.err
+				continue
+			}
+
+			votes[a.key]++
+			if votes[a.key] >= p.quorum {
+				return a.value, nil
+			}
+		}
+	}
+
+	if len(votes) > 1 {
+		p.logger.Error("eth - pool - providers returned different results", zap.String("method", method), zap.Int("results", len(votes)))
+		return nil, fmt.Errorf("%s : %w", method, ErrNoQuorum)
+	}
+	if last != nil && p.quorum == 1 {
+		return nil, last
+	}
+	return nil, fmt.Errorf("%s : %w, last error : %v", method, ErrNoQuorum, last)
+}
+
+// providers in order of requests: healthy ones by random weighted by latency, then the others by the time they are back
+func (p *Pool) candidates() []*provider {
+	p.Lock()
+	defer p.Unlock()
+
+	now := time.Now()
+	best := p.bestHead()
+
+	var healthy, down []*provider
+	weights := []float64{}
+	for _, pr := range p.providers {
+		if !p.healthy(pr, best, now) {
+			down = append(down, pr)
+			continue
+		}
+		healthy = append(healthy, pr)
+
+		latency := pr.latency
+		if latency < time.Millisecond {
+			latency = time.Millisecond
+		}
+		weights = append(weights, 1/latency.Seconds())
+	}
+
+	ordered := make([]*provider, 0, len(p.providers))
+	for len(healthy) > 0 {
+		sum := 0.0
+		for _, w := range weights {
+			sum += w
+		}
+
+		i := 0
+		for pick := rand.Float64() * sum; i < len(weights)-1; i++ {
+			pick -= weights[i]
+			if pick < 0 {
+				break
+			}
+		}
+
+		ordered = append(ordered, healthy[i])
+		healthy = append(healthy[:i], healthy[i+1:]...)
+		weights = append(weights[:i], weights[i+1:]...)
+	}
+
+	// all providers are tried before request fails
+	sort.SliceStable(down, func(i, j int) bool {
+		return down[i].downUntil.Before(down[j].downUntil)
+	})
+	return append(ordered, down...)
+}
+
+func (p *Pool) healthy(pr *provider, best int, now time.Time) bool {
+	if now.Before(pr.downUntil) {
+		return false
+	}
+	return p.maxLag <= 0 || pr.head == 0 || best-pr.head <= p.maxLag
+}
+
+func (p *Pool) bestHead() int {
+	best := 0
+	for _, pr := range p.providers {
+		if pr.head > best {
+			best = pr.head
+		}
+	}
+	return best
+}
+
+// record result of the request: latency of the answer, failure of the provider which didn't answer or throttled the request
+func (p *Pool) report(pr *provider, start time.Time, err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	var ethErr ethrpc.EthError
+	answered := err == nil || errors.Is(err, errNotFound) || errors.As(err, &ethErr)
+
+	switch {
+	case IsRateLimit(err):
+		pr.downUntil = time.Now().Add(rateLimitDownTime)
+	case answered:
+		latency := time.Since(start)
+		if pr.latency == 0 {
+			pr.latency = latency
+		} else {
+			pr.latency = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(pr.latency))
+		}
+		pr.failures = 0
+		pr.downUntil = time.Time{}
+	default:
+		pr.failures++
+		down := maxDownTime
+		if pr.failures < 6 {
+			down = time.Duration(1<<pr.failures) * time.Second
+		}
+		pr.downUntil = time.Now().Add(down)
+	}
+}
+
+// IsRangeLimit - provider rejected the request because of too many blocks or results
+func IsRangeLimit(err error) bool {
+	if err == nil || IsRateLimit(err) {
+		return false
+	}
+
+	var ethErr ethrpc.EthError
+	if errors.As(err, &ethErr) && ethErr.Code == -32005 {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, limit := range rangeLimitErrors {
+		if strings.Contains(message, limit) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRateLimit - provider throttled the request
+func IsRateLimit(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrRateLimited) {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, limit := range rateLimitErrors {
+		if strings.Contains(message, limit) {
+			return true
+		}
+	}
+	return false
+}
+
+// key of the logs which is the same for the same logs of the same blocks, emitter and topics are compared
+// as well as data, so providers which return different events of the transaction do not agree
+func logsKey(logs []ethrpc.Log) string {
+	h := sha256.New()
+	for _, l := range logs {
+		fmt.Fprintf(h, "%s:%s:%d:%t:%s:%s:%s;", l.BlockHash, l.TransactionHash, l.LogIndex, l.Removed,
+			strings.ToLower(l.Address), strings.ToLower(strings.Join(l.Topics, ",")), l.Data)
+	}
+	return fmt.Sprintf("%d:%x", len(logs), h.Sum(nil))
+}
+
+// host of the provider url for logs and metrics
+func providerName(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" {
+		return "provider"
+	}
+	return parsed.Host
+}
+
+// http client which turns 429 status into rate limit error, ethrpc fails to parse its body otherwise
+type httpClient struct {
+	client *http.Client
+}
+
+func (c *httpClient) Post(url string, contentType string, body io.Reader) (*http.Response, error) {
+	response, err := c.client.Post(url, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusTooManyRequests {
+		response.Body.Close()
+		return nil, ErrRateLimited
+	}
+	return response, nil
+}
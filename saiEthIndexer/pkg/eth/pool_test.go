@@ -0,0 +1,124 @@
+package eth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/onrik/ethrpc"
+	"go.uber.org/zap"
+)
+
+// provider which answers eth_getLogs with the logs or with the json-rpc error
+func newProvider(t *testing.T, logs []map[string]interface{}, rpcErr map[string]interface{}) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int    `json:"id"`
+			Method string `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch {
+		case req.Method == "eth_blockNumber":
+			response["result"] = "0x64"
+		case rpcErr != nil:
+			response["error"] = rpcErr
+		default:
+			response["result"] = logs
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv.URL
+}
+
+func testLog(address, data string, removed bool) []map[string]interface{} {
+	return []map[string]interface{}{{
+		"address":          address,
+		"topics":           []string{"0x0000000000000000000000000000000000000000000000000000000000000001"},
+		"data":             data,
+		"blockNumber":      "0x64",
+		"blockHash":        "0x00000000000000000000000000000000000000000000000000000000000000aa",
+		"transactionHash":  "0x00000000000000000000000000000000000000000000000000000000000000bb",
+		"transactionIndex": "0x0",
+		"logIndex":         "0x0",
+		"removed":          removed,
+	}}
+}
+
+func TestPoolLogsQuorum(t *testing.T) {
+	const emitter = "0x00000000000000000000000000000000000000cc"
+
+	var (
+		good     = testLog(emitter, "0x01", false)
+		data     = testLog(emitter, "0x02", false)
+		other    = testLog("0x00000000000000000000000000000000000000dd", "0x01", false)
+		removed  = testLog(emitter, "0x01", true)
+		failed   = map[string]interface{}{"code": -32000, "message": "header not found"}
+		tooLarge = map[string]interface{}{"code": -32005, "message": "query returned more than 10000 results"}
+	)
+
+	type answer struct {
+		logs []map[string]interface{}
+		err  map[string]interface{}
+	}
+
+	tests := []struct {
+		name       string
+		quorum     int
+		answers    []answer
+		noQuorum   bool
+		rangeLimit bool
+	}{
+		{name: "same logs", quorum: 2, answers: []answer{{logs: good}, {logs: good}, {logs: good}}},
+		{name: "one provider has other data", quorum: 2, answers: []answer{{logs: good}, {logs: data}, {logs: good}}},
+		{name: "one provider failed", quorum: 2, answers: []answer{{err: failed}, {logs: good}, {logs: good}}},
+		{name: "data mismatch", quorum: 2, answers: []answer{{logs: good}, {logs: data}}, noQuorum: true},
+		{name: "emitter mismatch", quorum: 2, answers: []answer{{logs: good}, {logs: other}}, noQuorum: true},
+		{name: "removed mismatch", quorum: 2, answers: []answer{{logs: good}, {logs: removed}}, noQuorum: true},
+		{name: "all different", quorum: 2, answers: []answer{{logs: good}, {logs: data}, {logs: other}}, noQuorum: true},
+		{name: "not enough answers", quorum: 2, answers: []answer{{logs: good}, {err: failed}}, noQuorum: true},
+		{name: "range limit of one provider", quorum: 2, answers: []answer{{logs: good}, {err: tooLarge}}, rangeLimit: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var urls []string
+			for _, a := range tt.answers {
+				urls = append(urls, newProvider(t, a.logs, a.err))
+			}
+
+			pool, err := NewPool(urls, tt.quorum, 0, time.Second, zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			logs, err := pool.EthGetLogs(ethrpc.FilterParams{})
+			switch {
+			case tt.rangeLimit:
+				if !IsRangeLimit(err) {
+					t.Fatalf("err = %v, want range limit", err)
+				}
+			case tt.noQuorum:
+				if !errors.Is(err, ErrNoQuorum) {
+					t.Fatalf("err = %v, want %v", err, ErrNoQuorum)
+				}
+			default:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(logs) != 1 || logs[0].Data != "0x01" || logs[0].Address != emitter {
+					t.Fatalf("logs = %+v", logs)
+				}
+			}
+		})
+	}
+}
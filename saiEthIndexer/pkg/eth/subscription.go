@@ -0,0 +1,170 @@
+package eth
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/onrik/ethrpc"
+)
+
+const (
+	pingPeriod   = 30 * time.Second
+	readTimeout  = 90 * time.Second // connection is dropped if node doesn't answer pings
+	writeTimeout = 10 * time.Second
+)
+
+// Notification - eth_subscription notification
+type Notification struct {
+	Kind   string          // kind of the subscription: newHeads, logs
+	Result json.RawMessage // header or log
+}
+
+// Subscriber - eth_subscribe client over websocket, notifications of all subscriptions come from Next
+type Subscriber struct {
+	conn   *websocket.Conn
+	id     int
+	kinds  map[string]string // kind by subscription id
+	queued []*wsMessage      // notifications received while waiting for subscription response
+	done   chan struct{}
+	once   sync.Once
+}
+
+type wsMessage struct {
+	ID     int              `json:"id"`
+	Method string           `json:"method"`
+	Result json.RawMessage  `json:"result"`
+	Error  *ethrpc.EthError `json:"error"`
+	Params struct {
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+}
+
+func DialSubscriber(url string) (*Subscriber, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("websocket.Dial : %w", err)
+	}
+
+	s := &Subscriber{
+		conn:  conn,
+		kinds: map[string]string{},
+		done:  make(chan struct{}),
+	}
+
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(readTimeout))
+	})
+
+	go s.ping()
+
+	return s, nil
+}
+
+// Subscribe - eth_subscribe to notifications of the kind (newHeads, logs) with optional params
+func (s *Subscriber) Subscribe(kind string, params ...interface{}) error {
+	s.id++
+	request := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      s.id,
+		"method":  "eth_subscribe",
+		"params":  append([]interface{}{kind}, params...),
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	err := s.conn.WriteJSON(request)
+	if err != nil {
+		return fmt.Errorf("WriteJSON : %w", err)
+	}
+
+	for {
+		msg, err := s.read()
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "eth_subscription" {
+			s.queued = append(s.queued, msg)
+			continue
+		}
+		if msg.ID != s.id {
+			continue
+		}
+		if msg.Error != nil {
+			return *msg.Error
+		}
+
+		var id string
+		err = json.Unmarshal(msg.Result, &id)
+		if err != nil {
+			return fmt.Errorf("json.Unmarshal subscription id : %w", err)
+		}
+		s.kinds[id] = kind
+		return nil
+	}
+}
+
+// Next - wait for the next notification, error is returned when connection is lost
+func (s *Subscriber) Next() (Notification, error) {
+	if len(s.queued) > 0 {
+		msg := s.queued[0]
+		s.queued = s.queued[1:]
+		return s.notification(msg), nil
+	}
+
+	for {
+		msg, err := s.read()
+		if err != nil {
+			return Notification{}, err
+		}
+		if msg.Method == "eth_subscription" {
+			return s.notification(msg), nil
+		}
+	}
+}
+
+// Close - close the connection, pending Next returns error
+func (s *Subscriber) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+	})
+	return s.conn.Close()
+}
+
+func (s *Subscriber) read() (*wsMessage, error) {
+	msg := &wsMessage{}
+	err := s.conn.ReadJSON(msg)
+	if err != nil {
+		return nil, fmt.Errorf("ReadJSON : %w", err)
+	}
+	return msg, nil
+}
+
+func (s *Subscriber) notification(msg *wsMessage) Notification {
+	return Notification{
+		Kind:   s.kinds[msg.Params.Subscription],
+		Result: msg.Params.Result,
+	}
+}
+
+// keep connection alive, WriteControl is safe to call concurrently with reads and writes
+func (s *Subscriber) ping() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout))
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package tasks
+
+import (
+	"strings"
+	"time"
+
+	"github.com/saiset-co/saiEthIndexer/config"
+	"github.com/saiset-co/saiEthIndexer/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// Backfill - index the contract from its checkpoint up to the confirmed part of the live cursor.
+// Events of the confirmed blocks are sent at once, worker stops when the contract is taken over by the live worker or deleted.
+// Stopped chain is checked between ranges, so the checkpoint of the scanned range is saved before the worker returns
+func (c *Chain) Backfill(address string) {
+	defer c.workers.Done()
+	defer c.stopBackfill(address)
+
+	r := c.newRangeSize()
+	c.Logger.Info("tasks - Backfill - started", zap.String("address", address))
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			c.Logger.Info("tasks - Backfill - stopped", zap.String("address", address))
+			return
+		default:
+		}
+
+		contract, ok := c.contract(address)
+		cursor, tracked := c.Checkpoints.Get(address)
+		if !ok || !tracked {
+			c.Logger.Info("tasks - Backfill - contract is deleted", zap.String("address", address))
+			return
+		}
+
+		live := c.Checkpoints.LiveCursor()
+		if cursor >= live {
+			c.Logger.Info("tasks - Backfill - contract is taken over by live worker", zap.String("address", address), zap.Int("block", cursor))
+			return
+		}
+
+		// blocks within confirmations are scanned by the live worker
+		target := c.backfillTarget(live)
+		if cursor >= target {
+			c.pause()
+			continue
+		}
+
+		result, to, err := c.scanRange([]config.Contract{contract}, cursor+1, target, r)
+		if err != nil {
+			c.Logger.Error("tasks - Backfill - scan", zap.String("address", address), zap.Int("from", cursor+1), zap.Error(err))
+			c.pause()
+			continue
+		}
+
+		err = c.BlockManager.Notify(result)
+		if err != nil {
+			c.Logger.Error("tasks - Backfill - notify", zap.String("address", address), zap.Int("from", cursor+1), zap.Error(err))
+			c.pause()
+			continue
+		}
+
+		// contract could be deleted during the scan
+		err = c.Checkpoints.Update(address, to)
+		if err != nil {
+			c.Logger.Error("tasks - Backfill - save checkpoint", zap.String("address", address), zap.Error(err))
+			c.pause()
+			continue
+		}
+
+		c.Logger.Sugar().Debugf("contract %s backfilled up to %d from %d", address, to, target)
+	}
+}
+
+// last block which backfill workers scan up to: blocks above it are not released by the live worker yet
+func (c *Chain) backfillTarget(live int) int {
+	target := live - c.Network.Confirmations
+	if target > live-1 {
+		target = live - 1
+	}
+	return target
+}
+
+// start backfill worker of the contract if it is not running
+func (c *Chain) startBackfill(address string) {
+	address = strings.ToLower(address)
+
+	c.backfillsMutex.Lock()
+	defer c.backfillsMutex.Unlock()
+
+	if c.backfills[address] || c.ctx.Err() != nil {
+		return
+	}
+	c.backfills[address] = true
+	metrics.BackfillWorkers.WithLabelValues(c.Network.Name).Inc()
+
+	c.workers.Add(1)
+	go c.Backfill(address)
+}
+
+func (c *Chain) stopBackfill(address string) {
+	c.backfillsMutex.Lock()
+	defer c.backfillsMutex.Unlock()
+
+	delete(c.backfills, strings.ToLower(address))
+	metrics.BackfillWorkers.WithLabelValues(c.Network.Name).Dec()
+}
+
+// wait before retry, at least a second or until the chain is stopped
+func (c *Chain) pause() {
+	sleep := time.Duration(c.Network.BlockTime) * time.Second
+	if sleep < time.Second {
+		sleep = time.Second
+	}
+	c.sleep(sleep)
+}
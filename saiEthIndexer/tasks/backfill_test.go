@@ -0,0 +1,79 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/saiset-co/saiEthIndexer/config"
+)
+
+func TestBackfillResume(t *testing.T) {
+	const address = "0x00000000000000000000000000000000000000aa"
+
+	tests := []struct {
+		name       string
+		checkpoint int
+		stopped    bool // chain is stopped before the worker starts
+		ranges     [][2]int
+		saved      int
+	}{
+		{"from the checkpoint", 40, false, [][2]int{{41, 70}, {71, 99}}, 99},
+		{"taken over by live worker", 100, false, nil, 100},
+		{"stopped", 40, true, nil, 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &fakeNode{head: 200}
+			c := newTestChain(t, node, config.Logs{BlockRange: 30, MaxBlockRange: 30})
+			c.Config.EthContracts.Contracts = []config.Contract{{Address: address, ABI: "[]"}}
+
+			if _, err := c.Checkpoints.Init(100, 0, nil, false); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Checkpoints.Set(address, tt.checkpoint); err != nil {
+				t.Fatal(err)
+			}
+			if tt.stopped {
+				c.cancel()
+			}
+
+			done := make(chan struct{})
+			c.workers.Add(1)
+			go func() {
+				c.Backfill(address)
+				close(done)
+			}()
+
+			// worker waits for new blocks once it is within confirmations of the live cursor
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				if cursor, _ := c.Checkpoints.Get(address); cursor == tt.saved {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("checkpoint is not saved")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			c.Stop()
+			<-done
+
+			node.Lock()
+			defer node.Unlock()
+			if !reflect.DeepEqual(node.ranges, tt.ranges) {
+				t.Fatalf("eth_getLogs ranges = %v, want %v", node.ranges, tt.ranges)
+			}
+
+			loaded, err := NewCheckpoints(c.Checkpoints.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cursor, _ := loaded.Get(address); cursor != tt.saved {
+				t.Fatalf("saved checkpoint = %d, want %d", cursor, tt.saved)
+			}
+		})
+	}
+}
@@ -2,36 +2,34 @@ package tasks
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"github.com/saiset-co/saiEthIndexer/internal/notifier"
-	repository "github.com/saiset-co/saiEthIndexer/internal/repo"
-	"io/ioutil"
-	"log"
 	"math/big"
-	"os"
 	"strconv"
 	"strings"
 
-	"github.com/adam-lavrik/go-imath/ix"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/onrik/ethrpc"
 	"github.com/saiset-co/saiEthIndexer/config"
+	"github.com/saiset-co/saiEthIndexer/internal/metrics"
+	"github.com/saiset-co/saiEthIndexer/internal/notifier"
+	repository "github.com/saiset-co/saiEthIndexer/internal/repo"
 	"go.uber.org/zap"
 )
 
-var startBlock int
-
 type BlockManager struct {
 	config    *config.Configuration
 	repo      repository.Repo
 	notifier  notifier.Notifier
 	logger    *zap.Logger
 	websocket *WebsocketManager
-}
-
-type Block struct {
-	ID int `json:"id"`
+	buffer    *ConfirmationBuffer
+	outbox    *Outbox
+	store     *Outbox     // outbox of the events storage, nil if it is disabled
+	events    *EventStore // events storage
+	network   string
+	chainID   int64
 }
 
 type LogTransfer struct {
@@ -41,18 +39,18 @@ type LogTransfer struct {
 	Tokens big.Int
 }
 
-func NewBlockManager(c config.Configuration, logger *zap.Logger) *BlockManager {
+func NewBlockManager(c config.Configuration, network config.Network, logger *zap.Logger, buffer *ConfirmationBuffer, outbox, store *Outbox, events *EventStore) *BlockManager {
 	manager := &BlockManager{
 		config:    &c,
+		network:   network.Name,
+		chainID:   network.ChainID,
 		logger:    logger,
 		websocket: NewWebSocketManager(c),
-		repo: repository.NewMongoRepo(
-			c.Specific.Storage.Collection,
-			c.Specific.Storage.Email,
-			c.Specific.Storage.Password,
-			c.Specific.Storage.Token,
-			c.Specific.Storage.URL,
-		),
+		buffer:    buffer,
+		outbox:    outbox,
+		store:     store,
+		events:    events,
+		repo:      newRepo(&c),
 		notifier: notifier.NewNotifier(
 			c.Specific.Notifier.SenderID,
 			c.Specific.Notifier.Email,
@@ -65,111 +63,70 @@ func NewBlockManager(c config.Configuration, logger *zap.Logger) *BlockManager {
 	return manager
 }
 
-func (bm *BlockManager) GetLastBlock(id int) (*Block, error) {
-	block := Block{ID: id}
-	pwd, err := os.Getwd()
-	if err != nil {
-		bm.logger.Error("tasks - BlockManager - get currect directory", zap.Error(err))
-		return &block, nil
-	}
-
-	data, err := ioutil.ReadFile(pwd + "/block.data")
-	if err != nil {
-		bm.logger.Error("tasks - BlockManager - read file", zap.Error(err))
-		return &block, nil
-	}
-
-	lastDataBlock, strErr := strconv.Atoi(string(data))
-
-	if strErr != nil {
-		log.Println("Data from file can't be converted to int:", err)
-		return &block, nil
-	}
-
-	var lastBlocks []int
-	for i := 0; i < len(bm.config.EthContracts.Contracts); i++ {
-		lastBlocks = append(lastBlocks, bm.config.EthContracts.Contracts[i].StartBlock)
-	}
-
-	if lastDataBlock > 0 {
-		startBlock = lastDataBlock
-	} else if len(lastBlocks) > 0 {
-		startBlock = ix.MinSlice(lastBlocks)
-	} else if bm.config.StartBlock > 0 {
-		startBlock = bm.config.StartBlock
-	} else {
-		startBlock = id
-	}
-
-	return &Block{ID: startBlock}, nil
+func newRepo(c *config.Configuration) repository.Repo {
+	return repository.NewMongoRepo(
+		c.Specific.Storage.Collection,
+		c.Specific.Storage.Email,
+		c.Specific.Storage.Password,
+		c.Specific.Storage.Token,
+		c.Specific.Storage.URL,
+	)
 }
 
-func (bm *BlockManager) SetLastBlock(blk *Block) error {
-	pwd, err := os.Getwd()
-
-	if err != nil {
-		bm.logger.Error("tasks - BlockManager - set last block - read currect directory", zap.Error(err))
-		return err
-	}
-
-	lastBlock := strconv.Itoa(blk.ID)
-	err = ioutil.WriteFile(pwd+"/block.data", []byte(lastBlock), 0777)
-	if err != nil {
-		bm.logger.Error("tasks - BlockManager - set last block - write to file", zap.Error(err))
-	}
-
-	bm.logger.Sugar().Debugf("block %d was saved to the temp file", blk.ID)
-	return nil
+func (bm *BlockManager) HandleReceipts(receipt *ethrpc.TransactionReceipt, _abi abi.ABI) ([]map[string]interface{}, error) {
+	return bm.decodeLogs(receipt.Logs, _abi), nil
 }
 
-func (bm *BlockManager) HandleReceipts(receipt *ethrpc.TransactionReceipt, _abi abi.ABI) ([]map[string]interface{}, error) {
+// events of the abi decoded from the logs, logs of unknown events are skipped
+func (bm *BlockManager) decodeLogs(logs []ethrpc.Log, _abi abi.ABI) []map[string]interface{} {
 	var events []map[string]interface{}
 
-	for _, l := range receipt.Logs {
-		id := common.HexToHash(l.Topics[0])
-		_event, eventErr := _abi.EventByID(id)
-		if eventErr != nil {
+	for _, l := range logs {
+		if len(l.Topics) == 0 {
 			continue
 		}
 
-		data := map[string]interface{}{}
-		event := map[string]interface{}{}
-
-		d, _ := hex.DecodeString(l.Data[2:])
-		unpackErr := _event.Inputs.UnpackIntoMap(data, d)
-
-		if unpackErr != nil {
-			fmt.Println("can't unpack event:", unpackErr)
+		_event, err := _abi.EventByID(common.HexToHash(l.Topics[0]))
+		if err != nil {
 			continue
 		}
 
-		for eventId, eventData := range _event.Inputs {
-			if eventData.Indexed {
-				data[eventData.Name] = l.Topics[eventId+1]
-			}
+		data, err := decodeEvent(_event, l)
+		if err != nil {
+			bm.logger.Error("block manager - decode logs - unpack event", zap.String("event", _event.Name), zap.String("tx_hash", l.TransactionHash), zap.Int("log_index", l.LogIndex), zap.Error(err))
+			continue
 		}
 
-		data["name"] = _event.Name
+		// event is a reserved word of solidity, so no argument is overwritten by the name
+		data["event"] = _event.Name
 
-		event["Data"] = data
-		event["Log"] = l
-
-		events = append(events, event)
+		events = append(events, map[string]interface{}{
+			"Data": data,
+			"Log":  l,
+		})
 	}
 
-	return events, nil
+	return events
 }
 
-func (bm *BlockManager) HandleTransactions(trs []ethrpc.Transaction, receipts map[string]*ethrpc.TransactionReceipt) {
+// HandleTransactions - transactions of the watched contracts with operations or events of the contract to notify the bridge about
+func (bm *BlockManager) HandleTransactions(contracts []config.Contract, trs []ethrpc.Transaction, receipts map[string]*ethrpc.TransactionReceipt) []map[string]interface{} {
+	var notifications []map[string]interface{}
+
 	for j := 0; j < len(trs); j++ {
-		for i := 0; i < len(bm.config.EthContracts.Contracts); i++ {
-			status, _ := strconv.ParseBool(receipts[trs[j].Hash].Status[2:])
+		for i := 0; i < len(contracts); i++ {
+			if strings.ToLower(trs[j].From) != strings.ToLower(contracts[i].Address) && strings.ToLower(trs[j].To) != strings.ToLower(contracts[i].Address) {
+				continue
+			}
 
-			if bm.config.SkipFailedTransactions && !status {
+			receipt, ok := receipts[trs[j].Hash]
+			if !ok {
 				continue
 			}
 
-			if strings.ToLower(trs[j].From) != strings.ToLower(bm.config.EthContracts.Contracts[i].Address) && strings.ToLower(trs[j].To) != strings.ToLower(bm.config.EthContracts.Contracts[i].Address) {
+			status, _ := strconv.ParseBool(receipt.Status[2:])
+
+			if bm.config.SkipFailedTransactions && !status {
 				continue
 			}
 
@@ -182,82 +139,293 @@ func (bm *BlockManager) HandleTransactions(trs []ethrpc.Transaction, receipts ma
 			//	continue
 			//}
 
-			decodedSig, err := hex.DecodeString(trs[j].Input[2:10])
-
+			_abi, err := abi.JSON(strings.NewReader(contracts[i].ABI))
 			if err != nil {
-				bm.logger.Error("block manager - handle transaction - decode transaction function idintifier", zap.String("transaction hash", trs[j].Hash), zap.Error(err))
+				bm.logger.Error("block manager - handle transaction - parse abi from config", zap.String("address", contracts[i].Address), zap.Error(err))
 				continue
 			}
 
-			_abi, err := abi.JSON(strings.NewReader(bm.config.EthContracts.Contracts[i].ABI))
+			// logs emitted by the other contracts called by the transaction are not events of this contract
+			events := bm.decodeLogs(contractLogs(receipt.Logs, contracts[i].Address), _abi)
+
+			// transaction with the configured events is notified regardless of its method
+			names := bm.config.ContractEvents(contracts[i])
+			events = filterEvents(events, names)
+			notify := len(names) != 0 && len(events) != 0
+
+			method, decodedInput, err := decodeInput(&trs[j], _abi)
 			if err != nil {
-				bm.logger.Error("block manager - handle transaction - parse abi from config", zap.String("address", bm.config.EthContracts.Contracts[i].Address), zap.Error(err))
+				if !notify {
+					bm.logger.Error("block manager - handle transaction - decode input", zap.String("transaction hash", trs[j].Hash), zap.Error(err))
+					continue
+				}
+				decodedInput = map[string]interface{}{}
+			}
+
+			for _, operation := range bm.config.ContractOperations(contracts[i]) {
+				if method != nil && operation == method.Name {
+					notify = true
+				}
+			}
+			if !notify {
 				continue
 			}
 
-			events, trErr := bm.HandleReceipts(receipts[trs[j].Hash], _abi)
+			notifications = append(notifications, bm.notification(contracts[i].Address, &trs[j], events, status, method, decodedInput))
 
-			if trErr != nil {
-				bm.logger.Error("block manager - handle transaction events - HandleReceipts", zap.String("transaction hash", trs[j].Hash), zap.Error(trErr))
-				continue
+			//err = bm.repo.Create(data)
+			//if err != nil {
+			//	bm.logger.Error("block manager - handle transaction - bm.repo.Create", zap.String("tx_hash", trs[j].Hash), zap.Error(err))
+			//	continue
+			//}
+
+			bm.logger.Sugar().Infof("%d transaction from %s to %s has been updated.\n", trs[j].TransactionIndex, trs[j].From, trs[j].To)
+		}
+	}
+
+	return notifications
+}
+
+// logs emitted by the contract
+func contractLogs(logs []ethrpc.Log, address string) []ethrpc.Log {
+	var emitted []ethrpc.Log
+	for _, l := range logs {
+		if strings.EqualFold(l.Address, address) {
+			emitted = append(emitted, l)
+		}
+	}
+	return emitted
+}
+
+// events with the names, all events if names are empty
+func filterEvents(events []map[string]interface{}, names []string) []map[string]interface{} {
+	if len(names) == 0 {
+		return events
+	}
+
+	var filtered []map[string]interface{}
+	for _, event := range events {
+		data, _ := event["Data"].(map[string]interface{})
+		for _, name := range names {
+			if data["event"] == name {
+				filtered = append(filtered, event)
+				break
 			}
+		}
+	}
+	return filtered
+}
 
-			method, err := _abi.MethodById(decodedSig)
-			if err != nil {
-				bm.logger.Error("block manager - handle transaction - MethodById", zap.String("transaction hash", trs[j].Hash), zap.Error(err))
-				continue
+// method of the abi called by the transaction with decoded arguments
+func decodeInput(tr *ethrpc.Transaction, _abi abi.ABI) (*abi.Method, map[string]interface{}, error) {
+	if len(tr.Input) < 10 {
+		return nil, nil, errors.New("transaction does not call a method")
+	}
+
+	decodedData, err := hex.DecodeString(tr.Input[2:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("DecodeString : %w", err)
+	}
+
+	method, err := _abi.MethodById(decodedData[:4])
+	if err != nil {
+		return nil, nil, fmt.Errorf("MethodById : %w", err)
+	}
+
+	decodedInput := map[string]interface{}{}
+	err = method.Inputs.UnpackIntoMap(decodedInput, decodedData[4:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("UnpackIntoMap : %w", err)
+	}
+
+	for name, value := range decodedInput {
+		decodedInput[name] = jsonValue(value)
+	}
+
+	return method, decodedInput, nil
+}
+
+// notification about the transaction of the watched contract
+func (bm *BlockManager) notification(contract string, tr *ethrpc.Transaction, events []map[string]interface{}, status bool, method *abi.Method, input map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"Id":        notificationID(bm.chainID, contract, tr, events),
+		"ChainId":   bm.chainID,
+		"Contract":  contract,
+		"Number":    tr.BlockNumber,
+		"Hash":      tr.Hash,
+		"From":      tr.From,
+		"To":        tr.To,
+		"Amount":    tr.Value.String(),
+		"Events":    events,
+		"Status":    status,
+		"Operation": method,
+		"Input":     input,
+	}
+}
+
+// deterministic id of the notification : chain id and transaction hash with index of its first log,
+// transaction without events is identified by the contract
+func notificationID(chainID int64, contract string, tr *ethrpc.Transaction, events []map[string]interface{}) string {
+	for _, event := range events {
+		if l, ok := event["Log"].(ethrpc.Log); ok {
+			return fmt.Sprintf("%d:%s:%d", chainID, strings.ToLower(tr.Hash), l.LogIndex)
+		}
+	}
+	return fmt.Sprintf("%d:%s:%s", chainID, strings.ToLower(tr.Hash), strings.ToLower(contract))
+}
+
+// AddBlock - hold block events in the confirmation buffer
+func (bm *BlockManager) AddBlock(blkInfo *ethrpc.Block, events []map[string]interface{}) error {
+	return bm.buffer.Add(&BlockRecord{
+		Number:     blkInfo.Number,
+		Hash:       blkInfo.Hash,
+		ParentHash: blkInfo.ParentHash,
+		Events:     events,
+	})
+}
+
+// ReleaseConfirmed - enqueue events from blocks with enough confirmations to the outbox
+func (bm *BlockManager) ReleaseConfirmed(head int) error {
+	defer func() {
+		metrics.PendingEvents.WithLabelValues(bm.network).Set(float64(bm.buffer.Pending()))
+	}()
+
+	return bm.buffer.Release(head, func(blk *BlockRecord) error {
+		return bm.send(blk.Number, blk.Events)
+	})
+}
+
+// Notify - enqueue events of the confirmed blocks to the outbox at once, in block order
+func (bm *BlockManager) Notify(result *scanResult) error {
+	for _, blk := range result.blocks {
+		err := bm.send(blk.Number, result.events[blk.Number])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replay - enqueue notifications of the scanned confirmed blocks to the outbox again, flagged as replays of the replay id.
+// Events storage gets the original notifications, so only the missing ones are stored
+func (bm *BlockManager) Replay(result *scanResult, replayID string) error {
+	for _, blk := range result.blocks {
+		events := result.events[blk.Number]
+		if len(events) == 0 {
+			continue
+		}
+
+		replays := make([]map[string]interface{}, 0, len(events))
+		for _, event := range events {
+			replay := make(map[string]interface{}, len(event)+2)
+			for key, value := range event {
+				replay[key] = value
 			}
+			replay["Replay"] = true
+			replay["ReplayId"] = replayID
+			replays = append(replays, replay)
+		}
 
-			decodedData, err := hex.DecodeString(trs[j].Input[2:])
+		err := bm.outbox.Add(blk.Number, replays)
+		if err != nil {
+			return fmt.Errorf("block %d : outbox.Add : %w", blk.Number, err)
+		}
 
+		if bm.store != nil {
+			err = bm.store.Add(blk.Number, events)
 			if err != nil {
-				bm.logger.Error("block manager - handle transaction - decode input", zap.String("transaction hash", trs[j].Hash), zap.Error(err))
-				continue
+				return fmt.Errorf("block %d : store.Add : %w", blk.Number, err)
 			}
+		}
+	}
+	return nil
+}
 
-			decodedInput := map[string]interface{}{}
-			err = method.Inputs.UnpackIntoMap(decodedInput, decodedData[4:])
+// Merge - add events of the contract handed over to the live worker to the confirmation buffer,
+// events of the blocks which are already released are enqueued to the outbox at once
+func (bm *BlockManager) Merge(result *scanResult) error {
+	defer func() {
+		metrics.PendingEvents.WithLabelValues(bm.network).Set(float64(bm.buffer.Pending()))
+	}()
 
+	for _, blk := range result.blocks {
+		events := result.events[blk.Number]
+		if len(events) == 0 {
+			continue
+		}
+
+		released, err := bm.buffer.Merge(&BlockRecord{
+			Number:     blk.Number,
+			Hash:       blk.Hash,
+			ParentHash: blk.ParentHash,
+			Events:     events,
+		})
+		if err != nil {
+			return fmt.Errorf("buffer.Merge : %w", err)
+		}
+
+		if released {
+			err = bm.send(blk.Number, events)
 			if err != nil {
-				bm.logger.Error("block manager - handle transaction - UnpackIntoMap", zap.String("transaction hash", trs[j].Hash), zap.Error(err))
-				continue
+				return err
 			}
+		}
+	}
+	return nil
+}
 
-			data := map[string]interface{}{
-				"Number":    trs[j].BlockNumber,
-				"Hash":      trs[j].Hash,
-				"From":      trs[j].From,
-				"To":        trs[j].To,
-				"Amount":    trs[j].Value.Int64(),
-				"Events":    events,
-				"Status":    status,
-				"Operation": method,
-				"Input":     decodedInput,
-			}
+func (bm *BlockManager) send(number int, events []map[string]interface{}) error {
+	if len(events) == 0 {
+		return nil
+	}
 
-			for _, operation := range bm.config.Operations {
-				if operation == method.Name {
-					//err = bm.websocket.SendMessage(string(raw), bm.config.WebSocket.Token)
-					//if err != nil {
-					//	bm.logger.Error("block manager - handle transaction - SendWebSocketMsg", zap.String("transaction hash", trs[j].Hash), zap.Error(err))
-					//	continue
-					//}
-
-					err = bm.notifier.SendTx(data)
-					if err != nil {
-						bm.logger.Error("block manager - send notification - bm.notifier.SendTx", zap.String("tx_hash", trs[j].Hash), zap.Error(err))
-						continue
-					}
-				}
-			}
+	err := bm.outbox.Add(number, events)
+	if err != nil {
+		return fmt.Errorf("block %d : outbox.Add : %w", number, err)
+	}
 
-			//err = bm.repo.Create(data)
-			//if err != nil {
-			//	bm.logger.Error("block manager - handle transaction - bm.repo.Create", zap.String("tx_hash", trs[j].Hash), zap.Error(err))
-			//	continue
-			//}
+	if bm.store != nil {
+		err = bm.store.Add(number, events)
+		if err != nil {
+			return fmt.Errorf("block %d : store.Add : %w", number, err)
+		}
+	}
+	return nil
+}
 
-			bm.logger.Sugar().Infof("%d transaction from %s to %s has been updated.\n", trs[j].TransactionIndex, trs[j].From, trs[j].To)
+// Dispatch - deliver events of the outbox to the bridge and to the events storage
+func (bm *BlockManager) Dispatch() {
+	if bm.store != nil {
+		go bm.store.Dispatch(bm.events, bm.logger)
+	}
+	bm.outbox.Dispatch(bm.notifier, bm.logger)
+}
+
+// Rollback - remove blocks above fork block, events of removed blocks are cancelled in the outbox or retracted if delivered
+func (bm *BlockManager) Rollback(fork int) error {
+	orphaned, err := bm.buffer.Rollback(fork)
+	metrics.Reorg(bm.network, len(orphaned))
+	metrics.PendingEvents.WithLabelValues(bm.network).Set(float64(bm.buffer.Pending()))
+
+	for _, blk := range orphaned {
+		bm.logger.Warn("block manager - rollback - orphaned block", zap.Int("number", blk.Number), zap.String("hash", blk.Hash), zap.Bool("released", blk.Released))
+
+		if !blk.Released {
+			continue
+		}
+
+		retractErr := bm.outbox.Retract(blk.Number, blk.Events)
+		if retractErr != nil {
+			bm.logger.Error("block manager - rollback - bm.outbox.Retract", zap.Int("number", blk.Number), zap.Error(retractErr))
+		}
+
+		if bm.store != nil {
+			retractErr = bm.store.Retract(blk.Number, blk.Events)
+			if retractErr != nil {
+				bm.logger.Error("block manager - rollback - bm.store.Retract", zap.Int("number", blk.Number), zap.Error(retractErr))
+			}
 		}
 	}
+
+	return err
 }
@@ -0,0 +1,212 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saiset-co/saiEthIndexer/config"
+	"github.com/saiset-co/saiEthIndexer/internal/metrics"
+	"github.com/saiset-co/saiEthIndexer/pkg/eth"
+	"go.uber.org/zap"
+)
+
+const defaultHealthCheck = 15 * time.Second
+
+// Chain - indexer of one EVM network with its own workers, confirmation buffer, checkpoints and outbox
+type Chain struct {
+	Config       *config.Configuration
+	Network      config.Network
+	EthClient    *eth.Pool
+	Logger       *zap.Logger
+	BlockManager *BlockManager
+	Checkpoints  *Checkpoints
+	primary      bool          // contracts without network, files of the single network versions
+	wake         chan struct{} // new head or logs notification, loop doesn't wait for sleep
+	resubscribe  chan struct{} // contracts are changed, logs subscription is renewed
+
+	ctx     context.Context // cancelled on stop, workers return between ranges
+	cancel  context.CancelFunc
+	workers sync.WaitGroup // live and backfill workers
+
+	backfillsMutex sync.Mutex
+	backfills      map[string]bool // contracts with running backfill workers
+}
+
+func NewChain(config *config.Configuration, network config.Network, primary bool, logger *zap.Logger) (*Chain, error) {
+	logger = logger.With(zap.String("network", network.Name))
+
+	rpc := config.Specific.RPC
+	ethClient, err := eth.NewPool(network.RPC, network.Quorum, rpc.MaxLag, time.Duration(rpc.Timeout)*time.Second, logger)
+	if err != nil {
+		return nil, fmt.Errorf("rpc pool : %w", err)
+	}
+
+	if network.ChainID == 0 {
+		network.ChainID, err = eth.ChainID(ethClient)
+		if err != nil {
+			return nil, fmt.Errorf("chain id : %w", err)
+		}
+	}
+
+	buffer, err := NewConfirmationBuffer(networkPath(pendingBlocksPath, network.Name, primary), network.Confirmations)
+	if err != nil {
+		return nil, fmt.Errorf("confirmation buffer : %w", err)
+	}
+
+	checkpoints, err := NewCheckpoints(networkPath(checkpointsPath, network.Name, primary))
+	if err != nil {
+		return nil, fmt.Errorf("checkpoints : %w", err)
+	}
+
+	outbox, err := NewOutbox(networkPath(outboxPath, network.Name, primary), network.Name, sinkBridge)
+	if err != nil {
+		return nil, fmt.Errorf("outbox : %w", err)
+	}
+
+	var store *Outbox
+	var events *EventStore
+	if config.Specific.Storage.Enabled {
+		store, err = NewOutbox(networkPath(storeOutboxPath, network.Name, primary), network.Name, sinkStorage)
+		if err != nil {
+			return nil, fmt.Errorf("storage outbox : %w", err)
+		}
+		events = NewEventStore(newRepo(config), network.Name)
+	}
+
+	logger.Info("tasks - NewChain - network", zap.Int64("chain_id", network.ChainID), zap.Int("confirmations", network.Confirmations), zap.Bool("primary", primary))
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Chain{
+		Config:       config,
+		Network:      network,
+		EthClient:    ethClient,
+		Logger:       logger,
+		BlockManager: NewBlockManager(*config, network, logger, buffer, outbox, store, events),
+		Checkpoints:  checkpoints,
+		primary:      primary,
+		wake:         make(chan struct{}, 1),
+		resubscribe:  make(chan struct{}, 1),
+		backfills:    map[string]bool{},
+		ctx:          ctx,
+		cancel:       cancel,
+	}, nil
+}
+
+// Run - index the network in the configured mode
+func (c *Chain) Run() {
+	if c.Config.Specific.Subscription.Enabled && c.Network.WebSocket != "" {
+		go c.Subscribe()
+	}
+
+	go c.BlockManager.Dispatch()
+	go c.HealthCheck()
+
+	c.workers.Add(1)
+	defer c.workers.Done()
+	c.ProcessLive()
+}
+
+// Stop - stop live and backfill workers after their current ranges
+func (c *Chain) Stop() {
+	c.cancel()
+	c.workers.Wait()
+}
+
+// sleep for d, false if the chain is stopped
+func (c *Chain) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+// HealthCheck - check providers of the network periodically, unavailable and lagging providers are skipped
+func (c *Chain) HealthCheck() {
+	interval := time.Duration(c.Config.Specific.RPC.HealthCheck) * time.Second
+	if interval <= 0 {
+		interval = defaultHealthCheck
+	}
+
+	for {
+		for _, status := range c.EthClient.Status() {
+			metrics.ProviderStatus(c.Network.Name, status.Name, status.Up, status.Latency, status.Head)
+		}
+
+		time.Sleep(interval)
+		c.EthClient.Check()
+	}
+}
+
+// wait for the next loop : block time or notification of the subscription
+func (c *Chain) wait() {
+	timer := time.NewTimer(time.Duration(c.Network.BlockTime) * time.Second)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-c.wake:
+	case <-c.ctx.Done():
+	}
+}
+
+// find the last stored block which is still in the canonical chain and roll back blocks above it
+func (c *Chain) handleReorg(number int) (int, error) {
+	fork := number - 1
+	found := false
+
+	for _, n := range c.BlockManager.buffer.Numbers() {
+		if n >= number {
+			continue
+		}
+
+		stored, ok := c.BlockManager.buffer.Get(n)
+		if !ok {
+			continue
+		}
+
+		canonical, err := c.EthClient.EthGetBlockByNumber(n, false)
+		if err != nil {
+			return 0, fmt.Errorf("EthGetBlockByNumber : %w", err)
+		}
+
+		if canonical != nil && canonical.Hash == stored.Hash {
+			found = true
+			break
+		}
+
+		fork = n - 1
+	}
+
+	if !found {
+		c.Logger.Error("tasks - handleReorg - reorg is deeper than stored block history", zap.Int("fork", fork))
+	}
+
+	c.Logger.Warn("tasks - handleReorg - roll back", zap.Int("fork", fork), zap.Int("orphaned", number-1-fork))
+
+	err := c.BlockManager.Rollback(fork)
+	if err != nil {
+		return 0, fmt.Errorf("Rollback : %w", err)
+	}
+
+	return fork, nil
+}
+
+// file of the network : primary network keeps files of the single network versions, others get network name suffix
+func networkPath(path, network string, primary bool) string {
+	if primary {
+		return path
+	}
+
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + network + ext
+}
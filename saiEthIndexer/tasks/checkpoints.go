@@ -0,0 +1,183 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	checkpointsPath = "./checkpoints.json"
+	legacyBlockPath = "./block.data" // global cursor of the previous versions
+)
+
+// Checkpoints - last scanned block of every contract.
+// Contracts at the live cursor are indexed by the live worker, contracts behind it by their backfill workers
+type Checkpoints struct {
+	sync.Mutex
+	path      string
+	loaded    bool
+	Live      int            `json:"live"`      // last block scanned by the live worker
+	Contracts map[string]int `json:"contracts"` // last block scanned for the contract by lowercase address
+}
+
+// load checkpoints from file, empty checkpoints if file does not exist
+func NewCheckpoints(path string) (*Checkpoints, error) {
+	c := &Checkpoints{
+		path:      path,
+		Contracts: map[string]int{},
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c, nil
+		}
+		return nil, fmt.Errorf("ReadFile : %w", err)
+	}
+
+	err = jsoniter.Unmarshal(data, c)
+	if err != nil {
+		return nil, fmt.Errorf("Unmarshal : %w", err)
+	}
+	if c.Contracts == nil {
+		c.Contracts = map[string]int{}
+	}
+	c.loaded = true
+
+	return c, nil
+}
+
+// Init - set live cursor of the new checkpoints. Cursor of the previous versions is taken over by the live worker
+// with all the contracts if migrate is set, otherwise indexing starts from the start block of the config or from the head
+func (c *Checkpoints) Init(head, startBlock int, addresses []string, migrate bool) (bool, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.loaded {
+		return false, nil
+	}
+
+	c.Live = head
+	if startBlock > 0 {
+		c.Live = startBlock - 1
+	}
+
+	legacy := false
+	data, err := os.ReadFile(legacyBlockPath)
+	if migrate && err == nil {
+		block, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err == nil && block > 0 {
+			c.Live = block
+			for _, address := range addresses {
+				c.Contracts[strings.ToLower(address)] = block
+			}
+			legacy = true
+		}
+	}
+
+	c.loaded = true
+	return legacy, c.save()
+}
+
+// LiveCursor - last block scanned by the live worker
+func (c *Checkpoints) LiveCursor() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.Live
+}
+
+// Get - last scanned block of the contract
+func (c *Checkpoints) Get(address string) (int, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	block, ok := c.Contracts[strings.ToLower(address)]
+	return block, ok
+}
+
+// IsLive - contract is indexed by the live worker
+func (c *Checkpoints) IsLive(address string) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	block, ok := c.Contracts[strings.ToLower(address)]
+	return ok && block >= c.Live
+}
+
+// Set - store last scanned block of the contract
+func (c *Checkpoints) Set(address string, block int) error {
+	c.Lock()
+	defer c.Unlock()
+
+	c.Contracts[strings.ToLower(address)] = block
+	return c.save()
+}
+
+// Update - store last scanned block of the contract if it is not deleted
+func (c *Checkpoints) Update(address string, block int) error {
+	c.Lock()
+	defer c.Unlock()
+
+	address = strings.ToLower(address)
+	if _, ok := c.Contracts[address]; !ok {
+		return nil
+	}
+
+	c.Contracts[address] = block
+	return c.save()
+}
+
+// Advance - move live cursor with the cursors of the contracts scanned by the live worker
+func (c *Checkpoints) Advance(block int, addresses []string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	c.Live = block
+	for _, address := range addresses {
+		address = strings.ToLower(address)
+		if _, ok := c.Contracts[address]; ok {
+			c.Contracts[address] = block
+		}
+	}
+	return c.save()
+}
+
+// Retain - remove checkpoints of the deleted contracts
+func (c *Checkpoints) Retain(addresses []string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	keep := map[string]bool{}
+	for _, address := range addresses {
+		keep[strings.ToLower(address)] = true
+	}
+
+	removed := false
+	for address := range c.Contracts {
+		if !keep[address] {
+			delete(c.Contracts, address)
+			removed = true
+		}
+	}
+
+	if !removed {
+		return nil
+	}
+	return c.save()
+}
+
+func (c *Checkpoints) save() error {
+	data, err := jsoniter.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshal : %w", err)
+	}
+
+	return writeFileAtomic(c.path, data)
+}
@@ -0,0 +1,110 @@
+package tasks
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckpointsResume(t *testing.T) {
+	const a, b = "0x00000000000000000000000000000000000000AA", "0x00000000000000000000000000000000000000bb"
+
+	tests := []struct {
+		name      string
+		run       func(c *Checkpoints) error // changes of the previous run
+		live      int
+		contracts map[string]int
+		isLive    []bool // a, b
+	}{
+		{
+			name: "contract behind the live cursor",
+			run: func(c *Checkpoints) error {
+				if err := c.Set(a, 40); err != nil {
+					return err
+				}
+				return c.Set(b, 100)
+			},
+			live:      100,
+			contracts: map[string]int{"0x00000000000000000000000000000000000000aa": 40, b: 100},
+			isLive:    []bool{false, true},
+		},
+		{
+			name: "live worker moves only its contracts",
+			run: func(c *Checkpoints) error {
+				if err := c.Set(a, 40); err != nil {
+					return err
+				}
+				if err := c.Set(b, 100); err != nil {
+					return err
+				}
+				return c.Advance(120, []string{a, b})
+			},
+			live:      120,
+			contracts: map[string]int{"0x00000000000000000000000000000000000000aa": 120, b: 120},
+			isLive:    []bool{true, true},
+		},
+		{
+			name: "backfill of the deleted contract is not saved",
+			run: func(c *Checkpoints) error {
+				if err := c.Set(a, 40); err != nil {
+					return err
+				}
+				if err := c.Set(b, 100); err != nil {
+					return err
+				}
+				if err := c.Retain([]string{b}); err != nil {
+					return err
+				}
+				return c.Update(a, 70)
+			},
+			live:      100,
+			contracts: map[string]int{b: 100},
+			isLive:    []bool{false, true},
+		},
+		{
+			name: "backfill range is saved",
+			run: func(c *Checkpoints) error {
+				if err := c.Set(a, 40); err != nil {
+					return err
+				}
+				return c.Update(a, 70)
+			},
+			live:      100,
+			contracts: map[string]int{"0x00000000000000000000000000000000000000aa": 70},
+			isLive:    []bool{false, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "checkpoints.json")
+
+			c, err := NewCheckpoints(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = c.Init(100, 0, nil, false); err != nil {
+				t.Fatal(err)
+			}
+			if err = tt.run(c); err != nil {
+				t.Fatal(err)
+			}
+
+			// cursors of the previous run are kept, head is not taken again
+			loaded, err := NewCheckpoints(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if migrated, err := loaded.Init(500, 0, nil, false); err != nil || migrated {
+				t.Fatalf("Init = %v, %v", migrated, err)
+			}
+
+			if loaded.LiveCursor() != tt.live || !reflect.DeepEqual(loaded.Contracts, tt.contracts) {
+				t.Fatalf("live = %d, contracts = %v, want %d, %v", loaded.LiveCursor(), loaded.Contracts, tt.live, tt.contracts)
+			}
+			if isLive := []bool{loaded.IsLive(a), loaded.IsLive(b)}; !reflect.DeepEqual(isLive, tt.isLive) {
+				t.Fatalf("is live = %v, want %v", isLive, tt.isLive)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	pendingBlocksPath = "./pending_blocks.json"
+
+	// released blocks kept to detect reorgs deeper than confirmation depth
+	blockHistory = 128
+)
+
+// BlockRecord - scanned block with the events found in it
+type BlockRecord struct {
+	Number     int                      `json:"number"`
+	Hash       string                   `json:"hash"`
+	ParentHash string                   `json:"parent_hash"`
+	Events     []map[string]interface{} `json:"events"`
+	Released   bool                     `json:"released"` // events were sent to the bridge
+}
+
+// ConfirmationBuffer - recent blocks ordered by number, events are held until block gets enough confirmations
+type ConfirmationBuffer struct {
+	sync.Mutex
+	path   string
+	depth  int
+	blocks []*BlockRecord
+}
+
+// load buffer from file, empty buffer if file does not exist
+func NewConfirmationBuffer(path string, depth int) (*ConfirmationBuffer, error) {
+	if depth < 0 {
+		depth = 0
+	}
+
+	b := &ConfirmationBuffer{
+		path:   path,
+		depth:  depth,
+		blocks: make([]*BlockRecord, 0),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return b, nil
+		}
+		return nil, fmt.Errorf("ReadFile : %w", err)
+	}
+
+	err = jsoniter.Unmarshal(data, &b.blocks)
+	if err != nil {
+		return nil, fmt.Errorf("Unmarshal : %w", err)
+	}
+
+	return b, nil
+}
+
+// Get - stored block by number
+func (b *ConfirmationBuffer) Get(number int) (*BlockRecord, bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, blk := range b.blocks {
+		if blk.Number == number {
+			return blk, true
+		}
+	}
+
+	return nil, false
+}
+
+// IsReorg - true if parent of the block differs from the stored previous block
+func (b *ConfirmationBuffer) IsReorg(number int, parentHash string) bool {
+	prev, ok := b.Get(number - 1)
+	if !ok {
+		return false
+	}
+
+	return prev.Hash != parentHash
+}
+
+// Numbers - stored block numbers, newest first
+func (b *ConfirmationBuffer) Numbers() []int {
+	b.Lock()
+	defer b.Unlock()
+
+	numbers := make([]int, 0, len(b.blocks))
+	for i := len(b.blocks) - 1; i >= 0; i-- {
+		numbers = append(numbers, b.blocks[i].Number)
+	}
+
+	return numbers
+}
+
+// Add - store scanned block, blocks with the same or higher number are replaced.
+// Already stored block with the same hash is kept as is
+func (b *ConfirmationBuffer) Add(blk *BlockRecord) error {
+	b.Lock()
+	defer b.Unlock()
+
+	// block is scanned again, keep it with release status
+	kept := b.cut(blk.Number)
+	if len(kept) > 0 && kept[len(kept)-1].Number == blk.Number && kept[len(kept)-1].Hash == blk.Hash {
+		return nil
+	}
+
+	b.blocks = b.cut(blk.Number - 1)
+	b.blocks = append(b.blocks, blk)
+
+	return b.save()
+}
+
+// Rollback - remove blocks above fork block, removed blocks are returned
+func (b *ConfirmationBuffer) Rollback(fork int) ([]*BlockRecord, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	kept := b.cut(fork)
+	orphaned := append([]*BlockRecord{}, b.blocks[len(kept):]...)
+	b.blocks = kept
+
+	return orphaned, b.save()
+}
+
+// Release - send events of blocks with enough confirmations in block order.
+// Releasing stops on the first failed block, so it is retried on the next call
+func (b *ConfirmationBuffer) Release(head int, send func(blk *BlockRecord) error) error {
+	b.Lock()
+	defer b.Unlock()
+
+	var err error
+	for _, blk := range b.blocks {
+		if blk.Released {
+			continue
+		}
+
+		if head-blk.Number < b.depth {
+			break
+		}
+
+		err = send(blk)
+		if err != nil {
+			break
+		}
+
+		blk.Released = true
+	}
+
+	b.prune(head)
+
+	if saveErr := b.save(); err == nil {
+		err = saveErr
+	}
+
+	return err
+}
+
+// Merge - add events of the block scanned for the contract handed over to the live worker.
+// Block which is not stored yet is inserted in order, true is returned if block is already released and events should be sent at once
+func (b *ConfirmationBuffer) Merge(blk *BlockRecord) (bool, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	i := 0
+	for i < len(b.blocks) && b.blocks[i].Number < blk.Number {
+		i++
+	}
+
+	if i < len(b.blocks) && b.blocks[i].Number == blk.Number {
+		stored := b.blocks[i]
+		if stored.Hash != blk.Hash {
+			return false, fmt.Errorf("block %d : hash %s differs from stored %s", blk.Number, blk.Hash, stored.Hash)
+		}
+		if stored.Released {
+			return true, nil
+		}
+		stored.Events = append(stored.Events, blk.Events...)
+		return false, b.save()
+	}
+
+	b.blocks = append(b.blocks[:i], append([]*BlockRecord{blk}, b.blocks[i:]...)...)
+	return false, b.save()
+}
+
+// Pending - number of events waiting for confirmations
+func (b *ConfirmationBuffer) Pending() int {
+	b.Lock()
+	defer b.Unlock()
+
+	pending := 0
+	for _, blk := range b.blocks {
+		if !blk.Released {
+			pending += len(blk.Events)
+		}
+	}
+
+	return pending
+}
+
+// blocks up to number (inclusive)
+func (b *ConfirmationBuffer) cut(number int) []*BlockRecord {
+	for i, blk := range b.blocks {
+		if blk.Number > number {
+			return b.blocks[:i]
+		}
+	}
+	return b.blocks
+}
+
+// drop released blocks which are out of the history window
+func (b *ConfirmationBuffer) prune(head int) {
+	i := 0
+	for i < len(b.blocks) && b.blocks[i].Released && head-b.blocks[i].Number >= b.depth+blockHistory {
+		i++
+	}
+	b.blocks = b.blocks[i:]
+}
+
+func (b *ConfirmationBuffer) save() error {
+	data, err := jsoniter.Marshal(b.blocks)
+	if err != nil {
+		return fmt.Errorf("marshal : %w", err)
+	}
+
+	return writeFileAtomic(b.path, data)
+}
+
+// write data to synced temp file and rename it, so file is never half-written or lost after crash
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("CreateTemp : %w", err)
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write : %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("rename : %w", err)
+	}
+
+	// rename is durable when the directory entry is flushed
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("open dir : %w", err)
+	}
+	err = dir.Sync()
+	if closeErr := dir.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("sync dir : %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package tasks
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/onrik/ethrpc"
+)
+
+// arguments of the event decoded from the log: non-indexed ones from data, indexed ones from topics in their order.
+// Indexed strings, bytes, arrays and tuples are stored as keccak256 hash of the value, so the hash is returned
+func decodeEvent(_event *abi.Event, l ethrpc.Log) (map[string]interface{}, error) {
+	data, err := hex.DecodeString(strings.TrimPrefix(l.Data, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("DecodeString : %w", err)
+	}
+
+	decoded := map[string]interface{}{}
+	err = _event.Inputs.UnpackIntoMap(decoded, data)
+	if err != nil {
+		return nil, fmt.Errorf("UnpackIntoMap : %w", err)
+	}
+
+	// topic 0 is the event signature
+	topics := l.Topics[1:]
+	if _event.Anonymous {
+		topics = l.Topics
+	}
+
+	topic := 0
+	for _, input := range _event.Inputs {
+		if !input.Indexed {
+			continue
+		}
+		if topic >= len(topics) {
+			return nil, fmt.Errorf("topic of %s is missing", input.Name)
+		}
+
+		hash := common.HexToHash(topics[topic])
+		topic++
+
+		switch input.Type.T {
+		case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+			decoded[input.Name] = hash
+			continue
+		}
+
+		err = abi.ParseTopicsIntoMap(decoded, abi.Arguments{input}, []common.Hash{hash})
+		if err != nil {
+			return nil, fmt.Errorf("ParseTopicsIntoMap %s : %w", input.Name, err)
+		}
+	}
+
+	for name, value := range decoded {
+		decoded[name] = jsonValue(value)
+	}
+
+	return decoded, nil
+}
+
+// decoded abi value which is safe for json: integers wider than 32 bits are decimal strings,
+// addresses, hashes and bytes are hex strings, tuples are maps by component names
+func jsonValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case nil:
+		return nil
+	case *big.Int:
+		if value == nil {
+			return nil
+		}
+		return value.String()
+	case uint64:
+		return strconv.FormatUint(value, 10)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case common.Address:
+		return value.Hex()
+	case common.Hash:
+		return value.Hex()
+	case []byte:
+		return hexutil.Encode(value)
+	case string, bool:
+		return value
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+		return jsonValue(rv.Elem().Interface())
+	case reflect.Array, reflect.Slice:
+		// fixed bytes: bytes1..bytes32, function
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return hexutil.Encode(b)
+		}
+
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = jsonValue(rv.Index(i).Interface())
+		}
+		return list
+	case reflect.Struct:
+		// tuple types of the abi have json tags with names of the components
+		tuple := map[string]interface{}{}
+		for i := 0; i < rv.NumField(); i++ {
+			field := rv.Type().Field(i)
+			name := field.Tag.Get("json")
+			if name == "" {
+				name = field.Name
+			}
+			tuple[name] = jsonValue(rv.Field(i).Interface())
+		}
+		return tuple
+	}
+
+	return v
+}
@@ -0,0 +1,345 @@
+package tasks
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/onrik/ethrpc"
+	"github.com/saiset-co/saiEthIndexer/config"
+	"go.uber.org/zap"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// recorded receipt with abi of the contract and input of the transaction
+type decodeFixture struct {
+	ABI     json.RawMessage           `json:"abi"`
+	Input   string                    `json:"input"`
+	Receipt ethrpc.TransactionReceipt `json:"receipt"`
+}
+
+type decodeResult struct {
+	Events []interface{}          `json:"events"`
+	Method string                 `json:"method,omitempty"`
+	Input  map[string]interface{} `json:"input,omitempty"`
+}
+
+func TestDecodeGolden(t *testing.T) {
+	fixtures, err := filepath.Glob("testdata/decode/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bm := &BlockManager{logger: zap.NewNop()}
+
+	for _, path := range fixtures {
+		if strings.HasSuffix(path, ".golden.json") {
+			continue
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fixture decodeFixture
+			err = json.Unmarshal(data, &fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_abi, err := abi.JSON(bytes.NewReader(fixture.ABI))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			events, err := bm.HandleReceipts(&fixture.Receipt, _abi)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != len(fixture.Receipt.Logs) {
+				t.Fatalf("%d events decoded from %d logs", len(events), len(fixture.Receipt.Logs))
+			}
+
+			result := decodeResult{}
+			for _, event := range events {
+				result.Events = append(result.Events, event["Data"])
+			}
+
+			if fixture.Input != "" {
+				method, input, err := decodeInput(&ethrpc.Transaction{Input: fixture.Input}, _abi)
+				if err != nil {
+					t.Fatal(err)
+				}
+				result.Method = method.Name
+				result.Input = input
+			}
+
+			got, err := json.MarshalIndent(result, "", "\t")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", "decode", name+".golden.json")
+			if *update {
+				err = os.WriteFile(golden, got, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("decoded %s differs from %s:\n%s", name, golden, got)
+			}
+		})
+	}
+}
+
+// values are checked by hand against the words of the recorded data and topics, apart from the golden files.
+// Hashed indexed values are compared with keccak of their known preimages
+func TestDecodeHandChecked(t *testing.T) {
+	exp := func(base int64, n uint) *big.Int {
+		return new(big.Int).Exp(big.NewInt(base), big.NewInt(int64(n)), nil)
+	}
+	keccak := func(s string) string {
+		return crypto.Keccak256Hash([]byte(s)).Hex()
+	}
+
+	tests := []struct {
+		name      string
+		signature string // keccak of the signature is the first topic
+		want      map[string]interface{}
+	}{
+		{
+			name:      "erc20_transfer",
+			signature: "Transfer(address,address,uint256)",
+			want: map[string]interface{}{
+				"event": "Transfer",
+				"from":  "0x8894E0a0c962CB723c1976a4421c95949bE2D4E3",
+				"to":    "0x28C6c06298d514Db089934071355E5743bf21d60",
+				"value": new(big.Int).Mul(big.NewInt(1250000), exp(10, 18)).String(), // 0x108b2a2c28029094000000
+			},
+		},
+		{
+			name:      "bridge_export",
+			signature: "TokensExported(string,uint256)",
+			want: map[string]interface{}{
+				"event":      "TokensExported",
+				"cyclAddres": keccak("kira1qffxre9qgmm5jvpm8s0wvz2smlhtdj3hlgsh7p"),
+				"amount":     "98765432109876543210", // 0x055aa54d38e5267eea
+			},
+		},
+		{
+			// data holds amount and delta, topics hold sender, id and nonce after the signature
+			name:      "indexed_after_data",
+			signature: "Deposit(uint256,address,int256,bytes32,uint64)",
+			want: map[string]interface{}{
+				"event":  "Deposit",
+				"amount": new(big.Int).Sub(exp(2, 256), big.NewInt(1)).String(), // all bits set
+				"sender": "0x4B20993Bc481177ec7E8f571ceCaE8A9e22C02db",
+				"delta":  "-1500", // two's complement 0x...fa24
+				"id":     "0x582e742554063799e6cd840f0f44a2396df8e9f46fd013d0453d07a65fe2f2d1",
+				"nonce":  new(big.Int).Sub(exp(2, 64), big.NewInt(1)).String(),
+			},
+		},
+		{
+			// order is at offset 0x40 with amounts at 0x60 of the tuple, data is at offset 0x100,
+			// indexed tuple and string are hashes of their values
+			name:      "tuple_order",
+			signature: "OrderFilled(bytes32,(address,uint256[],bool),bytes,(address,uint24),string)",
+			want: map[string]interface{}{
+				"event":     "OrderFilled",
+				"orderHash": keccak("order-1"),
+				"order": map[string]interface{}{
+					"maker":   "0x90F79bf6EB2c4f870365E785982E1f101E93b906",
+					"amounts": []interface{}{"7", exp(2, 128).String()},
+					"partial": true,
+				},
+				"data": "0xdeadbeef",
+				"pool": "0xa0fea7f8abf6f631221d522a2dd265f3dc9d3ecbe582ae446876cc1fadca1714",
+				"memo": keccak("memo"),
+			},
+		},
+	}
+
+	bm := &BlockManager{logger: zap.NewNop()}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "decode", tt.name+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fixture decodeFixture
+			err = json.Unmarshal(data, &fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if topic := fixture.Receipt.Logs[0].Topics[0]; topic != keccak(tt.signature) {
+				t.Fatalf("topic %s is not the signature %s", topic, tt.signature)
+			}
+
+			_abi, err := abi.JSON(bytes.NewReader(fixture.ABI))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			events, err := bm.HandleReceipts(&fixture.Receipt, _abi)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// compared as json, decoded numbers are strings and tuples are maps
+			got, err := json.Marshal(events[0]["Data"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := json.Marshal(tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("decoded %s:\n%s\nwant:\n%s", tt.name, got, want)
+			}
+		})
+	}
+}
+
+func TestJSONValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{"uint8", uint8(7), uint8(7)},
+		{"uint64", uint64(18446744073709551615), "18446744073709551615"},
+		{"int64", int64(-9), "-9"},
+		{"bytes4", [4]byte{0xde, 0xad, 0xbe, 0xef}, "0xdeadbeef"},
+		{"empty bytes", []byte{}, "0x"},
+		{"bool", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := jsonValue(tt.value)
+			if got != tt.want {
+				t.Errorf("jsonValue(%v) = %v (%T), want %v (%T)", tt.value, got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventArgumentName(t *testing.T) {
+	_abi, err := abi.JSON(strings.NewReader(`[{"anonymous":false,"inputs":[{"indexed":false,"name":"name","type":"string"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Registered","type":"event"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_event := _abi.Events["Registered"]
+	data, err := _event.Inputs.NonIndexed().Pack("Transfer", big.NewInt(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bm := &BlockManager{logger: zap.NewNop()}
+	events := bm.decodeLogs([]ethrpc.Log{{Topics: []string{_event.ID.Hex()}, Data: "0x" + hex.EncodeToString(data)}}, _abi)
+	if len(events) != 1 {
+		t.Fatalf("%d events decoded", len(events))
+	}
+
+	// argument called name is not overwritten by the event name
+	decoded := events[0]["Data"].(map[string]interface{})
+	if decoded["name"] != "Transfer" || decoded["event"] != "Registered" {
+		t.Fatalf("decoded = %v", decoded)
+	}
+
+	tests := []struct {
+		names []string
+		want  int
+	}{
+		{nil, 1},
+		{[]string{"Registered"}, 1},
+		{[]string{"Transfer"}, 0},
+	}
+	for _, tt := range tests {
+		if got := filterEvents(events, tt.names); len(got) != tt.want {
+			t.Errorf("filterEvents(%v) = %d events, want %d", tt.names, len(got), tt.want)
+		}
+	}
+}
+
+func TestTransactionOfTwoContracts(t *testing.T) {
+	const pingABI = `[{"anonymous":false,"inputs":[{"indexed":false,"name":"value","type":"uint256"}],"name":"Ping","type":"event"}]`
+
+	_abi, err := abi.JSON(strings.NewReader(pingABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_event := _abi.Events["Ping"]
+	log := func(address string, index int, value int64) ethrpc.Log {
+		data, err := _event.Inputs.Pack(big.NewInt(value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ethrpc.Log{Address: address, LogIndex: index, TransactionHash: "0xtx", Topics: []string{_event.ID.Hex()}, Data: "0x" + hex.EncodeToString(data)}
+	}
+
+	// both contracts emit the same event in one transaction
+	a, b := "0x00000000000000000000000000000000000000aa", "0x00000000000000000000000000000000000000bb"
+	contracts := []config.Contract{
+		{Address: a, ABI: pingABI, Events: []string{"Ping"}},
+		{Address: strings.ToUpper(b[:2]) + b[2:], ABI: pingABI, Events: []string{"Ping"}},
+	}
+	block := 10
+	trs := []ethrpc.Transaction{{Hash: "0xtx", From: b, To: a, BlockNumber: &block}}
+	receipts := map[string]*ethrpc.TransactionReceipt{
+		"0xtx": {Status: "0x1", Logs: []ethrpc.Log{log(b, 0, 1), log(a, 1, 2), log(b, 2, 3)}},
+	}
+
+	bm := &BlockManager{config: &config.Configuration{}, logger: zap.NewNop(), chainID: 1}
+	notifications := bm.HandleTransactions(contracts, trs, receipts)
+	if len(notifications) != 2 {
+		t.Fatalf("%d notifications, want 2", len(notifications))
+	}
+
+	tests := []struct {
+		id      string
+		indexes []int
+	}{
+		{"1:0xtx:1", []int{1}},
+		{"1:0xtx:0", []int{0, 2}},
+	}
+	for i, tt := range tests {
+		if id := notifications[i]["Id"]; id != tt.id {
+			t.Errorf("notification %d: id = %v, want %s", i, id, tt.id)
+		}
+
+		var indexes []int
+		for _, event := range notifications[i]["Events"].([]map[string]interface{}) {
+			indexes = append(indexes, event["Log"].(ethrpc.Log).LogIndex)
+		}
+		if !reflect.DeepEqual(indexes, tt.indexes) {
+			t.Errorf("notification %d: logs = %v, want %v", i, indexes, tt.indexes)
+		}
+	}
+}
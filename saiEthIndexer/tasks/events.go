@@ -0,0 +1,310 @@
+package tasks
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/saiset-co/sai-storage-mongo/external/adapter"
+	repository "github.com/saiset-co/saiEthIndexer/internal/repo"
+)
+
+const (
+	storeOutboxPath = "./storage_outbox.json"
+
+	defaultEventsLimit = 100
+	maxEventsLimit     = 1000
+)
+
+var (
+	ErrStorageDisabled = errors.New("events storage is disabled")
+	ErrInvalidCursor   = errors.New("invalid cursor")
+
+	addressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+)
+
+// indexes of the events collection for the queries of EventsQuery, position is the sort key
+var eventIndexes = []repository.Index{
+	{Keys: []map[string]int{{"internal_id": 1}}, Unique: true},
+	{Keys: []map[string]int{{"position": 1}}},
+	{Keys: []map[string]int{{"contract": 1}, {"position": 1}}},
+	{Keys: []map[string]int{{"events": 1}, {"position": 1}}},
+	{Keys: []map[string]int{{"participants": 1}, {"position": 1}}},
+	{Keys: []map[string]int{{"tx_hash": 1}}},
+}
+
+// EventStore - indexed transactions of the contracts in sai-storage, one document per notification.
+// It is delivered from its own outbox, so stored events follow confirmations and reorgs like the bridge notifications
+type EventStore struct {
+	repo    repository.Repo
+	network string
+}
+
+func NewEventStore(repo repository.Repo, network string) *EventStore {
+	return &EventStore{
+		repo:    repo,
+		network: network,
+	}
+}
+
+// stored notification
+type eventDocument struct {
+	ID           string                   `json:"internal_id"`
+	Position     string                   `json:"position"` // block and id, sort key and cursor of the queries
+	ChainID      int64                    `json:"chain_id"`
+	Network      string                   `json:"network"`
+	Contract     string                   `json:"contract"`
+	Block        int                      `json:"block"`
+	TxHash       string                   `json:"tx_hash"`
+	From         string                   `json:"from"`
+	To           string                   `json:"to"`
+	Amount       string                   `json:"amount"`
+	Status       bool                     `json:"status"`
+	Method       string                   `json:"method,omitempty"`
+	Input        map[string]interface{}   `json:"input,omitempty"`
+	Events       []string                 `json:"events"`       // names of the events
+	Data         []map[string]interface{} `json:"data"`         // decoded arguments of the events
+	Participants []string                 `json:"participants"` // lowercase addresses of the transaction, events and input
+	Retracted    bool                     `json:"retracted"`    // block of the transaction was orphaned
+}
+
+// notification as it is stored in the outbox
+type storedNotification struct {
+	ID        string      `json:"Id"`
+	ChainID   int64       `json:"ChainId"`
+	Contract  string      `json:"Contract"`
+	Number    int         `json:"Number"`
+	Hash      string      `json:"Hash"`
+	From      string      `json:"From"`
+	To        string      `json:"To"`
+	Amount    interface{} `json:"Amount"`
+	Status    bool        `json:"Status"`
+	Operation *struct {
+		Name string `json:"Name"`
+	} `json:"Operation"`
+	Input  map[string]interface{} `json:"Input"`
+	Events []struct {
+		Data map[string]interface{} `json:"Data"`
+	} `json:"Events"`
+}
+
+// EventsQuery - filter of the stored events, empty fields are not filtered
+type EventsQuery struct {
+	Network   string `form:"network"`
+	Contract  string `form:"contract"`
+	Event     string `form:"event"`
+	Address   string `form:"address"` // participant of the transaction
+	TxHash    string `form:"tx_hash"`
+	FromBlock int    `form:"from_block"`
+	ToBlock   int    `form:"to_block"`
+	Retracted bool   `form:"retracted"` // include events of the orphaned blocks
+	Desc      bool   `form:"desc"`      // newest first
+	Limit     int    `form:"limit"`
+	Cursor    string `form:"cursor"` // next_cursor of the previous page
+}
+
+// EventsPage - page of the stored events
+type EventsPage struct {
+	Events     []map[string]interface{} `json:"events"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+}
+
+// CreateIndexes - create indexes of the events collection
+func (s *EventStore) CreateIndexes() error {
+	return s.repo.CreateIndexes(eventIndexes)
+}
+
+// SendTx - store the notification, already stored notification is restored if it was retracted
+func (s *EventStore) SendTx(key string, data interface{}) error {
+	doc, err := s.document(key, data)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.Create(doc)
+	if err == nil {
+		return nil
+	}
+	if !isDuplicate(err) {
+		return fmt.Errorf("Create : %w", err)
+	}
+
+	err = s.repo.Update(map[string]interface{}{"internal_id": key}, map[string]interface{}{
+		"$set": map[string]interface{}{"retracted": false},
+	})
+	if err != nil {
+		return fmt.Errorf("Update : %w", err)
+	}
+	return nil
+}
+
+// RetractTx - mark the notification of the orphaned block as retracted
+func (s *EventStore) RetractTx(key string, _ interface{}) error {
+	err := s.repo.Update(map[string]interface{}{"internal_id": key}, map[string]interface{}{
+		"$set": map[string]interface{}{"retracted": true},
+	})
+	if err != nil {
+		return fmt.Errorf("Update : %w", err)
+	}
+	return nil
+}
+
+// Find - page of the events by the query in block order
+func (s *EventStore) Find(q EventsQuery) (*EventsPage, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultEventsLimit
+	}
+	if limit > maxEventsLimit {
+		limit = maxEventsLimit
+	}
+
+	selector := map[string]interface{}{}
+	if !q.Retracted {
+		selector["retracted"] = false
+	}
+	if q.Network != "" {
+		selector["network"] = q.Network
+	}
+	if q.Contract != "" {
+		selector["contract"] = strings.ToLower(q.Contract)
+	}
+	if q.Event != "" {
+		selector["events"] = q.Event
+	}
+	if q.Address != "" {
+		selector["participants"] = strings.ToLower(q.Address)
+	}
+	if q.TxHash != "" {
+		selector["tx_hash"] = strings.ToLower(q.TxHash)
+	}
+
+	blocks := map[string]interface{}{}
+	if q.FromBlock > 0 {
+		blocks["$gte"] = q.FromBlock
+	}
+	if q.ToBlock > 0 {
+		blocks["$lte"] = q.ToBlock
+	}
+	if len(blocks) > 0 {
+		selector["block"] = blocks
+	}
+
+	order, next := 1, "$gt"
+	if q.Desc {
+		order, next = -1, "$lt"
+	}
+	if q.Cursor != "" {
+		position, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		selector["position"] = map[string]interface{}{next: string(position)}
+	}
+
+	// one more event tells if there is the next page
+	events, err := s.repo.Read(selector, &adapter.Options{
+		Limit: int64(limit + 1),
+		Sort:  map[string]int{"position": order},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Read : %w", err)
+	}
+
+	page := &EventsPage{Events: events}
+	if page.Events == nil {
+		page.Events = []map[string]interface{}{}
+	}
+	if len(page.Events) > limit {
+		page.Events = page.Events[:limit]
+		position, _ := page.Events[limit-1]["position"].(string)
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(position))
+	}
+
+	return page, nil
+}
+
+// document of the notification, data of the outbox is decoded from json so events of the previous versions are stored too
+func (s *EventStore) document(key string, data interface{}) (*eventDocument, error) {
+	raw, err := jsoniter.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("Marshal : %w", err)
+	}
+
+	n := storedNotification{}
+	err = jsoniter.Unmarshal(raw, &n)
+	if err != nil {
+		return nil, fmt.Errorf("Unmarshal : %w", err)
+	}
+
+	doc := &eventDocument{
+		ID:       key,
+		Position: fmt.Sprintf("%012d:%s", n.Number, key),
+		ChainID:  n.ChainID,
+		Network:  s.network,
+		Contract: strings.ToLower(n.Contract),
+		Block:    n.Number,
+		TxHash:   strings.ToLower(n.Hash),
+		From:     strings.ToLower(n.From),
+		To:       strings.ToLower(n.To),
+		Amount:   fmt.Sprint(n.Amount),
+		Status:   n.Status,
+		Input:    n.Input,
+		Events:   []string{},
+		Data:     []map[string]interface{}{},
+	}
+	if n.Operation != nil {
+		doc.Method = n.Operation.Name
+	}
+
+	participants := map[string]bool{}
+	for _, address := range []string{doc.From, doc.To, doc.Contract} {
+		if address != "" {
+			participants[address] = true
+		}
+	}
+	collectAddresses(n.Input, participants)
+
+	for _, event := range n.Events {
+		if name, ok := event.Data["event"].(string); ok {
+			doc.Events = append(doc.Events, name)
+		}
+		doc.Data = append(doc.Data, event.Data)
+		collectAddresses(event.Data, participants)
+	}
+
+	for address := range participants {
+		doc.Participants = append(doc.Participants, address)
+	}
+	sort.Strings(doc.Participants)
+
+	return doc, nil
+}
+
+// lowercase addresses of the decoded values
+func collectAddresses(value interface{}, addresses map[string]bool) {
+	switch v := value.(type) {
+	case string:
+		if addressPattern.MatchString(v) {
+			addresses[strings.ToLower(v)] = true
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			collectAddresses(item, addresses)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectAddresses(item, addresses)
+		}
+	}
+}
+
+// storage rejected the document because of the unique index
+func isDuplicate(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "E11000") || strings.Contains(message, "duplicate key")
+}
@@ -0,0 +1,325 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/saiset-co/sai-storage-mongo/external/adapter"
+	repository "github.com/saiset-co/saiEthIndexer/internal/repo"
+)
+
+// in-memory collection with the selectors used by the event store
+type fakeRepo struct {
+	docs    []map[string]interface{}
+	indexes []repository.Index
+	reads   []*adapter.Options
+}
+
+func (r *fakeRepo) Create(data interface{}) error {
+	raw, err := jsoniter.Marshal(data)
+	if err != nil {
+		return err
+	}
+	doc := map[string]interface{}{}
+	if err = jsoniter.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+
+	for _, stored := range r.docs {
+		if stored["internal_id"] == doc["internal_id"] {
+			return errors.New("E11000 duplicate key error")
+		}
+	}
+	r.docs = append(r.docs, doc)
+	return nil
+}
+
+func (r *fakeRepo) Read(selector map[string]interface{}, options *adapter.Options) ([]map[string]interface{}, error) {
+	r.reads = append(r.reads, options)
+
+	var found []map[string]interface{}
+	for _, doc := range r.docs {
+		if matches(doc, selector) {
+			found = append(found, doc)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		less := found[i]["position"].(string) < found[j]["position"].(string)
+		if options.Sort.(map[string]int)["position"] < 0 {
+			return !less
+		}
+		return less
+	})
+
+	if options.Limit > 0 && int64(len(found)) > options.Limit {
+		found = found[:options.Limit]
+	}
+	return found, nil
+}
+
+func (r *fakeRepo) Update(selector map[string]interface{}, document interface{}) error {
+	set := document.(map[string]interface{})["$set"].(map[string]interface{})
+	for _, doc := range r.docs {
+		if matches(doc, selector) {
+			for key, value := range set {
+				doc[key] = value
+			}
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepo) CreateIndexes(indexes []repository.Index) error {
+	r.indexes = indexes
+	return nil
+}
+
+// equality, membership in arrays and range operators of mongo
+func matches(doc, selector map[string]interface{}) bool {
+	for key, want := range selector {
+		value := doc[key]
+
+		if ops, ok := want.(map[string]interface{}); ok {
+			for op, bound := range ops {
+				if !compare(value, op, bound) {
+					return false
+				}
+			}
+			continue
+		}
+
+		if list, ok := value.([]interface{}); ok {
+			found := false
+			for _, item := range list {
+				if item == want {
+					found = true
+				}
+			}
+			if !found {
+				return false
+			}
+			continue
+		}
+
+		if fmt.Sprint(value) != fmt.Sprint(want) {
+			return false
+		}
+	}
+	return true
+}
+
+func compare(value interface{}, op string, bound interface{}) bool {
+	var c int
+	switch v := value.(type) {
+	case string:
+		b := bound.(string)
+		switch {
+		case v < b:
+			c = -1
+		case v > b:
+			c = 1
+		}
+	case float64:
+		b := float64(bound.(int))
+		switch {
+		case v < b:
+			c = -1
+		case v > b:
+			c = 1
+		}
+	default:
+		return false
+	}
+
+	switch op {
+	case "$gt":
+		return c > 0
+	case "$gte":
+		return c >= 0
+	case "$lt":
+		return c < 0
+	case "$lte":
+		return c <= 0
+	}
+	return false
+}
+
+const (
+	tokenA = "0x00000000000000000000000000000000000000Aa"
+	tokenB = "0x00000000000000000000000000000000000000bb"
+	holder = "0x00000000000000000000000000000000000000Cc"
+)
+
+func storedEvent(id string, block int, contract, event string) map[string]interface{} {
+	return map[string]interface{}{
+		"Id":       id,
+		"ChainId":  1,
+		"Contract": contract,
+		"Number":   block,
+		"Hash":     "0xTX" + id,
+		"From":     "0x00000000000000000000000000000000000000dd",
+		"To":       contract,
+		"Amount":   "0",
+		"Status":   true,
+		"Events": []map[string]interface{}{
+			{"Data": map[string]interface{}{"event": event, "to": holder}},
+		},
+	}
+}
+
+func newTestEventStore(t *testing.T) (*EventStore, *fakeRepo) {
+	repo := &fakeRepo{}
+	store := NewEventStore(repo, "test")
+
+	events := []map[string]interface{}{
+		storedEvent("1:a", 10, tokenA, "Transfer"),
+		storedEvent("1:b", 11, tokenB, "Transfer"),
+		storedEvent("1:c", 11, tokenA, "Approval"),
+		storedEvent("1:d", 12, tokenA, "Transfer"),
+		storedEvent("1:e", 13, tokenB, "Approval"),
+	}
+	for _, event := range events {
+		if err := store.SendTx(event["Id"].(string), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// orphaned event is kept as retracted
+	if err := store.RetractTx("1:e", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return store, repo
+}
+
+// ids of the events of all pages
+func findAll(t *testing.T, store *EventStore, q EventsQuery) ([]string, int) {
+	var ids []string
+	pages := 0
+	for {
+		page, err := store.Find(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+
+		for _, event := range page.Events {
+			ids = append(ids, event["internal_id"].(string))
+		}
+		if page.NextCursor == "" {
+			return ids, pages
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+func TestEventStoreFind(t *testing.T) {
+	store, _ := newTestEventStore(t)
+
+	tests := []struct {
+		name  string
+		query EventsQuery
+		ids   []string
+		pages int
+	}{
+		{"block order", EventsQuery{Limit: 2}, []string{"1:a", "1:b", "1:c", "1:d"}, 2},
+		{"newest first", EventsQuery{Limit: 3, Desc: true}, []string{"1:d", "1:c", "1:b", "1:a"}, 2},
+		{"single page", EventsQuery{}, []string{"1:a", "1:b", "1:c", "1:d"}, 1},
+		{"retracted", EventsQuery{Limit: 2, Retracted: true}, []string{"1:a", "1:b", "1:c", "1:d", "1:e"}, 3},
+		{"contract of any case", EventsQuery{Contract: "0x00000000000000000000000000000000000000AA", Limit: 1}, []string{"1:a", "1:c", "1:d"}, 3},
+		{"event", EventsQuery{Event: "Transfer"}, []string{"1:a", "1:b", "1:d"}, 1},
+		{"participant", EventsQuery{Address: holder, Contract: tokenB, Retracted: true}, []string{"1:b", "1:e"}, 1},
+		{"transaction", EventsQuery{TxHash: "0xtx1:c"}, []string{"1:c"}, 1},
+		{"blocks", EventsQuery{FromBlock: 11, ToBlock: 12, Limit: 2}, []string{"1:b", "1:c", "1:d"}, 2},
+		{"other network", EventsQuery{Network: "other"}, nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, pages := findAll(t, store, tt.query)
+			if !reflect.DeepEqual(ids, tt.ids) || pages != tt.pages {
+				t.Fatalf("ids = %v in %d pages, want %v in %d", ids, pages, tt.ids, tt.pages)
+			}
+		})
+	}
+}
+
+func TestEventStoreFindLimit(t *testing.T) {
+	store, repo := newTestEventStore(t)
+
+	tests := []struct {
+		limit int
+		read  int64 // one more event is read to find the next page
+	}{
+		{0, defaultEventsLimit + 1},
+		{-1, defaultEventsLimit + 1},
+		{5, 6},
+		{maxEventsLimit + 1, maxEventsLimit + 1},
+	}
+
+	for _, tt := range tests {
+		repo.reads = nil
+		if _, err := store.Find(EventsQuery{Limit: tt.limit}); err != nil {
+			t.Fatal(err)
+		}
+		if got := repo.reads[0].Limit; got != tt.read {
+			t.Errorf("limit %d: read %d, want %d", tt.limit, got, tt.read)
+		}
+	}
+
+	if _, err := store.Find(EventsQuery{Cursor: "not base64!"}); err != ErrInvalidCursor {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidCursor)
+	}
+}
+
+func TestEventStoreRestore(t *testing.T) {
+	store, _ := newTestEventStore(t)
+
+	// event of the block which is back in the chain is stored again
+	if err := store.SendTx("1:e", storedEvent("1:e", 13, tokenB, "Approval")); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, _ := findAll(t, store, EventsQuery{Contract: tokenB})
+	if !reflect.DeepEqual(ids, []string{"1:b", "1:e"}) {
+		t.Fatalf("ids = %v", ids)
+	}
+}
+
+func TestEventIndexes(t *testing.T) {
+	store, repo := newTestEventStore(t)
+	if err := store.CreateIndexes(); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := map[string]bool{}
+	for _, index := range repo.indexes {
+		var fields []string
+		for _, key := range index.Keys {
+			for field := range key {
+				fields = append(fields, field)
+			}
+		}
+		keys[fmt.Sprint(fields, index.Unique)] = true
+	}
+
+	// every filter of the queries is followed by the sort key, so pages are read from the index
+	tests := []string{
+		"[internal_id] true",
+		"[position] false",
+		"[contract position] false",
+		"[events position] false",
+		"[participants position] false",
+		"[tx_hash] false",
+	}
+	for _, want := range tests {
+		if !keys[want] {
+			t.Errorf("index %s is not created, indexes : %v", want, repo.indexes)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package tasks
+
+import (
+	"strings"
+	"time"
+
+	"github.com/saiset-co/saiEthIndexer/config"
+	"github.com/saiset-co/saiEthIndexer/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// ProcessLive - index contracts at the live cursor from the cursor up to the head of the chain.
+// Events are held in the confirmation buffer, contracts behind the cursor are handed to backfill workers
+func (c *Chain) ProcessLive() {
+	r := c.newRangeSize()
+
+	for c.ctx.Err() == nil {
+		head, err := c.EthClient.EthBlockNumber()
+		if err != nil {
+			c.Logger.Error("tasks - ProcessLive - get block number from eth client", zap.Error(err))
+			c.sleep(time.Duration(c.Network.BlockTime) * time.Second)
+			continue
+		}
+
+		c.Logger.Sugar().Debugf("get most recent block from geth-server : %d", head)
+		metrics.SetHead(c.Network.Name, head)
+
+		legacy, err := c.Checkpoints.Init(head, c.Network.StartBlock, addresses(c.contracts()), c.primary)
+		if err != nil {
+			c.Logger.Error("tasks - ProcessLive - init checkpoints", zap.Error(err))
+			c.sleep(time.Duration(c.Network.BlockTime) * time.Second)
+			continue
+		}
+		if legacy {
+			c.Logger.Info("tasks - ProcessLive - cursor of block.data is taken over", zap.Int("block", c.Checkpoints.LiveCursor()))
+		}
+
+		for from := c.Checkpoints.LiveCursor() + 1; from <= head && c.ctx.Err() == nil; {
+			c.assign()
+
+			contracts := c.liveContracts()
+			result, to, err := c.scanRange(contracts, from, head, r)
+			if err != nil {
+				c.Logger.Error("tasks - ProcessLive - scan", zap.Int("from", from), zap.Error(err))
+				break
+			}
+
+			if c.BlockManager.buffer.IsReorg(from, result.first.ParentHash) {
+				c.Logger.Warn("tasks - ProcessLive - reorg detected", zap.Int("block", from), zap.String("parent_hash", result.first.ParentHash))
+
+				fork, err := c.handleReorg(from)
+				if err != nil {
+					c.Logger.Error("tasks - ProcessLive - handle reorg", zap.Error(err))
+					break
+				}
+
+				// continue from the block after the fork
+				err = c.Checkpoints.Advance(fork, addresses(contracts))
+				if err != nil {
+					c.Logger.Error("tasks - ProcessLive - save checkpoints", zap.Error(err))
+					break
+				}
+				from = fork + 1
+				continue
+			}
+
+			added := true
+			for _, blk := range result.blocks {
+				err = c.BlockManager.AddBlock(blk, result.events[blk.Number])
+				if err != nil {
+					c.Logger.Error("tasks - ProcessLive - add block to confirmation buffer", zap.Int("block", blk.Number), zap.Error(err))
+					added = false
+					break
+				}
+			}
+			if !added {
+				break
+			}
+
+			err = c.BlockManager.ReleaseConfirmed(head)
+			if err != nil {
+				c.Logger.Error("tasks - ProcessLive - release confirmed blocks", zap.Error(err))
+			}
+
+			err = c.Checkpoints.Advance(to, addresses(contracts))
+			if err != nil {
+				c.Logger.Error("tasks - ProcessLive - save checkpoints", zap.Error(err))
+				break
+			}
+			metrics.BlockProcessed(c.Network.Name, to, head)
+
+			from = to + 1
+		}
+
+		c.assign()
+		c.wait()
+	}
+}
+
+// assign contracts to workers. New contracts start from their start block, contracts behind the confirmed part
+// of the live cursor get backfill worker, contracts within confirmations are taken over by the live worker
+func (c *Chain) assign() {
+	contracts := c.contracts()
+	live := c.Checkpoints.LiveCursor()
+	target := c.backfillTarget(live)
+
+	err := c.Checkpoints.Retain(addresses(contracts))
+	if err != nil {
+		c.Logger.Error("tasks - assign - remove checkpoints of deleted contracts", zap.Error(err))
+	}
+
+	for _, contract := range contracts {
+		cursor, ok := c.Checkpoints.Get(contract.Address)
+		if !ok {
+			cursor = live
+			if contract.StartBlock > 0 && contract.StartBlock-1 < live {
+				cursor = contract.StartBlock - 1
+			}
+
+			err = c.Checkpoints.Set(contract.Address, cursor)
+			if err != nil {
+				c.Logger.Error("tasks - assign - save checkpoint", zap.String("address", contract.Address), zap.Error(err))
+				continue
+			}
+			c.Logger.Info("tasks - assign - new contract", zap.String("address", contract.Address), zap.Int("from", cursor+1))
+		}
+
+		switch {
+		case cursor >= live:
+		case cursor >= target:
+			c.takeOver(contract, cursor, live)
+		default:
+			c.startBackfill(contract.Address)
+		}
+	}
+}
+
+// scan blocks above the contract cursor up to the live cursor and merge its events into the confirmation buffer
+func (c *Chain) takeOver(contract config.Contract, cursor, live int) {
+	result, err := c.scan([]config.Contract{contract}, cursor+1, live)
+	if err != nil {
+		c.Logger.Error("tasks - takeOver - scan", zap.String("address", contract.Address), zap.Int("from", cursor+1), zap.Int("to", live), zap.Error(err))
+		return
+	}
+
+	err = c.BlockManager.Merge(result)
+	if err != nil {
+		c.Logger.Error("tasks - takeOver - merge events", zap.String("address", contract.Address), zap.Error(err))
+		return
+	}
+
+	err = c.Checkpoints.Update(contract.Address, live)
+	if err != nil {
+		c.Logger.Error("tasks - takeOver - save checkpoint", zap.String("address", contract.Address), zap.Error(err))
+		return
+	}
+
+	c.Logger.Info("tasks - takeOver - contract is indexed by live worker", zap.String("address", contract.Address), zap.Int("block", live))
+}
+
+// contracts of the network, contracts without network belong to the primary one
+func (c *Chain) contracts() []config.Contract {
+	mutex := c.Config.EthContracts.Mutex
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	var contracts []config.Contract
+	for _, contract := range c.Config.EthContracts.Contracts {
+		if contract.Network == c.Network.Name || (contract.Network == "" && c.primary) {
+			contracts = append(contracts, contract)
+		}
+	}
+	return contracts
+}
+
+// contracts at the live cursor
+func (c *Chain) liveContracts() []config.Contract {
+	var live []config.Contract
+	for _, contract := range c.contracts() {
+		if c.Checkpoints.IsLive(contract.Address) {
+			live = append(live, contract)
+		}
+	}
+	return live
+}
+
+// contract of the config by address
+func (c *Chain) contract(address string) (config.Contract, bool) {
+	for _, contract := range c.contracts() {
+		if strings.EqualFold(contract.Address, address) {
+			return contract, true
+		}
+	}
+	return config.Contract{}, false
+}
+
+func addresses(contracts []config.Contract) []string {
+	list := make([]string, 0, len(contracts))
+	for _, contract := range contracts {
+		list = append(list, contract.Address)
+	}
+	return list
+}
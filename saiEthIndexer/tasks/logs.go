@@ -0,0 +1,219 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/onrik/ethrpc"
+	"github.com/saiset-co/saiEthIndexer/config"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLogsRange    = 1000
+	defaultMaxLogsRange = 10000
+)
+
+// fetch logs of the contracts by eth_getLogs, logs emitted by internal calls are found too.
+// Headers of the range edges are fetched to detect reorgs, blocks in between are stored only if they have events
+func (c *Chain) scanLogs(contracts []config.Contract, from, to int) (*scanResult, error) {
+	result := &scanResult{
+		events: map[int][]map[string]interface{}{},
+	}
+
+	first, err := c.EthClient.EthGetBlockByNumber(from, false)
+	if err != nil || first == nil {
+		return nil, fmt.Errorf("EthGetBlockByNumber %d : %w", from, err)
+	}
+	result.first = first
+
+	last := first
+	if to != from {
+		last, err = c.EthClient.EthGetBlockByNumber(to, false)
+		if err != nil || last == nil {
+			return nil, fmt.Errorf("EthGetBlockByNumber %d : %w", to, err)
+		}
+	}
+
+	filter, err := c.BlockManager.LogFilter(contracts)
+	if err != nil {
+		return nil, fmt.Errorf("LogFilter : %w", err)
+	}
+
+	var logs []ethrpc.Log
+	if len(filter.Address) != 0 {
+		filter.FromBlock = fmt.Sprintf("0x%x", from)
+		filter.ToBlock = fmt.Sprintf("0x%x", to)
+
+		logs, err = c.EthClient.EthGetLogs(filter)
+		if err != nil {
+			return nil, fmt.Errorf("EthGetLogs : %w", err)
+		}
+	}
+
+	// logs of the range edges are from other chain, range is scanned again
+	for _, l := range logs {
+		if (l.BlockNumber == from && l.BlockHash != first.Hash) || (l.BlockNumber == to && l.BlockHash != last.Hash) {
+			return nil, errors.New("chain changed during the scan")
+		}
+	}
+
+	trs := map[string]*ethrpc.Transaction{}
+	for _, l := range logs {
+		if _, ok := trs[l.TransactionHash]; ok || l.Removed {
+			continue
+		}
+
+		tr, err := c.EthClient.EthGetTransactionByHash(l.TransactionHash)
+		if err != nil || tr == nil {
+			return nil, fmt.Errorf("EthGetTransactionByHash %s : %w", l.TransactionHash, err)
+		}
+		trs[l.TransactionHash] = tr
+	}
+
+	c.Logger.Sugar().Debugf("blocks %d-%d analyzed, %d logs found", from, to, len(logs))
+
+	notifications, hashes := c.BlockManager.HandleLogs(contracts, logs, trs)
+	result.events = notifications
+
+	result.blocks = []*ethrpc.Block{first}
+	for number := from + 1; number < to; number++ {
+		if hash, ok := hashes[number]; ok {
+			result.blocks = append(result.blocks, &ethrpc.Block{Number: number, Hash: hash})
+		}
+	}
+	if to != from {
+		result.blocks = append(result.blocks, last)
+	}
+
+	return result, nil
+}
+
+// LogFilter - eth_getLogs filter by addresses of the watched contracts and topics of their events.
+// Contracts without their events in abi are left out of the filter, topics are not filtered if any contract takes all events
+func (bm *BlockManager) LogFilter(contracts []config.Contract) (ethrpc.FilterParams, error) {
+	filter := ethrpc.FilterParams{}
+	topics := []string{}
+	known := map[string]bool{}
+	all := false
+
+	for _, contract := range contracts {
+		names := bm.config.ContractEvents(contract)
+		if len(names) == 0 {
+			all = true
+			filter.Address = append(filter.Address, contract.Address)
+			continue
+		}
+
+		_abi, err := abi.JSON(strings.NewReader(contract.ABI))
+		if err != nil {
+			return filter, fmt.Errorf("parse abi of %s : %w", contract.Address, err)
+		}
+
+		found := false
+		for _, name := range names {
+			event, ok := _abi.Events[name]
+			if !ok {
+				continue
+			}
+			found = true
+			if !known[event.ID.Hex()] {
+				known[event.ID.Hex()] = true
+				topics = append(topics, event.ID.Hex())
+			}
+		}
+		if found {
+			filter.Address = append(filter.Address, contract.Address)
+		}
+	}
+
+	// events of the other contracts are filtered after decoding
+	if len(topics) != 0 && !all {
+		filter.Topics = [][]string{topics}
+	}
+
+	return filter, nil
+}
+
+// HandleLogs - notifications about transactions which emitted logs of the watched contracts, by block number.
+// Transaction is notified if its method is in operations of the contract or if it emitted events configured for the contract.
+// Hashes of the blocks with logs are returned by block number
+func (bm *BlockManager) HandleLogs(contracts []config.Contract, logs []ethrpc.Log, trs map[string]*ethrpc.Transaction) (map[int][]map[string]interface{}, map[int]string) {
+	notifications := map[int][]map[string]interface{}{}
+	hashes := map[int]string{}
+
+	// logs of the transaction by contract, in the chain order
+	type source struct {
+		tx       string
+		contract string
+	}
+	grouped := map[source][]ethrpc.Log{}
+	var order []source
+
+	for _, l := range logs {
+		if l.Removed {
+			continue
+		}
+		hashes[l.BlockNumber] = l.BlockHash
+
+		key := source{tx: l.TransactionHash, contract: strings.ToLower(l.Address)}
+		if _, ok := grouped[key]; !ok {
+			order = append(order, key)
+		}
+		grouped[key] = append(grouped[key], l)
+	}
+
+	abis := map[string]abi.ABI{}
+	watched := map[string]config.Contract{}
+	for _, contract := range contracts {
+		_abi, err := abi.JSON(strings.NewReader(contract.ABI))
+		if err != nil {
+			bm.logger.Error("block manager - handle logs - parse abi from config", zap.String("address", contract.Address), zap.Error(err))
+			continue
+		}
+		abis[strings.ToLower(contract.Address)] = _abi
+		watched[strings.ToLower(contract.Address)] = contract
+	}
+
+	for _, key := range order {
+		_abi, ok := abis[key.contract]
+		tr := trs[key.tx]
+		if !ok || tr == nil {
+			continue
+		}
+
+		contract := watched[key.contract]
+		names := bm.config.ContractEvents(contract)
+
+		events := filterEvents(bm.decodeLogs(grouped[key], _abi), names)
+		if len(events) == 0 {
+			continue
+		}
+
+		// method is not decoded for internal calls of the contract
+		method, decodedInput, err := decodeInput(tr, _abi)
+		if err != nil {
+			bm.logger.Debug("block manager - handle logs - decode input", zap.String("transaction hash", tr.Hash), zap.Error(err))
+			decodedInput = map[string]interface{}{}
+		}
+
+		notify := len(names) != 0
+		for _, operation := range bm.config.ContractOperations(contract) {
+			if method != nil && operation == method.Name {
+				notify = true
+			}
+		}
+		if !notify {
+			continue
+		}
+
+		number := grouped[key][0].BlockNumber
+		notifications[number] = append(notifications[number], bm.notification(key.contract, tr, events, true, method, decodedInput))
+
+		bm.logger.Sugar().Infof("transaction %s of contract %s has been updated.", tr.Hash, key.contract)
+	}
+
+	return notifications, hashes
+}
@@ -0,0 +1,406 @@
+package tasks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/saiset-co/saiEthIndexer/internal/metrics"
+	"github.com/saiset-co/saiEthIndexer/internal/notifier"
+	"go.uber.org/zap"
+)
+
+const (
+	outboxPath = "./outbox.json"
+
+	// receivers of the outbox entries
+	sinkBridge  = "bridge"
+	sinkStorage = "storage"
+
+	methodNotify  = "notify"
+	methodRetract = "retract"
+
+	// ids of delivered notifications kept to skip events scanned again
+	deliveredHistory = 10000
+
+	// changes appended to the outbox file over its size before the file is rewritten by snapshot
+	outboxCompaction = 10000
+
+	// changes of the outbox in the file
+	recordSnapshot = "snapshot"
+	recordAdd      = "add"
+	recordRemove   = "remove"
+	recordAttempt  = "attempt"
+	recordDeliver  = "deliver"
+	recordForget   = "forget"
+
+	minRetryDelay = time.Second
+	maxRetryDelay = 5 * time.Minute
+)
+
+// OutboxEntry - notification or retraction waiting for delivery to the bridge
+type OutboxEntry struct {
+	ID       string                 `json:"id"`     // idempotency key of the event
+	Method   string                 `json:"method"` // notify or retract
+	Block    int                    `json:"block"`
+	Tx       map[string]interface{} `json:"tx"`
+	Attempts int                    `json:"attempts"`
+	Error    string                 `json:"error,omitempty"` // error of the last attempt
+}
+
+// outboxRecord - change of the outbox, one json line of the outbox file.
+// Snapshot holds the whole outbox, file of the previous versions is a snapshot without op
+type outboxRecord struct {
+	Op        string         `json:"op,omitempty"`
+	Entry     *OutboxEntry   `json:"entry,omitempty"` // added entry
+	Index     int            `json:"index,omitempty"` // index of removed or attempted entry
+	ID        string         `json:"id,omitempty"`    // delivered or forgotten id
+	Error     string         `json:"error,omitempty"` // error of the attempt
+	Entries   []*OutboxEntry `json:"entries,omitempty"`
+	Delivered []string       `json:"delivered,omitempty"`
+}
+
+// Outbox - persistent queue of the events for the bridge. Events are written to the outbox before cursors move
+// and are delivered in order until acknowledged, so they are neither lost nor sent twice by the indexer.
+// Changes are appended to the outbox file, the file is rewritten by snapshot when it grows over the outbox size
+type Outbox struct {
+	sync.Mutex
+	path      string
+	network   string
+	sink      string
+	Entries   []*OutboxEntry
+	Delivered []string // ids of the delivered notifications, oldest first
+
+	delivered map[string]bool
+	inflight  *OutboxEntry // entry being delivered
+	wake      chan struct{}
+	file      *os.File // outbox file opened for append, nil if it has to be rewritten
+	records   int      // records in the outbox file
+}
+
+// load outbox from file, empty outbox if file does not exist
+func NewOutbox(path, network, sink string) (*Outbox, error) {
+	o := &Outbox{
+		path:      path,
+		network:   network,
+		sink:      sink,
+		delivered: map[string]bool{},
+		wake:      make(chan struct{}, 1),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("ReadFile : %w", err)
+	}
+
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		r := &outboxRecord{}
+		err = jsoniter.Unmarshal(line, r)
+		if err != nil {
+			// last change could be written partially before crash, it was not acknowledged
+			if i == len(lines)-1 {
+				break
+			}
+			return nil, fmt.Errorf("Unmarshal : %w", err)
+		}
+		o.apply(r)
+	}
+
+	err = o.compact()
+	if err != nil {
+		return nil, fmt.Errorf("compact : %w", err)
+	}
+
+	return o, nil
+}
+
+// Add - enqueue notifications of the block, already delivered or queued events are skipped
+func (o *Outbox) Add(block int, events []map[string]interface{}) error {
+	o.Lock()
+	defer o.Unlock()
+
+	var records []*outboxRecord
+	for _, event := range events {
+		id := eventID(event)
+		if o.delivered[id] || o.find(id, methodNotify) >= 0 || (o.inflight != nil && o.inflight.ID == id && o.inflight.Method == methodNotify) {
+			continue
+		}
+
+		records = append(records, o.apply(&outboxRecord{Op: recordAdd, Entry: &OutboxEntry{ID: id, Method: methodNotify, Block: block, Tx: event}}))
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+	return o.commit(records...)
+}
+
+// Retract - cancel queued notifications of the orphaned block, retraction is enqueued for the delivered ones
+func (o *Outbox) Retract(block int, events []map[string]interface{}) error {
+	o.Lock()
+	defer o.Unlock()
+
+	var records []*outboxRecord
+	for _, event := range events {
+		id := eventID(event)
+
+		if i := o.find(id, methodNotify); i >= 0 {
+			records = append(records, o.apply(&outboxRecord{Op: recordRemove, Index: i}))
+			continue
+		}
+
+		sent := o.delivered[id] || (o.inflight != nil && o.inflight.ID == id && o.inflight.Method == methodNotify)
+		if !sent {
+			continue
+		}
+
+		// event can be notified again if transaction gets to the canonical chain
+		records = append(records,
+			o.apply(&outboxRecord{Op: recordForget, ID: id}),
+			o.apply(&outboxRecord{Op: recordAdd, Entry: &OutboxEntry{ID: id, Method: methodRetract, Block: block, Tx: event}}),
+		)
+	}
+
+	return o.commit(records...)
+}
+
+// Dispatch - deliver entries in order, failed delivery is retried with backoff until acknowledged
+func (o *Outbox) Dispatch(n notifier.Notifier, logger *zap.Logger) {
+	delay := minRetryDelay
+
+	for {
+		entry := o.next()
+		if entry == nil {
+			<-o.wake
+			continue
+		}
+
+		var err error
+		if entry.Method == methodRetract {
+			err = n.RetractTx(entry.ID, entry.Tx)
+		} else {
+			err = n.SendTx(entry.ID, entry.Tx)
+		}
+		o.record(entry, err)
+
+		saveErr := o.complete(entry, err)
+		if saveErr != nil {
+			logger.Error("outbox - dispatch - save", zap.String("sink", o.sink), zap.String("id", entry.ID), zap.Error(saveErr))
+		}
+
+		if err != nil {
+			logger.Error("outbox - dispatch - deliver", zap.String("sink", o.sink), zap.String("id", entry.ID), zap.String("method", entry.Method), zap.Int("block", entry.Block), zap.Int("attempts", entry.Attempts), zap.Duration("retry", delay), zap.Error(err))
+			time.Sleep(delay)
+			delay *= 2
+			if delay > maxRetryDelay {
+				delay = maxRetryDelay
+			}
+			continue
+		}
+
+		delay = minRetryDelay
+		logger.Sugar().Debugf("%s %s of block %d delivered to %s", entry.Method, entry.ID, entry.Block, o.sink)
+	}
+}
+
+// metrics of the delivery
+func (o *Outbox) record(entry *OutboxEntry, err error) {
+	switch {
+	case o.sink == sinkStorage:
+		metrics.StorageResult(o.network, err)
+	case entry.Method == methodRetract:
+		metrics.RetractionResult(o.network, err)
+	default:
+		metrics.NotificationResult(o.network, err)
+	}
+}
+
+// first entry, it stays in the outbox until delivered
+func (o *Outbox) next() *OutboxEntry {
+	o.Lock()
+	defer o.Unlock()
+
+	if len(o.Entries) == 0 {
+		return nil
+	}
+	o.inflight = o.Entries[0]
+	return o.inflight
+}
+
+// remove delivered entry, attempt is recorded on failure
+func (o *Outbox) complete(entry *OutboxEntry, err error) error {
+	o.Lock()
+	defer o.Unlock()
+
+	o.inflight = nil
+
+	index := -1
+	for i, e := range o.Entries {
+		if e == entry {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return o.commit()
+	}
+
+	if err != nil {
+		return o.commit(o.apply(&outboxRecord{Op: recordAttempt, Index: index, Error: err.Error()}))
+	}
+
+	records := []*outboxRecord{o.apply(&outboxRecord{Op: recordRemove, Index: index})}
+
+	// notification retracted during the delivery is not remembered as delivered
+	if entry.Method == methodNotify && o.find(entry.ID, methodRetract) < 0 {
+		records = append(records, o.apply(&outboxRecord{Op: recordDeliver, ID: entry.ID}))
+	}
+
+	return o.commit(records...)
+}
+
+// apply change to the outbox, changes are applied in the same way when the outbox file is loaded
+func (o *Outbox) apply(r *outboxRecord) *outboxRecord {
+	switch r.Op {
+	case "", recordSnapshot:
+		o.Entries = r.Entries
+		o.Delivered = nil
+		o.delivered = map[string]bool{}
+		for _, id := range r.Delivered {
+			o.deliver(id)
+		}
+	case recordAdd:
+		o.Entries = append(o.Entries, r.Entry)
+	case recordRemove:
+		if r.Index < len(o.Entries) {
+			o.Entries = append(o.Entries[:r.Index], o.Entries[r.Index+1:]...)
+		}
+	case recordAttempt:
+		if r.Index < len(o.Entries) {
+			o.Entries[r.Index].Attempts++
+			o.Entries[r.Index].Error = r.Error
+		}
+	case recordDeliver:
+		o.deliver(r.ID)
+	case recordForget:
+		delete(o.delivered, r.ID)
+		for i, delivered := range o.Delivered {
+			if delivered == r.ID {
+				o.Delivered = append(o.Delivered[:i], o.Delivered[i+1:]...)
+				break
+			}
+		}
+	}
+	return r
+}
+
+// remember delivered id, oldest ids over the history are forgotten
+func (o *Outbox) deliver(id string) {
+	o.delivered[id] = true
+	o.Delivered = append(o.Delivered, id)
+	if len(o.Delivered) > deliveredHistory {
+		for _, id := range o.Delivered[:len(o.Delivered)-deliveredHistory] {
+			delete(o.delivered, id)
+		}
+		o.Delivered = o.Delivered[len(o.Delivered)-deliveredHistory:]
+	}
+}
+
+// index of queued entry which is not being delivered
+func (o *Outbox) find(id, method string) int {
+	for i, e := range o.Entries {
+		if e.ID == id && e.Method == method && e != o.inflight {
+			return i
+		}
+	}
+	return -1
+}
+
+// append changes to the outbox file and wake the dispatcher
+func (o *Outbox) commit(records ...*outboxRecord) error {
+	metrics.OutboxPending.WithLabelValues(o.network, o.sink).Set(float64(len(o.Entries)))
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+	if o.file == nil || o.records+len(records) > len(o.Entries)+len(o.Delivered)+outboxCompaction {
+		return o.compact()
+	}
+
+	var data []byte
+	for _, r := range records {
+		line, err := jsoniter.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("marshal : %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	_, err := o.file.Write(data)
+	if err == nil {
+		err = o.file.Sync()
+	}
+	if err != nil {
+		// partially written changes are dropped by the rewrite on the next commit
+		o.file.Close()
+		o.file = nil
+		return fmt.Errorf("write : %w", err)
+	}
+
+	o.records += len(records)
+	return nil
+}
+
+// rewrite the outbox file by snapshot of the outbox
+func (o *Outbox) compact() error {
+	data, err := jsoniter.Marshal(&outboxRecord{Op: recordSnapshot, Entries: o.Entries, Delivered: o.Delivered})
+	if err != nil {
+		return fmt.Errorf("marshal : %w", err)
+	}
+
+	if o.file != nil {
+		o.file.Close()
+		o.file = nil
+	}
+
+	err = writeFileAtomic(o.path, append(data, '\n'))
+	if err != nil {
+		return err
+	}
+
+	o.file, err = os.OpenFile(o.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("OpenFile : %w", err)
+	}
+
+	o.records = 1
+	return nil
+}
+
+// idempotency key of the event, events of the previous versions have no id and are identified by transaction hash.
+// Replayed event gets the replay id, so it is sent again
+func eventID(event map[string]interface{}) string {
+	id, ok := event["Id"].(string)
+	if !ok || id == "" {
+		id = fmt.Sprint(event["Hash"])
+	}
+
+	if replay, ok := event["ReplayId"].(string); ok && replay != "" {
+		return id + ":replay:" + replay
+	}
+	return id
+}
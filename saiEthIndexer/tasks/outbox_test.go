@@ -0,0 +1,141 @@
+package tasks
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func outboxIDs(o *Outbox) []string {
+	var ids []string
+	for _, e := range o.Entries {
+		ids = append(ids, e.Method+" "+e.ID)
+	}
+	return ids
+}
+
+func TestOutboxReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+
+	o, err := NewOutbox(path, "test", sinkBridge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := []map[string]interface{}{{"Id": "a"}, {"Id": "b"}, {"Id": "c"}}
+	if err = o.Add(1, events); err != nil {
+		t.Fatal(err)
+	}
+
+	// a is delivered, b fails once, c is cancelled before delivery
+	if err = o.complete(o.next(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = o.complete(o.next(), errors.New("unavailable")); err != nil {
+		t.Fatal(err)
+	}
+	if err = o.Retract(1, events[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if err = o.Retract(1, events[2:]); err != nil {
+		t.Fatal(err)
+	}
+
+	// every change is appended, the file is not rewritten
+	if o.records != 10 {
+		t.Fatalf("records = %d, want 10", o.records)
+	}
+
+	// partially written change is dropped on load
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"add","entry":{"id":"d"`)
+	f.Close()
+
+	loaded, err := NewOutbox(path, "test", sinkBridge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"notify b", "retract a"}
+	if got := outboxIDs(loaded); !reflect.DeepEqual(got, want) {
+		t.Fatalf("entries = %v, want %v", got, want)
+	}
+	if loaded.Entries[0].Attempts != 1 || loaded.Entries[0].Error != "unavailable" {
+		t.Fatalf("attempt of b is not restored: %+v", loaded.Entries[0])
+	}
+	if len(loaded.Delivered) != 0 || loaded.delivered["a"] {
+		t.Fatalf("retracted a is still delivered: %v", loaded.Delivered)
+	}
+	if loaded.records != 1 {
+		t.Fatalf("records after load = %d, want snapshot only", loaded.records)
+	}
+}
+
+func TestOutboxCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+
+	o, err := NewOutbox(path, "test", sinkBridge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = o.Add(1, []map[string]interface{}{{"Id": "a"}, {"Id": "b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// file grown over the outbox size is rewritten by snapshot on the next change
+	o.records = len(o.Entries) + len(o.Delivered) + outboxCompaction
+	if err = o.complete(o.next(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if o.records != 1 {
+		t.Fatalf("records = %d, want snapshot only", o.records)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"op":"snapshot","entries":[{"id":"b","method":"notify","block":1,"tx":{"Id":"b"},"attempts":0}],"delivered":["a"]}` + "\n"; string(data) != want {
+		t.Fatalf("outbox file = %s, want %s", data, want)
+	}
+
+	// changes after the snapshot are appended
+	if err = o.complete(o.next(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := NewOutbox(path, "test", sinkBridge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Delivered, []string{"a", "b"}) || len(loaded.Entries) != 0 {
+		t.Fatalf("outbox is not restored: %v %v", outboxIDs(loaded), loaded.Delivered)
+	}
+}
+
+func TestOutboxLegacyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+
+	legacy := `{"entries":[{"id":"b","method":"notify","block":2,"tx":{"Id":"b"},"attempts":3}],"delivered":["a"]}`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := NewOutbox(path, "test", sinkBridge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := outboxIDs(o); !reflect.DeepEqual(got, []string{"notify b"}) || o.Entries[0].Attempts != 3 {
+		t.Fatalf("entries = %v", got)
+	}
+	if !o.delivered["a"] {
+		t.Fatal("delivered id is not restored")
+	}
+}
@@ -0,0 +1,259 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/saiset-co/saiEthIndexer/config"
+	"go.uber.org/zap"
+)
+
+// statuses of the replay
+const (
+	ReplayRunning = "running"
+	ReplayDone    = "done"
+	ReplayFailed  = "failed"
+
+	// notifications listed in the replay report, the rest are only counted
+	maxReplayEvents = 10000
+	// finished replays kept for the status requests
+	maxReplayJobs = 100
+	// attempts to scan the range before the replay fails
+	maxReplayAttempts = 5
+)
+
+var (
+	ErrInvalidReplay  = errors.New("invalid replay")
+	ErrReplayRunning  = errors.New("replay is already running")
+	ErrReplayNotFound = errors.New("replay not found")
+)
+
+// ReplayRequest - confirmed blocks to scan again for the contracts
+type ReplayRequest struct {
+	Network   string   `json:"network"`   // first network if empty
+	Contracts []string `json:"contracts"` // addresses, all contracts of the network if empty
+	FromBlock int      `json:"from_block"`
+	ToBlock   int      `json:"to_block"`
+	DryRun    bool     `json:"dry_run"` // report notifications without sending them
+}
+
+// ReplayEvent - notification found by the replay
+type ReplayEvent struct {
+	ID       string   `json:"id"`
+	Block    int      `json:"block"`
+	TxHash   string   `json:"tx_hash"`
+	Contract string   `json:"contract"`
+	Method   string   `json:"method,omitempty"`
+	Events   []string `json:"events"`
+}
+
+// ReplayJob - state and report of the replay
+type ReplayJob struct {
+	ID         string        `json:"id"` // ReplayId of the sent notifications
+	Network    string        `json:"network"`
+	Contracts  []string      `json:"contracts"`
+	FromBlock  int           `json:"from_block"`
+	ToBlock    int           `json:"to_block"`
+	DryRun     bool          `json:"dry_run"`
+	Status     string        `json:"status"` // running, done or failed
+	Block      int           `json:"block"`  // last scanned block
+	Found      int           `json:"found"`
+	Emitted    int           `json:"emitted"` // notifications enqueued to the outbox, 0 for dry run
+	Events     []ReplayEvent `json:"events"`  // first 10000 found notifications
+	Error      string        `json:"error,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+}
+
+// Replay - start the scan of the confirmed blocks for the contracts. It runs along with the workers of the network
+// and doesn't move their cursors, found notifications are reported or enqueued to the outbox flagged as replays
+func (t *TaskManager) Replay(req ReplayRequest) (*ReplayJob, error) {
+	chain, ok := t.chain(req.Network)
+	if !ok {
+		return nil, fmt.Errorf("%w : unknown network %s", ErrInvalidReplay, req.Network)
+	}
+
+	if req.FromBlock <= 0 || req.ToBlock < req.FromBlock {
+		return nil, fmt.Errorf("%w : block range %d-%d", ErrInvalidReplay, req.FromBlock, req.ToBlock)
+	}
+
+	contracts := chain.contracts()
+	if len(req.Contracts) != 0 {
+		var selected []config.Contract
+		for _, address := range req.Contracts {
+			i := contractIndex(contracts, address)
+			if i < 0 {
+				return nil, fmt.Errorf("%w : %s in network %s", ErrContractNotFound, address, chain.Network.Name)
+			}
+			selected = append(selected, contracts[i])
+		}
+		contracts = selected
+	}
+	if len(contracts) == 0 {
+		return nil, fmt.Errorf("%w : network %s has no contracts", ErrInvalidReplay, chain.Network.Name)
+	}
+
+	// blocks within confirmations can be orphaned, they are not replayed
+	head, err := chain.EthClient.EthBlockNumber()
+	if err != nil {
+		return nil, fmt.Errorf("EthBlockNumber : %w", err)
+	}
+	if confirmed := head - chain.Network.Confirmations; req.ToBlock > confirmed {
+		return nil, fmt.Errorf("%w : block %d is not confirmed, last confirmed block is %d", ErrInvalidReplay, req.ToBlock, confirmed)
+	}
+
+	t.replaysMutex.Lock()
+	defer t.replaysMutex.Unlock()
+
+	for _, job := range t.replays {
+		if job.Status == ReplayRunning {
+			return nil, fmt.Errorf("%w : %s", ErrReplayRunning, job.ID)
+		}
+	}
+
+	job := &ReplayJob{
+		ID:        strconv.FormatInt(time.Now().UnixNano(), 36),
+		Network:   chain.Network.Name,
+		Contracts: addresses(contracts),
+		FromBlock: req.FromBlock,
+		ToBlock:   req.ToBlock,
+		DryRun:    req.DryRun,
+		Status:    ReplayRunning,
+		Block:     req.FromBlock - 1,
+		Events:    []ReplayEvent{},
+		StartedAt: time.Now(),
+	}
+
+	t.replays = append(t.replays, job)
+	if len(t.replays) > maxReplayJobs {
+		t.replays = t.replays[len(t.replays)-maxReplayJobs:]
+	}
+
+	go t.runReplay(chain, contracts, job)
+
+	t.Logger.Info("tasks - Replay - started", zap.String("id", job.ID), zap.String("network", job.Network), zap.Strings("contracts", job.Contracts), zap.Int("from", job.FromBlock), zap.Int("to", job.ToBlock), zap.Bool("dry_run", job.DryRun))
+
+	copied := job.copy()
+	return &copied, nil
+}
+
+// ReplayJob - replay by id
+func (t *TaskManager) ReplayJob(id string) (*ReplayJob, error) {
+	t.replaysMutex.Lock()
+	defer t.replaysMutex.Unlock()
+
+	for _, job := range t.replays {
+		if job.ID == id {
+			copied := job.copy()
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("%w : %s", ErrReplayNotFound, id)
+}
+
+// ReplayJobs - started replays, oldest first. Notifications are not listed
+func (t *TaskManager) ReplayJobs() []ReplayJob {
+	t.replaysMutex.Lock()
+	defer t.replaysMutex.Unlock()
+
+	jobs := make([]ReplayJob, 0, len(t.replays))
+	for _, job := range t.replays {
+		copied := job.copy()
+		copied.Events = nil
+		jobs = append(jobs, copied)
+	}
+	return jobs
+}
+
+// scan the range of the replay, failed scan is retried
+func (t *TaskManager) runReplay(chain *Chain, contracts []config.Contract, job *ReplayJob) {
+	r := chain.newRangeSize()
+	attempts := 0
+
+	for from := job.FromBlock; from <= job.ToBlock; {
+		result, to, err := chain.scanRange(contracts, from, job.ToBlock, r)
+		if err == nil && !job.DryRun {
+			err = chain.BlockManager.Replay(result, job.ID)
+		}
+		if err != nil {
+			attempts++
+			chain.Logger.Error("tasks - runReplay - scan", zap.String("id", job.ID), zap.Int("from", from), zap.Int("attempts", attempts), zap.Error(err))
+			if attempts >= maxReplayAttempts {
+				t.finishReplay(job, err)
+				return
+			}
+			chain.pause()
+			continue
+		}
+		attempts = 0
+
+		t.replaysMutex.Lock()
+		for _, blk := range result.blocks {
+			for _, notification := range result.events[blk.Number] {
+				job.Found++
+				if !job.DryRun {
+					job.Emitted++
+				}
+				if len(job.Events) < maxReplayEvents {
+					job.Events = append(job.Events, replayEvent(blk.Number, notification))
+				}
+			}
+		}
+		job.Block = to
+		t.replaysMutex.Unlock()
+
+		from = to + 1
+	}
+
+	t.finishReplay(job, nil)
+}
+
+func (t *TaskManager) finishReplay(job *ReplayJob, err error) {
+	t.replaysMutex.Lock()
+	defer t.replaysMutex.Unlock()
+
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Status = ReplayDone
+	if err != nil {
+		job.Status = ReplayFailed
+		job.Error = err.Error()
+	}
+
+	t.Logger.Info("tasks - Replay - finished", zap.String("id", job.ID), zap.String("status", job.Status), zap.Int("block", job.Block), zap.Int("found", job.Found), zap.Int("emitted", job.Emitted))
+}
+
+// copy of the job, replays mutex must be locked
+func (j *ReplayJob) copy() ReplayJob {
+	copied := *j
+	copied.Contracts = append([]string{}, j.Contracts...)
+	copied.Events = append([]ReplayEvent{}, j.Events...)
+	return copied
+}
+
+// report of the notification
+func replayEvent(block int, notification map[string]interface{}) ReplayEvent {
+	event := ReplayEvent{
+		ID:     eventID(notification),
+		Block:  block,
+		Events: []string{},
+	}
+	event.TxHash, _ = notification["Hash"].(string)
+	event.Contract, _ = notification["Contract"].(string)
+
+	if method, ok := notification["Operation"].(*abi.Method); ok && method != nil {
+		event.Method = method.Name
+	}
+
+	events, _ := notification["Events"].([]map[string]interface{})
+	for _, e := range events {
+		data, _ := e["Data"].(map[string]interface{})
+		if name, ok := data["event"].(string); ok {
+			event.Events = append(event.Events, name)
+		}
+	}
+	return event
+}
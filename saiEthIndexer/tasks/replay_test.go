@@ -0,0 +1,186 @@
+package tasks
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/onrik/ethrpc"
+	"github.com/saiset-co/saiEthIndexer/config"
+	"go.uber.org/zap"
+)
+
+const (
+	replayABI      = `[{"anonymous":false,"inputs":[{"indexed":false,"name":"value","type":"uint256"}],"name":"Ping","type":"event"}]`
+	replayContract = "0x00000000000000000000000000000000000000aa"
+)
+
+// manager of the chain with the contract which emitted Ping in blocks 20 and 30
+func newReplayManager(t *testing.T, node *fakeNode) (*TaskManager, *Chain) {
+	_abi, err := abi.JSON(strings.NewReader(replayABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_event := _abi.Events["Ping"]
+
+	for i, block := range []int{20, 30} {
+		data, err := _event.Inputs.Pack(big.NewInt(int64(block)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		node.logs = append(node.logs, ethrpc.Log{
+			Address:         replayContract,
+			Topics:          []string{_event.ID.Hex()},
+			Data:            "0x" + hex.EncodeToString(data),
+			BlockNumber:     block,
+			LogIndex:        i,
+			TransactionHash: blockHash(block + 1000),
+		})
+	}
+
+	c := newTestChain(t, node, config.Logs{BlockRange: 50, MaxBlockRange: 50})
+	c.Network.Confirmations = 10
+	c.Config.EthContracts.Contracts = []config.Contract{{Address: replayContract, ABI: replayABI, Events: []string{"Ping"}}}
+
+	outbox, err := NewOutbox(filepath.Join(t.TempDir(), "outbox.json"), "test", sinkBridge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.BlockManager.outbox = outbox
+
+	return &TaskManager{Config: c.Config, Logger: zap.NewNop(), Chains: []*Chain{c}}, c
+}
+
+// replay after it is finished
+func waitReplay(t *testing.T, m *TaskManager, id string) *ReplayJob {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.ReplayJob(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != ReplayRunning {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("replay %s is not finished", id)
+	return nil
+}
+
+func TestReplayRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		req  ReplayRequest
+		err  error
+	}{
+		{"unknown network", ReplayRequest{Network: "other", FromBlock: 1, ToBlock: 10}, ErrInvalidReplay},
+		{"no first block", ReplayRequest{ToBlock: 10}, ErrInvalidReplay},
+		{"reversed range", ReplayRequest{FromBlock: 10, ToBlock: 9}, ErrInvalidReplay},
+		{"unconfirmed blocks", ReplayRequest{FromBlock: 1, ToBlock: 191}, ErrInvalidReplay},
+		{"unknown contract", ReplayRequest{Contracts: []string{"0x00000000000000000000000000000000000000bb"}, FromBlock: 1, ToBlock: 10}, ErrContractNotFound},
+		{"confirmed blocks", ReplayRequest{Contracts: []string{strings.ToUpper(replayContract)}, FromBlock: 1, ToBlock: 190, DryRun: true}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newReplayManager(t, &fakeNode{head: 200})
+
+			job, err := m.Replay(tt.req)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err == nil {
+				waitReplay(t, m, job.ID)
+			}
+		})
+	}
+}
+
+func TestReplayDryRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		dryRun  bool
+		emitted int
+	}{
+		{"dry run", true, 0},
+		{"replay", false, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, c := newReplayManager(t, &fakeNode{head: 200})
+
+			job, err := m.Replay(ReplayRequest{FromBlock: 1, ToBlock: 120, DryRun: tt.dryRun})
+			if err != nil {
+				t.Fatal(err)
+			}
+			job = waitReplay(t, m, job.ID)
+
+			if job.Status != ReplayDone || job.Block != 120 || job.Found != 2 || job.Emitted != tt.emitted {
+				t.Fatalf("job = %+v", job)
+			}
+
+			var blocks []int
+			for _, event := range job.Events {
+				blocks = append(blocks, event.Block)
+				if !reflect.DeepEqual(event.Events, []string{"Ping"}) {
+					t.Fatalf("event = %+v", event)
+				}
+			}
+			if !reflect.DeepEqual(blocks, []int{20, 30}) {
+				t.Fatalf("blocks = %v", blocks)
+			}
+
+			// notifications are enqueued flagged as replays, dry run doesn't touch the outbox and checkpoints
+			if len(c.BlockManager.outbox.Entries) != tt.emitted {
+				t.Fatalf("outbox has %d entries, want %d", len(c.BlockManager.outbox.Entries), tt.emitted)
+			}
+			for _, entry := range c.BlockManager.outbox.Entries {
+				if entry.Tx["Replay"] != true || entry.Tx["ReplayId"] != job.ID {
+					t.Fatalf("entry is not flagged as replay : %v", entry.Tx)
+				}
+			}
+			if c.Checkpoints.LiveCursor() != 0 {
+				t.Fatalf("live cursor is moved to %d", c.Checkpoints.LiveCursor())
+			}
+		})
+	}
+}
+
+func TestReplayOneAtATime(t *testing.T) {
+	node := &fakeNode{head: 200, gate: make(chan struct{})}
+	m, _ := newReplayManager(t, node)
+
+	first, err := m.Replay(ReplayRequest{FromBlock: 1, ToBlock: 100, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// scan of the first replay waits for the node
+	if _, err = m.Replay(ReplayRequest{FromBlock: 1, ToBlock: 100, DryRun: true}); !errors.Is(err, ErrReplayRunning) {
+		t.Fatalf("err = %v, want %v", err, ErrReplayRunning)
+	}
+
+	close(node.gate)
+	if job := waitReplay(t, m, first.ID); job.Status != ReplayDone {
+		t.Fatalf("first replay = %+v", job)
+	}
+
+	second, err := m.Replay(ReplayRequest{FromBlock: 1, ToBlock: 100, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitReplay(t, m, second.ID)
+
+	jobs := m.ReplayJobs()
+	if len(jobs) != 2 || jobs[0].ID != first.ID || jobs[1].ID != second.ID || jobs[0].Events != nil {
+		t.Fatalf("jobs = %+v", jobs)
+	}
+}
@@ -0,0 +1,137 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/onrik/ethrpc"
+	"github.com/saiset-co/saiEthIndexer/config"
+	"github.com/saiset-co/saiEthIndexer/pkg/eth"
+	"go.uber.org/zap"
+)
+
+// scanned blocks with events of the contracts
+type scanResult struct {
+	first  *ethrpc.Block
+	blocks []*ethrpc.Block                  // blocks to store: every block in blocks mode, range edges and blocks with events in logs mode
+	events map[int][]map[string]interface{} // notifications by block number
+}
+
+// number of blocks scanned at once, adapted to provider limits in logs mode
+type rangeSize struct {
+	size int
+	max  int
+}
+
+func (c *Chain) newRangeSize() *rangeSize {
+	if c.Config.Specific.Mode != config.ModeLogs {
+		return &rangeSize{size: 1, max: 1}
+	}
+
+	r := &rangeSize{
+		size: c.Config.Specific.Logs.BlockRange,
+		max:  c.Config.Specific.Logs.MaxBlockRange,
+	}
+	if r.size <= 0 {
+		r.size = defaultLogsRange
+	}
+	if r.max <= 0 {
+		r.max = defaultMaxLogsRange
+	}
+	if r.size > r.max {
+		r.size = r.max
+	}
+	return r
+}
+
+// scan blocks of the range from the block up to the limit for the contracts.
+// Range is halved on provider limits and doubled back on success, returns result and the last scanned block
+func (c *Chain) scanRange(contracts []config.Contract, from, limit int, r *rangeSize) (*scanResult, int, error) {
+	for {
+		to := from + r.size - 1
+		if to > limit {
+			to = limit
+		}
+
+		result, err := c.scan(contracts, from, to)
+		if eth.IsRangeLimit(err) && to > from {
+			r.size = (to - from + 1) / 2
+			c.Logger.Warn("tasks - scanRange - provider limit, range is reduced", zap.Int("from", from), zap.Int("range", r.size), zap.Error(err))
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if r.size < r.max {
+			r.size *= 2
+			if r.size > r.max {
+				r.size = r.max
+			}
+		}
+		return result, to, nil
+	}
+}
+
+// scan blocks from-to for the contracts in the configured mode
+func (c *Chain) scan(contracts []config.Contract, from, to int) (*scanResult, error) {
+	if c.Config.Specific.Mode == config.ModeLogs {
+		return c.scanLogs(contracts, from, to)
+	}
+	return c.scanBlocks(contracts, from, to)
+}
+
+// fetch blocks with transactions, receipts are fetched for the transactions of the contracts only
+func (c *Chain) scanBlocks(contracts []config.Contract, from, to int) (*scanResult, error) {
+	result := &scanResult{
+		events: map[int][]map[string]interface{}{},
+	}
+
+	addresses := map[string]bool{}
+	for _, contract := range contracts {
+		addresses[strings.ToLower(contract.Address)] = true
+	}
+
+	for i := from; i <= to; i++ {
+		blkInfo, err := c.EthClient.EthGetBlockByNumber(i, true)
+		if err != nil || blkInfo == nil {
+			return nil, fmt.Errorf("EthGetBlockByNumber %d : %w", i, err)
+		}
+
+		if len(result.blocks) > 0 && result.blocks[len(result.blocks)-1].Hash != blkInfo.ParentHash {
+			return nil, errors.New("chain changed during the scan")
+		}
+		if result.first == nil {
+			result.first = blkInfo
+		}
+		result.blocks = append(result.blocks, blkInfo)
+
+		if len(blkInfo.Transactions) == 0 {
+			c.Logger.Info("tasks - scanBlocks - no transactions found", zap.Int("block", i))
+			continue
+		}
+
+		receipts := map[string]*ethrpc.TransactionReceipt{}
+		for _, tr := range blkInfo.Transactions {
+			if !addresses[strings.ToLower(tr.From)] && !addresses[strings.ToLower(tr.To)] {
+				continue
+			}
+
+			receipt, err := c.EthClient.EthGetTransactionReceipt(tr.Hash)
+			if err != nil || receipt == nil {
+				return nil, fmt.Errorf("EthGetTransactionReceipt %s : %w", tr.Hash, err)
+			}
+			receipts[tr.Hash] = receipt
+		}
+
+		c.Logger.Sugar().Debugf("block %d analyzed, %d total transactions, %d of the contracts", i, len(blkInfo.Transactions), len(receipts))
+
+		notifications := c.BlockManager.HandleTransactions(contracts, blkInfo.Transactions, receipts)
+		if len(notifications) > 0 {
+			result.events[i] = notifications
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,229 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onrik/ethrpc"
+	"github.com/saiset-co/saiEthIndexer/config"
+	"github.com/saiset-co/saiEthIndexer/pkg/eth"
+	"go.uber.org/zap"
+)
+
+// json-rpc node with empty blocks and the logs, eth_getLogs over more than logsLimit blocks is rejected
+type fakeNode struct {
+	sync.Mutex
+	head      int
+	logsLimit int // 0 - no limit
+	logs      []ethrpc.Log
+	ranges    [][2]int      // eth_getLogs requests
+	gate      chan struct{} // eth_getLogs waits until it is closed, nil - no wait
+}
+
+func blockHash(number int) string {
+	return fmt.Sprintf("0x%064x", number+1)
+}
+
+func (n *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     int               `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Method == "eth_getLogs" && n.gate != nil {
+		<-n.gate
+	}
+
+	n.Lock()
+	defer n.Unlock()
+
+	var result interface{}
+	var rpcErr interface{}
+
+	switch req.Method {
+	case "eth_blockNumber":
+		result = fmt.Sprintf("0x%x", n.head)
+	case "eth_getBlockByNumber":
+		var hex string
+		json.Unmarshal(req.Params[0], &hex)
+		number, _ := strconv.ParseInt(hex, 0, 64)
+		result = map[string]interface{}{
+			"number":       hex,
+			"hash":         blockHash(int(number)),
+			"parentHash":   blockHash(int(number) - 1),
+			"transactions": []interface{}{},
+		}
+	case "eth_getLogs":
+		var filter struct {
+			FromBlock string `json:"fromBlock"`
+			ToBlock   string `json:"toBlock"`
+		}
+		json.Unmarshal(req.Params[0], &filter)
+		from, _ := strconv.ParseInt(filter.FromBlock, 0, 64)
+		to, _ := strconv.ParseInt(filter.ToBlock, 0, 64)
+		n.ranges = append(n.ranges, [2]int{int(from), int(to)})
+
+		if n.logsLimit > 0 && int(to-from+1) > n.logsLimit {
+			rpcErr = map[string]interface{}{"code": -32005, "message": "query returned more than 10000 results"}
+			break
+		}
+
+		logs := []map[string]interface{}{}
+		for _, l := range n.logs {
+			if l.BlockNumber < int(from) || l.BlockNumber > int(to) {
+				continue
+			}
+			logs = append(logs, map[string]interface{}{
+				"address":          l.Address,
+				"topics":           l.Topics,
+				"data":             l.Data,
+				"blockNumber":      fmt.Sprintf("0x%x", l.BlockNumber),
+				"blockHash":        blockHash(l.BlockNumber),
+				"transactionHash":  l.TransactionHash,
+				"transactionIndex": "0x0",
+				"logIndex":         fmt.Sprintf("0x%x", l.LogIndex),
+			})
+		}
+		result = logs
+	case "eth_getTransactionByHash":
+		var hash string
+		json.Unmarshal(req.Params[0], &hash)
+		result = map[string]interface{}{"hash": hash, "input": "0x", "value": "0x0"}
+	default:
+		rpcErr = map[string]interface{}{"code": -32601, "message": "method not found"}
+	}
+
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	if rpcErr != nil {
+		response["error"] = rpcErr
+	} else {
+		response["result"] = result
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// chain of the fake node in logs mode, checkpoints are in the temp dir of the test
+func newTestChain(t *testing.T, node *fakeNode, logs config.Logs) *Chain {
+	srv := httptest.NewServer(node)
+	t.Cleanup(srv.Close)
+
+	pool, err := eth.NewPool([]string{srv.URL}, 1, 0, time.Second, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoints, err := NewCheckpoints(filepath.Join(t.TempDir(), "checkpoints.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Configuration{
+		Specific:     config.Specific{Mode: config.ModeLogs, Logs: logs},
+		EthContracts: config.EthContracts{Mutex: &sync.RWMutex{}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	return &Chain{
+		Config:       cfg,
+		Network:      config.Network{Name: "test"},
+		EthClient:    pool,
+		Logger:       zap.NewNop(),
+		BlockManager: &BlockManager{config: cfg, logger: zap.NewNop(), network: "test", chainID: 1},
+		Checkpoints:  checkpoints,
+		primary:      true,
+		ctx:          ctx,
+		cancel:       cancel,
+		backfills:    map[string]bool{},
+	}
+}
+
+func TestScanRangeHalving(t *testing.T) {
+	contracts := []config.Contract{{Address: "0x00000000000000000000000000000000000000aa", ABI: "[]"}}
+
+	tests := []struct {
+		name      string
+		logsLimit int
+		size      int
+		max       int
+		limit     int
+		ranges    [][2]int // eth_getLogs requests
+		to        int
+		nextSize  int
+	}{
+		{
+			name: "no provider limit", size: 100, max: 400, limit: 1000,
+			ranges: [][2]int{{1, 100}}, to: 100, nextSize: 200,
+		},
+		{
+			name: "halved to the provider limit", logsLimit: 30, size: 100, max: 400, limit: 1000,
+			ranges: [][2]int{{1, 100}, {1, 50}, {1, 25}}, to: 25, nextSize: 50,
+		},
+		{
+			name: "doubled up to max", size: 300, max: 400, limit: 1000,
+			ranges: [][2]int{{1, 300}}, to: 300, nextSize: 400,
+		},
+		{
+			name: "range is cut at the limit", logsLimit: 10, size: 100, max: 400, limit: 20,
+			ranges: [][2]int{{1, 20}, {1, 10}}, to: 10, nextSize: 20,
+		},
+		{
+			name: "halved down to a single block", logsLimit: 1, size: 4, max: 4, limit: 1000,
+			ranges: [][2]int{{1, 4}, {1, 2}, {1, 1}}, to: 1, nextSize: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &fakeNode{head: 2000, logsLimit: tt.logsLimit}
+			c := newTestChain(t, node, config.Logs{BlockRange: tt.size, MaxBlockRange: tt.max})
+
+			r := c.newRangeSize()
+			result, to, err := c.scanRange(contracts, 1, tt.limit, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if to != tt.to || r.size != tt.nextSize {
+				t.Fatalf("to = %d, range = %d, want %d, %d", to, r.size, tt.to, tt.nextSize)
+			}
+			if !reflect.DeepEqual(node.ranges, tt.ranges) {
+				t.Fatalf("eth_getLogs ranges = %v, want %v", node.ranges, tt.ranges)
+			}
+			if result.first.Number != 1 || result.blocks[len(result.blocks)-1].Number != to {
+				t.Fatalf("blocks %d-%d, want 1-%d", result.first.Number, result.blocks[len(result.blocks)-1].Number, to)
+			}
+		})
+	}
+}
+
+func TestScanRangeOtherErrors(t *testing.T) {
+	node := &fakeNode{head: 2000}
+	c := newTestChain(t, node, config.Logs{BlockRange: 100, MaxBlockRange: 400})
+
+	// errors other than provider limits are returned without halving
+	contracts := []config.Contract{{Address: "0x00000000000000000000000000000000000000aa", ABI: "not json", Events: []string{"Ping"}}}
+
+	r := c.newRangeSize()
+	if _, _, err := c.scanRange(contracts, 1, 1000, r); err == nil {
+		t.Fatal("scan with broken abi succeeded")
+	}
+	if r.size != 100 {
+		t.Fatalf("range = %d, want 100", r.size)
+	}
+}
@@ -0,0 +1,123 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saiset-co/saiEthIndexer/internal/metrics"
+	"github.com/saiset-co/saiEthIndexer/pkg/eth"
+	"go.uber.org/zap"
+)
+
+const maxReconnectDelay = time.Minute
+
+// Subscribe - subscribe to new heads and logs of the contracts by websocket and wake the indexing loop on notifications.
+// Notifications are not indexed directly: loop fetches blocks from the last stored one, so blocks missed while
+// connection was lost are backfilled by the polling path
+func (c *Chain) Subscribe() {
+	minDelay := time.Duration(c.Config.Specific.Subscription.Reconnect) * time.Second
+	if minDelay <= 0 {
+		minDelay = time.Second
+	}
+	delay := minDelay
+
+	for {
+		subscribed, err := c.subscribe()
+		if subscribed {
+			delay = minDelay
+		}
+		c.Logger.Error("tasks - Subscribe - subscription is lost, reconnecting", zap.Duration("delay", delay), zap.Error(err))
+		metrics.SubscriptionReconnects.WithLabelValues(c.Network.Name).Inc()
+
+		// catch up by polling while reconnecting
+		c.notify()
+
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// connect, subscribe and wait for notifications until connection is lost or contracts are changed
+func (c *Chain) subscribe() (bool, error) {
+	sub, err := eth.DialSubscriber(c.Network.WebSocket)
+	if err != nil {
+		return false, fmt.Errorf("DialSubscriber : %w", err)
+	}
+	defer sub.Close()
+
+	err = sub.Subscribe("newHeads")
+	if err != nil {
+		return false, fmt.Errorf("subscribe newHeads : %w", err)
+	}
+
+	filter, err := c.BlockManager.LogFilter(c.contracts())
+	if err != nil {
+		return false, fmt.Errorf("LogFilter : %w", err)
+	}
+	if len(filter.Address) != 0 {
+		params := map[string]interface{}{"address": filter.Address}
+		if len(filter.Topics) != 0 {
+			params["topics"] = filter.Topics
+		}
+
+		err = sub.Subscribe("logs", params)
+		if err != nil {
+			return false, fmt.Errorf("subscribe logs : %w", err)
+		}
+	}
+
+	c.Logger.Info("tasks - Subscribe - subscribed", zap.String("url", c.Network.WebSocket), zap.Int("contracts", len(filter.Address)))
+
+	// blocks produced before the subscription
+	c.notify()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-c.resubscribe:
+			c.Logger.Info("tasks - Subscribe - contracts are changed, renew subscription")
+			sub.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		n, err := sub.Next()
+		if err != nil {
+			return true, err
+		}
+
+		switch n.Kind {
+		case "newHeads":
+			header := struct {
+				Number string `json:"number"`
+			}{}
+			if json.Unmarshal(n.Result, &header) == nil {
+				head, err := strconv.ParseInt(strings.TrimPrefix(header.Number, "0x"), 16, 64)
+				if err == nil {
+					metrics.SetHead(c.Network.Name, int(head))
+					c.Logger.Sugar().Debugf("new head from subscription : %d", head)
+				}
+			}
+		case "logs":
+			c.Logger.Sugar().Debugf("log from subscription : %s", n.Result)
+		}
+
+		c.notify()
+	}
+}
+
+// wake the indexing loop
+func (c *Chain) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
@@ -2,15 +2,12 @@ package tasks
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/adam-lavrik/go-imath/ix"
-	"os"
+	"strings"
 	"sync"
-	"time"
 
-	"github.com/onrik/ethrpc"
 	"github.com/saiset-co/saiEthIndexer/config"
-	"github.com/saiset-co/saiEthIndexer/pkg/eth"
 	"github.com/saiset-co/saiEthIndexer/utils"
 	"go.uber.org/zap"
 )
@@ -20,226 +17,253 @@ const (
 	contractsPath = "./contracts.json"
 )
 
+var (
+	ErrContractExists   = errors.New("contract already exists")
+	ErrContractNotFound = errors.New("contract not found")
+	ErrInvalidContract  = errors.New("invalid contract")
+)
+
 type TaskManager struct {
-	Config       *config.Configuration
-	EthClient    *ethrpc.EthRPC
-	Logger       *zap.Logger
-	BlockManager *BlockManager
-	resultChan   chan error
-}
+	Config *config.Configuration
+	Logger *zap.Logger
+	Chains []*Chain    // indexers of the networks, the first one is primary
+	Events *EventStore // events storage for queries, nil if it is disabled
 
-var StopLoop bool
+	replaysMutex sync.Mutex
+	replays      []*ReplayJob
+}
 
 func NewManager(config *config.Configuration, logger *zap.Logger) (*TaskManager, error) {
-	ethClient, err := eth.GetClient(config.Specific.GethServer, logger)
-	if err != nil {
-		return nil, err
+	t := &TaskManager{
+		Config: config,
+		Logger: logger,
 	}
 
-	blockManager := NewBlockManager(*config, logger)
-
-	return &TaskManager{
-		Config:       config,
-		EthClient:    ethClient,
-		Logger:       logger,
-		BlockManager: blockManager,
-		resultChan:   make(chan error),
-	}, nil
-}
-
-// Process blocks, which got from geth-server
-func (t *TaskManager) ProcessBlocks() {
+	names := map[string]bool{}
+	for i, network := range config.Specific.EthNetworks() {
+		if network.Name == "" || names[network.Name] {
+			return nil, fmt.Errorf("network %d : name is empty or duplicated", i)
+		}
+		names[network.Name] = true
 
-	for {
-		StopLoop = false
-		blockID, err := t.EthClient.EthBlockNumber()
+		chain, err := NewChain(config, network, i == 0, logger)
 		if err != nil {
-			t.Logger.Error("tasks - ProcessBlocks - get block number from eth client", zap.Error(err))
-			continue
+			return nil, fmt.Errorf("network %s : %w", network.Name, err)
 		}
+		t.Chains = append(t.Chains, chain)
+	}
 
-		t.Logger.Sugar().Debugf("get most recent block from geth-server : %d", blockID)
-
-		blk, err := t.BlockManager.GetLastBlock(blockID)
+	if config.Specific.Storage.Enabled {
+		t.Events = NewEventStore(newRepo(config), "")
+		err := t.Events.CreateIndexes()
 		if err != nil {
-			continue
+			logger.Error("tasks - NewManager - create indexes of events storage", zap.Error(err))
 		}
+	}
 
-		t.Logger.Sugar().Debugf("get most recent block from storage : %d", blockID)
-
-		for i := blk.ID; i <= blockID; i++ {
-			blkInfo, err := t.EthClient.EthGetBlockByNumber(i, true)
-			if err != nil || blkInfo == nil {
-				blk.ID = i
-				t.BlockManager.SetLastBlock(blk)
-				t.Logger.Error("tasks - ProcessBlocks - get block by number from server", zap.Error(err))
-				continue
-			}
-
-			if len(blkInfo.Transactions) == 0 {
-				blk.ID = i
-				t.BlockManager.SetLastBlock(blk)
-				t.Logger.Info("tasks - ProcessBlocks - get block by number from server - transactions - no transactions found", zap.Int("current block id in for cycle", i), zap.Int("current block id from eth server", blockID))
-				continue
-			}
-
-			t.Logger.Sugar().Debugf("block %d from %d analyzed, %d total transactions", i, blockID, len(blkInfo.Transactions))
-			receipts := map[string]*ethrpc.TransactionReceipt{}
-
-			for _, tr := range blkInfo.Transactions {
-				receipt, err := t.EthClient.EthGetTransactionReceipt(tr.Hash)
-
-				if err != nil {
-					t.Logger.Error("tasks - ProcessBlocks - get transaction receipt from server", zap.Error(err))
-					continue
-				}
-
-				receipts[tr.Hash] = receipt
-			}
-
-			t.BlockManager.HandleTransactions(blkInfo.Transactions, receipts)
-
-			if StopLoop {
-				t.Logger.Sugar().Debug("Got new contract, will continue with the new lowest block.")
-				break
-			} else {
-				blk.ID = i
-				t.BlockManager.SetLastBlock(blk)
-			}
-		}
+	return t, nil
+}
 
-		time.Sleep(time.Duration(t.Config.Specific.Sleep) * time.Second)
+// FindEvents - page of the stored events by the query
+func (t *TaskManager) FindEvents(q EventsQuery) (*EventsPage, error) {
+	if t.Events == nil {
+		return nil, ErrStorageDisabled
 	}
+	return t.Events.Find(q)
 }
 
-func (t *TaskManager) AddContract(contracts []config.Contract) error {
-	t.Config.EthContracts.Mutex.Lock()
-	defer t.Config.EthContracts.Mutex.Unlock()
-	t.Config.EthContracts.Contracts = append(t.Config.EthContracts.Contracts, contracts...)
-
-	var blocks []int
-	for i := 0; i < len(contracts); i++ {
-		blocks = append(blocks, contracts[i].StartBlock)
+// Run - index the networks in the configured mode
+func (t *TaskManager) Run() {
+	wg := sync.WaitGroup{}
+	for _, chain := range t.Chains {
+		wg.Add(1)
+		go func(chain *Chain) {
+			defer wg.Done()
+			chain.Run()
+		}(chain)
 	}
+	wg.Wait()
+}
 
-	lblk, glbErr := t.BlockManager.GetLastBlock(0)
-	if glbErr != nil {
-		t.Logger.Error("task - addContract - get last block", zap.Error(glbErr))
-		return glbErr
+// Stop - stop workers of the networks after their current ranges
+func (t *TaskManager) Stop() {
+	wg := sync.WaitGroup{}
+	for _, chain := range t.Chains {
+		wg.Add(1)
+		go func(chain *Chain) {
+			defer wg.Done()
+			chain.Stop()
+		}(chain)
 	}
+	wg.Wait()
+}
 
-	blocks = append(blocks, lblk.ID)
+// chain of the network, primary chain for contracts without network
+func (t *TaskManager) chain(network string) (*Chain, bool) {
+	for _, chain := range t.Chains {
+		if chain.Network.Name == network || (network == "" && chain.primary) {
+			return chain, true
+		}
+	}
+	return nil, false
+}
 
-	blk := &Block{ID: ix.MinSlice(blocks)}
-	t.BlockManager.SetLastBlock(blk)
-	StopLoop = true
+// Contracts - contracts of the config
+func (t *TaskManager) Contracts() []config.Contract {
+	t.Config.EthContracts.Mutex.RLock()
+	defer t.Config.EthContracts.Mutex.RUnlock()
 
-	go t.RewriteContractsConfig(contractsPath)
+	return append([]config.Contract{}, t.Config.EthContracts.Contracts...)
+}
 
-	err := <-t.resultChan
-	if err != nil {
-		return err
+// Contract - contract of the config by address
+func (t *TaskManager) Contract(address string) (config.Contract, error) {
+	contracts := t.Contracts()
+	i := contractIndex(contracts, address)
+	if i < 0 {
+		return config.Contract{}, fmt.Errorf("%w : %s", ErrContractNotFound, address)
 	}
-	t.Logger.Sugar().Debugf("contracts")
-
-	return nil
+	return contracts[i], nil
 }
 
-func (t *TaskManager) RewriteContractsConfig(contractsConfigPath string) {
-	data, err := json.MarshalIndent(&t.Config.EthContracts, "", "	")
-	if err != nil {
-		t.Logger.Error("task - addContract - rewrite contracts config - marshal contracts", zap.Error(err))
-		t.resultChan <- err
-		return
+// AddContract - add contracts to the config, they are indexed from their start blocks by backfill workers
+// without rewinding the other contracts. Nothing is added if any contract is invalid or already exists
+func (t *TaskManager) AddContract(contracts []config.Contract) error {
+	for _, contract := range contracts {
+		err := t.validateContract(contract)
+		if err != nil {
+			return err
+		}
 	}
 
-	f, err := os.OpenFile(contractsPath, os.O_RDWR|os.O_TRUNC, 0755)
-	if err != nil {
-		t.Logger.Error("task - addContract - rewrite contracts config - open contract config file", zap.Error(err))
-		t.resultChan <- err
-		return
-	}
+	t.Config.EthContracts.Mutex.Lock()
+	defer t.Config.EthContracts.Mutex.Unlock()
 
-	err = f.Truncate(0)
-	if err != nil {
-		t.Logger.Error("task - addContract - rewrite contracts config - truncate", zap.Error(err))
-		t.resultChan <- err
-		return
+	list := append([]config.Contract{}, t.Config.EthContracts.Contracts...)
+	for _, contract := range contracts {
+		if contractIndex(list, contract.Address) >= 0 {
+			return fmt.Errorf("%w : %s", ErrContractExists, contract.Address)
+		}
+		list = append(list, contract)
 	}
 
-	_, err = f.Seek(0, 0)
+	err := t.saveContracts(list)
 	if err != nil {
-		t.Logger.Error("task - addContract - rewrite contracts config - seek", zap.Error(err))
-		t.resultChan <- err
-		return
+		return err
 	}
 
-	_, err = f.Write(data)
-	if err != nil {
-		t.Logger.Error("task - addContract - rewrite contracts config - write", zap.Error(err))
-		t.resultChan <- err
-		return
+	t.Logger.Info("tasks - AddContract - contracts are added", zap.Strings("addresses", addresses(contracts)))
+	return nil
+}
+
+// UpdateContract - replace the contract of the config, address can't be changed.
+// Indexed contract keeps its checkpoint, so changed start block doesn't rewind it
+func (t *TaskManager) UpdateContract(address string, contract config.Contract) error {
+	if contract.Address == "" {
+		contract.Address = address
+	}
+	if !strings.EqualFold(contract.Address, address) {
+		return fmt.Errorf("%w : address %s can't be changed to %s", ErrInvalidContract, address, contract.Address)
 	}
 
-	err = t.ReloadContracts()
+	err := t.validateContract(contract)
 	if err != nil {
-		t.Logger.Error("task - addContract - rewrite contracts config - reload contract", zap.Error(err))
-		t.resultChan <- err
-		return
+		return err
 	}
 
-	t.resultChan <- nil
-
-	t.Logger.Sugar().Infof("active config : %+v\n", t.Config)
-	return
-}
+	t.Config.EthContracts.Mutex.Lock()
+	defer t.Config.EthContracts.Mutex.Unlock()
 
-// reload config after contracts was added via http add_contracts endpoint
-func (t *TaskManager) ReloadContracts() error {
-	contracts := config.EthContracts{}
-	b, err := os.ReadFile(contractsPath)
-	if err != nil {
-		return fmt.Errorf("contracts json read error: %w", err)
+	list := append([]config.Contract{}, t.Config.EthContracts.Contracts...)
+	i := contractIndex(list, address)
+	if i < 0 {
+		return fmt.Errorf("%w : %s", ErrContractNotFound, address)
 	}
-	err = json.Unmarshal(b, &contracts)
+	list[i] = contract
+
+	err = t.saveContracts(list)
 	if err != nil {
-		return fmt.Errorf("contracts json unmarshal error: %w", err)
+		return err
 	}
 
-	t.Config.EthContracts = contracts
-	t.Config.EthContracts.Mutex = new(sync.RWMutex)
-	t.BlockManager.config.EthContracts = contracts
-	t.BlockManager.config.EthContracts.Mutex = new(sync.RWMutex)
+	t.Logger.Info("tasks - UpdateContract - contract is updated", zap.String("address", contract.Address))
 	return nil
 }
 
+// DeleteContract - remove contracts from the config, nothing is removed if any of them is not found
 func (t *TaskManager) DeleteContract(contracts []string) error {
 	t.Config.EthContracts.Mutex.Lock()
 	defer t.Config.EthContracts.Mutex.Unlock()
-	var notFoundAddresses []string
 
-LOOP:
-	for _, incomingAddress := range contracts {
-		for i, existingContract := range t.Config.EthContracts.Contracts {
-			t.Logger.Sugar().Infof("address : %s, index : %d", existingContract.Address, i)
-			if incomingAddress == existingContract.Address {
-				t.Config.EthContracts.Contracts = utils.RemoveContract(t.Config.EthContracts.Contracts, i)
-				continue LOOP
-			}
+	list := append([]config.Contract{}, t.Config.EthContracts.Contracts...)
+	var notFoundAddresses []string
+	for _, address := range contracts {
+		i := contractIndex(list, address)
+		if i < 0 {
+			notFoundAddresses = append(notFoundAddresses, address)
+			continue
 		}
-		notFoundAddresses = append(notFoundAddresses, incomingAddress)
+		list = utils.RemoveContract(list, i)
 	}
 
 	if len(notFoundAddresses) != 0 {
-		t.Logger.Info("Not found addresses to delete", zap.Strings("addresses", notFoundAddresses), zap.Int("len", len(notFoundAddresses)))
+		return fmt.Errorf("%w : %s", ErrContractNotFound, strings.Join(notFoundAddresses, ", "))
 	}
 
-	go t.RewriteContractsConfig(contractsPath)
-
-	err := <-t.resultChan
+	err := t.saveContracts(list)
 	if err != nil {
 		return err
 	}
 
+	t.Logger.Info("tasks - DeleteContract - contracts are deleted", zap.Strings("addresses", contracts))
 	return nil
 }
+
+// abi, operations, events and network of the contract
+func (t *TaskManager) validateContract(contract config.Contract) error {
+	err := contract.Validate()
+	if err != nil {
+		return fmt.Errorf("%w : contract %s : %s", ErrInvalidContract, contract.Address, err)
+	}
+
+	if _, ok := t.chain(contract.Network); !ok {
+		return fmt.Errorf("%w : contract %s : unknown network %s", ErrInvalidContract, contract.Address, contract.Network)
+	}
+	return nil
+}
+
+// write contracts to contracts.json and apply them, contracts mutex must be locked.
+// Live workers assign new contracts and renew logs subscription
+func (t *TaskManager) saveContracts(contracts []config.Contract) error {
+	data, err := json.MarshalIndent(&config.EthContracts{Contracts: contracts}, "", "	")
+	if err != nil {
+		return fmt.Errorf("MarshalIndent : %w", err)
+	}
+
+	err = writeFileAtomic(contractsPath, data)
+	if err != nil {
+		return fmt.Errorf("writeFileAtomic : %w", err)
+	}
+
+	t.Config.EthContracts.Contracts = contracts
+
+	for _, chain := range t.Chains {
+		chain.notify()
+
+		select {
+		case chain.resubscribe <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// index of the contract by address, -1 if it is not found
+func contractIndex(contracts []config.Contract, address string) int {
+	for i, contract := range contracts {
+		if strings.EqualFold(contract.Address, address) {
+			return i
+		}
+	}
+	return -1
+}
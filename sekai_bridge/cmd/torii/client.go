@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Client - saiService api client of the bridge node
+type Client struct {
+	URL   string
+	Token string
+}
+
+type apiRequest struct {
+	Method   string            `json:"method"`
+	Data     interface{}       `json:"data"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+type apiError struct {
+	Status string `json:"Status"`
+	Error  string `json:"Error"`
+}
+
+// Call - call node handler, response is decoded to out if it is not nil
+func (c *Client) Call(method string, data, out interface{}) error {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	payload, err := json.Marshal(apiRequest{
+		Method:   method,
+		Data:     data,
+		Metadata: map[string]string{"token": c.Token},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal : %w", err)
+	}
+
+	// keygen and signing wait for the whole ceremony
+	client := &http.Client{Timeout: 20 * time.Minute}
+
+	res, err := client.Post(c.URL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		apiErr := apiError{}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s : %s (status %d)", method, apiErr.Error, res.StatusCode)
+		}
+		return fmt.Errorf("%s : status %d : %s", method, res.StatusCode, body)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	err = json.Unmarshal(body, out)
+	if err != nil {
+		return fmt.Errorf("unmarshal %s response : %w", method, err)
+	}
+
+	return nil
+}
+
+func (c *Client) requireToken() error {
+	if c.Token == "" {
+		return errors.New("api token is not set, use --token or TORII_TOKEN")
+	}
+	return nil
+}
+
+func jsonIndent(v interface{}) ([]byte, error) {
+	return json.MarshalIndent(v, "", "  ")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"github.com/KiraCore/sekai-bridge/types"
+	"github.com/spf13/cobra"
+)
+
+func newKeygenCmd(client *Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "keygen",
+		Short: "Start tss keygen ceremony, waits until the key is generated",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := client.requireToken(); err != nil {
+				return err
+			}
+
+			var y interface{}
+			err := client.Call("keygen", nil, &y)
+			if err != nil {
+				return err
+			}
+
+			return printJSON(cmd, map[string]interface{}{"pubkey_y": y})
+		},
+	}
+}
+
+func newReshareCmd(client *Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "reshare",
+		Short: "Reshare tss key to the new committee (not supported by the node yet)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := client.requireToken(); err != nil {
+				return err
+			}
+
+			var result interface{}
+			err := client.Call("reshare", nil, &result)
+			if err != nil {
+				return err
+			}
+
+			return printJSON(cmd, result)
+		},
+	}
+}
+
+func newSessionsCmd(client *Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "sessions",
+		Short: "Show keygen and keysign ceremonies state",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := client.requireToken(); err != nil {
+				return err
+			}
+
+			sessions := types.TssSessions{}
+			err := client.Call("sessions", nil, &sessions)
+			if err != nil {
+				return err
+			}
+
+			return printJSON(cmd, sessions)
+		},
+	}
+}
+
+func newCommitteeCmd(client *Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "committee",
+		Short: "Show tss committee health",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := client.requireToken(); err != nil {
+				return err
+			}
+
+			health := types.CommitteeHealth{}
+			err := client.Call("committee", nil, &health)
+			if err != nil {
+				return err
+			}
+
+			return printJSON(cmd, health)
+		},
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/KiraCore/sekai-bridge/types"
+	"github.com/binance-chain/tss-lib/common"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcutil/bech32"
+	"github.com/spf13/cobra"
+	"github.com/tendermint/tendermint/crypto/secp256k1"
+	"golang.org/x/crypto/sha3"
+)
+
+type pubkeyExport struct {
+	Uncompressed string `json:"uncompressed"`
+	Compressed   string `json:"compressed"`
+	Ethereum     string `json:"ethereum"`
+	Bech32       string `json:"bech32"`
+}
+
+func newPubkeyCmd(client *Client) *cobra.Command {
+	var prefix string
+
+	cmd := &cobra.Command{
+		Use:   "pubkey",
+		Short: "Export bridge tss public key as Ethereum address and bech32 address",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pub, err := fetchPubkey(client)
+			if err != nil {
+				return err
+			}
+
+			address, err := bech32Address(prefix, pub)
+			if err != nil {
+				return err
+			}
+
+			return printJSON(cmd, pubkeyExport{
+				Uncompressed: hex.EncodeToString(pub.SerializeUncompressed()),
+				Compressed:   hex.EncodeToString(pub.SerializeCompressed()),
+				Ethereum:     ethAddress(pub),
+				Bech32:       address,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&prefix, "prefix", "kira", "bech32 address prefix")
+
+	return cmd
+}
+
+func newVerifyCmd(client *Client) *cobra.Command {
+	var (
+		msg       string
+		signature string
+		pubkeyHex string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify tss signature against the bridge public key",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				pub *btcec.PublicKey
+				err error
+			)
+
+			if pubkeyHex != "" {
+				pub, err = parsePubkey(pubkeyHex)
+			} else {
+				pub, err = fetchPubkey(client)
+			}
+			if err != nil {
+				return err
+			}
+
+			valid, err := verifySignature(pub, msg, signature)
+			if err != nil {
+				return err
+			}
+
+			err = printJSON(cmd, map[string]bool{"is_valid": valid})
+			if err != nil {
+				return err
+			}
+
+			if !valid {
+				return errors.New("signature is not valid")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&msg, "msg", "", "signed message, as it was sent to the sign handler")
+	cmd.Flags().StringVar(&signature, "signature", "", "base64 signature returned by the sign handler")
+	cmd.Flags().StringVar(&pubkeyHex, "pubkey", "", "hex public key, fetched from the node if empty")
+	cmd.MarkFlagRequired("msg")
+	cmd.MarkFlagRequired("signature")
+
+	return cmd
+}
+
+func newTokenCmd(client *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Manage node api token",
+	}
+
+	var newToken string
+
+	rotate := &cobra.Command{
+		Use:   "rotate",
+		Short: "Replace node api token, random token is generated if --new is empty",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := client.requireToken(); err != nil {
+				return err
+			}
+
+			if newToken == "" {
+				b := make([]byte, 32)
+				_, err := rand.Read(b)
+				if err != nil {
+					return err
+				}
+				newToken = base64.RawURLEncoding.EncodeToString(b)
+			}
+
+			err := client.Call("rotate_token", map[string]string{"token": newToken}, nil)
+			if err != nil {
+				return err
+			}
+
+			return printJSON(cmd, map[string]string{"token": newToken})
+		},
+	}
+
+	rotate.Flags().StringVar(&newToken, "new", "", "new api token")
+	cmd.AddCommand(rotate)
+
+	return cmd
+}
+
+func fetchPubkey(client *Client) (*btcec.PublicKey, error) {
+	pubkey := types.BridgePubkey{}
+	err := client.Call("pubkey", nil, &pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePubkey(pubkey.Uncompressed)
+}
+
+func parsePubkey(s string) (*btcec.PublicKey, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("decode public key : %w", err)
+	}
+
+	pub, err := btcec.ParsePubKey(b, btcec.S256())
+	if err != nil {
+		return nil, fmt.Errorf("parse public key : %w", err)
+	}
+
+	return pub, nil
+}
+
+// signature is base64 encoded json of the tss ecdsa signature, message is verified as is (like node verify handler)
+func verifySignature(pub *btcec.PublicKey, msg, signature string) (bool, error) {
+	b, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, fmt.Errorf("decode signature : %w", err)
+	}
+
+	sig := common.ECSignature{}
+	err = json.Unmarshal(b, &sig)
+	if err != nil {
+		return false, fmt.Errorf("unmarshal signature : %w", err)
+	}
+
+	r := new(big.Int).SetBytes(sig.GetR())
+	s := new(big.Int).SetBytes(sig.GetS())
+
+	return ecdsa.Verify(pub.ToECDSA(), []byte(msg), r, s), nil
+}
+
+// EIP-55 checksummed address: last 20 bytes of keccak256 of the uncompressed key without prefix
+func ethAddress(pub *btcec.PublicKey) string {
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(pub.SerializeUncompressed()[1:])
+	address := hex.EncodeToString(hash.Sum(nil)[12:])
+
+	hash = sha3.NewLegacyKeccak256()
+	hash.Write([]byte(address))
+	checksum := hash.Sum(nil)
+
+	result := []byte(address)
+	for i := range result {
+		nibble := checksum[i/2]
+		if i%2 == 0 {
+			nibble >>= 4
+		}
+		if result[i] >= 'a' && nibble&0x0f >= 8 {
+			result[i] -= 'a' - 'A'
+		}
+	}
+
+	return "0x" + string(result)
+}
+
+// cosmos account address: ripemd160(sha256(compressed key))
+func bech32Address(prefix string, pub *btcec.PublicKey) (string, error) {
+	address := secp256k1.PubKey(pub.SerializeCompressed()).Address()
+
+	data, err := bech32.ConvertBits(address, 8, 5, true)
+	if err != nil {
+		return "", fmt.Errorf("convert bits : %w", err)
+	}
+
+	return bech32.Encode(prefix, data)
+}
@@ -0,0 +1,59 @@
+// torii - admin cli for the sekai bridge node api
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	err := newRootCmd().Execute()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func newRootCmd() *cobra.Command {
+	client := &Client{}
+
+	root := &cobra.Command{
+		Use:          "torii",
+		Short:        "Operate a torii bridge node",
+		SilenceUsage: true,
+	}
+
+	root.PersistentFlags().StringVar(&client.URL, "node", envOr("TORII_NODE", "http://localhost:8885"), "node api url (TORII_NODE)")
+	root.PersistentFlags().StringVar(&client.Token, "token", os.Getenv("TORII_TOKEN"), "node api token (TORII_TOKEN)")
+
+	root.AddCommand(
+		newKeygenCmd(client),
+		newReshareCmd(client),
+		newSessionsCmd(client),
+		newCommitteeCmd(client),
+		newTransfersCmd(client),
+		newVerifyCmd(client),
+		newPubkeyCmd(client),
+		newTokenCmd(client),
+	)
+
+	return root
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// print value as indented json
+func printJSON(cmd *cobra.Command, v interface{}) error {
+	b, err := jsonIndent(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(cmd.OutOrStdout(), string(b))
+	return err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/KiraCore/sekai-bridge/types"
+	"github.com/spf13/cobra"
+)
+
+func newTransfersCmd(client *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "transfers",
+		Short: "List and retry bridge transfers",
+	}
+
+	cmd.AddCommand(newTransfersListCmd(client), newTransfersRetryCmd(client))
+
+	return cmd
+}
+
+func newTransfersListCmd(client *Client) *cobra.Command {
+	var (
+		status string
+		asJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List transfers, newest first",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := client.requireToken(); err != nil {
+				return err
+			}
+
+			transfers := make([]types.Transfer, 0)
+			err := client.Call("transfers", map[string]string{"status": status}, &transfers)
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				return printJSON(cmd, transfers)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tSTATUS\tAMOUNT\tRECIPIENT\tDEST TX\tUPDATED\tERROR")
+			for _, t := range transfers {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					t.ID, t.Status, t.Amount, t.Recipient, t.DestTx,
+					time.Unix(t.UpdatedAt, 0).UTC().Format(time.RFC3339), t.Error)
+			}
+
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&status, "status", "", "filter by status: pending, submitted, confirmed, failed, retracted")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print full transfers as json")
+
+	return cmd
+}
+
+func newTransfersRetryCmd(client *Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "retry <id>",
+		Short: "Sign and submit failed or stuck pending transfer again",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := client.requireToken(); err != nil {
+				return err
+			}
+
+			transfer := types.Transfer{}
+			err := client.Call("retry_transfer", map[string]string{"id": args[0]}, &transfer)
+			if err != nil {
+				return err
+			}
+
+			return printJSON(cmd, transfer)
+		},
+	}
+}
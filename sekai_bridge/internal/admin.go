@@ -0,0 +1,238 @@
+package internal
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/KiraCore/sekai-bridge/types"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/go-playground/validator"
+	"go.uber.org/zap"
+)
+
+// minimal length of the rotated api token
+const minTokenLength = 16
+
+type transfersRequest struct {
+	Status string `json:"status"`
+}
+
+type retryTransferRequest struct {
+	ID string `json:"id" validate:"required"`
+}
+
+type rotateTokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// keygen and keysign ceremonies state
+func (is *InternalService) sessions() (interface{}, int, error) {
+	sessions := types.TssSessions{}
+
+	if keygen := is.Tss.KeygenInstance; keygen != nil {
+		sessions.Keygen.Initialized = true
+		sessions.Keygen.Started = keygen.IsStarted.Load()
+		keygen.KeygenMsgsStorage.RLock()
+		sessions.Keygen.Messages = len(keygen.KeygenMsgsStorage.M)
+		keygen.KeygenMsgsStorage.RUnlock()
+	}
+
+	if keysign := is.Tss.KeysignInstance; keysign != nil {
+		sessions.Keysign.Initialized = true
+		sessions.Keysign.Started = keysign.IsStarted.Load()
+		keysign.KeysignMsgsStorage.RLock()
+		sessions.Keysign.Messages = len(keysign.KeysignMsgsStorage.M)
+		keysign.KeysignMsgsStorage.RUnlock()
+	}
+
+	return sessions, http.StatusOK, nil
+}
+
+// connected tss parties and ability to run ceremonies
+func (is *InternalService) committee() (interface{}, int, error) {
+	health := types.CommitteeHealth{
+		Parties:   is.Tss.Parties,
+		Threshold: is.Tss.Threshold,
+		Quorum:    is.Tss.Quorum,
+		Connected: make(map[string]string),
+		KeyLoaded: is.Tss.Key != nil,
+	}
+
+	if is.Tss.LocalPartyID != nil {
+		health.LocalParty = is.Tss.LocalPartyID.Id
+	}
+
+	is.P2P.RLock()
+	health.P2PConnections = len(is.P2P.ConnectionStorage)
+	is.P2P.RUnlock()
+
+	is.Tss.RLock()
+	for pubkey, addr := range is.Tss.ConnectionStorage {
+		health.Connected[pubkey] = addr
+	}
+	is.Tss.RUnlock()
+
+	// local party is a member of the committee too
+	members := len(health.Connected) + 1
+	health.CanSign = health.KeyLoaded && members >= health.Quorum
+	health.CanKeygen = members >= health.Parties
+
+	return health, http.StatusOK, nil
+}
+
+// ledger transfers filtered by status
+func (is *InternalService) transfers(data interface{}) (interface{}, int, error) {
+	var request transfersRequest
+
+	if data != nil {
+		dataJSON, err := json.Marshal(data)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+
+		err = json.Unmarshal(dataJSON, &request)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	}
+
+	return is.Ledger.List(request.Status), http.StatusOK, nil
+}
+
+// sign and submit failed or stuck pending transfer again
+func (is *InternalService) retryTransfer(data, meta interface{}) (interface{}, int, error) {
+	var request retryTransferRequest
+
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	err = json.Unmarshal(dataJSON, &request)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	err = validator.New().Struct(request)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	transfer, err := is.Ledger.Get(request.ID)
+	if errors.Is(err, errTransferNotFound) {
+		return nil, http.StatusNotFound, err
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	notification := &NotificationRequest{
+		From:           transfer.From,
+		TX:             transfer.Tx,
+		IdempotencyKey: transfer.IdempotencyKey,
+	}
+
+	retried, err := newTransfer(notification)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	existing, isNew, err := is.Ledger.Begin(retried, true)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Ledger.Begin : %w", err)
+	}
+
+	if !isNew {
+		return existing, http.StatusConflict, fmt.Errorf("transfer %s is %s and can't be retried", existing.ID, existing.Status)
+	}
+
+	is.Logger.Info("handlers -> retry_transfer", zap.String("id", retried.ID))
+
+	return is.processTransfer(retried.ID, notification, meta)
+}
+
+// tss public key of the bridge
+func (is *InternalService) pubkey() (interface{}, int, error) {
+	if is.Tss.Key == nil {
+		return nil, http.StatusNotFound, errors.New("key was not generated yet")
+	}
+
+	pub := (*btcec.PublicKey)(is.Tss.Key.ECDSAPub.ToECDSAPubKey())
+
+	return types.BridgePubkey{
+		Uncompressed: hex.EncodeToString(pub.SerializeUncompressed()),
+		Compressed:   hex.EncodeToString(pub.SerializeCompressed()),
+	}, http.StatusOK, nil
+}
+
+// replace api token, new token is saved to the token file and used after restart
+func (is *InternalService) rotateToken(data interface{}) (interface{}, int, error) {
+	var request rotateTokenRequest
+
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	err = json.Unmarshal(dataJSON, &request)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	err = validator.New().Struct(request)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	if len(request.Token) < minTokenLength {
+		return nil, http.StatusBadRequest, fmt.Errorf("token should be at least %d characters long", minTokenLength)
+	}
+
+	is.tokenMu.Lock()
+	defer is.tokenMu.Unlock()
+
+	err = os.WriteFile(is.Context.GetConfig("token_file", "token").(string), []byte(request.Token), 0600)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("write token file : %w", err)
+	}
+
+	is.token = request.Token
+
+	is.Logger.Info("handlers -> rotate_token -> api token was rotated")
+
+	return "ok", http.StatusOK, nil
+}
+
+// api token: rotated token from the token file or token from config
+func (is *InternalService) apiToken() string {
+	is.tokenMu.RLock()
+	defer is.tokenMu.RUnlock()
+
+	if is.token != "" {
+		return is.token
+	}
+
+	return is.Context.GetConfig("token", "").(string)
+}
+
+// read rotated token saved by rotate_token
+func (is *InternalService) loadToken() error {
+	b, err := os.ReadFile(is.Context.GetConfig("token_file", "token").(string))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	is.tokenMu.Lock()
+	is.token = strings.TrimSpace(string(b))
+	is.tokenMu.Unlock()
+
+	return nil
+}
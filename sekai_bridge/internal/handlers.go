@@ -6,11 +6,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/KiraCore/sekai-bridge/metrics"
 	"github.com/KiraCore/sekai-bridge/utils"
 	"github.com/go-playground/validator"
 	jsoniter "github.com/json-iterator/go"
-	"math/big"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/KiraCore/sekai-bridge/tss"
@@ -86,6 +87,22 @@ func (is *InternalService) NewHandler() saiService.Handler {
 				return is.stats()
 			},
 		},
+		"tx_status": saiService.HandlerElement{
+			Name:        "tx_status",
+			Description: "Destination transaction status webhook",
+			Function: func(data, meta interface{}) (interface{}, int, error) {
+				tokenIsValid, err := is.validateToken(meta)
+				if err != nil {
+					return "", http.StatusInternalServerError, err
+				}
+
+				if !tokenIsValid {
+					return "", http.StatusInternalServerError, errors.New("token doe not valid")
+				}
+
+				return is.handleTxStatus(data)
+			},
+		},
 		"notify": saiService.HandlerElement{
 			Name:        "notify",
 			Description: "Notification webhook",
@@ -102,6 +119,103 @@ func (is *InternalService) NewHandler() saiService.Handler {
 				return is.handleTransaction(data, meta)
 			},
 		},
+		"retract": saiService.HandlerElement{
+			Name:        "retract",
+			Description: "Retraction webhook for transactions from orphaned blocks",
+			Function: func(data, meta interface{}) (interface{}, int, error) {
+				tokenIsValid, err := is.validateToken(meta)
+				if err != nil {
+					return "", http.StatusInternalServerError, err
+				}
+
+				if !tokenIsValid {
+					return "", http.StatusInternalServerError, errors.New("token doe not valid")
+				}
+
+				return is.handleRetract(data)
+			},
+		},
+		"sessions": saiService.HandlerElement{
+			Name:        "sessions",
+			Description: "Keygen and keysign ceremonies state",
+			Function: is.tokenRequired(func(data, meta interface{}) (interface{}, int, error) {
+				return is.sessions()
+			}),
+		},
+		"committee": saiService.HandlerElement{
+			Name:        "committee",
+			Description: "TSS committee health",
+			Function: is.tokenRequired(func(data, meta interface{}) (interface{}, int, error) {
+				return is.committee()
+			}),
+		},
+		"transfers": saiService.HandlerElement{
+			Name:        "transfers",
+			Description: "List ledger transfers",
+			Function: is.tokenRequired(func(data, meta interface{}) (interface{}, int, error) {
+				return is.transfers(data)
+			}),
+		},
+		"retry_transfer": saiService.HandlerElement{
+			Name:        "retry_transfer",
+			Description: "Sign and submit failed transfer again",
+			Function:    is.tokenRequired(is.retryTransfer),
+		},
+		"pubkey": saiService.HandlerElement{
+			Name:        "pubkey",
+			Description: "Bridge tss public key",
+			Function: func(data, meta interface{}) (interface{}, int, error) {
+				return is.pubkey()
+			},
+		},
+		"rotate_token": saiService.HandlerElement{
+			Name:        "rotate_token",
+			Description: "Replace api token",
+			Function: is.tokenRequired(func(data, meta interface{}) (interface{}, int, error) {
+				return is.rotateToken(data)
+			}),
+		},
+		"reshare": saiService.HandlerElement{
+			Name:        "reshare",
+			Description: "Reshare tss key to the new committee, not supported yet",
+			Function: is.tokenRequired(func(data, meta interface{}) (interface{}, int, error) {
+				// @TODO: resharing protocol of tss-lib is not wired to p2p yet
+				return nil, http.StatusNotImplemented, errors.New("key resharing is not supported, the new committee has to run keygen")
+			}),
+		},
+		"reserves": saiService.HandlerElement{
+			Name:        "reserves",
+			Description: "Latest proof-of-reserves report",
+			Function: func(data, meta interface{}) (interface{}, int, error) {
+				report := is.Reserves.Last()
+				if report == nil {
+					return nil, http.StatusNotFound, errors.New("reserves were not reconciled yet")
+				}
+
+				return report, http.StatusOK, nil
+			},
+		},
+		"reconcile": saiService.HandlerElement{
+			Name:        "reconcile",
+			Description: "Reconcile bridge reserves with the transfer ledger now",
+			Function: func(data, meta interface{}) (interface{}, int, error) {
+				tokenIsValid, err := is.validateToken(meta)
+				if err != nil {
+					return "", http.StatusInternalServerError, err
+				}
+
+				if !tokenIsValid {
+					return "", http.StatusInternalServerError, errors.New("token doe not valid")
+				}
+
+				report, err := is.reconcile()
+				if err != nil {
+					return report, http.StatusInternalServerError, err
+				}
+
+				return report, http.StatusOK, nil
+			},
+		},
 	}
 }
 
@@ -138,18 +252,26 @@ func (is *InternalService) sign(data interface{}) (interface{}, int, error) {
 
 // stats handler (for debugging)
 func (is *InternalService) stats() (interface{}, int, error) {
+	p2pStats := is.P2P.GetStats()
+
 	stats := &types.SekaiBridgeStats{
 		HTTPPort:             is.P2P.Config.P2P.Http.Port,
 		P2PPort:              is.P2P.Config.P2P.Port,
 		P2PSlot:              is.P2P.Config.P2P.Slot,
 		P2pPeers:             is.P2P.Config.Peers,
-		P2PConnectionStorage: is.P2P.ConnectionStorage,
-		P2PSavedMessages:     make(map[string]bool),
+		P2PConnectionStorage: p2pStats.ConnectionStorage,
+		P2PSavedMessages:     p2pStats.SavedMessages,
 		TssPartyID:           *is.Tss.LocalPartyID,
-		TssConnectionStorage: is.Tss.ConnectionStorage,
+		TssConnectionStorage: make(map[string]string),
 		TssPartiesMap:        make(map[string]bool),
 		TssKeygenMsgStorage:  make(map[string]bool),
 	}
+	is.Tss.RLock()
+	for pubkey, addr := range is.Tss.ConnectionStorage {
+		stats.TssConnectionStorage[pubkey] = addr
+	}
+	is.Tss.RUnlock()
+
 	if is.Tss.PartiesMap != nil {
 		for id := range is.Tss.PartiesMap {
 			stats.TssPartiesMap[id.Id+"|"+id.Moniker+"|"+fmt.Sprintf("index = %d", id.Index)] = true
@@ -161,16 +283,6 @@ func (is *InternalService) stats() (interface{}, int, error) {
 		}
 	}
 
-	it := is.P2P.Cache.Iterator()
-	for it.SetNext() {
-		entryInfo, err := it.Value()
-		if err != nil {
-			is.P2P.Logger.Error("p2p -> server -> GetStats -> it.Value", zap.Error(err))
-			continue
-		}
-		stats.P2PSavedMessages[entryInfo.Key()] = true
-	}
-
 	return stats, 200, nil
 }
 
@@ -221,38 +333,175 @@ func (is *InternalService) handleTransaction(data, meta interface{}) (interface{
 	if err != nil {
 		return nil, 500, err
 	}
+
+	transfer, err := newTransfer(request)
+	if err != nil {
+		return nil, 500, err
+	}
+
+	existing, isNew, err := is.Ledger.Begin(transfer, false)
+	if err != nil {
+		return nil, 500, fmt.Errorf("Ledger.Begin : %w", err)
+	}
+
+	// transfer is already in progress or done
+	if !isNew {
+		if is.Ledger.Stale(existing) {
+			is.Logger.Error("handlers -> notify -> transfer is stuck in pending, it has to be retried by the operator", zap.String("id", existing.ID))
+		} else {
+			is.Logger.Info("handlers -> notify -> transfer already exists", zap.String("id", existing.ID), zap.String("status", existing.Status))
+		}
+		return existing, 200, nil
+	}
+
+	return is.processTransfer(transfer.ID, request, meta)
+}
+
+// sign transaction and submit it to the destination chain, transfer status is saved to the ledger
+func (is *InternalService) processTransfer(id string, request *NotificationRequest, meta interface{}) (interface{}, int, error) {
 	signature, status, err := is.sign(request.TX)
 	if err != nil {
+		is.failTransfer(id, err)
 		return nil, status, err
 	}
 
+	var destTx string
+
 	switch request.From {
 	case "Cosmos":
-		err = is.callEthContract(request.TX, signature, meta)
-		if err != nil {
-			return nil, 500, err
-		}
+		destTx, err = is.callEthContract(request.TX, signature, meta, id)
+		metrics.TxResult(metrics.ChainEthereum, err)
 	case "Ethereum":
-		err = is.callCosmosContract(request.TX, signature, meta)
-		if err != nil {
-			return nil, 500, err
+		destTx, err = is.callCosmosContract(request.TX, signature, meta)
+		metrics.TxResult(metrics.ChainCosmos, err)
+	}
+
+	if err != nil {
+		is.failTransfer(id, err)
+		return nil, 500, err
+	}
+
+	transfer, err := is.Ledger.Update(id, func(t *types.Transfer) {
+		t.Status = types.TransferSubmitted
+		t.DestTx = destTx
+	})
+	if err != nil {
+		return nil, 500, fmt.Errorf("Ledger.Update : %w", err)
+	}
+
+	return transfer, 200, nil
+}
+
+func (is *InternalService) failTransfer(id string, reason error) {
+	_, err := is.Ledger.Update(id, func(t *types.Transfer) {
+		t.Status = types.TransferFailed
+		t.Error = reason.Error()
+	})
+	if err != nil {
+		is.Logger.Error("handlers -> failTransfer -> Ledger.Update", zap.String("id", id), zap.Error(err))
+	}
+}
+
+// destination transaction status reported by the interaction service
+func (is *InternalService) handleTxStatus(data interface{}) (interface{}, int, error) {
+	var request = new(types.TxStatus)
+
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		return nil, 500, err
+	}
+
+	err = json.Unmarshal(dataJson, request)
+	if err != nil {
+		return nil, 500, err
+	}
+
+	err = validator.New().Struct(request)
+	if err != nil {
+		return nil, 400, err
+	}
+
+	transfer, err := is.Ledger.Update(request.Reference, func(t *types.Transfer) {
+		if request.Hash != "" {
+			t.DestTx = request.Hash
+		}
+
+		switch request.Status {
+		case "confirmed":
+			t.Status = types.TransferConfirmed
+			t.Error = ""
+		default:
+			t.Status = types.TransferFailed
+			t.Error = "destination transaction " + request.Status
 		}
+	})
+	if err != nil {
+		return nil, 500, fmt.Errorf("Ledger.Update : %w", err)
+	}
+
+	metrics.TxStatus.WithLabelValues(strings.ToLower(transfer.From), request.Status).Inc()
+
+	is.Logger.Info("handlers -> tx_status", zap.String("id", transfer.ID), zap.String("status", transfer.Status), zap.String("dest_tx", transfer.DestTx))
+
+	return transfer, 200, nil
+}
+
+// source transaction was in the block orphaned by reorg
+func (is *InternalService) handleRetract(data interface{}) (interface{}, int, error) {
+	var request = new(NotificationRequest)
+
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		return nil, 500, err
+	}
+
+	err = json.Unmarshal(dataJson, request)
+	if err != nil {
+		return nil, 500, err
+	}
+
+	retracted, err := newTransfer(request)
+	if err != nil {
+		return nil, 400, err
+	}
+
+	var previous string
+	transfer, err := is.Ledger.Update(retracted.ID, func(t *types.Transfer) {
+		previous = t.Status
+		t.Status = types.TransferRetracted
+		t.Error = "source transaction was in the orphaned block"
+	})
+	if errors.Is(err, errTransferNotFound) {
+		is.Logger.Info("handlers -> retract -> transfer not found", zap.String("id", retracted.ID))
+		return retracted, 200, nil
+	}
+	if err != nil {
+		return nil, 500, fmt.Errorf("Ledger.Update : %w", err)
+	}
+
+	metrics.Retractions.WithLabelValues(strings.ToLower(transfer.From), previous).Inc()
+
+	// funds were already released on the destination chain, operator has to resolve it
+	if transfer.DestTx != "" {
+		is.Logger.Error("handlers -> retract -> transfer was already submitted", zap.String("id", transfer.ID), zap.String("previous_status", previous), zap.String("dest_tx", transfer.DestTx))
+	} else {
+		is.Logger.Warn("handlers -> retract", zap.String("id", transfer.ID), zap.String("previous_status", previous))
 	}
 
-	return data, 200, nil
+	return transfer, 200, nil
 }
 
-func (is *InternalService) callEthContract(txData, signature, meta interface{}) error {
+func (is *InternalService) callEthContract(txData, signature, meta interface{}, reference string) (string, error) {
 	var tx = new(CosmosTx)
 
 	txJson, err := json.Marshal(txData)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	err = json.Unmarshal(txJson, tx)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	url := is.Context.GetConfig("interaction.ethereum", "").(string)
@@ -269,31 +518,49 @@ func (is *InternalService) callEthContract(txData, signature, meta interface{})
 				{Type: "uint256", Value: tx.Amount},
 			},
 			Signature: signature.(*tss.SignMessageResponse).SignatureMarshalled,
+			Reference: reference,
 		},
 		Metadata: meta,
 	}
 
 	payload, err := jsoniter.Marshal(&newRequest)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	_, err = utils.SaiQuerySender(bytes.NewReader(payload), url, "")
+	res, err := utils.SaiQuerySender(bytes.NewReader(payload), url, "")
+	if err != nil {
+		return "", err
+	}
+
+	var response = struct {
+		Result string `json:"Result"`
+	}{}
+
+	err = jsoniter.Unmarshal(res, &response)
+	if err != nil {
+		return "", fmt.Errorf("unmarshal interaction response : %w", err)
+	}
 
-	return err
+	return response.Result, nil
 }
 
-func (is *InternalService) callCosmosContract(txData, signature, meta interface{}) error {
+func (is *InternalService) callCosmosContract(txData, signature, meta interface{}) (string, error) {
 	var tx = new(EthereumTx)
 
 	txJson, err := json.Marshal(txData)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	err = json.Unmarshal(txJson, tx)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	amount, err := strconv.Atoi(tx.Input.Amount.String())
+	if err != nil {
+		return "", fmt.Errorf("amount : %w", err)
 	}
 
 	url := is.Context.GetConfig("interaction.cosmos", "").(string)
@@ -313,7 +580,7 @@ func (is *InternalService) callCosmosContract(txData, signature, meta interface{
 			To:          tx.Input.CyclAddress,
 			ChainId:     sekaiNetwork,
 			Memo:        "Bridge exchange",
-			Amount:      tx.Input.Amount,
+			Amount:      amount,
 			GasLimit:    sekaiGaslimit,
 			FeeAmount:   sekaiFee,
 			Signature:   signature.(*tss.SignMessageResponse).SignatureMarshalled,
@@ -323,12 +590,76 @@ func (is *InternalService) callCosmosContract(txData, signature, meta interface{
 
 	payload, err := jsoniter.Marshal(&newRequest)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	res, err := utils.SaiQuerySender(bytes.NewReader(payload), url, "")
+	if err != nil {
+		return "", err
 	}
 
-	_, err = utils.SaiQuerySender(bytes.NewReader(payload), url, "")
+	var txHash string
 
-	return err
+	err = jsoniter.Unmarshal(res, &txHash)
+	if err != nil {
+		return "", fmt.Errorf("unmarshal interaction response : %w", err)
+	}
+
+	return txHash, nil
+}
+
+// build ledger record from the indexer notification
+func newTransfer(request *NotificationRequest) (*types.Transfer, error) {
+	txJson, err := json.Marshal(request.TX)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := &types.Transfer{
+		From:           request.From,
+		Tx:             request.TX,
+		IdempotencyKey: request.IdempotencyKey,
+	}
+
+	// replay of the event is the same transfer
+	if i := strings.Index(transfer.IdempotencyKey, replayKeySuffix); i >= 0 {
+		transfer.IdempotencyKey = transfer.IdempotencyKey[:i]
+	}
+
+	switch request.From {
+	case "Cosmos":
+		var tx = new(CosmosTx)
+		err = json.Unmarshal(txJson, tx)
+		if err != nil {
+			return nil, err
+		}
+		transfer.SourceTx = tx.Hash
+		transfer.Recipient = tx.To
+		transfer.Amount = tx.Amount
+	case "Ethereum":
+		var tx = new(EthereumTx)
+		err = json.Unmarshal(txJson, tx)
+		if err != nil {
+			return nil, err
+		}
+		transfer.SourceTx = tx.Hash
+		transfer.Recipient = tx.Input.CyclAddress
+		transfer.Amount = tx.Input.Amount.String()
+	default:
+		return nil, fmt.Errorf("unknown source chain %s", request.From)
+	}
+
+	if transfer.SourceTx == "" {
+		return nil, errors.New("source transaction hash is empty")
+	}
+
+	// several events of the same transaction are separate transfers
+	transfer.ID = strings.ToLower(request.From + ":" + transfer.IdempotencyKey)
+	if transfer.IdempotencyKey == "" {
+		transfer.ID = strings.ToLower(request.From + ":" + transfer.SourceTx)
+	}
+
+	return transfer, nil
 }
 
 type CosmosTx struct {
@@ -340,9 +671,10 @@ type CosmosTx struct {
 }
 
 type NotificationRequest struct {
-	From      string      `json:"from"`
-	TX        interface{} `json:"tx"`
-	Signature string      `json:"signature"`
+	From           string      `json:"from"`
+	TX             interface{} `json:"tx"`
+	Signature      string      `json:"signature"`
+	IdempotencyKey string      `json:"idempotency_key"` // same for every delivery of the event, replays have the replay suffix
 }
 
 type verifyRequest struct {
@@ -354,14 +686,15 @@ type verifyResponse struct {
 	Valid bool `json:"is_valid"`
 }
 
+// amounts are decimal strings, numbers of the previous indexer versions are accepted too
 type EthereumTx struct {
-	Amount big.Int `json:"Amount"`
-	From   string  `json:"From"`
-	Hash   string  `json:"Hash"`
+	Amount json.Number `json:"Amount"`
+	From   string      `json:"From"`
+	Hash   string      `json:"Hash"`
 	Input  struct {
-		Amount      int    `json:"amount"`
-		CyclAddress string `json:"cyclAddress"`
-		Hash        string `json:"hash"`
+		Amount      json.Number `json:"amount"`
+		CyclAddress string      `json:"cyclAddress"`
+		Hash        string      `json:"hash"`
 	} `json:"Input"`
 	Number    int    `json:"Number"`
 	Status    bool   `json:"Status"`
@@ -389,5 +722,21 @@ func (is *InternalService) validateToken(meta interface{}) (bool, error) {
 		return false, err
 	}
 
-	return token == is.Context.GetConfig("token", "").(string), nil
+	return token == is.apiToken(), nil
+}
+
+// handler function which requires valid api token in metadata
+func (is *InternalService) tokenRequired(f saiService.HandlerFunc) saiService.HandlerFunc {
+	return func(data, meta interface{}) (interface{}, int, error) {
+		tokenIsValid, err := is.validateToken(meta)
+		if err != nil {
+			return "", http.StatusInternalServerError, err
+		}
+
+		if !tokenIsValid {
+			return "", http.StatusInternalServerError, errors.New("token doe not valid")
+		}
+
+		return f(data, meta)
+	}
 }
@@ -0,0 +1,235 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/KiraCore/sekai-bridge/types"
+)
+
+var errTransferNotFound = errors.New("transfer was not found")
+
+const (
+	// indexers mark replayed deliveries of the event by the suffix of the idempotency key
+	replayKeySuffix = ":replay:"
+
+	// pending transfer which is not updated for this time is considered stuck
+	stalePending = 30 * time.Minute
+)
+
+// Ledger - bridge transfers, persisted to the json file
+type Ledger struct {
+	sync.RWMutex
+	path      string
+	transfers map[string]*types.Transfer
+	loadedAt  int64 // pending transfers updated before are left by the previous run
+}
+
+// load ledger from file, empty ledger if file does not exist
+func NewLedger(path string) (*Ledger, error) {
+	l := &Ledger{
+		path:      path,
+		transfers: make(map[string]*types.Transfer),
+		loadedAt:  time.Now().Unix(),
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return l, nil
+		}
+		return nil, fmt.Errorf("ReadFile : %w", err)
+	}
+
+	transfers := make([]*types.Transfer, 0)
+	err = json.Unmarshal(b, &transfers)
+	if err != nil {
+		return nil, fmt.Errorf("Unmarshal : %w", err)
+	}
+
+	for _, t := range transfers {
+		l.transfers[t.ID] = t
+	}
+
+	return l, nil
+}
+
+// Begin - register new transfer. If transfer exists and can't be restarted, existing copy
+// is returned with false, so the same source transaction is not bridged twice.
+// Stale pending transfer is restarted only by retry of the operator, as it could be already submitted
+func (l *Ledger) Begin(transfer *types.Transfer, retry bool) (types.Transfer, bool, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	existing, ok := l.transfers[transfer.ID]
+	if ok && !canRestart(existing) && !(retry && l.stale(existing)) {
+		return *existing, false, nil
+	}
+
+	started := *transfer
+	now := time.Now().Unix()
+	if ok {
+		started.CreatedAt = existing.CreatedAt
+	} else {
+		started.CreatedAt = now
+	}
+	started.UpdatedAt = now
+	started.Status = types.TransferPending
+	started.Error = ""
+
+	err := l.save(&started)
+	if err != nil {
+		return types.Transfer{}, false, err
+	}
+
+	return started, true, nil
+}
+
+// failed transfers and transfers retracted before submission can be started again
+func canRestart(t *types.Transfer) bool {
+	switch t.Status {
+	case types.TransferFailed:
+		return true
+	case types.TransferRetracted:
+		return t.DestTx == ""
+	}
+	return false
+}
+
+// pending transfer of the previous run or not updated for too long
+func (l *Ledger) stale(t *types.Transfer) bool {
+	return t.Status == types.TransferPending &&
+		(t.UpdatedAt < l.loadedAt || time.Since(time.Unix(t.UpdatedAt, 0)) > stalePending)
+}
+
+// Stale - transfer is stuck in pending and can be retried by the operator
+func (l *Ledger) Stale(t types.Transfer) bool {
+	l.RLock()
+	defer l.RUnlock()
+
+	return l.stale(&t)
+}
+
+// Update - change transfer with f and persist ledger, transfer is not changed if it can't be saved
+func (l *Ledger) Update(id string, f func(t *types.Transfer)) (types.Transfer, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	existing, ok := l.transfers[id]
+	if !ok {
+		return types.Transfer{}, errTransferNotFound
+	}
+
+	transfer := *existing
+	f(&transfer)
+	transfer.UpdatedAt = time.Now().Unix()
+
+	err := l.save(&transfer)
+	if err != nil {
+		return types.Transfer{}, err
+	}
+
+	return transfer, nil
+}
+
+// Get - copy of transfer by id
+func (l *Ledger) Get(id string) (types.Transfer, error) {
+	l.RLock()
+	defer l.RUnlock()
+
+	transfer, ok := l.transfers[id]
+	if !ok {
+		return types.Transfer{}, errTransferNotFound
+	}
+
+	return *transfer, nil
+}
+
+// List - transfers with status (all if status is empty), newest first
+func (l *Ledger) List(status string) []types.Transfer {
+	l.RLock()
+	defer l.RUnlock()
+
+	list := make([]types.Transfer, 0, len(l.transfers))
+	for _, t := range l.transfers {
+		if status != "" && t.Status != status {
+			continue
+		}
+		list = append(list, *t)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt != list[j].CreatedAt {
+			return list[i].CreatedAt > list[j].CreatedAt
+		}
+		return list[i].ID < list[j].ID
+	})
+
+	return list
+}
+
+// write ledger with changed transfer to temp file and rename it, so file is never half-written.
+// Transfer is put to the ledger only when the file is replaced
+func (l *Ledger) save(changed *types.Transfer) error {
+	transfers := make([]*types.Transfer, 0, len(l.transfers)+1)
+	for id, t := range l.transfers {
+		if id != changed.ID {
+			transfers = append(transfers, t)
+		}
+	}
+	transfers = append(transfers, changed)
+
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].ID < transfers[j].ID
+	})
+
+	b, err := json.MarshalIndent(transfers, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal : %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("CreateTemp : %w", err)
+	}
+
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write : %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), l.path)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("rename : %w", err)
+	}
+	l.transfers[changed.ID] = changed
+
+	// rename is durable when the directory entry is flushed
+	dir, err := os.Open(filepath.Dir(l.path))
+	if err != nil {
+		return fmt.Errorf("open dir : %w", err)
+	}
+	err = dir.Sync()
+	if closeErr := dir.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("sync dir : %w", err)
+	}
+
+	return nil
+}
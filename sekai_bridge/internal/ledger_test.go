@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KiraCore/sekai-bridge/types"
+)
+
+func TestLedgerSaveFailure(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewLedger(filepath.Join(dir, "transfers.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, isNew, err := l.Begin(&types.Transfer{ID: "a"}, false)
+	if err != nil || !isNew {
+		t.Fatalf("Begin = %v, %v", isNew, err)
+	}
+
+	// file can't be written, transfers are not changed in memory
+	l.path = filepath.Join(dir, "missing", "transfers.json")
+
+	if _, err = l.Update("a", func(t *types.Transfer) { t.Status = types.TransferSubmitted }); err == nil {
+		t.Fatal("Update succeeded without the file")
+	}
+	if _, _, err = l.Begin(&types.Transfer{ID: "b"}, false); err == nil {
+		t.Fatal("Begin succeeded without the file")
+	}
+
+	if transfer, _ := l.Get("a"); transfer.Status != types.TransferPending {
+		t.Fatalf("status = %s, want %s", transfer.Status, types.TransferPending)
+	}
+	if _, err = l.Get("b"); err != errTransferNotFound {
+		t.Fatalf("transfer which was not saved is in the ledger: %v", err)
+	}
+}
+
+func TestLedgerStalePending(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transfers.json")
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name      string
+		updatedAt int64
+		reload    bool // pending transfer is left by the previous run
+		retry     bool
+		restarted bool
+	}{
+		{"in progress", now, false, false, false},
+		{"in progress retry", now, false, true, false},
+		{"timed out", now - int64(stalePending.Seconds()) - 1, false, false, false},
+		{"timed out retry", now - int64(stalePending.Seconds()) - 1, false, true, true},
+		{"previous run", now - 1, true, false, false},
+		{"previous run retry", now - 1, true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := NewLedger(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err = l.Begin(&types.Transfer{ID: tt.name}, false); err != nil {
+				t.Fatal(err)
+			}
+			l.transfers[tt.name].UpdatedAt = tt.updatedAt
+
+			l.loadedAt = now - int64(stalePending.Seconds()) - 10
+			if tt.reload {
+				l.loadedAt = now
+			}
+
+			_, restarted, err := l.Begin(&types.Transfer{ID: tt.name}, tt.retry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restarted != tt.restarted {
+				t.Fatalf("restarted = %v, want %v", restarted, tt.restarted)
+			}
+		})
+	}
+}
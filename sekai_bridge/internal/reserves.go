@@ -0,0 +1,497 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KiraCore/sekai-bridge/metrics"
+	"github.com/KiraCore/sekai-bridge/types"
+	"github.com/KiraCore/sekai-bridge/utils"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/saiset-co/sai-storage-mongo/external/adapter"
+	"github.com/tendermint/tendermint/crypto/secp256k1"
+	"go.uber.org/zap"
+)
+
+const (
+	// erc-20 balanceOf(address) selector
+	balanceOfSelector = "70a08231"
+
+	// limit of the reconciliation requests, so hung endpoint does not block next reconciliations
+	reservesTimeout = time.Minute
+)
+
+// Reserves - latest proof-of-reserves report
+type Reserves struct {
+	sync.RWMutex
+	key    secp256k1.PrivKey
+	report *types.ReserveReport
+}
+
+type reservesKeyFile struct {
+	PubKey  string `json:"pub_key"`
+	PrivKey string `json:"priv_key"`
+}
+
+type ethCallRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type ethCallResponse struct {
+	Result string `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type bankBalancesResponse struct {
+	Balances []struct {
+		Denom  string `json:"denom"`
+		Amount string `json:"amount"`
+	} `json:"balances"`
+}
+
+// load node reserves signing key, new key is generated if file does not exist
+func NewReserves(path string) (*Reserves, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key := utils.GeneratePrivateKey()
+
+		b, err = json.Marshal(reservesKeyFile{
+			PubKey:  utils.GetPubkeyString(key),
+			PrivKey: base64.StdEncoding.EncodeToString(key),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("marshal : %w", err)
+		}
+
+		err = os.WriteFile(path, b, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("WriteFile : %w", err)
+		}
+
+		return &Reserves{key: key}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ReadFile : %w", err)
+	}
+
+	keyFile := reservesKeyFile{}
+	err = json.Unmarshal(b, &keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal : %w", err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(keyFile.PrivKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode private key : %w", err)
+	}
+
+	if len(key) != secp256k1.PrivKeySize {
+		return nil, fmt.Errorf("wrong private key size %d", len(key))
+	}
+
+	return &Reserves{key: key}, nil
+}
+
+// Last - latest report, nil if reconciliation was not done yet
+func (r *Reserves) Last() *types.ReserveReport {
+	r.RLock()
+	defer r.RUnlock()
+	return r.report
+}
+
+// Reconcile - periodically compare bridge reserves on both chains with the transfer ledger
+func (is *InternalService) Reconcile() {
+	interval, err := time.ParseDuration(is.Context.GetConfig("reserves.interval", "1h").(string))
+	if err != nil || interval <= 0 {
+		is.Logger.Info("reserves -> reconciliation is disabled", zap.Error(err))
+		return
+	}
+
+	// first report is made at startup
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := is.reconcile()
+		if err != nil {
+			metrics.ReserveChecks.WithLabelValues("error").Inc()
+			is.Logger.Error("reserves -> reconcile", zap.Error(err))
+		}
+
+		select {
+		case <-is.Context.Context.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// build, sign, store and check the report
+func (is *InternalService) reconcile() (*types.ReserveReport, error) {
+	ctx, cancel := context.WithTimeout(is.Context.Context, reservesTimeout)
+	defer cancel()
+
+	report, err := is.buildReserveReport(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = is.signReserveReport(report)
+	if err != nil {
+		return nil, fmt.Errorf("sign : %w", err)
+	}
+
+	is.Reserves.Lock()
+	is.Reserves.report = report
+	is.Reserves.Unlock()
+
+	metrics.SetReserves(metrics.ChainEthereum, report.Ethereum.Balance, report.Ethereum.Expected, report.Ethereum.Balanced)
+	metrics.SetReserves(metrics.ChainCosmos, report.Cosmos.Balance, report.Cosmos.Expected, report.Cosmos.Balanced)
+
+	if report.Balanced {
+		metrics.ReserveChecks.WithLabelValues("balanced").Inc()
+		is.Logger.Info("reserves -> balanced", zap.String("id", report.ID))
+	} else {
+		metrics.ReserveChecks.WithLabelValues("mismatch").Inc()
+		is.alertReserves(ctx, report)
+	}
+
+	err = is.storeReserveReport(ctx, report)
+	if err != nil {
+		return report, fmt.Errorf("store : %w", err)
+	}
+
+	return report, nil
+}
+
+func (is *InternalService) buildReserveReport(ctx context.Context) (*types.ReserveReport, error) {
+	ethBalance, err := is.ethTokenBalance(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ethereum balance : %w", err)
+	}
+
+	cosmosBalance, err := is.cosmosEscrowBalance(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cosmos balance : %w", err)
+	}
+
+	tolerance, err := parseAmount(is.Context.GetConfig("reserves.tolerance", "0").(string))
+	if err != nil {
+		return nil, fmt.Errorf("tolerance : %w", err)
+	}
+
+	ethInitial, err := parseAmount(is.Context.GetConfig("reserves.ethereum.initial", "0").(string))
+	if err != nil {
+		return nil, fmt.Errorf("ethereum initial : %w", err)
+	}
+
+	cosmosInitial, err := parseAmount(is.Context.GetConfig("reserves.cosmos.initial", "0").(string))
+	if err != nil {
+		return nil, fmt.Errorf("cosmos initial : %w", err)
+	}
+
+	var (
+		ethSide    = newSideTotals(ethInitial)
+		cosmosSide = newSideTotals(cosmosInitial)
+		transfers  = is.Ledger.List("")
+	)
+
+	is.addTransfers(ethSide, cosmosSide, transfers)
+
+	report := &types.ReserveReport{
+		ID:        fmt.Sprintf("reserves:%d", time.Now().UnixNano()),
+		Ethereum:  ethSide.compare(ethBalance, tolerance),
+		Cosmos:    cosmosSide.compare(cosmosBalance, tolerance),
+		Transfers: len(transfers),
+		CreatedAt: time.Now().Unix(),
+		PubKey:    utils.GetPubkeyString(is.Reserves.key),
+	}
+	report.Balanced = report.Ethereum.Balanced && report.Cosmos.Balanced
+
+	return report, nil
+}
+
+type sideTotals struct {
+	initial, locked, released, inFlight *big.Int
+}
+
+func newSideTotals(initial *big.Int) *sideTotals {
+	return &sideTotals{
+		initial:  initial,
+		locked:   new(big.Int),
+		released: new(big.Int),
+		inFlight: new(big.Int),
+	}
+}
+
+// account transfers on their source and destination sides
+func (is *InternalService) addTransfers(ethSide, cosmosSide *sideTotals, transfers []types.Transfer) {
+	for _, t := range transfers {
+		amount, err := parseAmount(t.Amount)
+		if err != nil {
+			is.Logger.Error("reserves -> wrong transfer amount", zap.String("id", t.ID), zap.String("amount", t.Amount))
+			continue
+		}
+
+		source, dest := ethSide, cosmosSide
+		if t.From == "Cosmos" {
+			source, dest = cosmosSide, ethSide
+		}
+
+		// source transaction of the retracted transfer is not in the chain
+		if t.Status != types.TransferRetracted {
+			source.locked.Add(source.locked, amount)
+		}
+
+		switch {
+		case t.Status == types.TransferConfirmed, t.Status == types.TransferRetracted && t.DestTx != "":
+			dest.released.Add(dest.released, amount)
+		case t.Status == types.TransferPending, t.Status == types.TransferSubmitted:
+			dest.inFlight.Add(dest.inFlight, amount)
+		}
+	}
+}
+
+// side is balanced if balance is between expected reserves with and without in-flight releases
+func (s *sideTotals) compare(balance, tolerance *big.Int) types.ReserveSide {
+	expected := new(big.Int).Add(s.initial, s.locked)
+	expected.Sub(expected, s.released)
+
+	difference := new(big.Int).Sub(balance, expected)
+
+	upper := new(big.Int).Add(expected, tolerance)
+	lower := new(big.Int).Sub(expected, s.inFlight)
+	lower.Sub(lower, tolerance)
+
+	return types.ReserveSide{
+		Balance:    balance.String(),
+		Expected:   expected.String(),
+		Initial:    s.initial.String(),
+		Locked:     s.locked.String(),
+		Released:   s.released.String(),
+		InFlight:   s.inFlight.String(),
+		Difference: difference.String(),
+		Balanced:   balance.Cmp(lower) >= 0 && balance.Cmp(upper) <= 0,
+	}
+}
+
+// token balance of the bridge contract via eth_call balanceOf
+func (is *InternalService) ethTokenBalance(ctx context.Context) (*big.Int, error) {
+	rpc := is.Context.GetConfig("reserves.ethereum.rpc", "").(string)
+	token := is.Context.GetConfig("reserves.ethereum.token", "").(string)
+	holder := is.Context.GetConfig("reserves.ethereum.holder", "").(string)
+
+	if rpc == "" || token == "" || holder == "" {
+		return nil, errors.New("reserves.ethereum rpc, token and holder should be set")
+	}
+
+	holder = strings.TrimPrefix(strings.ToLower(holder), "0x")
+	if len(holder) != 40 {
+		return nil, fmt.Errorf("wrong holder address %s", holder)
+	}
+
+	payload, err := json.Marshal(ethCallRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "eth_call",
+		Params: []interface{}{
+			map[string]string{
+				"to":   token,
+				"data": "0x" + balanceOfSelector + strings.Repeat("0", 24) + holder,
+			},
+			"latest",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resBytes, err := httpPostJSON(ctx, rpc, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	response := ethCallResponse{}
+	err = json.Unmarshal(resBytes, &response)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal : %w", err)
+	}
+
+	if response.Error != nil {
+		return nil, fmt.Errorf("eth_call : %d %s", response.Error.Code, response.Error.Message)
+	}
+
+	balance, ok := new(big.Int).SetString(strings.TrimPrefix(response.Result, "0x"), 16)
+	if !ok {
+		return nil, fmt.Errorf("wrong eth_call result %q", response.Result)
+	}
+
+	return balance, nil
+}
+
+// bridge module escrow balance via sekai bank REST
+func (is *InternalService) cosmosEscrowBalance(ctx context.Context) (*big.Int, error) {
+	url := is.Context.GetConfig("sekai.url", "").(string)
+	address := is.Context.GetConfig("reserves.cosmos.address", "").(string)
+	denom := is.Context.GetConfig("reserves.cosmos.denom", "ukex").(string)
+
+	if url == "" || address == "" {
+		return nil, errors.New("sekai.url and reserves.cosmos.address should be set")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/cosmos/bank/v1beta1/balances/"+address, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	resBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("balances res status: %v, %s", res.StatusCode, resBytes)
+	}
+
+	response := bankBalancesResponse{}
+	err = json.Unmarshal(resBytes, &response)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal : %w", err)
+	}
+
+	for _, coin := range response.Balances {
+		if coin.Denom == denom {
+			return parseAmount(coin.Amount)
+		}
+	}
+
+	return new(big.Int), nil
+}
+
+// sign report json with empty signature field by node key
+func (is *InternalService) signReserveReport(report *types.ReserveReport) error {
+	report.Signature = ""
+
+	msg, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	signature, err := is.Reserves.key.Sign(msg)
+	if err != nil {
+		return err
+	}
+
+	report.Signature = base64.StdEncoding.EncodeToString(signature)
+
+	return nil
+}
+
+// save report to sai-storage
+func (is *InternalService) storeReserveReport(ctx context.Context, report *types.ReserveReport) error {
+	url := is.Context.GetConfig("reserves.storage.url", "").(string)
+	if url == "" {
+		return nil
+	}
+
+	req := adapter.Request{
+		Method: "create",
+		Data: adapter.CreateRequest{
+			Collection: is.Context.GetConfig("reserves.storage.collection", "Reserves").(string),
+			Documents:  []interface{}{report},
+		},
+	}
+
+	payload, err := jsoniter.Marshal(&req)
+	if err != nil {
+		return err
+	}
+
+	_, err = utils.SaiQuerySenderContext(ctx, bytes.NewReader(payload), url, is.Context.GetConfig("reserves.storage.token", "").(string))
+
+	return err
+}
+
+// log mismatch and send report to the alert webhook
+func (is *InternalService) alertReserves(ctx context.Context, report *types.ReserveReport) {
+	is.Logger.Error("reserves -> mismatch",
+		zap.String("id", report.ID),
+		zap.String("ethereum_balance", report.Ethereum.Balance),
+		zap.String("ethereum_expected", report.Ethereum.Expected),
+		zap.String("cosmos_balance", report.Cosmos.Balance),
+		zap.String("cosmos_expected", report.Cosmos.Expected))
+
+	webhook := is.Context.GetConfig("reserves.alert_webhook", "").(string)
+	if webhook == "" {
+		return
+	}
+
+	payload, err := json.Marshal(report)
+	if err != nil {
+		is.Logger.Error("reserves -> alert -> marshal", zap.Error(err))
+		return
+	}
+
+	_, err = httpPostJSON(ctx, webhook, payload)
+	if err != nil {
+		is.Logger.Error("reserves -> alert -> webhook", zap.Error(err))
+	}
+}
+
+func httpPostJSON(ctx context.Context, url string, payload []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	resBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("res status: %v, %s", res.StatusCode, resBytes)
+	}
+
+	return resBytes, nil
+}
+
+func parseAmount(s string) (*big.Int, error) {
+	amount, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("wrong amount %q", s)
+	}
+	return amount, nil
+}
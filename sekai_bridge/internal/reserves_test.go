@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/KiraCore/sekai-bridge/types"
+	"go.uber.org/zap"
+)
+
+func TestReservesCompare(t *testing.T) {
+	transfers := []types.Transfer{
+		{ID: "e1", From: "Ethereum", Amount: "100", Status: types.TransferConfirmed},
+		{ID: "e2", From: "Ethereum", Amount: "40", Status: types.TransferSubmitted},
+		{ID: "e3", From: "Ethereum", Amount: "7", Status: types.TransferFailed},
+		{ID: "e4", From: "Ethereum", Amount: "1000", Status: types.TransferRetracted},
+		{ID: "e5", From: "Ethereum", Amount: "5", Status: types.TransferRetracted, DestTx: "0xdest"},
+		{ID: "c1", From: "Cosmos", Amount: "30", Status: types.TransferConfirmed},
+		{ID: "c2", From: "Cosmos", Amount: "2", Status: types.TransferPending},
+		{ID: "c3", From: "Cosmos", Amount: "wrong", Status: types.TransferConfirmed},
+	}
+
+	// ethereum: locked 100+40+7 (retracted are not in the chain), released 30, in flight 2
+	// cosmos: locked 30+2, released 100 and 5 of the retracted transfer which was submitted, in flight 40
+	tests := []struct {
+		name    string
+		side    string
+		initial int64
+		balance int64
+		want    types.ReserveSide
+	}{
+		{
+			name: "ethereum balanced", side: "ethereum", initial: 1000, balance: 1117,
+			want: types.ReserveSide{Expected: "1117", Initial: "1000", Locked: "147", Released: "30", InFlight: "2", Difference: "0", Balanced: true},
+		},
+		{
+			name: "ethereum release in flight", side: "ethereum", initial: 1000, balance: 1115,
+			want: types.ReserveSide{Expected: "1117", Initial: "1000", Locked: "147", Released: "30", InFlight: "2", Difference: "-2", Balanced: true},
+		},
+		{
+			name: "ethereum missing", side: "ethereum", initial: 1000, balance: 1114,
+			want: types.ReserveSide{Expected: "1117", Initial: "1000", Locked: "147", Released: "30", InFlight: "2", Difference: "-3", Balanced: false},
+		},
+		{
+			name: "ethereum surplus", side: "ethereum", initial: 1000, balance: 1118,
+			want: types.ReserveSide{Expected: "1117", Initial: "1000", Locked: "147", Released: "30", InFlight: "2", Difference: "1", Balanced: false},
+		},
+		{
+			name: "cosmos balanced", side: "cosmos", initial: 500, balance: 427,
+			want: types.ReserveSide{Expected: "427", Initial: "500", Locked: "32", Released: "105", InFlight: "40", Difference: "0", Balanced: true},
+		},
+		{
+			name: "cosmos release in flight", side: "cosmos", initial: 500, balance: 387,
+			want: types.ReserveSide{Expected: "427", Initial: "500", Locked: "32", Released: "105", InFlight: "40", Difference: "-40", Balanced: true},
+		},
+	}
+
+	is := &InternalService{Logger: zap.NewNop()}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ethSide, cosmosSide := newSideTotals(big.NewInt(tt.initial)), newSideTotals(big.NewInt(tt.initial))
+			is.addTransfers(ethSide, cosmosSide, transfers)
+
+			side := ethSide
+			if tt.side == "cosmos" {
+				side = cosmosSide
+			}
+
+			got := side.compare(big.NewInt(tt.balance), new(big.Int))
+
+			want := tt.want
+			want.Balance = big.NewInt(tt.balance).String()
+			if got != want {
+				t.Fatalf("compare = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestReservesTolerance(t *testing.T) {
+	side := newSideTotals(big.NewInt(100))
+
+	tests := []struct {
+		balance  int64
+		balanced bool
+	}{
+		{94, false},
+		{95, true},
+		{105, true},
+		{106, false},
+	}
+
+	for _, tt := range tests {
+		if got := side.compare(big.NewInt(tt.balance), big.NewInt(5)); got.Balanced != tt.balanced {
+			t.Errorf("balance %d: balanced = %v, want %v", tt.balance, got.Balanced, tt.balanced)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/KiraCore/sekai-bridge/tss"
 	"github.com/KiraCore/sekai-bridge/utils"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/saiset-co/saiP2P-go/config"
 	p2p "github.com/saiset-co/saiP2P-go/core"
@@ -22,10 +24,15 @@ import (
 )
 
 type InternalService struct {
-	Context *saiService.Context
-	P2P     *p2p.Core
-	Tss     *tss.TssServer
-	Logger  *zap.Logger
+	Context  *saiService.Context
+	P2P      *p2p.Core
+	Tss      *tss.TssServer
+	Logger   *zap.Logger
+	Ledger   *Ledger   // bridge transfers
+	Reserves *Reserves // proof-of-reserves reports
+
+	tokenMu sync.RWMutex
+	token   string // api token rotated by rotate_token
 }
 
 func (is *InternalService) Init() {
@@ -43,6 +50,24 @@ func (is *InternalService) Init() {
 		is.Logger.Fatal("GetConfig", zap.Error(err))
 	}
 
+	// transfers ledger
+	is.Ledger, err = NewLedger(is.Context.GetConfig("ledger.path", "transfers.json").(string))
+	if err != nil {
+		is.Logger.Fatal("NewLedger", zap.Error(err))
+	}
+
+	// api token rotated by rotate_token
+	err = is.loadToken()
+	if err != nil {
+		is.Logger.Fatal("loadToken", zap.Error(err))
+	}
+
+	// proof-of-reserves signing key
+	is.Reserves, err = NewReserves(is.Context.GetConfig("reserves.key_file", "reserves_key.json").(string))
+	if err != nil {
+		is.Logger.Fatal("NewReserves", zap.Error(err))
+	}
+
 	// p2p initialization
 	testFilterFunc := func(interface{}) bool {
 		return true
@@ -67,31 +92,34 @@ func (is *InternalService) Init() {
 		is.Tss.Logger.Info("key was not found")
 	}
 
+	is.Tss.Listen(context.Background())
+
+	// tss messages are sent by topics, messages without topic are not handled
 	go func() {
 		for {
 			p2pMsg, err := is.P2P.NextMsg(context.Background())
-			if err != nil {
-				is.P2P.Logger.Error("internal -> service -> Init -> NextMsg", zap.Error(err))
-				continue
-			}
-
-			if p2pMsg == nil {
+			if err != nil || p2pMsg == nil {
 				continue
 			}
 
-			go is.Tss.HandleP2Pmessage(p2pMsg)
+			is.P2P.Logger.Debug("internal -> service -> Init -> message without topic is dropped", zap.String("from", p2pMsg.From))
 		}
 	}()
 
 	time.Sleep(1 * time.Second)
 
-	if len(is.P2P.ConnectionStorage) > 0 {
-		for addr := range is.P2P.ConnectionStorage {
-			err := is.Tss.SendHandshake(addr)
-			if err != nil {
-				is.P2P.Logger.Error("internal -> service -> Init -> Handshake", zap.String("addr", addr), zap.Error(err))
-				continue
-			}
+	is.P2P.RLock()
+	addrs := make([]string, 0, len(is.P2P.ConnectionStorage))
+	for addr := range is.P2P.ConnectionStorage {
+		addrs = append(addrs, addr)
+	}
+	is.P2P.RUnlock()
+
+	for _, addr := range addrs {
+		err := is.Tss.SendHandshake(addr)
+		if err != nil {
+			is.P2P.Logger.Error("internal -> service -> Init -> Handshake", zap.String("addr", addr), zap.Error(err))
+			continue
 		}
 	}
 
@@ -126,6 +154,7 @@ func (is *InternalService) Init() {
 
 	go func() {
 		router := mux.NewRouter()
+		router.Handle("/metrics", promhttp.Handler())
 		router.Handle("/", http.FileServer(http.Dir("./html")))
 
 		if err := http.ListenAndServe(":"+conf.Http.Port, router); err != nil {
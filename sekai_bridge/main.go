@@ -15,6 +15,7 @@ func main() {
 
 	svc.RegisterTasks([]func(){
 		is.Process,
+		is.Reconcile,
 	})
 
 	svc.RegisterHandlers(
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "sekai_bridge"
+
+// chains the bridge submits transactions to
+const (
+	ChainEthereum = "ethereum"
+	ChainCosmos   = "cosmos"
+)
+
+var (
+	// KeygenDuration measures whole keygen ceremonies (successful or not)
+	KeygenDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "tss",
+		Name:      "keygen_duration_seconds",
+		Help:      "Duration of TSS keygen ceremonies.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200},
+	})
+
+	// KeygenFailures counts failed keygen ceremonies by reason
+	KeygenFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "tss",
+		Name:      "keygen_failures_total",
+		Help:      "Failed TSS keygen ceremonies by reason.",
+	}, []string{"reason"})
+
+	// KeysignDuration measures whole keysign ceremonies (successful or not)
+	KeysignDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "tss",
+		Name:      "keysign_duration_seconds",
+		Help:      "Duration of TSS keysign ceremonies.",
+		Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	})
+
+	// KeysignFailures counts failed keysign ceremonies by reason
+	KeysignFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "tss",
+		Name:      "keysign_failures_total",
+		Help:      "Failed TSS keysign ceremonies by reason.",
+	}, []string{"reason"})
+
+	// TxSubmitted counts transactions handed to the interaction services per destination chain
+	TxSubmitted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tx_submitted_total",
+		Help:      "Bridge transactions submitted per destination chain.",
+	}, []string{"chain"})
+
+	// TxFailed counts transactions which could not be submitted per destination chain
+	TxFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tx_failed_total",
+		Help:      "Bridge transactions failed per destination chain.",
+	}, []string{"chain"})
+
+	// TxStatus counts final statuses of destination transactions reported by the interaction services
+	TxStatus = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tx_status_total",
+		Help:      "Destination transaction statuses per source chain.",
+	}, []string{"source", "status"})
+
+	// Retractions counts source transactions retracted by the indexers because of reorgs,
+	// labeled by transfer status at the moment of retraction
+	Retractions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retractions_total",
+		Help:      "Source transactions retracted by reorgs per source chain and transfer status.",
+	}, []string{"source", "status"})
+
+	// ReserveChecks counts reserves reconciliations by result
+	ReserveChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "reserves",
+		Name:      "checks_total",
+		Help:      "Reserves reconciliations by result.",
+	}, []string{"result"})
+
+	// ReserveBalance is the bridge reserves balance read from chain
+	ReserveBalance = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "reserves",
+		Name:      "balance",
+		Help:      "Bridge reserves balance read from chain, token base units.",
+	}, []string{"chain"})
+
+	// ReserveExpected is the bridge reserves balance expected by the transfer ledger
+	ReserveExpected = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "reserves",
+		Name:      "expected",
+		Help:      "Bridge reserves balance expected by the transfer ledger, token base units.",
+	}, []string{"chain"})
+
+	// ReserveMismatch is 1 if reserves on chain do not match the transfer ledger
+	ReserveMismatch = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "reserves",
+		Name:      "mismatch",
+		Help:      "1 if reserves on chain do not match the transfer ledger.",
+	}, []string{"chain"})
+)
+
+// ObserveSince records elapsed time since start into the histogram
+func ObserveSince(h prometheus.Observer, start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// TxResult records the outcome of a transaction submission to chain
+func TxResult(chain string, err error) {
+	if err != nil {
+		TxFailed.WithLabelValues(chain).Inc()
+		return
+	}
+	TxSubmitted.WithLabelValues(chain).Inc()
+}
+
+// SetReserves updates reserves gauges of the chain, amounts are decimal strings
+func SetReserves(chain, balance, expected string, balanced bool) {
+	ReserveBalance.WithLabelValues(chain).Set(amountToFloat(balance))
+	ReserveExpected.WithLabelValues(chain).Set(amountToFloat(expected))
+
+	if balanced {
+		ReserveMismatch.WithLabelValues(chain).Set(0)
+		return
+	}
+	ReserveMismatch.WithLabelValues(chain).Set(1)
+}
+
+func amountToFloat(amount string) float64 {
+	f, _, err := big.ParseFloat(amount, 10, 64, big.ToNearestEven)
+	if err != nil {
+		return 0
+	}
+	v, _ := f.Float64()
+	return v
+}
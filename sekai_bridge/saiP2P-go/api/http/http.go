@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/saiset-co/saiP2P-go/core"
 	"go.uber.org/zap"
 )
@@ -27,7 +28,9 @@ func New(core *core.Core) *Router {
 func (r *Router) Routes() {
 	http.HandleFunc("/send", r.SendApi)   // send msgs by http
 	http.HandleFunc("/stats", r.GetStats) //get stats
+	http.HandleFunc("/peers", r.GetPeers) // known peer records
 	http.HandleFunc("/test_big_msg", r.TestBigMsg)
+	http.Handle("/metrics", promhttp.Handler()) // prometheus metrics
 }
 
 // GetStats - return app stats for a debug purposes
@@ -44,6 +47,19 @@ func (r *Router) GetStats(resp http.ResponseWriter, _ *http.Request) {
 	resp.Write(data)
 }
 
+// GetPeers - return peer records known by discovery
+func (r *Router) GetPeers(resp http.ResponseWriter, _ *http.Request) {
+	data, err := json.Marshal(r.Core.Discovery.Peers())
+	if err != nil {
+		err := map[string]interface{}{"Status": "NOK", "Error": err.Error()}
+		errBody, _ := json.Marshal(err)
+		log.Println(err)
+		resp.Write(errBody)
+		return
+	}
+	resp.Write(data)
+}
+
 // SendApi - api for accept messages from the http
 func (r *Router) SendApi(resp http.ResponseWriter, req *http.Request) {
 	message, err := io.ReadAll(req.Body)
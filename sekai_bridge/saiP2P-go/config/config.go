@@ -16,6 +16,14 @@ type Config struct {
 		Http struct {
 			Port string
 		}
+		KeyFile     string   `yaml:"keyFile"`     // static noise key of the node, generated if missing
+		TrustedKeys []string `yaml:"trustedKeys"` // hex static keys of allowed peers, node doesn't start if empty
+		MaxHops     int      `yaml:"maxHops"`     // max number of relays of the message
+		Stream      struct { // tcp transport for peers with reachable addresses
+			Port     string            `yaml:"port"`     // tcp listen port, stream transport is disabled if empty
+			MaxFrame int               `yaml:"maxFrame"` // max message size over tcp
+			Peers    map[string]string `yaml:"peers"`    // udp address of the peer -> its tcp address, other peers use udp
+		} `yaml:"stream"`
 	} `yaml:"p2p"`
 	Http struct {
 		Port string
@@ -25,9 +33,32 @@ type Config struct {
 	OnDirectMessageReceive    []string
 	DebugMode                 bool `yaml:"debug"`
 	UDP                       struct {
-		MsgBufferSize int           `yaml:"bufferSize"` // buffer for udp msg 49900 is max in ubuntu
-		Interval      time.Duration `yaml:"interval"`   // interval for p2p msg sending when
+		MsgBufferSize     int           `yaml:"bufferSize"`        // buffer for udp msg 49900 is max in ubuntu
+		Interval          time.Duration `yaml:"interval"`          // interval for p2p msg sending when
+		Window            int           `yaml:"window"`            // max unacked datagrams per peer, sending blocks when window is full
+		InitialRTO        time.Duration `yaml:"initialRto"`        // retransmission timeout before first rtt sample
+		MinRTO            time.Duration `yaml:"minRto"`            // lower bound of estimated retransmission timeout
+		MaxRTO            time.Duration `yaml:"maxRto"`            // upper bound of retransmission timeout (with backoff)
+		MaxRetries        int           `yaml:"maxRetries"`        // retransmissions before datagram is dropped
+		SendTimeout       time.Duration `yaml:"sendTimeout"`       // max wait for a free slot in the send window
+		ReassemblyTimeout time.Duration `yaml:"reassemblyTimeout"` // chunked message is dropped if not assembled in time
 	}
+	Inbound struct { // queues of received messages
+		QueueSize  int     `yaml:"queueSize"`  // max queued messages per peer
+		DropPolicy string  `yaml:"dropPolicy"` // newest or oldest, which message is dropped when peer queue is full
+		Rate       float64 `yaml:"rate"`       // datagrams per second accepted from peer, unlimited if 0
+		Burst      int     `yaml:"burst"`      // datagrams accepted from peer over the rate at once
+	} `yaml:"inbound"`
+	Discovery struct { // gossip of signed peer records
+		Interval    time.Duration `yaml:"interval"`    // gossip, liveness and reconnection interval
+		File        string        `yaml:"file"`        // known peers are saved here and used after restart
+		MaxPeers    int           `yaml:"maxPeers"`    // max known peer records
+		RecordTTL   time.Duration `yaml:"recordTtl"`   // older peer records are not accepted and not gossiped
+		BackoffMin  time.Duration `yaml:"backoffMin"`  // first reconnection delay
+		BackoffMax  time.Duration `yaml:"backoffMax"`  // max reconnection delay
+		MaxFailures int           `yaml:"maxFailures"` // peer is forgotten after this number of failed connections
+		EvictScore  int           `yaml:"evictScore"`  // connected peer with lower score is evicted when slots are full
+	} `yaml:"discovery"`
 	Cache struct { // cache settings
 		TTL         int `yaml:"ttl"`   // ttl for entry in cache
 		CleanPeriod int `yaml:"clean"` // when ttl expired entries should be cleaned
@@ -49,7 +80,76 @@ func Get() (Config, error) {
 		return config, fmt.Errorf("Unmarshal : %w", err)
 	}
 
+	config.SetDefaults()
+
 	fmt.Printf("p2pConf : %+v", config)
 	return config, nil
 
 }
+
+// SetDefaults - fill settings which were not provided
+func (c *Config) SetDefaults() {
+	if c.P2P.KeyFile == "" {
+		c.P2P.KeyFile = "p2p_key.json"
+	}
+	if c.P2P.MaxHops <= 0 {
+		c.P2P.MaxHops = 8
+	}
+	if c.Inbound.QueueSize <= 0 {
+		c.Inbound.QueueSize = 1024
+	}
+	if c.Inbound.DropPolicy == "" {
+		c.Inbound.DropPolicy = "newest"
+	}
+	if c.Inbound.Rate > 0 && c.Inbound.Burst <= 0 {
+		c.Inbound.Burst = int(c.Inbound.Rate)
+	}
+	if c.P2P.Stream.MaxFrame <= 0 {
+		c.P2P.Stream.MaxFrame = 16 << 20
+	}
+	if c.Discovery.Interval <= 0 {
+		c.Discovery.Interval = 30 * time.Second
+	}
+	if c.Discovery.File == "" {
+		c.Discovery.File = "p2p_peers.json"
+	}
+	if c.Discovery.MaxPeers <= 0 {
+		c.Discovery.MaxPeers = 256
+	}
+	if c.Discovery.RecordTTL <= 0 {
+		c.Discovery.RecordTTL = 24 * time.Hour
+	}
+	if c.Discovery.BackoffMin <= 0 {
+		c.Discovery.BackoffMin = 5 * time.Second
+	}
+	if c.Discovery.BackoffMax <= 0 {
+		c.Discovery.BackoffMax = 5 * time.Minute
+	}
+	if c.Discovery.MaxFailures <= 0 {
+		c.Discovery.MaxFailures = 10
+	}
+	if c.Discovery.EvictScore == 0 {
+		c.Discovery.EvictScore = -20
+	}
+	if c.UDP.Window <= 0 {
+		c.UDP.Window = 64
+	}
+	if c.UDP.InitialRTO <= 0 {
+		c.UDP.InitialRTO = time.Second
+	}
+	if c.UDP.MinRTO <= 0 {
+		c.UDP.MinRTO = 200 * time.Millisecond
+	}
+	if c.UDP.MaxRTO <= 0 {
+		c.UDP.MaxRTO = 10 * time.Second
+	}
+	if c.UDP.MaxRetries <= 0 {
+		c.UDP.MaxRetries = 8
+	}
+	if c.UDP.SendTimeout <= 0 {
+		c.UDP.SendTimeout = 30 * time.Second
+	}
+	if c.UDP.ReassemblyTimeout <= 0 {
+		c.UDP.ReassemblyTimeout = 2 * time.Minute
+	}
+}
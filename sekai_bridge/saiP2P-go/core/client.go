@@ -1,10 +1,11 @@
 package core
 
 import (
-	"encoding/json"
+	"errors"
 	"net"
 	"strconv"
 
+	"github.com/saiset-co/saiP2P-go/metrics"
 	"go.uber.org/zap"
 )
 
@@ -36,11 +37,12 @@ func (c *Core) ConnectToPeer() error {
 		return err
 	}
 
-	c.Server.Connections.Out, err = net.ListenUDP("udp4", nil)
+	conn, err := c.network.ListenUDP("")
 	if err != nil {
 		c.Logger.Error("Connect", zap.Error(err))
 		return err
 	}
+	c.Server.Connections.Out = c.Secure.Wrap(conn, noiseMaxDatagram)
 
 	defer c.Server.Connections.Out.Close()
 
@@ -54,67 +56,73 @@ func (c *Core) ConnectToPeer() error {
 
 	for {
 		n, serverAddr, err := c.Server.Connections.Out.ReadFromUDP(buf)
-		serverIP := serverAddr.IP.String()
-		serverPort := strconv.Itoa(serverAddr.Port)
 		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
 			c.Logger.Error("Connect", zap.Error(err))
 			continue
 		}
+		serverIP := serverAddr.IP.String()
+		serverPort := strconv.Itoa(serverAddr.Port)
+		metrics.BytesReceived.Add(float64(n))
 
-		var message map[string]interface{}
-
-		err = json.Unmarshal(buf[:n], &message)
+		messageType, err := wireType(buf[:n])
 		if err != nil {
-			c.Logger.Error("Connect", zap.String("buf", string(buf)), zap.Error(err))
+			c.Logger.Error("Connect", zap.String("addr", serverAddr.String()), zap.Error(err))
 			continue
 		}
 
-		//		c.Logger.Debug("p2p -> client -> incoming request", zap.Any("message", message))
-
-		if messageType, ok := message["type"]; ok != false {
-			switch messageType {
-			case punchResponse:
-				//			c.Logger.Debug(punchResponse, zap.Any("message", message))
-				err := c.ClientPunchResponseHandler(message, serverIP, serverPort)
-				if err != nil {
-					continue
-				}
-			case handshakeRequest:
-				err := c.ClientHandshakeRequestHandler(message)
-				if err != nil {
-					c.Logger.Error("p2p -> client -> ClientHandshakeRequestHandler", zap.Error(err))
-					continue
-				}
-				//c.Logger.Debug(handshakeRequest, zap.Any("s.m", c.Server.m))
-			case handshakeResponse:
-				//c.Logger.Debug("handshakeResponse", zap.Any("message", message))
-
-				err := c.ClientHandshakeResponseHandler(message, serverIP, serverPort)
-				if err != nil {
-					c.Logger.Error("p2p -> client -> ClientHandshakeResponseHandler", zap.Error(err))
-					continue
-				}
-
-				//				c.Logger.Debug(handshakeResponse, zap.Any("s.m", c.Server.m))
-			case MessageRequest:
-				err := c.ClientMessageHandler(buf[0:n])
-				if err != nil {
-					c.Logger.Error("p2p -> client -> ClientMessageHandler", zap.Error(err))
-					continue
-				}
-			case EventRequest:
-				err := c.ClientEventHandler(buf[0:n])
-				if err != nil {
-					c.Logger.Error("p2p -> client -> ClientEventHandler", zap.Error(err))
-					continue
-				}
-
-			default:
-				err := c.ClientDefaultHandler(message)
-				if err != nil {
-					c.Logger.Error("p2p -> client -> ClientDefaultHandler", zap.Error(err))
-					continue
-				}
+		//		c.Logger.Debug("p2p -> client -> incoming request", zap.String("type", messageType))
+
+		switch messageType {
+		case punchResponse:
+			err := c.ClientPunchResponseHandler(buf[0:n], serverIP, serverPort)
+			if err != nil {
+				continue
+			}
+		case handshakeRequest:
+			err := c.ClientHandshakeRequestHandler(buf[0:n])
+			if err != nil {
+				c.Logger.Error("p2p -> client -> ClientHandshakeRequestHandler", zap.Error(err))
+				continue
+			}
+		case handshakeResponse:
+			err := c.ClientHandshakeResponseHandler(buf[0:n], serverIP, serverPort)
+			if err != nil {
+				c.Logger.Error("p2p -> client -> ClientHandshakeResponseHandler", zap.Error(err))
+				continue
+			}
+		case MessageRequest:
+			err := c.ClientMessageHandler(buf[0:n], serverAddr)
+			if err != nil {
+				c.Logger.Error("p2p -> client -> ClientMessageHandler", zap.Error(err))
+				continue
+			}
+		case ackRequest:
+			err := c.ClientAckHandler(buf[0:n], serverAddr)
+			if err != nil {
+				c.Logger.Error("p2p -> client -> ClientAckHandler", zap.Error(err))
+				continue
+			}
+		case peersRequest:
+			err := c.ClientPeersHandler(buf[0:n], serverAddr)
+			if err != nil {
+				c.Logger.Error("p2p -> client -> ClientPeersHandler", zap.Error(err))
+				continue
+			}
+		case EventRequest:
+			err := c.ClientEventHandler(buf[0:n])
+			if err != nil {
+				c.Logger.Error("p2p -> client -> ClientEventHandler", zap.Error(err))
+				continue
+			}
+
+		default:
+			err := c.ClientDefaultHandler(buf[0:n])
+			if err != nil {
+				c.Logger.Error("p2p -> client -> ClientDefaultHandler", zap.Error(err))
+				continue
 			}
 		}
 		c.Logger.Debug("client - Messages",
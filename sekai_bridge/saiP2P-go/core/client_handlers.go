@@ -1,86 +1,86 @@
 package core
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
-	"reflect"
 	"strings"
 
+	"github.com/saiset-co/saiP2P-go/metrics"
 	"go.uber.org/zap"
 )
 
 // Punch response handler for client core part
-func (c *Core) ClientPunchResponseHandler(message map[string]interface{}, serverIP, serverPort string) error {
-	//			client.server.Logger.Debug(punchResponse, zap.Any("message", message))
+func (c *Core) ClientPunchResponseHandler(buf []byte, serverIP, serverPort string) error {
+	response := Response{}
+	err := Unmarshal(buf, &response)
+	if err != nil {
+		return fmt.Errorf("Unmarshal :%w", err)
+	}
 
-	if status, ok := message["status"]; ok == true && status.(string) == statusConnectionRejected {
-		c.Logger.Debug("client - connection rejected", zap.String("IP", message["ip"].(string)), zap.String("Port", message["port"].(string)))
+	if response.Status == statusConnectionRejected {
+		c.Logger.Debug("client - connection rejected", zap.String("IP", response.Ip), zap.String("Port", response.Port))
 		return errors.New("connection rejected")
 	}
 
-	if punch, ok := message["punch"]; ok != false && c.Server.Address.PunchPort == "" {
-		if punch, done := punch.(string); done != false {
-			c.Server.Address.PunchPort = punch
-			c.Server.Address.IP = net.ParseIP(message["ip"].(string))
-			c.addConnection(serverIP, serverPort, c.Server.Address.PunchPort)
-		}
+	if response.Punch != "" && c.setPunchAddress(net.ParseIP(response.Ip), response.Punch) {
+		c.addConnection(serverIP, serverPort, response.Punch)
 	}
 
 	//client.server.Logger.Debug("p2p - server - AddPubkeyToStorage - punchResp", zap.String("address", serverAddr.String())) //@debug
 
-	if list, ok := message["list"]; ok != false && len(c.ConnectionStorage) < c.Config.P2P.Slot {
-		if list, done := list.(map[string]interface{}); done != false {
-			for address := range list {
-				// client.server.Logger.Debug(punchResponse, zap.Any("ip", ip))
-				// client.server.Logger.Debug(punchResponse, zap.Any("port", port))
-				c.Server.AddrChan <- address
-			}
+	if c.connectionCount() < c.Config.P2P.Slot {
+		for address := range response.Connections {
+			c.Server.AddrChan <- address
 		}
 	}
 	return nil
 }
 
 // Handshake request handler for client core part
-func (c *Core) ClientHandshakeRequestHandler(message map[string]interface{}) error {
-	if localAddrS, ok := message["local_addr"]; ok == true {
-		if localAddrS, done := localAddrS.(string); done {
-			localAddr, err := net.ResolveUDPAddr("udp4", localAddrS)
+func (c *Core) ClientHandshakeRequestHandler(buf []byte) error {
+	request := Request{}
+	err := Unmarshal(buf, &request)
+	if err != nil {
+		return fmt.Errorf("Unmarshal :%w", err)
+	}
+
+	if request.LocalAddr != "" {
+		localAddr, err := net.ResolveUDPAddr("udp4", request.LocalAddr)
+		if err != nil {
+			c.Logger.Error("Connect", zap.Error(err))
+			return err
+		}
+
+		if c.connectionCount() < c.Config.P2P.Slot {
+			path := strings.Split(request.LocalAddr, ":")
+
+			if len(path) < 2 {
+				return errors.New("wrong address format")
+			}
+			err = c.CheckAllowConn(c.Server.FilterConnections, path[0], path[1])
 			if err != nil {
-				c.Logger.Error("Connect", zap.Error(err))
-				return err
+				return errors.New("connection not allowed")
+			}
+			_, punchPort := c.serverAddress()
+			c.addConnection(path[0], path[1], punchPort)
+			response := Response{Type: handshakeResponse,
+				Status:      "OK",
+				Connections: c.copyConnections(),
+				Punch:       "",
 			}
 
-			if len(c.ConnectionStorage) < c.Config.P2P.Slot {
-				path := strings.Split(localAddrS, ":")
-
-				if len(path) < 2 {
-					return errors.New("wrong address format")
-				}
-				err = c.CheckAllowConn(c.Server.FilterConnections, path[0], path[1])
-				if err != nil {
-					return errors.New("connection not allowed")
-				}
-				c.addConnection(path[0], path[1], c.Server.Address.PunchPort)
-				response := Response{Type: handshakeResponse,
-					Status:      "OK",
-					Connections: c.ConnectionStorage,
-					Punch:       "",
-				}
-
-				err = response.Send(c.Server.Connections.Out, localAddr)
-				if err != nil {
-					return fmt.Errorf("response.Send :%w", err)
-				}
-
-			} else {
-				response := Response{Type: handshakeResponse, Status: "NOK", Connections: c.ConnectionStorage}
-
-				err = response.Send(c.Server.Connections.Out, localAddr)
-				if err != nil {
-					return fmt.Errorf("response.Send :%w", err)
-				}
+			err = response.Send(c.Server.Connections.Out, localAddr)
+			if err != nil {
+				return fmt.Errorf("response.Send :%w", err)
+			}
+
+		} else {
+			response := Response{Type: handshakeResponse, Status: "NOK", Connections: c.copyConnections()}
+
+			err = response.Send(c.Server.Connections.Out, localAddr)
+			if err != nil {
+				return fmt.Errorf("response.Send :%w", err)
 			}
 		}
 	}
@@ -89,44 +89,52 @@ func (c *Core) ClientHandshakeRequestHandler(message map[string]interface{}) err
 }
 
 // Handshake response handler for client core part
-func (c *Core) ClientHandshakeResponseHandler(message map[string]interface{}, serverIP, serverPort string) error {
-	if list, ok := message["list"]; ok != false && len(c.ConnectionStorage) < c.Config.P2P.Slot {
-		if list, done := list.(map[string]bool); done != false {
-			for address := range list {
-				c.Server.AddrChan <- address
-			}
-		}
+func (c *Core) ClientHandshakeResponseHandler(buf []byte, serverIP, serverPort string) error {
+	response := Response{}
+	err := Unmarshal(buf, &response)
+	if err != nil {
+		return fmt.Errorf("Unmarshal :%w", err)
 	}
 
-	if status, ok := message["status"]; ok != false && status == "OK" {
+	if response.Status == "OK" {
 		c.Logger.Debug("handshakeResponse")
 		err := c.CheckAllowConn(c.Server.FilterConnections, serverIP, serverPort)
 		if err != nil {
 			return fmt.Errorf("CheckAllowConn :%w", err)
 		}
-		c.addConnection(serverIP, serverPort, c.Server.Address.PunchPort)
+		_, punchPort := c.serverAddress()
+		c.addConnection(serverIP, serverPort, punchPort)
 	}
 	return nil
 }
 
 // Message handler for client core part
-func (c *Core) ClientMessageHandler(buf []byte) error {
+func (c *Core) ClientMessageHandler(buf []byte, serverAddr *net.UDPAddr) error {
 	msg := Request{}
-	err := json.Unmarshal(buf, &msg)
+	err := Unmarshal(buf, &msg)
 	if err != nil {
 		return fmt.Errorf("Unmarshal :%w", err)
 	}
+
+	// datagram over peer rate is not acked, so peer sends it again later
+	if !c.Inbound.Allow(serverAddr.String()) {
+		return nil
+	}
+
+	if c.ackDatagram(c.Server.Connections.Out, serverAddr, &msg) {
+		return nil
+	}
+
 	c.Logger.Debug("client - messageRequest", zap.String("type", msg.Type),
 		zap.String("local", msg.LocalAddr),
 		zap.String("remote", msg.RemoteAddr))
 
-	found, err := c.CheckCache(string(msg.Message.Data))
+	found, err := c.seen(&msg.Message)
 	if err != nil {
-		return fmt.Errorf("CheckCache : %w", err)
+		return fmt.Errorf("seen : %w", err)
 	}
-	if !found {
-		c.Cache.Set(string(msg.Message.Data), nil)
-	} else {
+	metrics.MessageReceived(found)
+	if found {
 		return nil
 	}
 
@@ -140,20 +148,47 @@ func (c *Core) ClientMessageHandler(buf []byte) error {
 
 	// }
 
-	c.MsgCh <- &msg
+	if msg.Message.Target == c.Identity.ID() { // directed message to this node
+		c.deliver(serverAddr.String(), &msg)
+		c.Client.Messages.Messages++
+		return nil
+	}
+	if msg.Message.Target == "" {
+		c.deliver(serverAddr.String(), &msg)
+	}
+
+	// relay without blocking the read loop, acks of relayed datagrams are read by it
+	go c.relay(msg.Message)
+	c.Client.Messages.Messages++
+	return nil
+}
+
+// Ack handler for client core part
+func (c *Core) ClientAckHandler(buf []byte, serverAddr *net.UDPAddr) error {
+	request := Request{}
+	err := Unmarshal(buf, &request)
+	if err != nil {
+		return fmt.Errorf("Unmarshal :%w", err)
+	}
+	c.Reliable.HandleAck(serverAddr, request.Ack)
+	return nil
+}
 
-	c.SendMsg(msg.Message.Data, msg.Message.To, msg.LocalAddr)
+// Peer records handler for client core part
+func (c *Core) ClientPeersHandler(buf []byte, serverAddr *net.UDPAddr) error {
+	request := Request{}
+	err := Unmarshal(buf, &request)
 	if err != nil {
-		return fmt.Errorf("Send :%w", err)
+		return fmt.Errorf("Unmarshal :%w", err)
 	}
-	c.Client.Messages.Messages++
+	c.Discovery.HandleGossip(c.Server.Connections.Out, serverAddr, request.Peers, request.Routes)
 	return nil
 }
 
 // Event handler for client core part
 func (c *Core) ClientEventHandler(buf []byte) error {
 	request := Request{}
-	err := json.Unmarshal(buf, &request)
+	err := Unmarshal(buf, &request)
 	if err != nil {
 		return fmt.Errorf("Unmarshal :%w", err)
 	}
@@ -165,34 +200,14 @@ func (c *Core) ClientEventHandler(buf []byte) error {
 }
 
 // Default handler for client core part
-func (c *Core) ClientDefaultHandler(message map[string]interface{}) error {
-	c.Logger.Debug("Default", zap.Any("message", message))
-	if messageData, ok := message["message"]; ok == true {
-		if mess, done := messageData.(Message); done {
-			c.Logger.Debug("MessageHandler", zap.Any("message", mess))
-
-			found, err := c.CheckCache(string(mess.Data))
-			if err != nil {
-				return fmt.Errorf("CheckCache : %w", err)
-			}
-			if !found {
-				c.Cache.Set(string(mess.Data), nil)
-			} else {
-				return nil
-			}
-
-			err = c.SendMsg(mess.Data, mess.To, mess.From)
-			if err != nil {
-				return fmt.Errorf("Send :%w", err)
-			}
-
-			c.Logger.Debug("client - Connect - for loop - default", zap.String("type", reflect.TypeOf(messageData).String()))
-			err = c.Notify(mess)
-			if err != nil {
-				return fmt.Errorf("Notify :%w", err)
-			}
-			c.Client.Messages.Messages++
-		}
+func (c *Core) ClientDefaultHandler(buf []byte) error {
+	request := Request{}
+	err := Unmarshal(buf, &request)
+	if err != nil {
+		return fmt.Errorf("Unmarshal :%w", err)
 	}
+	c.Logger.Debug("client - unknown request", zap.String("type", request.Type),
+		zap.String("local", request.LocalAddr),
+		zap.String("remote", request.RemoteAddr))
 	return nil
 }
@@ -1,9 +1,11 @@
 package core
 
 import (
-	"encoding/json"
 	"fmt"
 	"net"
+	"strconv"
+
+	"github.com/saiset-co/saiP2P-go/metrics"
 )
 
 type EventType int
@@ -15,60 +17,83 @@ const (
 
 // Event model, used to handle different types of events (connecting, disconnecting...)
 type Event struct {
-	Address string    `json:"address"`
-	Type    EventType `json:"event_type"`
+	Address string    `json:"address" cbor:"1,keyasint"`
+	Type    EventType `json:"event_type" cbor:"2,keyasint"`
 }
 
 // Standart request model for p2p communication
 type Request struct {
-	Type       string  `json:"type"`
-	LocalAddr  string  `json:"local_addr"`
-	RemoteAddr string  `json:"remote_addr"`
-	Message    Message `json:"message"`
-	Event      *Event  `json:"event,omitempty"`
+	Type       string         `json:"type" cbor:"1,keyasint"`
+	LocalAddr  string         `json:"local_addr" cbor:"2,keyasint,omitempty"`
+	RemoteAddr string         `json:"remote_addr" cbor:"3,keyasint,omitempty"`
+	Message    Message        `json:"message" cbor:"4,keyasint"`
+	Event      *Event         `json:"event,omitempty" cbor:"5,keyasint,omitempty"`
+	Seq        uint64         `json:"seq,omitempty" cbor:"6,keyasint,omitempty"`   // sequence number of message datagram (per peer)
+	Epoch      uint64         `json:"epoch,omitempty" cbor:"7,keyasint,omitempty"` // sender epoch, seq numbering starts over with new epoch
+	Ack        *Ack           `json:"ack,omitempty" cbor:"8,keyasint,omitempty"`
+	Peers      []PeerRecord   `json:"peers,omitempty" cbor:"9,keyasint,omitempty"`   // gossip, first record belongs to the sender
+	Routes     map[string]int `json:"routes,omitempty" cbor:"10,keyasint,omitempty"` // gossip, distances to nodes reachable by the sender
 }
 
-func (r *Request) Send(conn *net.UDPConn, addr *net.UDPAddr) error {
-	requestJSON, err := json.Marshal(r)
+func (r *Request) Send(conn *SecureConn, addr *net.UDPAddr) error {
+	data, err := Marshal(r)
 	if err != nil {
 		return fmt.Errorf("Marshal : %w", err)
 	}
-	_, err = conn.WriteToUDP(requestJSON, addr)
+	n, err := conn.WriteToUDP(data, addr)
 	if err != nil {
 		return fmt.Errorf("WriteToUDP : %w", err)
 	}
+	metrics.BytesSent.WithLabelValues(r.Type).Add(float64(n))
+	if r.Type == MessageRequest {
+		metrics.MessagesSent.Inc()
+	}
 	return nil
 }
 
 // Standart response model for p2p communication
 type Response struct {
-	Type        string          `json:"type"`
-	Status      string          `json:"status"`
-	Connections map[string]bool `json:"list"`
-	Punch       string          `json:"punch"`
-	Ip          string          `json:"ip"`
-	Port        string          `json:"port"`
+	Type        string          `json:"type" cbor:"1,keyasint"`
+	Status      string          `json:"status" cbor:"2,keyasint,omitempty"`
+	Connections map[string]bool `json:"list" cbor:"3,keyasint,omitempty"`
+	Punch       string          `json:"punch" cbor:"4,keyasint,omitempty"`
+	Ip          string          `json:"ip" cbor:"5,keyasint,omitempty"`
+	Port        string          `json:"port" cbor:"6,keyasint,omitempty"`
 }
 
-func (r *Response) Send(conn *net.UDPConn, addr *net.UDPAddr) error {
-	requestJSON, err := json.Marshal(r)
+func (r *Response) Send(conn *SecureConn, addr *net.UDPAddr) error {
+	data, err := Marshal(r)
 	if err != nil {
 		return fmt.Errorf("Marshal : %w", err)
 	}
-	_, err = conn.WriteToUDP(requestJSON, addr)
+	n, err := conn.WriteToUDP(data, addr)
 	if err != nil {
 		return fmt.Errorf("WriteToUDP : %w", err)
 	}
+	metrics.BytesSent.WithLabelValues(r.Type).Add(float64(n))
 	return nil
 }
 
 // Message model for p2p communication
 type Message struct {
-	From       string   // message sender
-	To         []string //message recveiver
-	Data       []byte   // data
-	Hash       string   // hash of whole message (used when message sent by chunks)
-	TotalParts int      // chunks number
-	Part       int      // current chunk number
-	Last       bool     // flag if this message last
+	ID         string   `cbor:"1,keyasint,omitempty"`  // unique message id, hash of sender identity, nonce and data hash
+	Hops       int      `cbor:"2,keyasint,omitempty"`  // how many times message can be relayed yet
+	Topic      uint32   `cbor:"3,keyasint,omitempty"`  // topic id, 0 for messages sent by SendMsg
+	Target     string   `cbor:"4,keyasint,omitempty"`  // node id of the recipient of directed message, data is encrypted to it
+	Origin     string   `cbor:"5,keyasint,omitempty"`  // node id of the sender of directed message
+	From       string   `cbor:"6,keyasint,omitempty"`  // message sender
+	To         []string `cbor:"7,keyasint,omitempty"`  //message recveiver
+	Data       []byte   `cbor:"8,keyasint,omitempty"`  // data
+	Hash       string   `cbor:"9,keyasint,omitempty"`  // hash of whole message (used when message sent by chunks)
+	TotalParts int      `cbor:"10,keyasint,omitempty"` // chunks number
+	Part       int      `cbor:"11,keyasint,omitempty"` // current chunk number
+	Last       bool     `cbor:"12,keyasint,omitempty"` // flag if this message last
+}
+
+// key of the message (or message chunk) in dedup cache
+func (m *Message) key() string {
+	if m.Part != 0 {
+		return m.ID + ":" + strconv.Itoa(m.Part)
+	}
+	return m.ID
 }
@@ -3,6 +3,8 @@ package core
 import (
 	"context"
 	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -10,13 +12,14 @@ import (
 	"net"
 	"os"
 	"os/signal"
-	"sort"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/allegro/bigcache/v3"
 	"github.com/saiset-co/saiP2P-go/config"
+	"github.com/saiset-co/saiP2P-go/metrics"
 	"github.com/saiset-co/saiP2P-go/utils"
 	"go.uber.org/zap"
 )
@@ -50,15 +53,38 @@ type Core struct {
 	sync.RWMutex
 	ConnectionStorage map[string]bool // p2p connections listed here
 	//	SavedMessages     map[string]bool // saved messages, to prevent double messages sending
-	MsgCh chan *Request // to handle messages inside core
-	Cache *bigcache.BigCache
+	Inbound       *Inbound                 // queues of received messages per peer
+	subscriptions map[uint32]*Subscription // by topic id
+	Cache         *bigcache.BigCache
+	Reliable      *Reliable  // acks and retransmission of message datagrams
+	Secure        *Secure    // noise sessions, all datagrams are encrypted and authenticated
+	Identity      *Identity  // keys of the node
+	Discovery     *Discovery // gossip of peer records and reconnection
+	Router        *Router    // routes of directed messages
+	nonce         uint64     // message nonce, part of message id
+	clock         Clock
+	network       Network
 }
 
 // filter connections func type
 type filterConnections func(interface{}) bool
 
-// Init core
-func Init(config config.Config, f filterConnections) *Core {
+// Init core, options change environment of the core (used by tests)
+func Init(config config.Config, f filterConnections, options ...Option) *Core {
+	config.SetDefaults()
+
+	core := &Core{
+		ConnectionStorage: make(map[string]bool),
+		//	SavedMessages:     make(map[string]bool),
+		subscriptions: make(map[uint32]*Subscription),
+		Config:        config,
+		clock:         systemClock{},
+		network:       systemNetwork{},
+	}
+	for _, option := range options {
+		option(core)
+	}
+
 	// initialize cache
 	cacheConfig := bigcache.Config{
 		Shards:      1024,
@@ -72,28 +98,44 @@ func Init(config config.Config, f filterConnections) *Core {
 		log.Fatalf("p2p -> Server -> bigcache.New : %s", err.Error())
 	}
 
-	logger, err := utils.BuildLogger(true)
+	if core.Logger == nil {
+		core.Logger, err = utils.BuildLogger(true)
+		if err != nil {
+			log.Fatalf("p2p -> Server -> BuildLogger : %s", err.Error())
+		}
+	}
+	logger := core.Logger
+
+	identity, err := LoadIdentity(config.P2P.KeyFile)
 	if err != nil {
-		log.Fatalf("p2p -> Server -> BuildLogger : %s", err.Error())
+		log.Fatalf("p2p -> Server -> LoadIdentity : %s", err.Error())
 	}
 
-	core := &Core{
-		ConnectionStorage: make(map[string]bool),
-		//	SavedMessages:     make(map[string]bool),
-		MsgCh:  make(chan *Request),
-		Config: config,
-		Logger: logger,
-		Cache:  cache,
+	core.Inbound = NewInbound(config, core.clock)
+	core.Cache = cache
+	core.Reliable = NewReliable(config, logger, core.clock)
+	core.Secure = NewSecure(identity.Noise, config.P2P.TrustedKeys, logger, core.clock)
+	core.Identity = identity
+	core.nonce = uint64(core.clock.Now().UnixNano()) // ids are not repeated after restart
+
+	logger.Info("p2p -> identity", zap.String("id", identity.ID()), zap.String("key", core.PublicKey()))
+
+	// any key could join the committee otherwise
+	if len(config.P2P.TrustedKeys) == 0 {
+		log.Fatalf("p2p -> Server -> p2p.trustedKeys are empty, add static keys of the peers, key of the node is %s", core.PublicKey())
 	}
 
 	server := &Server{
 		AddrChan:          make(chan string),
 		FilterConnections: f,
 		RWMutex:           new(sync.RWMutex),
-		BufferedMsgs:      map[string][]*Message{},
+		BufferedMsgs:      map[string]*bufferedMsg{},
+		streamRoutes:      map[string]*net.UDPAddr{},
 	}
 
 	core.Server = server
+	core.Discovery = NewDiscovery(core)
+	core.Router = NewRouter(core)
 
 	return core
 }
@@ -101,7 +143,8 @@ func Init(config config.Config, f filterConnections) *Core {
 // Init client
 func (c *Core) CreateClient(peer string) *Core {
 	client := Client{}
-	client.Address.Local = c.Server.Address.IP.String() + ":" + c.Config.P2P.Port
+	ip, _ := c.serverAddress()
+	client.Address.Local = ip.String() + ":" + c.Config.P2P.Port
 	client.Address.Remote = peer
 
 	c.Client = &client
@@ -114,6 +157,19 @@ func (c *Core) Run(f filterConnections) {
 	c.Logger.Debug("Start", zap.Any("peers", c.Config.Peers))
 
 	go c.ProcessHandshakes()
+	go c.Reliable.Run()
+	go c.CleanBufferedMsgs()
+	go c.Discovery.Run()
+
+	if c.Config.P2P.Stream.Port != "" {
+		stream, err := ListenStream(":"+c.Config.P2P.Stream.Port, c.Config.P2P.Stream.MaxFrame, c.Logger)
+		if err != nil {
+			c.Logger.Error("Start -> ListenStream", zap.Error(err))
+		} else {
+			c.Server.Connections.Stream = c.Secure.Wrap(stream, c.Config.P2P.Stream.MaxFrame)
+			go c.ServeStream(f)
+		}
+	}
 
 	if len(c.Config.Peers) > 0 {
 		go c.RunClient()
@@ -124,44 +180,153 @@ func (c *Core) Run(f filterConnections) {
 	}
 }
 
-// Broadcasting messages logic
+// Broadcasting messages logic, sending to peer blocks while its send window is full.
+// Big messages are sent by chunks to udp peers and whole to stream transport peers.
+// Recipients which are not connected are reached by directed messages (addresses are resolved to node ids).
 func (c *Core) SendMsg(mes []byte, to []string, senderAddr string) error {
-	time.Sleep(5 * time.Millisecond)
-	if len(mes) > c.Config.UDP.MsgBufferSize {
-		msgs := c.PrepareMsgChunks(mes, to, senderAddr)
-		for _, msg := range msgs {
-			time.Sleep(500 * time.Millisecond)
-			c.DistributeMsg(to, *msg)
+	return c.sendMessage(0, mes, to, senderAddr)
+}
+
+func (c *Core) sendMessage(topic uint32, mes []byte, to []string, senderAddr string) error {
+	var directedErr error
+	if len(to) != 0 {
+		connected, ids := c.splitRecipients(to)
+		if len(ids) != 0 {
+			directedErr = c.sendDirected(topic, mes, ids)
+		}
+		if len(connected) == 0 {
+			return directedErr
+		}
+		to = connected
+	}
+
+	var message = Message{
+		ID:    c.messageID(mes),
+		Hops:  c.Config.P2P.MaxHops,
+		Topic: topic,
+		From:  senderAddr,
+		To:    to,
+		Data:  mes,
+	}
+
+	// own message is not handled again, when peers relay it back
+	c.Cache.Set(message.key(), nil)
+
+	c.send(message)
+	return directedErr
+}
+
+// split recipients to connected addresses and node ids of other recipients
+func (c *Core) splitRecipients(to []string) ([]string, []string) {
+	connected := make([]string, 0, len(to))
+	ids := make([]string, 0)
+
+	c.RLock()
+	defer c.RUnlock()
+
+	for _, recipient := range to {
+		if c.ConnectionStorage[recipient] {
+			connected = append(connected, recipient)
+			continue
+		}
+		id, ok := c.Discovery.lookup(recipient)
+		if !ok {
+			c.Logger.Debug("p2p -> core -> SendMsg : recepient is unknown", zap.String("recepient", recipient))
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return connected, ids
+}
 
+// relay received message to connected peers, number of relays is bounded by hops
+func (c *Core) relay(message Message) {
+	if message.Hops <= 1 {
+		metrics.RelaysDropped.Inc()
+		c.Logger.Debug("p2p -> core -> relay : hop limit reached", zap.String("id", message.ID))
+		return
+	}
+	message.Hops--
+
+	if message.Target != "" {
+		err := c.forward(message)
+		if err != nil {
+			c.Logger.Debug("p2p -> core -> relay -> forward", zap.Error(err), zap.String("id", message.ID), zap.String("target", message.Target))
 		}
-	} else {
-		c.Cache.Set(string(mes), nil)
+		return
+	}
+
+	if message.Part != 0 { // chunk is relayed as is, so it keeps message id and part
+		c.DistributeMsg(message.To, message)
+		return
+	}
+	c.send(message)
+}
 
-		var message = Message{
-			From: senderAddr,
-			To:   to,
-			Data: mes,
+func (c *Core) send(message Message) {
+	if len(message.Data) <= c.Config.UDP.MsgBufferSize {
+		c.DistributeMsg(message.To, message)
+		return
+	}
+
+	udpPeers := make([]string, 0)
+	for _, address := range c.recipients(message.To) {
+		if c.streamAddress(address) != nil {
+			c.sendMsg(message, address)
+			continue
 		}
+		udpPeers = append(udpPeers, address)
+	}
 
-		c.DistributeMsg(to, message)
+	if len(udpPeers) == 0 {
+		return
+	}
 
+	msgs := c.PrepareMsgChunks(message)
+	for _, msg := range msgs {
+		for _, address := range udpPeers {
+			c.sendMsg(*msg, address)
+		}
 	}
-	return nil
+}
+
+// unique message id : sender identity + nonce + data hash
+func (c *Core) messageID(data []byte) string {
+	dataHash := sha256.Sum256(data)
+	nonce := make([]byte, 8)
+	binary.BigEndian.PutUint64(nonce, atomic.AddUint64(&c.nonce, 1))
+
+	h := sha256.New()
+	h.Write([]byte(c.Identity.ID()))
+	h.Write(nonce)
+	h.Write(dataHash[:])
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// check if message (or message chunk) was already handled, marks it handled if not
+func (c *Core) seen(message *Message) (bool, error) {
+	if message.ID == "" {
+		return false, errors.New("message without id")
+	}
+	return c.CheckCache(message.key())
 }
 
 // prepare messages, if incoming data bigger than udp datagram size
-func (c *Core) PrepareMsgChunks(data []byte, to []string, senderAddr string) []*Message {
+func (c *Core) PrepareMsgChunks(message Message) []*Message {
 	msgs := make([]*Message, 0)
 	h := sha1.New()
-	h.Write(data)
+	h.Write(message.Data)
 	msgHash := hex.EncodeToString(h.Sum(nil))
-	byteChunks := utils.ChunkSlice(data, c.Config.UDP.MsgBufferSize)
+	byteChunks := utils.ChunkSlice(message.Data, c.Config.UDP.MsgBufferSize)
 	for idx, chunk := range byteChunks {
-		c.Cache.Set(string(chunk), nil)
-
 		msg := &Message{
-			From:       senderAddr,
-			To:         to,
+			ID:         message.ID,
+			Hops:       message.Hops,
+			Topic:      message.Topic,
+			Target:     message.Target,
+			Origin:     message.Origin,
+			From:       message.From,
+			To:         message.To,
 			Data:       chunk,
 			Hash:       msgHash,
 			TotalParts: len(byteChunks),
@@ -170,6 +335,7 @@ func (c *Core) PrepareMsgChunks(data []byte, to []string, senderAddr string) []*
 		if idx+1 == len(byteChunks) {
 			msg.Last = true
 		}
+		c.Cache.Set(msg.key(), nil)
 
 		msgs = append(msgs, msg)
 	}
@@ -178,35 +344,41 @@ func (c *Core) PrepareMsgChunks(data []byte, to []string, senderAddr string) []*
 
 // messages distribution
 func (c *Core) DistributeMsg(to []string, message Message) {
-	// send msg only to recepients if recepients exists
+	for _, address := range c.recipients(to) {
+		err := c.sendMsg(message, address)
+		if err != nil {
+			continue
+		}
+	}
+}
+
+// send msg only to recepients if recepients exists, to all connections if recepients is empty
+func (c *Core) recipients(to []string) []string {
+	c.RLock()
+	defer c.RUnlock()
+
+	addresses := make([]string, 0)
 	if len(to) != 0 {
 		for _, address := range to {
 			// check if recepient is from connected list
-			if c.ConnectionStorage[address] {
-				err := c.sendMsg(message, address)
-				if err != nil {
-					continue
-				}
-			} else {
+			if !c.ConnectionStorage[address] {
 				c.Logger.Debug("p2p -> server -> Send -> recepient is not from connected list", zap.String("recepient", address))
 				continue
 			}
-
-		}
-		//send msg to all connections if recepients is empty
-	} else {
-		for address := range c.ConnectionStorage {
-			err := c.sendMsg(message, address)
-			if err != nil {
-				continue
-			}
+			addresses = append(addresses, address)
 		}
+		return addresses
 	}
+
+	for address := range c.ConnectionStorage {
+		addresses = append(addresses, address)
+	}
+	return addresses
 }
 
 func (c *Core) sendMsg(message Message, address string) error {
 
-	if address == net.JoinHostPort(c.Server.Address.IP.String(), c.Config.P2P.Port) || address == net.JoinHostPort(c.Server.Address.IP.String(), c.Server.Address.PunchPort) {
+	if c.ownAddress(address) {
 		c.Logger.Error("p2p -> server -> Send : skip send to myself", zap.String("target", address))
 		return errors.New("skip sending to myself")
 	}
@@ -216,25 +388,20 @@ func (c *Core) sendMsg(message Message, address string) error {
 		return err
 	}
 
-	var localAddr string
-	if c.Server.Address.PunchPort == "" {
-		localAddr = net.JoinHostPort(c.Server.Address.IP.String(), c.Config.P2P.Port)
-	} else {
-		localAddr = net.JoinHostPort(c.Server.Address.IP.String(), c.Server.Address.PunchPort)
-	}
+	request := Request{Type: "message", LocalAddr: c.GetRealAddress(), RemoteAddr: clientAddr.String(), Message: message}
 
-	request := Request{Type: "message", LocalAddr: localAddr, RemoteAddr: clientAddr.String(), Message: message}
-
-	if c.Server.Connections.Out != nil {
-		err = request.Send(c.Server.Connections.Out, clientAddr)
+	if streamAddr := c.streamAddress(address); streamAddr != nil {
+		err = c.Reliable.Send(c.Server.Connections.Stream, streamAddr, request)
 		if err != nil {
-			c.Logger.Error("p2p -> server -> sendMsg -> request.Send(out)", zap.Error(err), zap.String("addr", clientAddr.String()))
-		}
-	} else {
-		err = request.Send(c.Server.Connections.In, clientAddr)
-		if err != nil {
-			c.Logger.Error("p2p -> server -> sendMsg -> request.Send(In)", zap.Error(err), zap.String("addr", clientAddr.String()))
+			c.Logger.Error("p2p -> server -> sendMsg -> Reliable.Send(stream)", zap.Error(err), zap.String("addr", streamAddr.String()))
 		}
+		c.Logger.Debug("p2p -> server -> sendMsg", zap.String("target", address), zap.String("stream", streamAddr.String()))
+		return nil
+	}
+
+	err = c.Reliable.Send(c.peerConn(), clientAddr, request)
+	if err != nil {
+		c.Logger.Error("p2p -> server -> sendMsg -> Reliable.Send", zap.Error(err), zap.String("addr", clientAddr.String()))
 	}
 	c.Logger.Debug("p2p -> server -> sendMsg", zap.String("target", address))
 	return nil
@@ -251,43 +418,87 @@ func (c *Core) RunClient() {
 	}
 }
 
+// connection used to send to connected peers : client connection if it exists
+func (c *Core) peerConn() *SecureConn {
+	if c.Server.Connections.Out != nil {
+		return c.Server.Connections.Out
+	}
+	return c.Server.Connections.In
+}
+
 // Process incoming connections
 func (c *Core) ProcessHandshakes() {
 	for {
 		peer := <-c.Server.AddrChan
 		//		c.Logger.Debug("HandleList", zap.Any("peer", peer))
 
-		if peer == net.JoinHostPort(c.Server.Address.IP.String(), c.Config.P2P.Port) || peer == net.JoinHostPort(c.Server.Address.IP.String(), c.Server.Address.PunchPort) {
+		err := c.requestHandshake(peer)
+		if err != nil {
+			c.Logger.Error("p2p -> core -> requestHandshake", zap.Error(err))
 			continue
 		}
+	}
+}
 
-		if existing := c.ConnectionStorage[peer]; existing != true || existing == true {
-			serverUDPAddr, err := net.ResolveUDPAddr("udp4", peer)
-			if err != nil {
-				c.Logger.Error("HandleList", zap.Error(err))
-				continue
-			}
+// send handshake request to the peer
+func (c *Core) requestHandshake(peer string) error {
+	if c.ownAddress(peer) {
+		return nil
+	}
 
-			request := Request{Type: handshakeRequest, LocalAddr: c.Server.Address.IP.String() + ":" + c.Server.Address.PunchPort, RemoteAddr: peer}
+	serverUDPAddr, err := net.ResolveUDPAddr("udp4", peer)
+	if err != nil {
+		return fmt.Errorf("ResolveUDPAddr : %w", err)
+	}
 
-			err = request.Send(c.Server.Connections.Out, serverUDPAddr)
-			if err != nil {
-				c.Logger.Error("p2p -> core -> request.Send", zap.Error(err))
-				continue
-			}
-		}
+	ip, punchPort := c.serverAddress()
+	request := Request{Type: handshakeRequest, LocalAddr: ip.String() + ":" + punchPort, RemoteAddr: peer}
+
+	err = request.Send(c.peerConn(), serverUDPAddr)
+	if err != nil {
+		return fmt.Errorf("request.Send : %w", err)
+	}
+	return nil
+}
+
+// disconnect from the peer to free its slot
+func (c *Core) evict(address string) {
+	addr, err := net.ResolveUDPAddr("udp4", address)
+	if err != nil {
+		c.Logger.Error("p2p -> core -> evict -> ResolveUDPAddr", zap.Error(err))
+		return
+	}
+
+	request := &Request{
+		Type: EventRequest,
+		Event: &Event{
+			Address: c.GetRealAddress(),
+			Type:    DisconnectionEventType,
+		},
+		LocalAddr:  c.GetRealAddress(),
+		RemoteAddr: address,
 	}
+
+	err = request.Send(c.peerConn(), addr)
+	if err != nil {
+		c.Logger.Error("p2p -> core -> evict -> request.Send", zap.Error(err))
+	}
+
+	c.RemoveConnection(address)
+}
+
+// PublicKey - hex static noise key of the node, peers can trust it by p2p.trustedKeys
+func (c *Core) PublicKey() string {
+	return hex.EncodeToString(c.Identity.Noise.Public)
 }
 
 // Return address ip+port if it is peernode, ip+punchport if not
 func (c *Core) GetRealAddress() string {
-	var address string
-	if c.Server.Address.PunchPort == "" {
-		address = net.JoinHostPort(c.Server.Address.IP.String(), c.Config.P2P.Port)
-	} else {
-		address = net.JoinHostPort(c.Server.Address.IP.String(), c.Server.Address.PunchPort)
+	ip, punchPort := c.serverAddress()
+	if punchPort == "" {
+		return net.JoinHostPort(ip.String(), c.Config.P2P.Port)
 	}
-	return address
+	return net.JoinHostPort(ip.String(), punchPort)
 }
 
 // Send disconnect event type to all connected nodes
@@ -315,7 +526,7 @@ func (c *Core) ProvideDisconnection() {
 			continue
 		}
 
-		err = disconnectionReq.Send(c.Server.Connections.In, addr)
+		err = disconnectionReq.Send(c.peerConn(), addr)
 		if err != nil {
 			c.Logger.Error("p2p -> core -> ProvideDisconnection -> request.Send", zap.Error(err))
 			continue
@@ -327,83 +538,129 @@ func (c *Core) ProvideDisconnection() {
 // get next message from p2p. If message sending by chunks, NextMsg will return whole message, when all chunks
 // got and checked. If error is nil and message is nil -> msg was not fully assembled
 func (c *Core) NextMsg(ctx context.Context) (*Message, error) {
-	select {
-	case req, ok := <-c.MsgCh:
-		if !ok {
-			return nil, errors.New("error")
+	return c.next(ctx, c.Inbound)
+}
+
+// next message from the queues, chunked message is assembled and directed message is decrypted
+func (c *Core) next(ctx context.Context, inbound *Inbound) (*Message, error) {
+	req, err := inbound.Pop(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.Logger.Debug("NextMsg", zap.String("hash", req.Message.Hash),
+		zap.Int("part", req.Message.Part),
+		zap.Int("total parts", req.Message.TotalParts),
+		zap.Bool("last", req.Message.Last))
+
+	msg := &req.Message
+	if req.Message.Hash != "" && req.Message.Part != 0 && req.Message.TotalParts != 0 {
+		msg, err = c.HandleIncomingChunkedMsg(&req.Message)
+		if err != nil {
+			return nil, fmt.Errorf("core -> NextMsg -> HandleIncomingChunkedMsg : %w", err)
 		}
-		c.Logger.Debug("NextMsg", zap.String("hash", req.Message.Hash),
-			zap.Int("part", req.Message.Part),
-			zap.Int("total parts", req.Message.TotalParts),
-			zap.Bool("last", req.Message.Last))
 
-		if req.Message.Hash != "" && req.Message.Part != 0 && req.Message.TotalParts != 0 {
-			msg, err := c.HandleIncomingChunkedMsg(&req.Message)
-			if err != nil {
-				return nil, fmt.Errorf("core -> NextMsg -> HandleIncomingChunkedMsg : %w", err)
-			}
+		if msg == nil { // not full message assembled yet
+			return nil, nil
+		}
 
-			if msg == nil { // not full message assembled yet
-				return nil, nil
-			}
+		// whole message could be already got from stream transport peer
+		found, err := c.CheckCache(msg.ID)
+		if err != nil {
+			return nil, fmt.Errorf("core -> NextMsg -> CheckCache : %w", err)
+		}
+		if found {
+			return nil, nil
+		}
+	}
 
-			return msg, nil
+	// directed message is encrypted to this node
+	if msg.Target != "" {
+		err := c.open(msg)
+		if err != nil {
+			return nil, fmt.Errorf("core -> NextMsg -> open : %w", err)
 		}
-		return &req.Message, nil
-	case <-ctx.Done():
-		return nil, ctx.Err()
 	}
+	return msg, nil
 }
 
 func (c *Core) HandleIncomingChunkedMsg(msg *Message) (*Message, error) {
-	c.Logger.Debug("HandleIncomingChunkedMsg", zap.String("hash", msg.Hash),
+	c.Logger.Debug("HandleIncomingChunkedMsg", zap.String("id", msg.ID),
+		zap.String("hash", msg.Hash),
 		zap.Int("part", msg.Part),
 		zap.Int("total parts", msg.TotalParts),
 		zap.Bool("last", msg.Last))
 
 	c.Server.RWMutex.Lock()
 	defer c.Server.Unlock()
-	slice, ok := c.Server.BufferedMsgs[msg.Hash]
+	buffered, ok := c.Server.BufferedMsgs[msg.ID]
 	if !ok { //first msg
-		slice = make([]*Message, 0)
-		slice = append(slice, msg)
-		c.Server.BufferedMsgs[msg.Hash] = slice
+		buffered = &bufferedMsg{parts: make(map[int]*Message)}
+		c.Server.BufferedMsgs[msg.ID] = buffered
+	}
+	buffered.parts[msg.Part] = msg // retransmitted chunk overwrites the same part
+	buffered.updated = c.clock.Now()
+
+	if len(buffered.parts) < msg.TotalParts {
 		return nil, nil
 	}
-	if len(slice) == msg.TotalParts-1 { // last msg got
-		slice = append(slice, msg)
-		// sort message
-		sort.SliceStable(slice, func(i, j int) bool {
-			return slice[i].Part < slice[j].Part
-		})
-		finalData := make([]byte, 0)
-		for _, msg := range slice {
-			finalData = append(finalData, msg.Data...)
-		}
-		finalMsg := &Message{
-			From:       msg.From,
-			To:         msg.To,
-			Data:       finalData,
-			Hash:       msg.Hash,
-			TotalParts: msg.TotalParts,
-			Part:       msg.Part,
-			Last:       msg.Last,
+
+	// all chunks got, assemble in parts order
+	finalData := make([]byte, 0)
+	for part := 1; part <= msg.TotalParts; part++ {
+		chunk, ok := buffered.parts[part]
+		if !ok {
+			delete(c.Server.BufferedMsgs, msg.ID)
+			metrics.ChunkReassemblyFailures.Inc()
+			return nil, fmt.Errorf("HandleIncomingChunkedMsg error, part %d of %d is missing, hash : %s", part, msg.TotalParts, msg.Hash)
 		}
+		finalData = append(finalData, chunk.Data...)
+	}
+	finalMsg := &Message{
+		ID:         msg.ID,
+		Hops:       msg.Hops,
+		Topic:      msg.Topic,
+		Target:     msg.Target,
+		Origin:     msg.Origin,
+		From:       msg.From,
+		To:         msg.To,
+		Data:       finalData,
+		Hash:       msg.Hash,
+		TotalParts: msg.TotalParts,
+		Part:       msg.TotalParts,
+		Last:       true,
+	}
 
-		h := sha1.New()
-		h.Write(finalMsg.Data)
-		calculatedHash := hex.EncodeToString(h.Sum(nil))
-		if msg.Hash != calculatedHash {
-			//c.Logger.Sugar().Errorf("MAP : %+v\n", testChunkMap)
-			return nil, fmt.Errorf("HandleIncomingChunkedMsg error, expected hash : %s, got %s", finalMsg.Hash, calculatedHash)
+	// clean buffered msgs storage
+	delete(c.Server.BufferedMsgs, msg.ID)
+
+	h := sha1.New()
+	h.Write(finalMsg.Data)
+	calculatedHash := hex.EncodeToString(h.Sum(nil))
+	if msg.Hash != calculatedHash {
+		metrics.ChunkReassemblyFailures.Inc()
+		return nil, fmt.Errorf("HandleIncomingChunkedMsg error, expected hash : %s, got %s", finalMsg.Hash, calculatedHash)
+	}
+	return finalMsg, nil
+}
+
+// CleanBufferedMsgs - drop chunked messages which were not assembled during reassembly timeout
+func (c *Core) CleanBufferedMsgs() {
+	ticks, stop := c.clock.Ticker(c.Config.UDP.ReassemblyTimeout / 2)
+	defer stop()
+
+	for now := range ticks {
+		c.Server.RWMutex.Lock()
+		for id, buffered := range c.Server.BufferedMsgs {
+			if now.Sub(buffered.updated) < c.Config.UDP.ReassemblyTimeout {
+				continue
+			}
+			c.Logger.Warn("p2p -> core -> CleanBufferedMsgs : chunked message was not assembled",
+				zap.String("id", id), zap.Int("parts", len(buffered.parts)))
+			delete(c.Server.BufferedMsgs, id)
+			metrics.ReassemblyTimeouts.Inc()
 		}
-		// clean buffered msgs storage
-		delete(c.Server.BufferedMsgs, msg.Hash)
-		return finalMsg, nil
+		c.Server.RWMutex.Unlock()
 	}
-	slice = append(slice, msg)
-	c.Server.BufferedMsgs[msg.Hash] = slice
-	return nil, nil
 }
 
 // @TODO: old version
@@ -466,20 +723,19 @@ func (c *Core) HandleIncomingChunkedMsg(msg *Message) (*Message, error) {
 func (c *Core) CheckAllowConn(filter filterConnections, ip, port string) error {
 	// check if connection is allowed
 	// s.IP.String() as example
-	if !filter(c.Server.Address.IP.String()) {
+	serverIP, punchPort := c.serverAddress()
+	if !filter(serverIP.String()) {
 		addr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(ip, port))
 		if err != nil {
 			c.Logger.Error("p2p -> server -> CheckAllowConn -> ResolveUDPAddr", zap.Error(err))
 			return err
 		}
 		c.Logger.Debug("p2p - server - StartServer - AllowConnection - reject connection", zap.String("host", addr.IP.String()), zap.String("port", port))
-		var usedPort string
-		if c.Server.Address.PunchPort == "" {
+		usedPort := punchPort
+		if usedPort == "" {
 			usedPort = c.Config.P2P.Port
-		} else {
-			usedPort = c.Server.Address.PunchPort
 		}
-		response := Response{Type: punchResponse, Status: statusConnectionRejected, Ip: c.Server.Address.IP.String(), Port: usedPort}
+		response := Response{Type: punchResponse, Status: statusConnectionRejected, Ip: serverIP.String(), Port: usedPort}
 		err = response.Send(c.Server.Connections.In, addr)
 		if err != nil {
 			c.Logger.Error("p2p -> server -> CheckAllowConn -> response.Send", zap.Error(err))
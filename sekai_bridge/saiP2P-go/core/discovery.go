@@ -0,0 +1,516 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saiset-co/saiP2P-go/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	peersRequest = "peers"
+
+	peerRecordPrefix  = "saiP2P-go/peer/1"
+	maxGossipRecords  = 32 // known records sent in one gossip request besides own record
+	maxScore          = 100
+	livenessIntervals = 3 // connected peer is dropped after this number of intervals without gossip
+	livenessPenalty   = 10
+	failurePenalty    = 5
+	recordClockSkew   = time.Minute // allowed timestamp in the future
+)
+
+// PeerRecord - signed address of the node, exchanged by gossip
+type PeerRecord struct {
+	ID        string `json:"id" cbor:"1,keyasint"`        // hex ed25519 key of the node
+	NoiseKey  string `json:"noise_key" cbor:"2,keyasint"` // hex static noise key of the node
+	Address   string `json:"address" cbor:"3,keyasint"`   // ip:port of the node
+	Timestamp int64  `json:"timestamp" cbor:"4,keyasint"` // unix nano, newer record replaces older one
+	Signature string `json:"signature" cbor:"5,keyasint"` // hex ed25519 signature
+}
+
+// Discovery - gossip of signed peer records, reconnection with backoff and peer scoring.
+// Known peers are saved to disk and used to reconnect after restart.
+type Discovery struct {
+	sync.Mutex
+	core      *Core
+	known     map[string]*knownPeer // by node id
+	bootstrap map[string]*knownPeer // peers from config, by configured address
+	links     map[string]*peerLink  // connected peers by address
+	dirty     bool                  // known peers were changed after last save
+}
+
+// known peer, exported fields are saved to disk
+type knownPeer struct {
+	Record      PeerRecord `json:"record"`
+	Score       int        `json:"score"`
+	Failures    int        `json:"failures"`
+	nextAttempt time.Time
+	attempted   time.Time // last connection attempt, zero if not waiting for connection
+}
+
+// connection to the peer
+type peerLink struct {
+	id       string // node id, empty until first gossip of the peer
+	lastSeen time.Time
+}
+
+// NewDiscovery - create discovery, known peers are loaded from disk
+func NewDiscovery(core *Core) *Discovery {
+	d := &Discovery{
+		core:      core,
+		known:     make(map[string]*knownPeer),
+		bootstrap: make(map[string]*knownPeer),
+		links:     make(map[string]*peerLink),
+	}
+
+	for _, address := range core.Config.Peers {
+		d.bootstrap[address] = &knownPeer{Record: PeerRecord{Address: address}}
+	}
+
+	err := d.load()
+	if err != nil {
+		core.Logger.Error("p2p -> discovery -> load", zap.Error(err))
+	}
+
+	return d
+}
+
+// Run - periodically gossip peer records, check liveness of connected peers and connect to known peers
+func (d *Discovery) Run() {
+	ticks, stop := d.core.clock.Ticker(d.core.Config.Discovery.Interval)
+	defer stop()
+
+	for range ticks {
+		d.gossip()
+		d.maintain()
+
+		err := d.save()
+		if err != nil {
+			d.core.Logger.Error("p2p -> discovery -> save", zap.Error(err))
+		}
+	}
+}
+
+// HandleGossip - accept peer records and routes. First record belongs to the sender and must match its noise session.
+func (d *Discovery) HandleGossip(conn *SecureConn, addr *net.UDPAddr, records []PeerRecord, routes map[string]int) {
+	if len(records) == 0 {
+		return
+	}
+
+	own := records[0]
+	if own.Verify() != nil || own.NoiseKey != hex.EncodeToString(conn.RemoteKey(addr)) {
+		d.core.Logger.Debug("p2p -> discovery -> HandleGossip : sender record is not valid", zap.String("addr", addr.String()))
+		metrics.PacketsRejected.WithLabelValues("gossip").Inc()
+		return
+	}
+
+	now := d.core.clock.Now()
+	self := d.core.Identity.ID()
+
+	d.Lock()
+	defer d.Unlock()
+
+	// peer could send from another address than it is connected by, links are matched by noise key
+	if link, ok := d.links[addr.String()]; ok {
+		link.id = own.ID
+	}
+	for address, link := range d.links {
+		if link.id == "" && d.remoteKey(address) == own.NoiseKey {
+			link.id = own.ID
+		}
+		if link.id == own.ID {
+			link.lastSeen = now
+			d.core.Router.learn(address, own.ID, routes)
+		}
+	}
+
+	for _, record := range records {
+		if record.ID == self {
+			continue
+		}
+		if !d.fresh(record, now) || record.Verify() != nil {
+			continue
+		}
+
+		peer, ok := d.known[record.ID]
+		if !ok {
+			if len(d.known) >= d.core.Config.Discovery.MaxPeers {
+				continue
+			}
+			peer = &knownPeer{}
+			d.known[record.ID] = peer
+		}
+		if record.Timestamp > peer.Record.Timestamp {
+			peer.Record = record
+			d.dirty = true
+		}
+	}
+
+	if peer, ok := d.known[own.ID]; ok && peer.Score < maxScore {
+		peer.Score++
+	}
+}
+
+// lookup node id of the recipient given by node id or address
+func (d *Discovery) lookup(recipient string) (string, bool) {
+	d.Lock()
+	defer d.Unlock()
+
+	if _, ok := d.known[recipient]; ok {
+		return recipient, true
+	}
+
+	address := d.resolve(recipient)
+	if address == "" {
+		return "", false
+	}
+	for id, peer := range d.known {
+		if d.resolve(peer.Record.Address) == address {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// record of the known node
+func (d *Discovery) record(id string) (PeerRecord, bool) {
+	d.Lock()
+	defer d.Unlock()
+
+	peer, ok := d.known[id]
+	if !ok {
+		return PeerRecord{}, false
+	}
+	return peer.Record, true
+}
+
+// Peers - known peer records
+func (d *Discovery) Peers() []PeerRecord {
+	d.Lock()
+	defer d.Unlock()
+
+	records := make([]PeerRecord, 0, len(d.known))
+	for _, peer := range d.known {
+		records = append(records, peer.Record)
+	}
+	return records
+}
+
+// peer was connected
+func (d *Discovery) connected(address string) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.links[address] = &peerLink{lastSeen: d.core.clock.Now()}
+
+	for _, peer := range d.candidates() {
+		if d.resolve(peer.Record.Address) == address {
+			peer.Failures = 0
+			peer.attempted = time.Time{}
+		}
+	}
+}
+
+// peer was disconnected, reconnection is scheduled
+func (d *Discovery) disconnected(address string) {
+	d.Lock()
+	defer d.Unlock()
+
+	link, ok := d.links[address]
+	if !ok {
+		return
+	}
+	delete(d.links, address)
+
+	if peer, ok := d.known[link.id]; ok {
+		peer.nextAttempt = d.core.clock.Now().Add(d.backoff(peer.Failures))
+	}
+}
+
+// send own record and known records to connected peers
+func (d *Discovery) gossip() {
+	own := PeerRecord{
+		ID:        d.core.Identity.ID(),
+		NoiseKey:  hex.EncodeToString(d.core.Identity.Noise.Public),
+		Address:   d.core.GetRealAddress(),
+		Timestamp: d.core.clock.Now().UnixNano(),
+	}
+	own.sign(d.core.Identity.Sign)
+
+	records := []PeerRecord{own}
+
+	d.Lock()
+	now := d.core.clock.Now()
+	known := make([]*knownPeer, 0, len(d.known))
+	for _, peer := range d.known {
+		if d.fresh(peer.Record, now) {
+			known = append(known, peer)
+		}
+	}
+	sort.Slice(known, func(i, j int) bool {
+		return known[i].Score > known[j].Score
+	})
+	for i := 0; i < len(known) && i < maxGossipRecords; i++ {
+		records = append(records, known[i].Record)
+	}
+	d.Unlock()
+
+	for _, address := range d.core.recipients(nil) {
+		addr, err := net.ResolveUDPAddr("udp4", address)
+		if err != nil {
+			d.core.Logger.Error("p2p -> discovery -> gossip -> ResolveUDPAddr", zap.Error(err))
+			continue
+		}
+
+		request := Request{Type: peersRequest, LocalAddr: own.Address, RemoteAddr: address, Peers: records, Routes: d.core.Router.advertise(address)}
+		err = request.Send(d.core.peerConn(), addr)
+		if err != nil {
+			d.core.Logger.Error("p2p -> discovery -> gossip -> request.Send", zap.Error(err), zap.String("addr", address))
+		}
+	}
+}
+
+// drop silent peers, evict peers with low score, connect to known peers while slots are free
+func (d *Discovery) maintain() {
+	config := d.core.Config.Discovery
+	now := d.core.clock.Now()
+
+	connections := make(map[string]bool)
+	for _, address := range d.core.recipients(nil) {
+		connections[address] = true
+	}
+
+	d.Lock()
+
+	// liveness
+	dead := make([]string, 0)
+	for address, link := range d.links {
+		if now.Sub(link.lastSeen) > livenessIntervals*config.Interval {
+			dead = append(dead, address)
+			if peer, ok := d.known[link.id]; ok {
+				peer.Score -= livenessPenalty
+			}
+		}
+	}
+
+	// failed connection attempts
+	for key, peer := range d.candidates() {
+		if peer.attempted.IsZero() || now.Sub(peer.attempted) < config.Interval {
+			continue
+		}
+		if connections[d.resolve(peer.Record.Address)] {
+			peer.attempted = time.Time{}
+			continue
+		}
+		peer.Failures++
+		peer.Score -= failurePenalty
+		peer.attempted = time.Time{}
+		peer.nextAttempt = now.Add(d.backoff(peer.Failures))
+		if _, ok := d.known[key]; ok && peer.Failures >= config.MaxFailures {
+			d.core.Logger.Debug("p2p -> discovery -> peer forgotten", zap.String("id", key), zap.String("address", peer.Record.Address))
+			delete(d.known, key)
+		}
+		d.dirty = true
+	}
+
+	// eviction of the worst connected peer, when slots are full
+	evict := ""
+	if len(connections)-len(dead) >= d.core.Config.P2P.Slot {
+		worst := config.EvictScore
+		for address, link := range d.links {
+			if peer, ok := d.known[link.id]; ok && peer.Score < worst {
+				worst = peer.Score
+				evict = address
+			}
+		}
+	}
+
+	// connection candidates, bootstrap peers first, then by score
+	attempts := make([]string, 0)
+	free := d.core.Config.P2P.Slot - len(connections) + len(dead)
+	if evict != "" {
+		free++
+	}
+	candidates := make([]*knownPeer, 0)
+	for key, peer := range d.candidates() {
+		if key == d.core.Identity.ID() || !peer.attempted.IsZero() || now.Before(peer.nextAttempt) {
+			continue
+		}
+		if address := d.resolve(peer.Record.Address); address == "" || connections[address] {
+			continue
+		}
+		candidates = append(candidates, peer)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		iBootstrap, jBootstrap := candidates[i].Record.ID == "", candidates[j].Record.ID == ""
+		if iBootstrap != jBootstrap {
+			return iBootstrap
+		}
+		return candidates[i].Score > candidates[j].Score
+	})
+	for i := 0; i < len(candidates) && i < free; i++ {
+		candidates[i].attempted = now
+		attempts = append(attempts, d.resolve(candidates[i].Record.Address))
+	}
+
+	d.Unlock()
+
+	for _, address := range dead {
+		d.core.Logger.Info("p2p -> discovery -> peer is silent, disconnected", zap.String("address", address))
+		d.core.RemoveConnection(address)
+	}
+
+	if evict != "" {
+		d.core.Logger.Info("p2p -> discovery -> peer evicted", zap.String("address", evict))
+		d.core.evict(evict)
+	}
+
+	for _, address := range attempts {
+		err := d.core.requestHandshake(address)
+		if err != nil {
+			d.core.Logger.Error("p2p -> discovery -> requestHandshake", zap.Error(err), zap.String("address", address))
+		}
+	}
+}
+
+// known and bootstrap peers, should be called under lock
+func (d *Discovery) candidates() map[string]*knownPeer {
+	peers := make(map[string]*knownPeer, len(d.known)+len(d.bootstrap))
+	for key, peer := range d.bootstrap {
+		peers[key] = peer
+	}
+	for key, peer := range d.known {
+		peers[key] = peer
+	}
+	return peers
+}
+
+// check record timestamp, should be called under lock
+func (d *Discovery) fresh(record PeerRecord, now time.Time) bool {
+	timestamp := time.Unix(0, record.Timestamp)
+	return now.Sub(timestamp) < d.core.Config.Discovery.RecordTTL && timestamp.Sub(now) < recordClockSkew
+}
+
+// reconnection delay with jitter
+func (d *Discovery) backoff(failures int) time.Duration {
+	config := d.core.Config.Discovery
+	delay := config.BackoffMin
+	for i := 0; i < failures && delay < config.BackoffMax; i++ {
+		delay *= 2
+	}
+	delay = minDuration(delay, config.BackoffMax)
+	return delay - delay/5 + time.Duration(rand.Int63n(int64(delay/5)*2+1))
+}
+
+// hex static key of the connected peer, empty if there is no session with it
+func (d *Discovery) remoteKey(address string) string {
+	addr, err := net.ResolveUDPAddr("udp4", address)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(d.core.peerConn().RemoteKey(addr))
+}
+
+// resolve address of the peer to the connection storage form
+func (d *Discovery) resolve(address string) string {
+	addr, err := net.ResolveUDPAddr("udp4", address)
+	if err != nil {
+		return ""
+	}
+	return addr.String()
+}
+
+func (d *Discovery) load() error {
+	data, err := os.ReadFile(d.core.Config.Discovery.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ReadFile : %w", err)
+	}
+
+	peers := make([]*knownPeer, 0)
+	err = json.Unmarshal(data, &peers)
+	if err != nil {
+		return fmt.Errorf("Unmarshal : %w", err)
+	}
+
+	for _, peer := range peers {
+		if peer.Record.Verify() != nil {
+			continue
+		}
+		d.known[peer.Record.ID] = peer
+	}
+
+	return nil
+}
+
+func (d *Discovery) save() error {
+	d.Lock()
+	if !d.dirty {
+		d.Unlock()
+		return nil
+	}
+	peers := make([]*knownPeer, 0, len(d.known))
+	for _, peer := range d.known {
+		peers = append(peers, peer)
+	}
+	data, err := json.Marshal(peers)
+	d.dirty = false
+	d.Unlock()
+	if err != nil {
+		return fmt.Errorf("Marshal : %w", err)
+	}
+
+	path := d.core.Config.Discovery.File
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return fmt.Errorf("WriteFile : %w", err)
+	}
+
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return fmt.Errorf("Rename : %w", err)
+	}
+
+	return nil
+}
+
+func (r *PeerRecord) payload() []byte {
+	return []byte(strings.Join([]string{peerRecordPrefix, r.ID, r.NoiseKey, r.Address, strconv.FormatInt(r.Timestamp, 10)}, "\n"))
+}
+
+func (r *PeerRecord) sign(key ed25519.PrivateKey) {
+	r.Signature = hex.EncodeToString(ed25519.Sign(key, r.payload()))
+}
+
+// Verify - check record signature by node id
+func (r *PeerRecord) Verify() error {
+	public, err := hex.DecodeString(r.ID)
+	if err != nil || len(public) != ed25519.PublicKeySize {
+		return errors.New("wrong peer id")
+	}
+
+	signature, err := hex.DecodeString(r.Signature)
+	if err != nil {
+		return fmt.Errorf("DecodeString : %w", err)
+	}
+
+	if !ed25519.Verify(public, r.payload(), signature) {
+		return errors.New("wrong peer record signature")
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Clock - source of time of the core, tests replace it by virtual clock
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	Ticker(d time.Duration) (<-chan time.Time, func()) // ticks channel and stop func
+}
+
+// Network - opens udp sockets of the core, tests replace it by simulated network
+type Network interface {
+	ListenUDP(address string) (PacketConn, error) // empty address or port means any port
+}
+
+// Option - changes environment of the core on Init
+type Option func(*Core)
+
+// WithClock - run core by the clock
+func WithClock(clock Clock) Option {
+	return func(c *Core) {
+		c.clock = clock
+	}
+}
+
+// WithNetwork - open core sockets in the network
+func WithNetwork(network Network) Option {
+	return func(c *Core) {
+		c.network = network
+	}
+}
+
+// WithLogger - use logger instead of default one
+func WithLogger(logger *zap.Logger) Option {
+	return func(c *Core) {
+		c.Logger = logger
+	}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (systemClock) Ticker(d time.Duration) (<-chan time.Time, func()) {
+	ticker := time.NewTicker(d)
+	return ticker.C, ticker.Stop
+}
+
+type systemNetwork struct{}
+
+func (systemNetwork) ListenUDP(address string) (PacketConn, error) {
+	var addr *net.UDPAddr
+	if address != "" {
+		var err error
+		addr, err = net.ResolveUDPAddr("udp4", address)
+		if err != nil {
+			return nil, fmt.Errorf("ResolveUDPAddr : %w", err)
+		}
+	}
+
+	conn, err := net.ListenUDP("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/flynn/noise"
+)
+
+// Identity - keys of the node : static noise key for sessions and ed25519 key for signed peer records.
+// Node id is hex ed25519 public key.
+type Identity struct {
+	Noise noise.DHKey
+	Sign  ed25519.PrivateKey
+}
+
+type identityFile struct {
+	Private     string `json:"private"`
+	Public      string `json:"public"`
+	SignPrivate string `json:"sign_private"`
+}
+
+// ID - node id, hex ed25519 public key
+func (i *Identity) ID() string {
+	return hex.EncodeToString(i.Sign.Public().(ed25519.PublicKey))
+}
+
+// LoadIdentity - read keys of the node, missing keys are generated and saved
+func LoadIdentity(path string) (*Identity, error) {
+	identity := &Identity{}
+	changed := false
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		identity.Noise, err = noiseSuite.GenerateKeypair(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("GenerateKeypair : %w", err)
+		}
+		changed = true
+	case err != nil:
+		return nil, fmt.Errorf("ReadFile : %w", err)
+	default:
+		file := identityFile{}
+		err = json.Unmarshal(data, &file)
+		if err != nil {
+			return nil, fmt.Errorf("Unmarshal : %w", err)
+		}
+
+		identity.Noise.Private, err = hex.DecodeString(file.Private)
+		if err != nil || len(identity.Noise.Private) != noiseSuite.DHLen() {
+			return nil, errors.New("wrong private key in identity file")
+		}
+		identity.Noise.Public, err = hex.DecodeString(file.Public)
+		if err != nil || len(identity.Noise.Public) != noiseSuite.DHLen() {
+			return nil, errors.New("wrong public key in identity file")
+		}
+
+		if file.SignPrivate != "" {
+			sign, err := hex.DecodeString(file.SignPrivate)
+			if err != nil || len(sign) != ed25519.PrivateKeySize {
+				return nil, errors.New("wrong sign key in identity file")
+			}
+			identity.Sign = sign
+		}
+	}
+
+	// identity files created before signed peer records have only noise key
+	if identity.Sign == nil {
+		_, identity.Sign, err = ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("GenerateKey : %w", err)
+		}
+		changed = true
+	}
+
+	if changed {
+		err = identity.save(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return identity, nil
+}
+
+func (i *Identity) save(path string) error {
+	data, err := json.Marshal(identityFile{
+		Private:     hex.EncodeToString(i.Noise.Private),
+		Public:      hex.EncodeToString(i.Noise.Public),
+		SignPrivate: hex.EncodeToString(i.Sign),
+	})
+	if err != nil {
+		return fmt.Errorf("Marshal : %w", err)
+	}
+
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return fmt.Errorf("WriteFile : %w", err)
+	}
+
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return fmt.Errorf("Rename : %w", err)
+	}
+
+	return nil
+}
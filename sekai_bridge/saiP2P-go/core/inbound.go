@@ -0,0 +1,162 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/saiset-co/saiP2P-go/config"
+	"github.com/saiset-co/saiP2P-go/metrics"
+)
+
+const (
+	DropNewest = "newest" // incoming message is dropped when peer queue is full
+	DropOldest = "oldest" // oldest queued message of the peer is dropped when queue is full
+)
+
+// Inbound - bounded queues of received messages per peer, consumed by NextMsg in round robin,
+// so one busy peer does not starve others. Pushing never blocks the read loop, messages over
+// queue size are dropped by drop policy. Datagrams over peer rate are not acked, so the sender
+// retransmits them later.
+type Inbound struct {
+	sync.Mutex
+	Config  config.Config
+	clock   Clock
+	queues  map[string][]*Request   // by peer address
+	order   []string                // peers with queued messages, round robin order
+	buckets map[string]*tokenBucket // rate limits by peer address
+	ready   chan struct{}           // signaled when message is queued
+}
+
+// token bucket of the peer rate limit
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewInbound - create inbound queues
+func NewInbound(config config.Config, clock Clock) *Inbound {
+	return &Inbound{
+		Config:  config,
+		clock:   clock,
+		queues:  make(map[string][]*Request),
+		buckets: make(map[string]*tokenBucket),
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+// Allow - check rate limit of the peer, takes token if datagram is allowed
+func (i *Inbound) Allow(peer string) bool {
+	rate := i.Config.Inbound.Rate
+	if rate <= 0 {
+		return true
+	}
+
+	i.Lock()
+	defer i.Unlock()
+
+	now := i.clock.Now()
+	burst := float64(i.Config.Inbound.Burst)
+	bucket, ok := i.buckets[peer]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, updated: now}
+		i.buckets[peer] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.updated).Seconds() * rate
+	if bucket.tokens > burst {
+		bucket.tokens = burst
+	}
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		metrics.MessagesDropped.WithLabelValues("rate").Inc()
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// Push - queue message of the peer, returns false if message was dropped
+func (i *Inbound) Push(peer string, request *Request) bool {
+	i.Lock()
+	defer i.Unlock()
+
+	queue, ok := i.queues[peer]
+	if !ok {
+		i.order = append(i.order, peer)
+	}
+
+	if len(queue) >= i.Config.Inbound.QueueSize {
+		metrics.MessagesDropped.WithLabelValues("queue").Inc()
+		if i.Config.Inbound.DropPolicy != DropOldest {
+			if !ok {
+				i.order = i.order[:len(i.order)-1]
+			}
+			return false
+		}
+		queue[0] = nil
+		queue = queue[1:]
+		metrics.InboundQueued.Dec()
+	}
+
+	i.queues[peer] = append(queue, request)
+	metrics.InboundQueued.Inc()
+
+	select {
+	case i.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// Pop - next queued message, blocks until message is queued or context is done
+func (i *Inbound) Pop(ctx context.Context) (*Request, error) {
+	for {
+		request := i.pop()
+		if request != nil {
+			return request, nil
+		}
+
+		select {
+		case <-i.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Forget - drop rate limit state of disconnected peer, queued messages are still consumed
+func (i *Inbound) Forget(peer string) {
+	i.Lock()
+	defer i.Unlock()
+
+	delete(i.buckets, peer)
+}
+
+// take message from the first peer in round robin order
+func (i *Inbound) pop() *Request {
+	i.Lock()
+	defer i.Unlock()
+
+	if len(i.order) == 0 {
+		return nil
+	}
+
+	peer := i.order[0]
+	queue := i.queues[peer]
+	request := queue[0]
+	queue[0] = nil
+	queue = queue[1:]
+	metrics.InboundQueued.Dec()
+
+	i.order = i.order[1:]
+	if len(queue) == 0 {
+		delete(i.queues, peer)
+	} else {
+		i.queues[peer] = queue
+		i.order = append(i.order, peer)
+	}
+
+	return request
+}
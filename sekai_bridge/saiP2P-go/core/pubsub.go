@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+	"hash/fnv"
+
+	"github.com/saiset-co/saiP2P-go/metrics"
+)
+
+// Subscription - stream of messages published to the topic
+type Subscription struct {
+	Topic   string
+	id      uint32
+	core    *Core
+	inbound *Inbound
+}
+
+// TopicID - id of the topic in message header, 0 is reserved for messages sent by SendMsg
+func TopicID(topic string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(topic))
+	if id := h.Sum32(); id != 0 {
+		return id
+	}
+	return 1
+}
+
+// Subscribe - receive messages of the topic. Messages of topics without subscription are dropped
+// before they are queued.
+func (c *Core) Subscribe(topic string) *Subscription {
+	id := TopicID(topic)
+
+	c.Lock()
+	defer c.Unlock()
+
+	if subscription, ok := c.subscriptions[id]; ok {
+		return subscription
+	}
+
+	subscription := &Subscription{
+		Topic:   topic,
+		id:      id,
+		core:    c,
+		inbound: NewInbound(c.Config, c.clock),
+	}
+	c.subscriptions[id] = subscription
+	return subscription
+}
+
+// Unsubscribe - stop receiving messages of the topic
+func (c *Core) Unsubscribe(topic string) {
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.subscriptions, TopicID(topic))
+}
+
+// Publish - broadcast data to subscribers of the topic
+func (c *Core) Publish(topic string, data []byte) error {
+	return c.sendMessage(TopicID(topic), data, nil, c.GetRealAddress())
+}
+
+// PublishTo - send data to subscribers of the topic among recipients (addresses or node ids)
+func (c *Core) PublishTo(topic string, data []byte, to []string) error {
+	return c.sendMessage(TopicID(topic), data, to, c.GetRealAddress())
+}
+
+// Next - get next message of the topic, see NextMsg
+func (s *Subscription) Next(ctx context.Context) (*Message, error) {
+	return s.core.next(ctx, s.inbound)
+}
+
+// queue received message to consumer of its topic
+func (c *Core) deliver(peer string, request *Request) {
+	inbound := c.Inbound
+	if topic := request.Message.Topic; topic != 0 {
+		c.RLock()
+		subscription, ok := c.subscriptions[topic]
+		c.RUnlock()
+		if !ok {
+			metrics.MessagesDropped.WithLabelValues("topic").Inc()
+			return
+		}
+		inbound = subscription.inbound
+	}
+
+	inbound.Push(peer, request)
+}
@@ -0,0 +1,336 @@
+package core
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/saiset-co/saiP2P-go/config"
+	"github.com/saiset-co/saiP2P-go/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	ackRequest = "ack"
+
+	maxSelectiveAcks = 64                    // max received datagrams above cumulative ack listed in one ack
+	rtoGranularity   = 10 * time.Millisecond // clock granularity G from RFC 6298
+)
+
+var errSendWindowFull = errors.New("send window is full")
+
+// Ack - acknowledgement of received message datagrams
+type Ack struct {
+	Epoch      uint64   `json:"epoch" cbor:"1,keyasint"`                    // epoch of the acked sender
+	Cumulative uint64   `json:"cum" cbor:"2,keyasint"`                      // all datagrams up to this seq were received
+	Selective  []uint64 `json:"sack,omitempty" cbor:"3,keyasint,omitempty"` // datagrams received above cumulative
+}
+
+// Reliable - delivery of message datagrams with acks and retransmission.
+// Every message datagram sent to peer gets sequence number, peer acks received datagrams,
+// unacked datagrams are retransmitted after RTO (estimated by RFC 6298). Number of unacked
+// datagrams per peer is bounded by the send window, sending blocks until window has free slot.
+type Reliable struct {
+	sync.Mutex
+	Config config.Config
+	Logger *zap.Logger
+	clock  Clock
+	epoch  uint64                // changes on restart, so peers reset receive state
+	send   map[string]*sendState // by peer address
+	recv   map[string]*recvState // by peer address
+}
+
+// sending state of the peer
+type sendState struct {
+	nextSeq  uint64
+	inflight map[uint64]*inflightDatagram
+	slots    chan struct{} // send window
+	srtt     time.Duration
+	rttvar   time.Duration
+	rto      time.Duration
+}
+
+// sent and not acked datagram
+type inflightDatagram struct {
+	request Request
+	conn    *SecureConn
+	addr    *net.UDPAddr
+	sentAt  time.Time
+	expires time.Time
+	retries int
+}
+
+// receiving state of the peer
+type recvState struct {
+	epoch      uint64
+	cumulative uint64
+	above      map[uint64]struct{}
+}
+
+// NewReliable - create reliable delivery state
+func NewReliable(config config.Config, logger *zap.Logger, clock Clock) *Reliable {
+	return &Reliable{
+		Config: config,
+		Logger: logger,
+		clock:  clock,
+		epoch:  uint64(clock.Now().UnixNano()),
+		send:   make(map[string]*sendState),
+		recv:   make(map[string]*recvState),
+	}
+}
+
+// Send - send request with sequence number, blocks while send window of the peer is full
+func (r *Reliable) Send(conn *SecureConn, addr *net.UDPAddr, request Request) error {
+	r.Lock()
+	state := r.sendState(addr.String())
+	r.Unlock()
+
+	select {
+	case state.slots <- struct{}{}:
+	case <-r.clock.After(r.Config.UDP.SendTimeout):
+		metrics.DeliveryFailures.WithLabelValues("window").Inc()
+		return errSendWindowFull
+	}
+
+	r.Lock()
+	state.nextSeq++
+	request.Seq = state.nextSeq
+	request.Epoch = r.epoch
+	now := r.clock.Now()
+	state.inflight[request.Seq] = &inflightDatagram{
+		request: request,
+		conn:    conn,
+		addr:    addr,
+		sentAt:  now,
+		expires: now.Add(state.rto),
+	}
+	r.Unlock()
+
+	err := request.Send(conn, addr)
+	if err != nil {
+		r.Lock()
+		r.release(state, request.Seq)
+		r.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// HandleAck - remove acked datagrams from the send window, update RTO
+func (r *Reliable) HandleAck(addr *net.UDPAddr, ack *Ack) {
+	if ack == nil || ack.Epoch != r.epoch {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	state, ok := r.send[addr.String()]
+	if !ok {
+		return
+	}
+
+	selective := make(map[uint64]struct{}, len(ack.Selective))
+	for _, seq := range ack.Selective {
+		selective[seq] = struct{}{}
+	}
+
+	now := r.clock.Now()
+	for seq, datagram := range state.inflight {
+		if _, ok := selective[seq]; seq > ack.Cumulative && !ok {
+			continue
+		}
+		// Karn's algorithm: rtt of retransmitted datagrams is ambiguous
+		if datagram.retries == 0 {
+			r.updateRTO(state, now.Sub(datagram.sentAt))
+		}
+		r.release(state, seq)
+	}
+}
+
+// Receive - register received datagram, returns ack for the sender and duplicate flag.
+// Ack is nil for requests without sequence number.
+func (r *Reliable) Receive(addr *net.UDPAddr, request *Request) (*Ack, bool) {
+	if request.Seq == 0 {
+		return nil, false
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	state, ok := r.recv[addr.String()]
+	if !ok || state.epoch != request.Epoch { // new peer or peer was restarted
+		state = &recvState{epoch: request.Epoch, above: make(map[uint64]struct{})}
+		r.recv[addr.String()] = state
+	}
+
+	duplicate := false
+	if _, ok := state.above[request.Seq]; ok || request.Seq <= state.cumulative {
+		duplicate = true
+	} else {
+		// sender never has more than window datagrams unacked,
+		// so datagrams below the window were given up by sender
+		if window := uint64(r.Config.UDP.Window); request.Seq > state.cumulative+window {
+			state.cumulative = request.Seq - window
+			for seq := range state.above {
+				if seq <= state.cumulative {
+					delete(state.above, seq)
+				}
+			}
+		}
+		state.above[request.Seq] = struct{}{}
+		for {
+			if _, ok := state.above[state.cumulative+1]; !ok {
+				break
+			}
+			state.cumulative++
+			delete(state.above, state.cumulative)
+		}
+	}
+
+	ack := &Ack{Epoch: request.Epoch, Cumulative: state.cumulative}
+	for seq := range state.above {
+		ack.Selective = append(ack.Selective, seq)
+	}
+	sort.Slice(ack.Selective, func(i, j int) bool {
+		return ack.Selective[i] < ack.Selective[j]
+	})
+	if len(ack.Selective) > maxSelectiveAcks {
+		ack.Selective = ack.Selective[:maxSelectiveAcks]
+	}
+
+	return ack, duplicate
+}
+
+// Forget - drop delivery state of the disconnected peer
+func (r *Reliable) Forget(address string) {
+	r.Lock()
+	defer r.Unlock()
+
+	if state, ok := r.send[address]; ok {
+		for seq := range state.inflight {
+			r.release(state, seq)
+		}
+		delete(r.send, address)
+	}
+	delete(r.recv, address)
+}
+
+// Run - retransmit expired datagrams, datagram is dropped after max retries
+func (r *Reliable) Run() {
+	ticks, stop := r.clock.Ticker(rtoGranularity)
+	defer stop()
+
+	for now := range ticks {
+		expired := make([]*inflightDatagram, 0)
+
+		r.Lock()
+		for address, state := range r.send {
+			backoff := false
+			for seq, datagram := range state.inflight {
+				if now.Before(datagram.expires) {
+					continue
+				}
+				if datagram.retries >= r.Config.UDP.MaxRetries {
+					r.Logger.Warn("p2p -> reliable -> Run : datagram dropped after max retries",
+						zap.String("peer", address), zap.Uint64("seq", seq))
+					metrics.DeliveryFailures.WithLabelValues("retries").Inc()
+					r.release(state, seq)
+					continue
+				}
+				if !backoff {
+					state.rto = minDuration(state.rto*2, r.Config.UDP.MaxRTO)
+					backoff = true
+				}
+				datagram.retries++
+				datagram.expires = now.Add(state.rto)
+				expired = append(expired, datagram)
+			}
+		}
+		r.Unlock()
+
+		for _, datagram := range expired {
+			metrics.Retransmissions.Inc()
+			err := datagram.request.Send(datagram.conn, datagram.addr)
+			if err != nil {
+				r.Logger.Error("p2p -> reliable -> Run -> request.Send", zap.Error(err), zap.String("addr", datagram.addr.String()))
+			}
+		}
+	}
+}
+
+// get or create sending state of the peer, should be called under lock
+func (r *Reliable) sendState(address string) *sendState {
+	state, ok := r.send[address]
+	if !ok {
+		state = &sendState{
+			inflight: make(map[uint64]*inflightDatagram),
+			slots:    make(chan struct{}, r.Config.UDP.Window),
+			rto:      r.Config.UDP.InitialRTO,
+		}
+		r.send[address] = state
+	}
+	return state
+}
+
+// remove datagram from the window and free its slot, should be called under lock
+func (r *Reliable) release(state *sendState, seq uint64) {
+	if _, ok := state.inflight[seq]; !ok {
+		return
+	}
+	delete(state.inflight, seq)
+	<-state.slots
+}
+
+// RFC 6298 estimation, should be called under lock
+func (r *Reliable) updateRTO(state *sendState, rtt time.Duration) {
+	metrics.RTT.Observe(rtt.Seconds())
+
+	if state.srtt == 0 {
+		state.srtt = rtt
+		state.rttvar = rtt / 2
+	} else {
+		diff := state.srtt - rtt
+		if diff < 0 {
+			diff = -diff
+		}
+		state.rttvar = (3*state.rttvar + diff) / 4
+		state.srtt = (7*state.srtt + rtt) / 8
+	}
+
+	rto := state.srtt + maxDuration(rtoGranularity, 4*state.rttvar)
+	state.rto = maxDuration(r.Config.UDP.MinRTO, minDuration(rto, r.Config.UDP.MaxRTO))
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// ack received message datagram to the sender, returns true if datagram was already received
+func (c *Core) ackDatagram(conn *SecureConn, addr *net.UDPAddr, request *Request) bool {
+	ack, duplicate := c.Reliable.Receive(addr, request)
+	if ack == nil {
+		return false
+	}
+
+	response := Request{Type: ackRequest, LocalAddr: c.GetRealAddress(), RemoteAddr: addr.String(), Ack: ack}
+	err := response.Send(conn, addr)
+	if err != nil {
+		c.Logger.Error("p2p -> core -> ackDatagram -> request.Send", zap.Error(err), zap.String("addr", addr.String()))
+	}
+
+	return duplicate
+}
@@ -0,0 +1,320 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flynn/noise"
+	"github.com/saiset-co/saiP2P-go/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	sealPrologue  = "saiP2P-go/directed/2"
+	sealBlockSize = 1 << 15 // plaintext bytes encrypted by one cipher block, noise limits message to 64KB
+	sealTagSize   = 16      // chacha20-poly1305 tag of the block
+)
+
+var errNoRoute = errors.New("no route to the node")
+
+// Router - routing table of directed messages, learned from gossip of connected peers.
+// Every peer advertises distances to nodes it can reach, route to the node goes through
+// connected peer with the smallest distance.
+type Router struct {
+	sync.Mutex
+	core   *Core
+	routes map[string]map[string]*route // node id -> next hop address -> route
+}
+
+// route to the node through connected peer
+type route struct {
+	hops    int
+	updated time.Time
+}
+
+// NewRouter - create empty routing table
+func NewRouter(core *Core) *Router {
+	return &Router{
+		core:   core,
+		routes: make(map[string]map[string]*route),
+	}
+}
+
+// NextHop - address of the connected peer to send directed message to the node
+func (r *Router) NextHop(id string) (string, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	now := r.core.clock.Now()
+	best, hops := "", 0
+	for address, route := range r.routes[id] {
+		if !r.fresh(route, now) {
+			continue
+		}
+		if best == "" || route.hops < hops || (route.hops == hops && address < best) {
+			best, hops = address, route.hops
+		}
+	}
+	return best, best != ""
+}
+
+// Table - distances to reachable nodes
+func (r *Router) Table() map[string]int {
+	return r.advertise("")
+}
+
+// learn routes advertised by connected peer, routes missing in advertisement are withdrawn
+func (r *Router) learn(address, id string, advertised map[string]int) {
+	r.Lock()
+	defer r.Unlock()
+
+	now := r.core.clock.Now()
+	self := r.core.Identity.ID()
+
+	for node, routes := range r.routes {
+		if _, ok := advertised[node]; !ok && node != id {
+			delete(routes, address)
+		}
+	}
+
+	r.set(id, address, 1, now)
+	for node, hops := range advertised {
+		if node == self || node == id || hops+1 > r.core.Config.P2P.MaxHops {
+			continue
+		}
+		r.set(node, address, hops+1, now)
+	}
+}
+
+// routes advertised to connected peer, routes through the peer itself are not advertised (split horizon)
+func (r *Router) advertise(exclude string) map[string]int {
+	r.Lock()
+	defer r.Unlock()
+
+	now := r.core.clock.Now()
+	table := make(map[string]int)
+	for node, routes := range r.routes {
+		for address, route := range routes {
+			if address == exclude || !r.fresh(route, now) {
+				continue
+			}
+			if hops, ok := table[node]; !ok || route.hops < hops {
+				table[node] = route.hops
+			}
+		}
+	}
+	return table
+}
+
+// drop routes through disconnected peer
+func (r *Router) forget(address string) {
+	r.Lock()
+	defer r.Unlock()
+
+	for node, routes := range r.routes {
+		delete(routes, address)
+		if len(routes) == 0 {
+			delete(r.routes, node)
+		}
+	}
+}
+
+// should be called under lock
+func (r *Router) set(id, address string, hops int, now time.Time) {
+	routes, ok := r.routes[id]
+	if !ok {
+		routes = make(map[string]*route)
+		r.routes[id] = routes
+	}
+	routes[address] = &route{hops: hops, updated: now}
+}
+
+// route is valid while peer keeps advertising it, should be called under lock
+func (r *Router) fresh(route *route, now time.Time) bool {
+	return now.Sub(route.updated) <= livenessIntervals*r.core.Config.Discovery.Interval
+}
+
+// SendTo - send directed message to nodes by their ids. Message is encrypted to the recipient
+// and routed through connected peers, so intermediate nodes can not read it.
+func (c *Core) SendTo(mes []byte, ids []string) error {
+	return c.sendDirected(0, mes, ids)
+}
+
+func (c *Core) sendDirected(topic uint32, mes []byte, ids []string) error {
+	failed := make([]string, 0)
+	for _, id := range ids {
+		err := c.sendTo(topic, mes, id)
+		if err != nil {
+			c.Logger.Error("p2p -> core -> SendTo", zap.Error(err), zap.String("id", id))
+			failed = append(failed, id)
+		}
+	}
+
+	if len(failed) != 0 {
+		return fmt.Errorf("message was not sent to %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (c *Core) sendTo(topic uint32, mes []byte, id string) error {
+	message := Message{
+		ID:     c.messageID(mes),
+		Hops:   c.Config.P2P.MaxHops,
+		Topic:  topic,
+		From:   c.GetRealAddress(),
+		Target: id,
+		Origin: c.Identity.ID(),
+	}
+
+	record, ok := c.Discovery.record(id)
+	if !ok {
+		return errors.New("unknown node")
+	}
+
+	var err error
+	message.Data, err = c.seal(&message, record, mes)
+	if err != nil {
+		return fmt.Errorf("seal : %w", err)
+	}
+
+	c.Cache.Set(message.key(), nil)
+
+	return c.forward(message)
+}
+
+// send directed message to the next hop, big messages are sent by chunks to udp peers
+func (c *Core) forward(message Message) error {
+	address, ok := c.Router.NextHop(message.Target)
+	if !ok {
+		metrics.DeliveryFailures.WithLabelValues("route").Inc()
+		return errNoRoute
+	}
+
+	if message.Part != 0 || len(message.Data) <= c.Config.UDP.MsgBufferSize || c.streamAddress(address) != nil {
+		return c.sendMsg(message, address)
+	}
+
+	for _, msg := range c.PrepareMsgChunks(message) {
+		err := c.sendMsg(*msg, address)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encrypt data to the static noise key of the recipient (noise X pattern), sender is authenticated by its static key.
+// Message id, topic, origin and target are bound by prologue, so relays can not change them.
+// Length of the data is the handshake payload, so blocks can not be cut from the end of the message.
+func (c *Core) seal(message *Message, recipient PeerRecord, data []byte) ([]byte, error) {
+	remote, err := hex.DecodeString(recipient.NoiseKey)
+	if err != nil {
+		return nil, fmt.Errorf("DecodeString : %w", err)
+	}
+
+	hs, err := noise.NewHandshakeState(noise.Config{
+		CipherSuite:   noiseSuite,
+		Random:        rand.Reader,
+		Pattern:       noise.HandshakeX,
+		Initiator:     true,
+		Prologue:      sealAD(message),
+		StaticKeypair: c.Identity.Noise,
+		PeerStatic:    remote,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("NewHandshakeState : %w", err)
+	}
+
+	length := make([]byte, 8)
+	binary.BigEndian.PutUint64(length, uint64(len(data)))
+
+	handshake, cs, _, err := hs.WriteMessage(nil, length)
+	if err != nil {
+		return nil, fmt.Errorf("WriteMessage : %w", err)
+	}
+
+	sealed := make([]byte, 2, 2+len(handshake)+len(data)+(len(data)/sealBlockSize+1)*sealTagSize)
+	binary.BigEndian.PutUint16(sealed, uint16(len(handshake)))
+	sealed = append(sealed, handshake...)
+	for start := 0; start < len(data) || start == 0; start += sealBlockSize {
+		end := start + sealBlockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		sealed, err = cs.Encrypt(sealed, nil, data[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("Encrypt : %w", err)
+		}
+	}
+	return sealed, nil
+}
+
+// decrypt directed message, sender static key must match the record of the origin node
+func (c *Core) open(message *Message) error {
+	if len(message.Data) < 2 {
+		return errors.New("sealed message is too short")
+	}
+	size := int(binary.BigEndian.Uint16(message.Data))
+	if len(message.Data) < 2+size {
+		return errors.New("sealed message is too short")
+	}
+
+	hs, err := noise.NewHandshakeState(noise.Config{
+		CipherSuite:   noiseSuite,
+		Pattern:       noise.HandshakeX,
+		Initiator:     false,
+		Prologue:      sealAD(message),
+		StaticKeypair: c.Identity.Noise,
+	})
+	if err != nil {
+		return fmt.Errorf("NewHandshakeState : %w", err)
+	}
+
+	length, cs, _, err := hs.ReadMessage(nil, message.Data[2:2+size])
+	if err != nil {
+		return fmt.Errorf("ReadMessage : %w", err)
+	}
+	if len(length) != 8 {
+		return errors.New("length of sealed message is missing")
+	}
+
+	origin, ok := c.Discovery.record(message.Origin)
+	if !ok || origin.NoiseKey != hex.EncodeToString(hs.PeerStatic()) {
+		return errors.New("sender of directed message is not authenticated")
+	}
+
+	blockSize := sealBlockSize + sealTagSize
+	sealed := message.Data[2+size:]
+	data := make([]byte, 0, len(sealed))
+	for len(sealed) > 0 {
+		block := sealed
+		if len(block) > blockSize {
+			block = block[:blockSize]
+		}
+		data, err = cs.Decrypt(data, nil, block)
+		if err != nil {
+			return fmt.Errorf("Decrypt : %w", err)
+		}
+		sealed = sealed[len(block):]
+	}
+
+	if uint64(len(data)) != binary.BigEndian.Uint64(length) {
+		return errors.New("sealed message is truncated")
+	}
+
+	message.Data = data
+	return nil
+}
+
+func sealAD(message *Message) []byte {
+	topic := strconv.FormatUint(uint64(message.Topic), 10)
+	return bytes.Join([][]byte{[]byte(sealPrologue), []byte(message.ID), []byte(topic), []byte(message.Origin), []byte(message.Target)}, []byte("\n"))
+}
@@ -0,0 +1,740 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/flynn/noise"
+	"github.com/saiset-co/saiP2P-go/metrics"
+	"go.uber.org/zap"
+)
+
+// datagram types, first byte of every datagram
+const (
+	noiseInitXX   byte = iota + 1 // XX first message, responder static key is unknown
+	noiseInitIK                   // IK first message, responder static key is known from previous session
+	noiseResponse                 // second handshake message
+	noiseFinal                    // XX third message
+	noiseData                     // transport datagram : type, session id, nonce, ciphertext
+	noiseReset                    // session id is unknown to the receiver
+)
+
+const (
+	noiseDataHeader  = 1 + 4 + 8
+	noiseResetSize   = 1 + 4
+	noiseMaxDatagram = 65535
+	noiseMaxQueue    = 256 // datagrams queued per peer while handshake is in progress
+	noiseRetransmit  = time.Second
+	noiseMaxRetries  = 5
+	noiseTimestamp   = 8 // size of IK timestamp payload
+
+	// pending responder handshakes of peers without session, inits of unknown peers are
+	// not authenticated until the final message, so their state is capped
+	noiseMaxResponders = 128
+
+	replayWindowWords = 16
+	replayWindowSize  = (replayWindowWords - 1) * 64 // last word is cleared when window slides
+)
+
+var (
+	noisePrologue = []byte("saiP2P-go/noise/1")
+	noiseSuite    = noise.NewCipherSuite(noise.DH25519, noise.CipherChaChaPoly, noise.HashBLAKE2s)
+
+	errHandshakeQueueFull = errors.New("noise handshake queue is full")
+)
+
+// Secure - noise identity of the node and settings shared by secure connections.
+// Peers authenticate each other by static keys (IK if key of the peer is known, XX otherwise),
+// every datagram after handshake is sealed by per-peer session keys.
+// Both sides send their wire version in handshake payloads, handshake with other version is aborted.
+type Secure struct {
+	sync.Mutex
+	Logger   *zap.Logger
+	Identity noise.DHKey // static key of the node
+	clock    Clock
+	version  byte              // wire version of the node
+	trusted  map[string]bool   // hex static keys of allowed peers, no peer is allowed if empty
+	known    map[string][]byte // static keys by peer address, used for IK handshakes
+	lastInit map[string]uint64 // latest IK timestamp by static key, replayed handshakes are rejected
+}
+
+// SecureConn - datagram connection, which sends and accepts only noise sealed datagrams.
+// Handshakes are made on demand, datagrams written before session is established are queued.
+type SecureConn struct {
+	PacketConn
+	secure    *Secure
+	mu        sync.Mutex
+	peers     map[string]*securePeer // by peer address
+	buf       []byte                 // read buffer, ReadFromUDP is called from one goroutine
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// noise state of the peer
+type securePeer struct {
+	addr      *net.UDPAddr
+	hs        *noise.HandshakeState // pending handshake
+	initiator bool
+	pattern   byte   // first message type of pending handshake
+	first     []byte // first message of pending responder handshake, to detect retransmission
+	lastMsg   []byte // last sent handshake datagram, resent on timeout
+	lastSent  time.Time
+	retries   int
+	session   *noiseSession
+	previous  *noiseSession // datagrams sealed before rekey are still accepted
+	queue     [][]byte
+}
+
+// established session
+type noiseSession struct {
+	id      uint32
+	send    noise.Cipher
+	recv    noise.Cipher
+	nonce   uint64
+	replay  replayWindow
+	remote  []byte    // static key of the peer
+	trigger []byte    // handshake datagram of the peer which completed the session
+	final   []byte    // our last handshake datagram, resent if peer did not complete the handshake
+	created time.Time // final is resent only for a short time after creation
+}
+
+// sliding window of received nonces (RFC 6479)
+type replayWindow struct {
+	top  uint64
+	bits [replayWindowWords]uint64
+}
+
+// NewSecure - create noise settings of the node
+func NewSecure(identity noise.DHKey, trusted []string, logger *zap.Logger, clock Clock) *Secure {
+	s := &Secure{
+		Logger:   logger,
+		Identity: identity,
+		clock:    clock,
+		version:  WireVersion,
+		trusted:  make(map[string]bool),
+		known:    make(map[string][]byte),
+		lastInit: make(map[string]uint64),
+	}
+	for _, key := range trusted {
+		s.trusted[key] = true
+	}
+	return s
+}
+
+// Wrap - wrap datagram connection, datagrams are sealed by noise sessions
+func (s *Secure) Wrap(conn PacketConn, maxDatagram int) *SecureConn {
+	c := &SecureConn{
+		PacketConn: conn,
+		secure:     s,
+		peers:      make(map[string]*securePeer),
+		buf:        make([]byte, maxDatagram+noiseDataHeader+16),
+		done:       make(chan struct{}),
+	}
+	go c.retransmit()
+	return c
+}
+
+// check if static key of the peer is allowed, no peer is allowed without trusted keys
+func (s *Secure) allowed(remote []byte) bool {
+	return s.trusted[hex.EncodeToString(remote)]
+}
+
+func (s *Secure) knownKey(address string) []byte {
+	s.Lock()
+	defer s.Unlock()
+	return s.known[address]
+}
+
+func (s *Secure) remember(address string, remote []byte) {
+	s.Lock()
+	defer s.Unlock()
+	s.known[address] = remote
+}
+
+func (s *Secure) forget(address string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.known, address)
+}
+
+// register IK timestamp of the static key, returns false for replayed handshake
+func (s *Secure) fresh(remote []byte, timestamp uint64) bool {
+	s.Lock()
+	defer s.Unlock()
+	key := hex.EncodeToString(remote)
+	if timestamp <= s.lastInit[key] {
+		return false
+	}
+	s.lastInit[key] = timestamp
+	return true
+}
+
+// WriteToUDP - seal datagram by peer session, datagram is queued if handshake is in progress
+func (c *SecureConn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	peer, ok := c.peers[addr.String()]
+	if !ok {
+		peer = &securePeer{addr: addr}
+		c.peers[addr.String()] = peer
+	}
+
+	if peer.session == nil {
+		if len(peer.queue) >= noiseMaxQueue {
+			metrics.PacketsRejected.WithLabelValues("queue").Inc()
+			return 0, errHandshakeQueueFull
+		}
+		peer.queue = append(peer.queue, append([]byte(nil), b...))
+		if peer.hs == nil {
+			err := c.initiate(peer)
+			if err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	}
+
+	return c.seal(peer, b)
+}
+
+// ReadFromUDP - read next authenticated datagram, handshakes are processed and unauthenticated datagrams are dropped
+func (c *SecureConn) ReadFromUDP(b []byte) (int, *net.UDPAddr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFromUDP(c.buf)
+		if err != nil {
+			return 0, addr, err
+		}
+
+		plaintext := c.open(addr, c.buf[:n])
+		if plaintext == nil {
+			continue
+		}
+		if len(plaintext) > len(b) {
+			metrics.PacketsRejected.WithLabelValues("size").Inc()
+			continue
+		}
+
+		return copy(b, plaintext), addr, nil
+	}
+}
+
+// RemoteKey - static key of the peer authenticated by current session, nil if there is no session
+func (c *SecureConn) RemoteKey(addr *net.UDPAddr) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	peer, ok := c.peers[addr.String()]
+	if !ok || peer.session == nil {
+		return nil
+	}
+	return peer.session.remote
+}
+
+// Close - stop handshake retransmission and close connection
+func (c *SecureConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	return c.PacketConn.Close()
+}
+
+// handle incoming datagram, returns plaintext of authenticated transport datagram
+func (c *SecureConn) open(addr *net.UDPAddr, datagram []byte) []byte {
+	if len(datagram) == 0 {
+		c.reject("short", addr)
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch datagram[0] {
+	case noiseInitXX, noiseInitIK:
+		c.handleInit(addr, datagram)
+	case noiseResponse:
+		c.handleResponse(addr, datagram)
+	case noiseFinal:
+		c.handleFinal(addr, datagram)
+	case noiseData:
+		return c.openData(addr, datagram)
+	case noiseReset:
+		c.handleReset(addr, datagram)
+	default:
+		c.reject("type", addr)
+	}
+	return nil
+}
+
+// start handshake with the peer, should be called under lock
+func (c *SecureConn) initiate(peer *securePeer) error {
+	pattern, msgType := noise.HandshakeXX, noiseInitXX
+	remote := c.secure.knownKey(peer.addr.String())
+	if remote != nil {
+		pattern, msgType = noise.HandshakeIK, noiseInitIK
+	}
+
+	hs, err := noise.NewHandshakeState(noise.Config{
+		CipherSuite:   noiseSuite,
+		Random:        rand.Reader,
+		Pattern:       pattern,
+		Initiator:     true,
+		Prologue:      noisePrologue,
+		StaticKeypair: c.secure.Identity,
+		PeerStatic:    remote,
+	})
+	if err != nil {
+		return fmt.Errorf("NewHandshakeState : %w", err)
+	}
+
+	// IK first message is accepted by responder without a round trip, timestamp prevents its replay
+	payload := []byte{c.secure.version}
+	if msgType == noiseInitIK {
+		payload = binary.BigEndian.AppendUint64(payload, uint64(c.secure.clock.Now().UnixNano()))
+	}
+
+	msg, _, _, err := hs.WriteMessage([]byte{msgType}, payload)
+	if err != nil {
+		return fmt.Errorf("WriteMessage : %w", err)
+	}
+
+	peer.hs = hs
+	peer.initiator = true
+	peer.pattern = msgType
+	peer.first = nil
+	peer.retries = 0
+	c.sendHandshake(peer, msg)
+
+	return nil
+}
+
+// first handshake message, should be called under lock
+func (c *SecureConn) handleInit(addr *net.UDPAddr, datagram []byte) {
+	peer, ok := c.peers[addr.String()]
+	if ok {
+		// retransmitted message, our response was lost
+		if peer.session != nil && bytes.Equal(peer.session.trigger, datagram) {
+			c.write(peer.addr, peer.session.final, "noise")
+			return
+		}
+		if peer.hs != nil && !peer.initiator && bytes.Equal(peer.first, datagram) {
+			c.sendHandshake(peer, peer.lastMsg)
+			return
+		}
+
+		// both nodes started handshake, node with lower ephemeral key stays initiator
+		if peer.hs != nil && peer.initiator {
+			if len(datagram) < 1+noiseSuite.DHLen() {
+				c.reject("handshake", addr)
+				return
+			}
+			if bytes.Compare(peer.hs.LocalEphemeral().Public, datagram[1:1+noiseSuite.DHLen()]) < 0 {
+				return
+			}
+		}
+	}
+
+	// flood of inits from unknown addresses does not grow the state and is not answered
+	if (!ok || peer.session == nil) && c.responders() >= noiseMaxResponders {
+		c.reject("limit", addr)
+		return
+	}
+
+	pattern := noise.HandshakeXX
+	if datagram[0] == noiseInitIK {
+		pattern = noise.HandshakeIK
+	}
+
+	hs, err := noise.NewHandshakeState(noise.Config{
+		CipherSuite:   noiseSuite,
+		Random:        rand.Reader,
+		Pattern:       pattern,
+		Initiator:     false,
+		Prologue:      noisePrologue,
+		StaticKeypair: c.secure.Identity,
+	})
+	if err != nil {
+		c.secure.Logger.Error("p2p -> secure -> handleInit -> NewHandshakeState", zap.Error(err))
+		return
+	}
+
+	payload, _, _, err := hs.ReadMessage(nil, datagram[1:])
+	if err != nil {
+		c.reject("handshake", addr)
+		return
+	}
+
+	rest := 0
+	if datagram[0] == noiseInitIK {
+		rest = noiseTimestamp
+	}
+	version, payload := splitVersion(payload, rest)
+
+	if datagram[0] == noiseInitIK {
+		if !c.secure.allowed(hs.PeerStatic()) {
+			c.reject("untrusted", addr)
+			return
+		}
+		if len(payload) != noiseTimestamp || !c.secure.fresh(hs.PeerStatic(), binary.BigEndian.Uint64(payload)) {
+			c.reject("replay", addr)
+			return
+		}
+	}
+
+	msg, cs1, cs2, err := hs.WriteMessage([]byte{noiseResponse}, []byte{c.secure.version})
+	if err != nil {
+		c.secure.Logger.Error("p2p -> secure -> handleInit -> WriteMessage", zap.Error(err))
+		return
+	}
+
+	// response tells our version to the peer, so it aborts the handshake too
+	if version != c.secure.version {
+		c.versionMismatch(addr, version)
+		c.write(addr, msg, "noise")
+		return
+	}
+
+	if !ok {
+		peer = &securePeer{addr: addr}
+		c.peers[addr.String()] = peer
+	}
+
+	if datagram[0] == noiseInitIK { // responder completes IK by sending response
+		c.establish(peer, hs, cs2, cs1, datagram, msg)
+		c.write(peer.addr, msg, "noise")
+		c.flush(peer)
+		return
+	}
+
+	peer.hs = hs
+	peer.initiator = false
+	peer.pattern = noiseInitXX
+	peer.first = append([]byte(nil), datagram...)
+	peer.retries = 0
+	c.sendHandshake(peer, msg)
+}
+
+// pending responder handshakes of peers without session, should be called under lock
+func (c *SecureConn) responders() int {
+	n := 0
+	for _, peer := range c.peers {
+		if peer.hs != nil && !peer.initiator && peer.session == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// second handshake message, should be called under lock
+func (c *SecureConn) handleResponse(addr *net.UDPAddr, datagram []byte) {
+	peer, ok := c.peers[addr.String()]
+	if !ok {
+		c.reject("unexpected", addr)
+		return
+	}
+
+	if peer.hs == nil || !peer.initiator {
+		// retransmitted response, our final message was lost
+		if peer.session != nil && bytes.Equal(peer.session.trigger, datagram) {
+			c.write(peer.addr, peer.session.final, "noise")
+			return
+		}
+		c.reject("unexpected", addr)
+		return
+	}
+
+	payload, cs1, cs2, err := peer.hs.ReadMessage(nil, datagram[1:])
+	if err != nil {
+		c.reject("handshake", addr)
+		return
+	}
+
+	if version, _ := splitVersion(payload, 0); version != c.secure.version {
+		c.versionMismatch(addr, version)
+		peer.hs = nil
+		peer.queue = nil
+		return
+	}
+
+	if peer.pattern == noiseInitIK {
+		c.establish(peer, peer.hs, cs1, cs2, nil, nil)
+		c.flush(peer)
+		return
+	}
+
+	if !c.secure.allowed(peer.hs.PeerStatic()) {
+		c.reject("untrusted", addr)
+		peer.hs = nil
+		return
+	}
+
+	msg, cs1, cs2, err := peer.hs.WriteMessage([]byte{noiseFinal}, nil)
+	if err != nil {
+		c.secure.Logger.Error("p2p -> secure -> handleResponse -> WriteMessage", zap.Error(err))
+		return
+	}
+
+	c.establish(peer, peer.hs, cs1, cs2, datagram, msg)
+	c.write(peer.addr, msg, "noise")
+	c.flush(peer)
+}
+
+// XX third handshake message, should be called under lock
+func (c *SecureConn) handleFinal(addr *net.UDPAddr, datagram []byte) {
+	peer, ok := c.peers[addr.String()]
+	if !ok || peer.hs == nil || peer.initiator || peer.pattern != noiseInitXX {
+		c.reject("unexpected", addr)
+		return
+	}
+
+	_, cs1, cs2, err := peer.hs.ReadMessage(nil, datagram[1:])
+	if err != nil {
+		c.reject("handshake", addr)
+		return
+	}
+
+	if !c.secure.allowed(peer.hs.PeerStatic()) {
+		c.reject("untrusted", addr)
+		peer.hs = nil
+		return
+	}
+
+	c.establish(peer, peer.hs, cs2, cs1, nil, nil)
+	c.flush(peer)
+}
+
+// transport datagram, should be called under lock
+func (c *SecureConn) openData(addr *net.UDPAddr, datagram []byte) []byte {
+	if len(datagram) < noiseDataHeader+16 {
+		c.reject("short", addr)
+		return nil
+	}
+
+	id := binary.BigEndian.Uint32(datagram[1:5])
+	nonce := binary.BigEndian.Uint64(datagram[5:noiseDataHeader])
+
+	var session *noiseSession
+	if peer, ok := c.peers[addr.String()]; ok {
+		if peer.session != nil && peer.session.id == id {
+			session = peer.session
+		} else if peer.previous != nil && peer.previous.id == id {
+			session = peer.previous
+		}
+	}
+
+	if session == nil {
+		c.reject("session", addr)
+		// sender restarts handshake or resends its final message
+		reset := make([]byte, noiseResetSize)
+		reset[0] = noiseReset
+		copy(reset[1:], datagram[1:5])
+		c.write(addr, reset, "noise")
+		return nil
+	}
+
+	if !session.replay.check(nonce) {
+		c.reject("replay", addr)
+		return nil
+	}
+
+	plaintext, err := session.recv.Decrypt(nil, nonce, datagram[:noiseDataHeader], datagram[noiseDataHeader:])
+	if err != nil {
+		c.reject("auth", addr)
+		return nil
+	}
+	session.replay.mark(nonce)
+
+	return plaintext
+}
+
+// session is unknown to the peer, should be called under lock
+func (c *SecureConn) handleReset(addr *net.UDPAddr, datagram []byte) {
+	peer, ok := c.peers[addr.String()]
+	if !ok || peer.session == nil || len(datagram) != noiseResetSize {
+		return
+	}
+	if peer.session.id != binary.BigEndian.Uint32(datagram[1:]) {
+		return
+	}
+
+	// our final message could be lost or reordered with data
+	if peer.session.final != nil && c.secure.clock.Now().Sub(peer.session.created) < noiseRetransmit*noiseMaxRetries {
+		c.write(peer.addr, peer.session.final, "noise")
+		return
+	}
+
+	// peer lost the session (restarted), current session is used until new one is established
+	if peer.hs == nil {
+		err := c.initiate(peer)
+		if err != nil {
+			c.secure.Logger.Error("p2p -> secure -> handleReset -> initiate", zap.Error(err))
+		}
+	}
+}
+
+// set new session of the peer, should be called under lock
+func (c *SecureConn) establish(peer *securePeer, hs *noise.HandshakeState, send, recv *noise.CipherState, trigger, final []byte) {
+	session := &noiseSession{
+		id:      binary.BigEndian.Uint32(hs.ChannelBinding()),
+		send:    send.Cipher(),
+		recv:    recv.Cipher(),
+		remote:  hs.PeerStatic(),
+		trigger: append([]byte(nil), trigger...),
+		final:   final,
+		created: c.secure.clock.Now(),
+	}
+
+	peer.previous = peer.session
+	peer.session = session
+	peer.hs = nil
+	peer.first = nil
+	peer.lastMsg = nil
+	peer.retries = 0
+
+	c.secure.remember(peer.addr.String(), session.remote)
+	metrics.Handshakes.WithLabelValues("completed").Inc()
+	c.secure.Logger.Debug("p2p -> secure -> session established",
+		zap.String("addr", peer.addr.String()),
+		zap.String("key", hex.EncodeToString(session.remote)))
+}
+
+// send datagrams queued during handshake, should be called under lock
+func (c *SecureConn) flush(peer *securePeer) {
+	for _, b := range peer.queue {
+		_, err := c.seal(peer, b)
+		if err != nil {
+			c.secure.Logger.Error("p2p -> secure -> flush -> seal", zap.Error(err), zap.String("addr", peer.addr.String()))
+		}
+	}
+	peer.queue = nil
+}
+
+// seal and send transport datagram, should be called under lock
+func (c *SecureConn) seal(peer *securePeer, b []byte) (int, error) {
+	session := peer.session
+	session.nonce++
+
+	header := make([]byte, noiseDataHeader)
+	header[0] = noiseData
+	binary.BigEndian.PutUint32(header[1:5], session.id)
+	binary.BigEndian.PutUint64(header[5:], session.nonce)
+
+	out := make([]byte, 0, noiseDataHeader+len(b)+16)
+	out = session.send.Encrypt(append(out, header...), session.nonce, header, b)
+
+	_, err := c.PacketConn.WriteToUDP(out, peer.addr)
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// send handshake datagram and remember it for retransmission, should be called under lock
+func (c *SecureConn) sendHandshake(peer *securePeer, msg []byte) {
+	peer.lastMsg = msg
+	peer.lastSent = c.secure.clock.Now()
+	c.write(peer.addr, msg, "noise")
+}
+
+func (c *SecureConn) write(addr *net.UDPAddr, datagram []byte, label string) {
+	n, err := c.PacketConn.WriteToUDP(datagram, addr)
+	if err != nil {
+		c.secure.Logger.Error("p2p -> secure -> WriteToUDP", zap.Error(err), zap.String("addr", addr.String()))
+		return
+	}
+	metrics.BytesSent.WithLabelValues(label).Add(float64(n))
+}
+
+// peer uses other wire version, should be called under lock
+func (c *SecureConn) versionMismatch(addr *net.UDPAddr, version byte) {
+	metrics.Handshakes.WithLabelValues("version").Inc()
+	c.secure.Logger.Error("p2p -> secure -> wire version mismatch, peer is not compatible",
+		zap.String("addr", addr.String()),
+		zap.Uint8("version", version),
+		zap.Uint8("local_version", c.secure.version))
+}
+
+func (c *SecureConn) reject(reason string, addr *net.UDPAddr) {
+	metrics.PacketsRejected.WithLabelValues(reason).Inc()
+	c.secure.Logger.Debug("p2p -> secure -> datagram rejected", zap.String("reason", reason), zap.String("addr", addr.String()))
+}
+
+// resend handshake messages without response, handshake fails after max retries
+func (c *SecureConn) retransmit() {
+	ticks, stop := c.secure.clock.Ticker(noiseRetransmit / 4)
+	defer stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case now := <-ticks:
+			c.mu.Lock()
+			for address, peer := range c.peers {
+				if peer.hs == nil {
+					if peer.session == nil && len(peer.queue) == 0 {
+						delete(c.peers, address)
+					}
+					continue
+				}
+				if now.Sub(peer.lastSent) < noiseRetransmit {
+					continue
+				}
+
+				if peer.retries >= noiseMaxRetries {
+					c.secure.Logger.Warn("p2p -> secure -> handshake failed", zap.String("addr", address), zap.Int("dropped", len(peer.queue)))
+					metrics.Handshakes.WithLabelValues("failed").Inc()
+					peer.hs = nil
+					peer.queue = nil
+					continue
+				}
+
+				// static key of the responder could be changed, fall back to XX
+				if peer.initiator && peer.pattern == noiseInitIK && peer.retries >= 1 {
+					c.secure.forget(address)
+					err := c.initiate(peer)
+					if err != nil {
+						c.secure.Logger.Error("p2p -> secure -> retransmit -> initiate", zap.Error(err))
+					}
+					continue
+				}
+
+				peer.retries++
+				c.sendHandshake(peer, peer.lastMsg)
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// check if nonce was not received yet and is not too old
+func (w *replayWindow) check(nonce uint64) bool {
+	if nonce == 0 {
+		return false
+	}
+	if nonce > w.top {
+		return true
+	}
+	if w.top-nonce >= replayWindowSize {
+		return false
+	}
+	return w.bits[(nonce/64)%replayWindowWords]&(1<<(nonce%64)) == 0
+}
+
+// mark nonce as received, window slides forward for newer nonce
+func (w *replayWindow) mark(nonce uint64) {
+	if nonce > w.top {
+		for word := w.top/64 + 1; word <= nonce/64 && word <= w.top/64+replayWindowWords; word++ {
+			w.bits[word%replayWindowWords] = 0
+		}
+		w.top = nonce
+	}
+	w.bits[(nonce/64)%replayWindowWords] |= 1 << (nonce % 64)
+}
@@ -1,29 +1,39 @@
 package core
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"sync"
+	"time"
 
+	"github.com/saiset-co/saiP2P-go/metrics"
 	"go.uber.org/zap"
 )
 
 // Server part of core
 type Server struct {
-	Address struct {
+	Address struct { // address of the node learned from peers, read by serverAddress
+		sync.RWMutex
 		IP        net.IP
 		PunchPort string
 	}
 	Connections struct {
-		In  *net.UDPConn
-		Out *net.UDPConn
+		In     *SecureConn // noise sealed datagrams
+		Out    *SecureConn
+		Stream *SecureConn // tcp transport for peers from p2p.stream.peers, nil if disabled
 	}
 	AddrChan          chan string
 	FilterConnections func(interface{}) bool
-	*sync.RWMutex                           // for BufferedMsgs map
-	BufferedMsgs      map[string][]*Message // map[buffered msg hash]*Message
+	*sync.RWMutex                             // for BufferedMsgs map
+	BufferedMsgs      map[string]*bufferedMsg // map[message id]chunks
+	streamRoutes      map[string]*net.UDPAddr // peer address -> tcp address, nil for udp peers
+}
+
+// chunks of the message being assembled
+type bufferedMsg struct {
+	parts   map[int]*Message // by part number
+	updated time.Time        // last chunk arrival, used for reassembly timeout
 }
 
 // server stats. For testing/debugging
@@ -41,12 +51,13 @@ func (c *Core) Serve(filter filterConnections) (err error) {
 		return err
 	}
 
-	c.Server.Address.IP = serverAddr.IP
+	c.setServerIP(serverAddr.IP)
 
-	c.Server.Connections.In, err = net.ListenUDP("udp4", serverAddr)
+	conn, err := c.network.ListenUDP(":" + c.Config.P2P.Port)
 	if err != nil {
 		return err
 	}
+	c.Server.Connections.In = c.Secure.Wrap(conn, noiseMaxDatagram)
 
 	defer func() {
 		//s.L.Debug("StartServer -> stopped")
@@ -59,49 +70,100 @@ func (c *Core) Serve(filter filterConnections) (err error) {
 		buf := make([]byte, 500000)
 		n, newClientAddr, err := c.Server.Connections.In.ReadFromUDP(buf)
 		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
 			c.Logger.Warn("StartServer", zap.Error(err))
 			continue
 		}
+		metrics.BytesReceived.Add(float64(n))
+		c.handleDatagram(c.Server.Connections.In, buf[:n], newClientAddr, filter)
+	}
+}
+
+// ServeStream - read messages of stream transport peers
+func (c *Core) ServeStream(filter filterConnections) {
+	defer c.Server.Connections.Stream.Close()
 
-		//	go func(buf []byte) {
-		incomingRequest := Request{}
-		//s.L.Debug("StartServer", zap.Any("incomingRequest", incomingRequest))
-		err = json.Unmarshal(buf[:n], &incomingRequest)
+	buf := make([]byte, c.Config.P2P.Stream.MaxFrame)
+	for {
+		n, newClientAddr, err := c.Server.Connections.Stream.ReadFromUDP(buf)
 		if err != nil {
-			c.Logger.Error("StartServer", zap.Error(err))
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			c.Logger.Warn("ServeStream", zap.Error(err))
 			continue
 		}
+		metrics.BytesReceived.Add(float64(n))
 
-		c.Logger.Debug("StartServer -> incomingRequest",
-			zap.String("Type", incomingRequest.Type),
-			zap.String("from", newClientAddr.String()),
-			zap.String("to", incomingRequest.RemoteAddr))
+		c.handleDatagram(c.Server.Connections.Stream, buf[:n], newClientAddr, filter)
+	}
+}
 
-		addr, err := net.ResolveUDPAddr("udp4", incomingRequest.RemoteAddr)
-		if err != nil {
-			c.Logger.Error("p2p -> StartServer -> ResolveUDPAddr", zap.Error(err))
-			continue
-		}
+// handle request read from the connection
+func (c *Core) handleDatagram(conn *SecureConn, data []byte, newClientAddr *net.UDPAddr, filter filterConnections) {
+	requestType, err := wireType(data)
+	if err != nil {
+		c.Logger.Error("StartServer", zap.String("from", newClientAddr.String()), zap.Error(err))
+		return
+	}
 
-		c.Server.Address.IP = addr.IP
+	// responses to handshakes requested from server connection
+	if requestType == punchResponse || requestType == handshakeResponse {
+		c.HandleResponse(requestType, data, newClientAddr)
+		return
+	}
 
-		if incomingRequest.Type == punchRequest {
-			err = c.CheckAllowConn(filter, newClientAddr.IP.String(), fmt.Sprintf("%d", (newClientAddr.Port)))
-			if err != nil {
-				continue
-			}
+	incomingRequest := Request{}
+	err = Unmarshal(data, &incomingRequest)
+	if err != nil {
+		c.Logger.Error("StartServer", zap.String("from", newClientAddr.String()), zap.Error(err))
+		return
+	}
+
+	c.Logger.Debug("StartServer -> incomingRequest",
+		zap.String("Type", incomingRequest.Type),
+		zap.String("from", newClientAddr.String()),
+		zap.String("to", incomingRequest.RemoteAddr))
+
+	addr, err := net.ResolveUDPAddr("udp4", incomingRequest.RemoteAddr)
+	if err != nil {
+		c.Logger.Error("p2p -> StartServer -> ResolveUDPAddr", zap.Error(err))
+		return
+	}
+
+	c.setServerIP(addr.IP)
+
+	if incomingRequest.Type == punchRequest {
+		err = c.CheckAllowConn(filter, newClientAddr.IP.String(), fmt.Sprintf("%d", (newClientAddr.Port)))
+		if err != nil {
+			return
 		}
+	}
 
-		switch incomingRequest.Type {
-		case punchRequest:
-			c.HandlePunch(incomingRequest, newClientAddr)
-		case handshakeRequest:
-			c.HandleHandshake(incomingRequest, newClientAddr)
-		case EventRequest:
-			c.HandleEvent(&incomingRequest)
-		default:
-			c.HandleMessage(incomingRequest, newClientAddr)
+	switch incomingRequest.Type {
+	case punchRequest:
+		c.HandlePunch(incomingRequest, newClientAddr)
+	case handshakeRequest:
+		c.HandleHandshake(incomingRequest, newClientAddr)
+	case EventRequest:
+		c.HandleEvent(&incomingRequest)
+	case ackRequest:
+		c.Reliable.HandleAck(newClientAddr, incomingRequest.Ack)
+	case peersRequest:
+		c.Discovery.HandleGossip(conn, newClientAddr, incomingRequest.Peers, incomingRequest.Routes)
+	default:
+		// datagram over peer rate is not acked, so peer sends it again later
+		if !c.Inbound.Allow(newClientAddr.String()) {
+			c.Logger.Debug("p2p -> server -> MessageHandler : peer rate limit exceeded", zap.String("from", newClientAddr.String()))
+			return
 		}
+		if c.ackDatagram(conn, newClientAddr, &incomingRequest) {
+			c.Logger.Debug("p2p -> server -> MessageHandler : retransmitted datagram, skip", zap.Uint64("seq", incomingRequest.Seq))
+			return
+		}
+		c.HandleMessage(incomingRequest, newClientAddr)
 	}
 }
 
@@ -109,7 +171,8 @@ func (c *Core) Serve(filter filterConnections) (err error) {
 // if ip already exists - do not overwrite (for local tests)
 func (c *Core) addConnection(ip, port, punchPort string) {
 	// avoid overwriting for local run
-	if port == punchPort && ip == c.Server.Address.IP.String() {
+	serverIP, _ := c.serverAddress()
+	if port == punchPort && ip == serverIP.String() {
 		return
 	}
 
@@ -118,6 +181,7 @@ func (c *Core) addConnection(ip, port, punchPort string) {
 	c.Lock()
 	c.ConnectionStorage[address] = true
 	c.Unlock()
+	c.Discovery.connected(address)
 	c.Logger.Debug("p2p - server - addClient", zap.String("address", address))
 }
 
@@ -133,11 +197,64 @@ func (c *Core) GetConnection(ip, port string) (string, error) {
 	return "", errors.New("no such client")
 }
 
-// Remove connection from connection storage
-func (c *Core) RemoveConnection(address string) {
+// ip and punch port of the node
+func (c *Core) serverAddress() (net.IP, string) {
+	c.Server.Address.RLock()
+	defer c.Server.Address.RUnlock()
+	return c.Server.Address.IP, c.Server.Address.PunchPort
+}
+
+func (c *Core) setServerIP(ip net.IP) {
+	c.Server.Address.Lock()
+	defer c.Server.Address.Unlock()
+	c.Server.Address.IP = ip
+}
+
+// set address of the node from the first punch response, false if punch port is already known
+func (c *Core) setPunchAddress(ip net.IP, punchPort string) bool {
+	c.Server.Address.Lock()
+	defer c.Server.Address.Unlock()
+	if c.Server.Address.PunchPort != "" {
+		return false
+	}
+	c.Server.Address.IP = ip
+	c.Server.Address.PunchPort = punchPort
+	return true
+}
+
+// check if address is the address of the node
+func (c *Core) ownAddress(address string) bool {
+	ip, punchPort := c.serverAddress()
+	return address == net.JoinHostPort(ip.String(), c.Config.P2P.Port) || address == net.JoinHostPort(ip.String(), punchPort)
+}
+
+// number of connections in connection storage
+func (c *Core) connectionCount() int {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.ConnectionStorage)
+}
+
+// copy of connection storage, map is sent to peers and logged without the lock
+func (c *Core) copyConnections() map[string]bool {
 	c.RLock()
 	defer c.RUnlock()
+	connections := make(map[string]bool, len(c.ConnectionStorage))
+	for address, ok := range c.ConnectionStorage {
+		connections[address] = ok
+	}
+	return connections
+}
+
+// Remove connection from connection storage
+func (c *Core) RemoveConnection(address string) {
+	c.Lock()
 	delete(c.ConnectionStorage, address)
+	c.Unlock()
+	c.Reliable.Forget(address)
+	c.Discovery.disconnected(address)
+	c.Router.forget(address)
+	c.Inbound.Forget(address)
 	c.Logger.Debug("p2p - server - deleteClient", zap.String("address", address))
 }
 
@@ -160,9 +277,9 @@ func (c *Core) Disconnect() {
 	for node := range c.ConnectionStorage {
 		disconnectionReq.RemoteAddr = node
 
-		data, err := json.Marshal(disconnectionReq)
+		data, err := Marshal(disconnectionReq)
 		if err != nil {
-			c.Logger.Error("p2p - server - Disconnect - Unmarshal", zap.Error(err))
+			c.Logger.Error("p2p - server - Disconnect - Marshal", zap.Error(err))
 			return
 		}
 
@@ -184,11 +301,12 @@ func (c *Core) Disconnect() {
 // (p2p-new) get new p2p stats of p2p App
 // return stats of p2p server for debbuging purposes
 func (c *Core) GetStats() AppStats {
+	ip, punchPort := c.serverAddress()
 	p2pStats := AppStats{
 		SavedMessages:     make(map[string]bool),
-		PunchPort:         c.Server.Address.PunchPort,
-		ConnectionStorage: c.ConnectionStorage,
-		IP:                c.Server.Address.IP.String(),
+		PunchPort:         punchPort,
+		ConnectionStorage: c.copyConnections(),
+		IP:                ip.String(),
 	}
 
 	it := c.Cache.Iterator()
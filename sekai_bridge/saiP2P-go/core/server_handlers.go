@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/saiset-co/saiP2P-go/metrics"
 	"go.uber.org/zap"
 )
 
@@ -12,7 +13,7 @@ import (
 func (c *Core) HandlePunch(request Request, newClientAddr *net.UDPAddr) {
 	c.Logger.Debug("p2p -> server -> punchRequest", zap.String("request", request.RemoteAddr))
 
-	c.Server.Address.IP = net.ParseIP(strings.Split(request.RemoteAddr, ":")[0])
+	c.setServerIP(net.ParseIP(strings.Split(request.RemoteAddr, ":")[0]))
 
 	newClientIP := newClientAddr.IP.String()
 	newClientPort := strconv.Itoa(newClientAddr.Port)
@@ -22,19 +23,19 @@ func (c *Core) HandlePunch(request Request, newClientAddr *net.UDPAddr) {
 	)
 
 	_, err := c.GetConnection(newClientIP, newClientPort)
-	if err != nil && len(c.ConnectionStorage) < c.Config.P2P.Slot {
+	if err != nil && c.connectionCount() < c.Config.P2P.Slot {
 		c.addConnection(newClientIP, newClientPort, "")
-		c.Logger.Info("clients connected", zap.Any("clients", c.ConnectionStorage))
+		c.Logger.Info("clients connected", zap.Any("clients", c.copyConnections()))
 	} else {
 		c.Logger.Error("PunchHandler", zap.Error(err))
-		c.Logger.Error("PunchHandler", zap.Any("len", len(c.ConnectionStorage)))
+		c.Logger.Error("PunchHandler", zap.Any("len", c.connectionCount()))
 		c.Logger.Error("PunchHandler", zap.Any("slot", c.Config.P2P.Slot))
 	}
 
 	//c.Logger.Debug("p2p - server - AddPubkeyToStorage - PunchHandler", zap.String("address", newClientAddr.String())) //@debug
 
 	// respone + send own pubkey to connected p2p node
-	response := Response{Type: punchResponse, Status: "OK", Connections: c.ConnectionStorage, Punch: newClientPort, Ip: newClientIP, Port: c.Config.P2P.Port}
+	response := Response{Type: punchResponse, Status: "OK", Connections: c.copyConnections(), Punch: newClientPort, Ip: newClientIP, Port: c.Config.P2P.Port}
 	err = response.Send(c.Server.Connections.In, newClientAddr)
 	if err != nil {
 		c.Logger.Error("p2p -> server -> HandlePunch -> response.Send", zap.Error(err))
@@ -45,8 +46,8 @@ func (c *Core) HandlePunch(request Request, newClientAddr *net.UDPAddr) {
 // Handle handshake request from server part of core
 func (c *Core) HandleHandshake(request Request, newClientAddr *net.UDPAddr) {
 
-	if len(c.ConnectionStorage) >= c.Config.P2P.Slot {
-		response := Response{Type: handshakeResponse, Status: "NOK", Connections: c.ConnectionStorage, Punch: ""}
+	if c.connectionCount() >= c.Config.P2P.Slot {
+		response := Response{Type: handshakeResponse, Status: "NOK", Connections: c.copyConnections(), Punch: ""}
 		err := response.Send(c.Server.Connections.In, newClientAddr)
 		if err != nil {
 			c.Logger.Error("p2p -> server -> HandleHandshake -> response.Send", zap.Error(err))
@@ -59,27 +60,46 @@ func (c *Core) HandleHandshake(request Request, newClientAddr *net.UDPAddr) {
 	c.HandlePunch(request, newClientAddr)
 }
 
+// Handle punch and handshake responses from server part of core
+func (c *Core) HandleResponse(responseType string, data []byte, newClientAddr *net.UDPAddr) {
+	serverIP := newClientAddr.IP.String()
+	serverPort := strconv.Itoa(newClientAddr.Port)
+
+	var err error
+	switch responseType {
+	case punchResponse:
+		err = c.ClientPunchResponseHandler(data, serverIP, serverPort)
+	case handshakeResponse:
+		err = c.ClientHandshakeResponseHandler(data, serverIP, serverPort)
+	}
+	if err != nil {
+		c.Logger.Debug("p2p -> server -> HandleResponse", zap.Error(err))
+	}
+}
+
 // Handle message from server part of core
 func (c *Core) HandleMessage(request Request, newClientAddr *net.UDPAddr) {
-	found, err := c.CheckCache(string(request.Message.Data))
+	found, err := c.seen(&request.Message)
 	if err != nil {
 		c.Logger.Error("core -> server -> HandleMessage", zap.Error(err))
 		return
 	}
-	if !found {
-		c.Cache.Set(string(request.Message.Data), nil)
-	} else {
-		c.Logger.Debug("p2p -> server -> MessageHandler : msg exists, skip sending ", zap.Any("from", request.Message.From))
+	metrics.MessageReceived(found)
+	if found {
+		c.Logger.Debug("p2p -> server -> MessageHandler : msg exists, skip sending ", zap.String("id", request.Message.ID), zap.Any("from", request.Message.From))
 		return
 	}
 
-	c.MsgCh <- &request
-
-	err = c.SendMsg(request.Message.Data, request.Message.To, request.LocalAddr)
-	if err != nil {
-		c.Logger.Error("Error", zap.Error(err))
+	if request.Message.Target == c.Identity.ID() { // directed message to this node
+		c.deliver(newClientAddr.String(), &request)
 		return
 	}
+	if request.Message.Target == "" {
+		c.deliver(newClientAddr.String(), &request)
+	}
+
+	// relay without blocking the read loop, acks of relayed datagrams are read by it
+	go c.relay(request.Message)
 }
 
 // Event handling. For example,connecting/disconnecting to node
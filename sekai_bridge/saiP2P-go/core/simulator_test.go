@@ -0,0 +1,512 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"math/rand"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flynn/noise"
+	"github.com/saiset-co/saiP2P-go/config"
+	"github.com/saiset-co/saiP2P-go/simnet"
+	"go.uber.org/zap"
+)
+
+const (
+	simStep = 5 * time.Millisecond
+	// nodes of the simulation with trusted keys
+	simCommittee = 4
+)
+
+// core sockets in the simulated network
+type simNetwork struct {
+	host *simnet.Host
+}
+
+func (n simNetwork) ListenUDP(address string) (PacketConn, error) {
+	socket, err := n.host.ListenUDP(address)
+	if err != nil {
+		return nil, err
+	}
+	return socket, nil
+}
+
+type simulation struct {
+	t         *testing.T
+	clock     *simnet.Clock
+	network   *simnet.Network
+	dir       string
+	nodes     int
+	outsiders int
+	trusted   []string // static keys of the committee nodes
+}
+
+// node of the simulation, received messages are collected
+type simNode struct {
+	*Core
+	host     *simnet.Host
+	mu       sync.Mutex
+	received []*Message
+}
+
+func newSimulation(t *testing.T, seed int64) *simulation {
+	clock := simnet.NewClock(time.Now())
+	s := &simulation{
+		t:       t,
+		clock:   clock,
+		network: simnet.NewNetwork(clock, seed),
+		dir:     t.TempDir(),
+	}
+
+	// keys of the committee are generated before nodes are started, so nodes trust each other
+	for i := 1; i <= simCommittee; i++ {
+		identity, err := LoadIdentity(s.keyFile(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.trusted = append(s.trusted, hex.EncodeToString(identity.Noise.Public))
+	}
+	return s
+}
+
+func (s *simulation) keyFile(n int) string {
+	return filepath.Join(s.dir, string(rune('a'+n))) + "_key.json"
+}
+
+// start the next node of the committee
+func (s *simulation) node(host *simnet.Host, peers ...string) *simNode {
+	s.nodes++
+	if s.nodes > simCommittee {
+		s.t.Fatal("simulation committee is full")
+	}
+	return s.start(host, s.keyFile(s.nodes), peers...)
+}
+
+// start the node with the key which is not trusted by the committee
+func (s *simulation) outsider(host *simnet.Host, peers ...string) *simNode {
+	s.outsiders++
+	return s.start(host, filepath.Join(s.dir, "outsider"+string(rune('a'+s.outsiders))+"_key.json"), peers...)
+}
+
+func (s *simulation) start(host *simnet.Host, keyFile string, peers ...string) *simNode {
+	name := strings.TrimSuffix(keyFile, "_key.json")
+
+	cfg := config.Config{}
+	cfg.P2P.Port = "9000"
+	cfg.P2P.Slot = 8
+	cfg.P2P.KeyFile = keyFile
+	cfg.P2P.TrustedKeys = s.trusted
+	cfg.Discovery.File = name + "_peers.json"
+	cfg.Discovery.Interval = time.Second
+	cfg.UDP.MsgBufferSize = 1000
+	cfg.Cache.TTL = 600
+	cfg.Cache.CleanPeriod = 600
+	cfg.Peers = peers
+
+	filter := func(interface{}) bool { return true }
+	node := &simNode{host: host}
+	node.Core = Init(cfg, filter, WithClock(s.clock), WithNetwork(simNetwork{host: host}), WithLogger(zap.NewNop()))
+	go node.Run(filter)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.t.Cleanup(cancel)
+	go func() {
+		for {
+			msg, err := node.NextMsg(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil || msg == nil {
+				continue
+			}
+			node.mu.Lock()
+			node.received = append(node.received, msg)
+			node.mu.Unlock()
+		}
+	}()
+
+	return node
+}
+
+func (s *simulation) run(d time.Duration) {
+	s.clock.Run(d, simStep)
+}
+
+// run until all nodes are connected to each other
+func (s *simulation) connect(timeout time.Duration, nodes ...*simNode) {
+	for elapsed := time.Duration(0); elapsed < timeout; elapsed += time.Second {
+		if connected(nodes) {
+			return
+		}
+		s.run(time.Second)
+	}
+	if !connected(nodes) {
+		for _, node := range nodes {
+			s.t.Logf("%s connections : %v", node.GetRealAddress(), node.connections())
+		}
+		s.t.Fatal("nodes are not connected")
+	}
+}
+
+func connected(nodes []*simNode) bool {
+	for _, node := range nodes {
+		if len(node.connections()) < len(nodes)-1 {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *simNode) connections() []string {
+	return n.recipients(nil)
+}
+
+func (n *simNode) messages() []*Message {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]*Message(nil), n.received...)
+}
+
+func (n *simNode) connectedTo(other *simNode) bool {
+	n.RLock()
+	defer n.RUnlock()
+	return n.ConnectionStorage[other.GetRealAddress()]
+}
+
+func TestSimPunchAndHandshake(t *testing.T) {
+	s := newSimulation(t, 1)
+	s.network.SetConditions(simnet.Conditions{Latency: 20 * time.Millisecond, Jitter: 5 * time.Millisecond})
+
+	a := s.node(s.network.AddHost("10.0.0.1"))
+	b := s.node(s.network.AddNATHost("192.168.1.2", "20.0.0.2", simnet.RestrictedCone), "10.0.0.1:9000")
+	c := s.node(s.network.AddNATHost("192.168.1.3", "20.0.0.3", simnet.FullCone), "10.0.0.1:9000")
+
+	s.connect(20*time.Second, a, b, c)
+
+	if !a.connectedTo(b) || !b.connectedTo(a) {
+		t.Fatalf("a and b are not connected : %v, %v", a.connections(), b.connections())
+	}
+
+	// node behind NAT is reachable by its public mapping
+	if b.GetRealAddress()[:len("20.0.0.2")] != "20.0.0.2" {
+		t.Fatalf("public address of b is not learned : %s", b.GetRealAddress())
+	}
+
+	// peers authenticated each other by noise keys
+	for _, node := range []*simNode{a, b, c} {
+		for _, other := range []*simNode{a, b, c} {
+			if node == other {
+				continue
+			}
+			if _, ok := node.Discovery.record(other.Identity.ID()); !ok {
+				t.Fatalf("%s does not know record of %s", node.GetRealAddress(), other.GetRealAddress())
+			}
+		}
+	}
+}
+
+func TestSimUntrustedPeerRejected(t *testing.T) {
+	s := newSimulation(t, 6)
+	a := s.node(s.network.AddHost("10.0.0.1"))
+	b := s.node(s.network.AddHost("10.0.0.2"), "10.0.0.1:9000")
+	x := s.outsider(s.network.AddHost("10.0.0.9"), "10.0.0.1:9000", "10.0.0.2:9000")
+
+	s.connect(10*time.Second, a, b)
+	s.run(10 * time.Second)
+
+	// noise handshake of the unknown static key is not completed
+	for _, node := range []*simNode{a, b} {
+		if node.connectedTo(x) || x.connectedTo(node) {
+			t.Fatalf("untrusted node is connected to %s : %v, %v", node.GetRealAddress(), node.connections(), x.connections())
+		}
+		if _, ok := node.Discovery.record(x.Identity.ID()); ok {
+			t.Fatalf("%s knows record of untrusted node", node.GetRealAddress())
+		}
+	}
+
+	err := x.SendMsg([]byte("untrusted"), nil, x.GetRealAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.run(2 * time.Second)
+
+	if len(a.messages()) != 0 || len(b.messages()) != 0 {
+		t.Fatal("message of untrusted node is delivered")
+	}
+}
+
+func TestSimChunkedReassembly(t *testing.T) {
+	s := newSimulation(t, 2)
+	a := s.node(s.network.AddHost("10.0.0.1"))
+	b := s.node(s.network.AddHost("10.0.0.2"), "10.0.0.1:9000")
+	s.connect(10*time.Second, a, b)
+
+	s.network.SetConditions(simnet.Conditions{
+		Latency:   20 * time.Millisecond,
+		Jitter:    10 * time.Millisecond,
+		Loss:      0.1,
+		Duplicate: 0.1,
+		Reorder:   0.2,
+	})
+
+	data := make([]byte, 50000) // 50 chunks
+	rand.New(rand.NewSource(2)).Read(data)
+	err := a.SendMsg(data, nil, a.GetRealAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.run(30 * time.Second)
+
+	received := b.messages()
+	if len(received) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(received))
+	}
+	if !bytes.Equal(received[0].Data, data) {
+		t.Fatal("assembled message differs from sent one")
+	}
+	if stats := s.network.Stats(); stats.Lost == 0 || stats.Duplicated == 0 {
+		t.Fatalf("network conditions were not applied : %+v", stats)
+	}
+}
+
+func TestSimDisconnectionEvents(t *testing.T) {
+	s := newSimulation(t, 3)
+	a := s.node(s.network.AddHost("10.0.0.1"))
+	b := s.node(s.network.AddHost("10.0.0.2"), "10.0.0.1:9000")
+	cHost := s.network.AddHost("10.0.0.3")
+	c := s.node(cHost, "10.0.0.1:9000")
+	s.connect(20*time.Second, a, b, c)
+
+	// graceful shutdown notifies peers
+	b.ProvideDisconnection()
+	b.host.SetDown(true)
+	s.run(500 * time.Millisecond)
+
+	if a.connectedTo(b) || c.connectedTo(b) {
+		t.Fatalf("b is still connected : %v, %v", a.connections(), c.connections())
+	}
+
+	// crashed peer is dropped by liveness check
+	cHost.SetDown(true)
+	s.run(time.Duration(livenessIntervals+2) * a.Config.Discovery.Interval)
+
+	if a.connectedTo(c) {
+		t.Fatalf("silent c is still connected : %v", a.connections())
+	}
+}
+
+func TestSimSealedTruncation(t *testing.T) {
+	s := newSimulation(t, 7)
+	a := s.node(s.network.AddHost("10.0.0.1"))
+	b := s.node(s.network.AddHost("10.0.0.2"), "10.0.0.1:9000")
+	s.connect(10*time.Second, a, b)
+	s.run(3 * a.Config.Discovery.Interval) // peer records are gossiped
+
+	record, ok := a.Discovery.record(b.Identity.ID())
+	if !ok {
+		t.Fatal("a does not know record of b")
+	}
+
+	// keygen messages are sealed by several blocks
+	data := make([]byte, 3*sealBlockSize+100)
+	rand.New(rand.NewSource(7)).Read(data)
+
+	sealed := func() Message {
+		message := Message{ID: "sealed", Topic: 1, Origin: a.Identity.ID(), Target: b.Identity.ID()}
+		var err error
+		message.Data, err = a.seal(&message, record, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return message
+	}
+
+	message := sealed()
+	err := b.open(&message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message.Data, data) {
+		t.Fatal("opened message differs from sealed one")
+	}
+
+	// trailing blocks are cut by relay
+	for _, blocks := range []int{1, 2} {
+		message = sealed()
+		message.Data = message.Data[:len(message.Data)-(100+sealTagSize)-(blocks-1)*(sealBlockSize+sealTagSize)]
+		err = b.open(&message)
+		if err == nil {
+			t.Fatalf("message without %d trailing blocks is opened", blocks)
+		}
+	}
+}
+
+func TestSimDeduplication(t *testing.T) {
+	s := newSimulation(t, 4)
+	a := s.node(s.network.AddHost("10.0.0.1"))
+	b := s.node(s.network.AddHost("10.0.0.2"), "10.0.0.1:9000")
+	c := s.node(s.network.AddHost("10.0.0.3"), "10.0.0.1:9000")
+	s.connect(20*time.Second, a, b, c)
+
+	s.network.SetConditions(simnet.Conditions{
+		Latency:   10 * time.Millisecond,
+		Jitter:    20 * time.Millisecond,
+		Loss:      0.05,
+		Duplicate: 0.5,
+		Reorder:   0.3,
+	})
+
+	// repeated payloads are different messages, relays and duplicates are delivered once
+	payloads := []string{"same", "same", "same", "one", "two", "three"}
+	for _, payload := range payloads {
+		err := a.SendMsg([]byte(payload), nil, a.GetRealAddress())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.run(20 * time.Second)
+
+	for _, node := range []*simNode{b, c} {
+		received := node.messages()
+		if len(received) != len(payloads) {
+			t.Fatalf("%s : expected %d messages, got %d", node.GetRealAddress(), len(payloads), len(received))
+		}
+		counts := make(map[string]int)
+		ids := make(map[string]bool)
+		for _, msg := range received {
+			counts[string(msg.Data)]++
+			if ids[msg.ID] {
+				t.Fatalf("%s : message %s delivered twice", node.GetRealAddress(), msg.ID)
+			}
+			ids[msg.ID] = true
+		}
+		if counts["same"] != 3 || counts["one"] != 1 {
+			t.Fatalf("%s : unexpected messages %v", node.GetRealAddress(), counts)
+		}
+	}
+}
+
+func TestSimWireVersionMismatch(t *testing.T) {
+	s := newSimulation(t, 5)
+
+	// secure connection of the node with the wire version, read datagrams are collected
+	secure := func(ip string, version byte) (*SecureConn, chan []byte) {
+		identity, err := noiseSuite.GenerateKeypair(crand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sec := NewSecure(identity, nil, zap.NewNop(), s.clock)
+		sec.version = version
+
+		socket, err := s.network.AddHost(ip).ListenUDP(":9000")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn := sec.Wrap(socket, noiseMaxDatagram)
+		t.Cleanup(func() { conn.Close() })
+
+		received := make(chan []byte, 1)
+		go func() {
+			buf := make([]byte, 100)
+			for {
+				n, _, err := conn.ReadFromUDP(buf)
+				if err != nil {
+					return
+				}
+				received <- append([]byte(nil), buf[:n]...)
+			}
+		}()
+		return conn, received
+	}
+
+	a, _ := secure("10.0.0.1", WireVersion)
+	b, received := secure("10.0.0.2", WireVersion+1)
+	aAddr := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 9000}
+	bAddr := &net.UDPAddr{IP: net.ParseIP("10.0.0.2"), Port: 9000}
+
+	_, err := a.WriteToUDP([]byte("hello"), bAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// both sides abort the handshake after one round trip instead of retrying it
+	s.run(500 * time.Millisecond)
+
+	a.mu.Lock()
+	peer := a.peers[bAddr.String()]
+	aborted := peer == nil || (peer.hs == nil && len(peer.queue) == 0)
+	a.mu.Unlock()
+	if !aborted {
+		t.Fatal("initiator did not abort handshake with peer of other version")
+	}
+	if a.RemoteKey(bAddr) != nil || b.RemoteKey(aAddr) != nil {
+		t.Fatal("session is established between different wire versions")
+	}
+
+	select {
+	case <-received:
+		t.Fatal("datagram is delivered to peer of other version")
+	default:
+	}
+}
+
+func TestSimHandshakeFloodCapped(t *testing.T) {
+	s := newSimulation(t, 8)
+
+	identity, err := noiseSuite.GenerateKeypair(crand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket, err := s.network.AddHost("10.0.0.1").ListenUDP(":9000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := NewSecure(identity, nil, zap.NewNop(), s.clock).Wrap(socket, noiseMaxDatagram)
+	t.Cleanup(func() { conn.Close() })
+
+	// unauthenticated XX inits from spoofed addresses
+	for i := 0; i < 2*noiseMaxResponders; i++ {
+		hs, err := noise.NewHandshakeState(noise.Config{
+			CipherSuite:   noiseSuite,
+			Random:        crand.Reader,
+			Pattern:       noise.HandshakeXX,
+			Initiator:     true,
+			Prologue:      noisePrologue,
+			StaticKeypair: identity,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, _, _, err := hs.WriteMessage([]byte{noiseInitXX}, []byte{WireVersion})
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.open(&net.UDPAddr{IP: net.IPv4(30, 0, byte(i>>8), byte(i)), Port: 9000}, msg)
+	}
+
+	conn.mu.Lock()
+	peers, responders := len(conn.peers), conn.responders()
+	conn.mu.Unlock()
+	if peers != noiseMaxResponders || responders != noiseMaxResponders {
+		t.Fatalf("%d peers with %d pending handshakes, want %d", peers, responders, noiseMaxResponders)
+	}
+
+	// handshakes which are not completed expire and free the state
+	s.run(2 * noiseRetransmit * noiseMaxRetries)
+
+	conn.mu.Lock()
+	peers = len(conn.peers)
+	conn.mu.Unlock()
+	if peers != 0 {
+		t.Fatalf("%d peers are left after handshakes expired", peers)
+	}
+}
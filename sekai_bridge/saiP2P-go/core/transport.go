@@ -0,0 +1,270 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	streamDialTimeout = 5 * time.Second
+	streamFrameHeader = 4 // big endian frame length
+)
+
+// PacketConn - datagram connection under the secure channel, udp socket or tcp streams
+type PacketConn interface {
+	ReadFromUDP(b []byte) (int, *net.UDPAddr, error)
+	WriteToUDP(b []byte, addr *net.UDPAddr) (int, error)
+	LocalAddr() net.Addr
+	Close() error
+}
+
+// StreamConn - tcp connections to peers presented as datagram connection.
+// Datagrams are length prefixed frames, so messages are not limited by udp datagram size.
+type StreamConn struct {
+	listener  *net.TCPListener
+	maxFrame  int
+	mu        sync.Mutex
+	peers     map[string]*streamPeer // by remote address, dialed and accepted connections
+	incoming  chan streamFrame
+	done      chan struct{}
+	closeOnce sync.Once
+	logger    *zap.Logger
+}
+
+// tcp connection of the peer, frames are written whole under lock
+type streamPeer struct {
+	sync.Mutex
+	conn *net.TCPConn
+}
+
+type streamFrame struct {
+	data []byte
+	addr *net.UDPAddr
+}
+
+// ListenStream - listen tcp port for stream transport
+func ListenStream(address string, maxFrame int, logger *zap.Logger) (*StreamConn, error) {
+	tcpAddr, err := net.ResolveTCPAddr("tcp4", address)
+	if err != nil {
+		return nil, fmt.Errorf("ResolveTCPAddr : %w", err)
+	}
+
+	listener, err := net.ListenTCP("tcp4", tcpAddr)
+	if err != nil {
+		return nil, fmt.Errorf("ListenTCP : %w", err)
+	}
+
+	s := &StreamConn{
+		listener: listener,
+		maxFrame: maxFrame,
+		peers:    make(map[string]*streamPeer),
+		incoming: make(chan streamFrame),
+		done:     make(chan struct{}),
+		logger:   logger,
+	}
+
+	go s.accept()
+
+	return s, nil
+}
+
+// ReadFromUDP - read next frame from any peer
+func (s *StreamConn) ReadFromUDP(b []byte) (int, *net.UDPAddr, error) {
+	select {
+	case frame := <-s.incoming:
+		if len(frame.data) > len(b) {
+			return 0, frame.addr, fmt.Errorf("frame of %d bytes is bigger than buffer", len(frame.data))
+		}
+		return copy(b, frame.data), frame.addr, nil
+	case <-s.done:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+// WriteToUDP - write frame to the peer, connection is dialed if not exists
+func (s *StreamConn) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	if len(b) > s.maxFrame {
+		return 0, fmt.Errorf("frame of %d bytes exceeds max frame size", len(b))
+	}
+
+	peer, err := s.peer(addr)
+	if err != nil {
+		return 0, err
+	}
+
+	frame := make([]byte, streamFrameHeader, streamFrameHeader+len(b))
+	binary.BigEndian.PutUint32(frame, uint32(len(b)))
+	frame = append(frame, b...)
+
+	peer.Lock()
+	_, err = peer.conn.Write(frame)
+	peer.Unlock()
+	if err != nil {
+		s.remove(addr.String(), peer)
+		return 0, fmt.Errorf("Write : %w", err)
+	}
+
+	return len(b), nil
+}
+
+// LocalAddr - tcp listen address
+func (s *StreamConn) LocalAddr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close - close listener and all peer connections
+func (s *StreamConn) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+
+	s.mu.Lock()
+	for address, peer := range s.peers {
+		peer.conn.Close()
+		delete(s.peers, address)
+	}
+	s.mu.Unlock()
+
+	return s.listener.Close()
+}
+
+// get connection of the peer or dial it
+func (s *StreamConn) peer(addr *net.UDPAddr) (*streamPeer, error) {
+	s.mu.Lock()
+	peer, ok := s.peers[addr.String()]
+	s.mu.Unlock()
+	if ok {
+		return peer, nil
+	}
+
+	conn, err := net.DialTimeout("tcp4", addr.String(), streamDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("Dial : %w", err)
+	}
+
+	peer = &streamPeer{conn: conn.(*net.TCPConn)}
+
+	s.mu.Lock()
+	if existing, ok := s.peers[addr.String()]; ok { // dialed concurrently
+		s.mu.Unlock()
+		conn.Close()
+		return existing, nil
+	}
+	s.peers[addr.String()] = peer
+	s.mu.Unlock()
+
+	go s.read(addr, peer)
+
+	return peer, nil
+}
+
+func (s *StreamConn) remove(address string, peer *streamPeer) {
+	s.mu.Lock()
+	if s.peers[address] == peer {
+		delete(s.peers, address)
+	}
+	s.mu.Unlock()
+	peer.conn.Close()
+}
+
+func (s *StreamConn) accept() {
+	for {
+		conn, err := s.listener.AcceptTCP()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.logger.Warn("p2p -> stream -> AcceptTCP", zap.Error(err))
+			continue
+		}
+
+		remote := conn.RemoteAddr().(*net.TCPAddr)
+		addr := &net.UDPAddr{IP: remote.IP, Port: remote.Port}
+		peer := &streamPeer{conn: conn}
+
+		s.mu.Lock()
+		s.peers[addr.String()] = peer
+		s.mu.Unlock()
+
+		go s.read(addr, peer)
+	}
+}
+
+// read frames of the peer until connection is closed
+func (s *StreamConn) read(addr *net.UDPAddr, peer *streamPeer) {
+	defer s.remove(addr.String(), peer)
+
+	header := make([]byte, streamFrameHeader)
+	for {
+		_, err := io.ReadFull(peer.conn, header)
+		if err != nil {
+			return
+		}
+
+		size := binary.BigEndian.Uint32(header)
+		if int(size) > s.maxFrame {
+			s.logger.Warn("p2p -> stream -> read : frame exceeds max frame size", zap.String("addr", addr.String()), zap.Uint32("size", size))
+			return
+		}
+
+		data := make([]byte, size)
+		_, err = io.ReadFull(peer.conn, data)
+		if err != nil {
+			return
+		}
+
+		select {
+		case s.incoming <- streamFrame{data: data, addr: addr}:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// tcp address of the peer, if messages to it are sent over stream transport (p2p.stream.peers)
+func (c *Core) streamAddress(address string) *net.UDPAddr {
+	if c.Server.Connections.Stream == nil {
+		return nil
+	}
+
+	c.Server.RWMutex.Lock()
+	defer c.Server.RWMutex.Unlock()
+
+	if target, ok := c.Server.streamRoutes[address]; ok {
+		return target
+	}
+
+	resolved := true
+	for peer, target := range c.Config.P2P.Stream.Peers {
+		peerAddr, err := net.ResolveUDPAddr("udp4", peer)
+		if err != nil {
+			resolved = false
+			continue
+		}
+		if peerAddr.String() != address {
+			continue
+		}
+
+		targetAddr, err := net.ResolveUDPAddr("udp4", target)
+		if err != nil {
+			c.Logger.Error("p2p -> core -> streamAddress -> ResolveUDPAddr", zap.Error(err), zap.String("addr", target))
+			return nil
+		}
+
+		c.Server.streamRoutes[address] = targetAddr
+		return targetAddr
+	}
+
+	// udp peer, not cached while some stream peers can not be resolved
+	if resolved {
+		c.Server.streamRoutes[address] = nil
+	}
+	return nil
+}
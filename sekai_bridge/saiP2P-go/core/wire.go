@@ -0,0 +1,71 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Wire format of requests, responses and payloads : version byte followed by CBOR with integer keys.
+// Binary data is encoded as is, without base64. Peers exchange wire version in noise handshake,
+// so nodes of different versions fail to connect instead of mis-parsing each other's datagrams.
+const (
+	WireVersion byte = 2
+	wireLegacy  byte = 1 // JSON wire, nodes without version negotiation
+)
+
+var ErrWireVersion = errors.New("unsupported wire version")
+
+// type of request or response, decoded before the whole datagram
+type wireHeader struct {
+	Type string `cbor:"1,keyasint"`
+}
+
+// Marshal - encode value to the wire format
+func Marshal(v interface{}) ([]byte, error) {
+	data, err := cbor.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("cbor.Marshal : %w", err)
+	}
+	return append([]byte{WireVersion}, data...), nil
+}
+
+// Unmarshal - decode value from the wire format, data of other wire version is rejected
+func Unmarshal(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return errors.New("empty data")
+	}
+	if data[0] != WireVersion {
+		version := data[0]
+		if version == '{' {
+			version = wireLegacy
+		}
+		return fmt.Errorf("%w %d", ErrWireVersion, version)
+	}
+
+	err := cbor.Unmarshal(data[1:], v)
+	if err != nil {
+		return fmt.Errorf("cbor.Unmarshal : %w", err)
+	}
+	return nil
+}
+
+// type of encoded request or response
+func wireType(data []byte) (string, error) {
+	header := wireHeader{}
+	err := Unmarshal(data, &header)
+	if err != nil {
+		return "", err
+	}
+	return header.Type, nil
+}
+
+// split handshake payload to wire version of the peer and the rest of the given size,
+// payload without version belongs to legacy node
+func splitVersion(payload []byte, rest int) (byte, []byte) {
+	if len(payload) != rest+1 {
+		return wireLegacy, payload
+	}
+	return payload[0], payload[1:]
+}
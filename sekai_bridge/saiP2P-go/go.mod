@@ -3,10 +3,25 @@ module github.com/saiset-co/saiP2P-go
 go 1.20
 
 require (
+	github.com/flynn/noise v1.1.0
+	github.com/fxamacker/cbor/v2 v2.5.0
+	github.com/prometheus/client_golang v1.19.1
 	go.uber.org/zap v1.26.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+)
+
 require (
 	github.com/allegro/bigcache/v3 v3.1.0
 	go.uber.org/multierr v1.10.0 // indirect
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "p2p"
+
+var (
+	// BytesSent counts bytes written to the udp sockets by request type
+	BytesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bytes_sent_total",
+		Help:      "Bytes sent to peers by request type.",
+	}, []string{"type"})
+
+	// BytesReceived counts bytes read from the udp sockets
+	BytesReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bytes_received_total",
+		Help:      "Bytes received from peers.",
+	})
+
+	// MessagesSent counts messages (or message chunks) sent to peers
+	MessagesSent = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_sent_total",
+		Help:      "Messages and message chunks sent to peers.",
+	})
+
+	// MessagesReceived counts messages received from peers, duplicates are labeled separately
+	MessagesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_received_total",
+		Help:      "Messages and message chunks received from peers.",
+	}, []string{"duplicate"})
+
+	// MessagesDropped counts received messages dropped by peer rate limit or full peer queue
+	MessagesDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_dropped_total",
+		Help:      "Received messages dropped by rate limit or full inbound queue.",
+	}, []string{"reason"})
+
+	// InboundQueued shows received messages waiting for NextMsg
+	InboundQueued = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "inbound_queued",
+		Help:      "Received messages queued for consumer.",
+	})
+
+	// RelaysDropped counts received messages which were not relayed because hop limit was reached
+	RelaysDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relays_dropped_total",
+		Help:      "Messages not relayed because hop limit was reached.",
+	})
+
+	// ChunkReassemblyFailures counts chunked messages which could not be assembled
+	ChunkReassemblyFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "chunk_reassembly_failures_total",
+		Help:      "Chunked messages failed to reassemble.",
+	})
+
+	// ReassemblyTimeouts counts chunked messages dropped because missing chunks did not arrive in time
+	ReassemblyTimeouts = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "chunk_reassembly_timeouts_total",
+		Help:      "Chunked messages dropped after reassembly timeout.",
+	})
+
+	// Retransmissions counts message datagrams sent again because ack was not received in time
+	Retransmissions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retransmissions_total",
+		Help:      "Message datagrams retransmitted after retransmission timeout.",
+	})
+
+	// DeliveryFailures counts message datagrams given up after max retries or full send window
+	DeliveryFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "delivery_failures_total",
+		Help:      "Message datagrams which were not delivered to peer.",
+	}, []string{"reason"})
+
+	// Handshakes counts noise handshakes by result
+	Handshakes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "handshakes_total",
+		Help:      "Noise handshakes with peers by result.",
+	}, []string{"result"})
+
+	// PacketsRejected counts datagrams dropped before processing, by reason
+	PacketsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "packets_rejected_total",
+		Help:      "Datagrams rejected by secure channel.",
+	}, []string{"reason"})
+
+	// RTT observes round trip time samples of acked datagrams
+	RTT = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rtt_seconds",
+		Help:      "Round trip time of acked message datagrams.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	})
+)
+
+// MessageReceived records incoming message, duplicate flag is used as label
+func MessageReceived(duplicate bool) {
+	if duplicate {
+		MessagesReceived.WithLabelValues("true").Inc()
+		return
+	}
+	MessagesReceived.WithLabelValues("false").Inc()
+}
@@ -0,0 +1,153 @@
+// Package simnet - simulated network for tests of p2p core : virtual clock and virtual udp sockets
+// with configurable latency, loss, duplication, reordering and NAT behavior.
+// All randomness comes from the seed, so the same seed gives the same fate of every datagram.
+package simnet
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// real pause after every step of Run
+const realStepPause = 200 * time.Microsecond
+
+// Clock - virtual clock, time moves only by Advance. Timers with the same time fire in creation order.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    uint64
+	timers timerQueue
+}
+
+type timer struct {
+	at      time.Time
+	seq     uint64
+	period  time.Duration // ticker period, 0 for one shot timers
+	ch      chan time.Time
+	fn      func()
+	stopped bool
+}
+
+// NewClock - create virtual clock starting at the time
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now - current virtual time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After - channel receiving virtual time after duration
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.schedule(&timer{ch: ch}, d)
+	return ch
+}
+
+// Ticker - channel receiving virtual time every period, ticks are dropped if receiver is slow
+func (c *Clock) Ticker(d time.Duration) (<-chan time.Time, func()) {
+	ch := make(chan time.Time, 1)
+	t := &timer{ch: ch, period: d}
+	c.schedule(t, d)
+	return ch, func() {
+		c.mu.Lock()
+		t.stopped = true
+		c.mu.Unlock()
+	}
+}
+
+// AfterFunc - call function from Advance after duration
+func (c *Clock) AfterFunc(d time.Duration, fn func()) {
+	c.schedule(&timer{fn: fn}, d)
+}
+
+// Advance - move time forward, due timers fire in time order
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		if len(c.timers) == 0 || c.timers[0].at.After(end) {
+			c.now = end
+			c.mu.Unlock()
+			return
+		}
+
+		t := heap.Pop(&c.timers).(*timer)
+		c.now = t.at
+		if t.stopped {
+			c.mu.Unlock()
+			continue
+		}
+		if t.period > 0 {
+			c.seq++
+			t.seq = c.seq
+			t.at = t.at.Add(t.period)
+			heap.Push(&c.timers, t)
+		}
+		now := c.now
+		c.mu.Unlock()
+
+		if t.fn != nil {
+			t.fn()
+			continue
+		}
+		select {
+		case t.ch <- now:
+		default:
+		}
+	}
+}
+
+// Run - advance time by steps, goroutines waiting for timers and datagrams
+// get real time to process them after every step
+func (c *Clock) Run(d, step time.Duration) {
+	for elapsed := time.Duration(0); elapsed < d; elapsed += step {
+		c.Advance(step)
+		time.Sleep(realStepPause)
+	}
+}
+
+func (c *Clock) schedule(t *timer, d time.Duration) {
+	if d <= 0 {
+		d = time.Nanosecond
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	t.seq = c.seq
+	t.at = c.now.Add(d)
+	heap.Push(&c.timers, t)
+}
+
+// timers ordered by time and creation
+type timerQueue []*timer
+
+func (q timerQueue) Len() int { return len(q) }
+
+func (q timerQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at.Before(q[j].at)
+}
+
+func (q timerQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *timerQueue) Push(x interface{}) { *q = append(*q, x.(*timer)) }
+
+func (q *timerQueue) Pop() interface{} {
+	old := *q
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return t
+}
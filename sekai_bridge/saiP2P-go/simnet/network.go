@@ -0,0 +1,357 @@
+package simnet
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	firstEphemeralPort = 40000
+	socketBuffer       = 4096 // datagrams queued in socket, more are dropped like by kernel
+)
+
+// NAT - behavior of the host address translation
+type NAT int
+
+const (
+	NoNAT          NAT = iota // host is reachable by its address
+	FullCone                  // one mapping per socket, anyone can send to the mapping
+	RestrictedCone            // one mapping per socket, only hosts the socket sent to can send to the mapping
+	Symmetric                 // mapping per socket and destination, only the destination can send to the mapping
+)
+
+// Conditions - quality of all links of the network
+type Conditions struct {
+	Latency   time.Duration // one way delay
+	Jitter    time.Duration // random extra delay up to jitter
+	Loss      float64       // probability datagram is lost
+	Duplicate float64       // probability datagram is delivered twice
+	Reorder   float64       // probability datagram is delayed, so next datagrams overtake it
+}
+
+// Stats - datagram counters of the network
+type Stats struct {
+	Sent       int
+	Delivered  int
+	Lost       int // by link loss
+	Duplicated int
+	Filtered   int // by NAT, down host, closed socket or full socket buffer
+}
+
+// Network - simulated udp network, datagrams are delivered by the virtual clock
+type Network struct {
+	sync.Mutex
+	Clock      *Clock
+	rng        *rand.Rand
+	conditions Conditions
+	hosts      map[string]*Host // by public ip
+	stats      Stats
+}
+
+// Host - simulated host, behind NAT if public ip differs from its ip
+type Host struct {
+	network  *Network
+	ip       net.IP
+	public   net.IP
+	nat      NAT
+	down     bool
+	nextPort int
+	sockets  map[int]*Socket     // by local port
+	mappings map[int]*mapping    // by public port
+	bySocket map[string]*mapping // by local port and destination (symmetric NAT) or local port
+}
+
+// NAT mapping of the socket
+type mapping struct {
+	socket  *Socket
+	port    int
+	remote  string          // destination of symmetric mapping
+	allowed map[string]bool // ips the socket sent to, for restricted cone
+}
+
+// Socket - simulated udp socket
+type Socket struct {
+	host      *Host
+	addr      *net.UDPAddr
+	inbox     chan datagram
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type datagram struct {
+	data []byte
+	from *net.UDPAddr
+}
+
+// NewNetwork - create network driven by the clock, seed defines fate of all datagrams
+func NewNetwork(clock *Clock, seed int64) *Network {
+	return &Network{
+		Clock: clock,
+		rng:   rand.New(rand.NewSource(seed)),
+		hosts: make(map[string]*Host),
+	}
+}
+
+// SetConditions - change quality of all links
+func (n *Network) SetConditions(conditions Conditions) {
+	n.Lock()
+	defer n.Unlock()
+	n.conditions = conditions
+}
+
+// Stats - datagram counters
+func (n *Network) Stats() Stats {
+	n.Lock()
+	defer n.Unlock()
+	return n.stats
+}
+
+// AddHost - add host reachable by the ip
+func (n *Network) AddHost(ip string) *Host {
+	return n.AddNATHost(ip, ip, NoNAT)
+}
+
+// AddNATHost - add host with private ip behind NAT with public ip
+func (n *Network) AddNATHost(ip, public string, nat NAT) *Host {
+	n.Lock()
+	defer n.Unlock()
+
+	if nat == NoNAT {
+		public = ip
+	}
+	if _, ok := n.hosts[public]; ok {
+		panic(fmt.Sprintf("simnet : host %s already exists", public))
+	}
+
+	host := &Host{
+		network:  n,
+		ip:       net.ParseIP(ip).To4(),
+		public:   net.ParseIP(public).To4(),
+		nat:      nat,
+		nextPort: firstEphemeralPort,
+		sockets:  make(map[int]*Socket),
+		mappings: make(map[int]*mapping),
+		bySocket: make(map[string]*mapping),
+	}
+	n.hosts[public] = host
+	return host
+}
+
+// SetDown - host down drops all its datagrams, like crashed or partitioned host
+func (h *Host) SetDown(down bool) {
+	h.network.Lock()
+	defer h.network.Unlock()
+	h.down = down
+}
+
+// ListenUDP - open socket, address is ip:port, :port or empty for any port
+func (h *Host) ListenUDP(address string) (*Socket, error) {
+	port := 0
+	if address != "" {
+		_, portStr, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("SplitHostPort : %w", err)
+		}
+		if portStr != "" {
+			port, err = strconv.Atoi(portStr)
+			if err != nil {
+				return nil, fmt.Errorf("Atoi : %w", err)
+			}
+		}
+	}
+
+	h.network.Lock()
+	defer h.network.Unlock()
+
+	if port == 0 {
+		for h.sockets[h.nextPort] != nil {
+			h.nextPort++
+		}
+		port = h.nextPort
+		h.nextPort++
+	}
+	if _, ok := h.sockets[port]; ok {
+		return nil, fmt.Errorf("port %d is already in use", port)
+	}
+
+	socket := &Socket{
+		host:  h,
+		addr:  &net.UDPAddr{IP: h.ip, Port: port},
+		inbox: make(chan datagram, socketBuffer),
+		done:  make(chan struct{}),
+	}
+	h.sockets[port] = socket
+	return socket, nil
+}
+
+// ReadFromUDP - read next delivered datagram
+func (s *Socket) ReadFromUDP(b []byte) (int, *net.UDPAddr, error) {
+	select {
+	case d := <-s.inbox:
+		return copy(b, d.data), d.from, nil
+	case <-s.done:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+// WriteToUDP - send datagram, it is delivered by the clock according to network conditions
+func (s *Socket) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	select {
+	case <-s.done:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	s.host.network.send(s, append([]byte(nil), b...), &net.UDPAddr{IP: addr.IP.To4(), Port: addr.Port})
+	return len(b), nil
+}
+
+// LocalAddr - address of the socket on its host
+func (s *Socket) LocalAddr() net.Addr {
+	return s.addr
+}
+
+// Close - close socket, blocked reads return net.ErrClosed
+func (s *Socket) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+
+		n := s.host.network
+		n.Lock()
+		delete(s.host.sockets, s.addr.Port)
+		for key, m := range s.host.bySocket {
+			if m.socket == s {
+				delete(s.host.bySocket, key)
+				delete(s.host.mappings, m.port)
+			}
+		}
+		n.Unlock()
+	})
+	return nil
+}
+
+func (n *Network) send(socket *Socket, data []byte, to *net.UDPAddr) {
+	n.Lock()
+	defer n.Unlock()
+
+	host := socket.host
+	n.stats.Sent++
+	if host.down {
+		n.stats.Filtered++
+		return
+	}
+
+	from := &net.UDPAddr{IP: host.public, Port: socket.addr.Port}
+	if host.nat != NoNAT {
+		m := host.mapping(socket, to)
+		m.allowed[to.IP.String()] = true
+		from.Port = m.port
+	}
+
+	// random values are drawn in the same order for every datagram, so the seed defines the fate
+	conditions := n.conditions
+	lost := n.rng.Float64() < conditions.Loss
+	duplicated := n.rng.Float64() < conditions.Duplicate
+	delays := []time.Duration{n.delay(), n.delay()}
+	if lost {
+		n.stats.Lost++
+		return
+	}
+
+	copies := 1
+	if duplicated {
+		n.stats.Duplicated++
+		copies = 2
+	}
+	for i := 0; i < copies; i++ {
+		n.Clock.AfterFunc(delays[i], func() {
+			n.deliver(data, from, to)
+		})
+	}
+}
+
+// random delay of the datagram, should be called under lock
+func (n *Network) delay() time.Duration {
+	conditions := n.conditions
+	delay := conditions.Latency
+	jitter := n.rng.Int63n(int64(conditions.Jitter) + 1)
+	reordered := n.rng.Float64() < conditions.Reorder
+	delay += time.Duration(jitter)
+	if reordered {
+		delay += 2*conditions.Latency + conditions.Jitter + time.Millisecond
+	}
+	return delay
+}
+
+func (n *Network) deliver(data []byte, from, to *net.UDPAddr) {
+	n.Lock()
+	defer n.Unlock()
+
+	host, ok := n.hosts[to.IP.String()]
+	if !ok || host.down {
+		n.stats.Filtered++
+		return
+	}
+
+	socket := host.sockets[to.Port]
+	if host.nat != NoNAT {
+		socket = nil
+		if m, ok := host.mappings[to.Port]; ok && m.accepts(host.nat, from) {
+			socket = m.socket
+		}
+	}
+	if socket == nil {
+		n.stats.Filtered++
+		return
+	}
+
+	select {
+	case socket.inbox <- datagram{data: data, from: from}:
+		n.stats.Delivered++
+	default:
+		n.stats.Filtered++
+	}
+}
+
+// NAT mapping of the socket for destination, created on first datagram, should be called under lock
+func (h *Host) mapping(socket *Socket, to *net.UDPAddr) *mapping {
+	key := strconv.Itoa(socket.addr.Port)
+	if h.nat == Symmetric {
+		key += "->" + to.String()
+	}
+
+	m, ok := h.bySocket[key]
+	if ok {
+		return m
+	}
+
+	for h.mappings[h.nextPort] != nil || h.sockets[h.nextPort] != nil {
+		h.nextPort++
+	}
+	m = &mapping{socket: socket, port: h.nextPort, allowed: make(map[string]bool)}
+	if h.nat == Symmetric {
+		m.remote = to.String()
+	}
+	h.nextPort++
+
+	h.bySocket[key] = m
+	h.mappings[m.port] = m
+	return m
+}
+
+// check NAT filtering of incoming datagram
+func (m *mapping) accepts(nat NAT, from *net.UDPAddr) bool {
+	switch nat {
+	case FullCone:
+		return true
+	case RestrictedCone:
+		return m.allowed[from.IP.String()]
+	case Symmetric:
+		return m.remote == from.String()
+	}
+	return false
+}
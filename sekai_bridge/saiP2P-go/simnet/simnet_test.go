@@ -0,0 +1,143 @@
+package simnet
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func udpAddr(address string) *net.UDPAddr {
+	addr, err := net.ResolveUDPAddr("udp4", address)
+	if err != nil {
+		panic(err)
+	}
+	return addr
+}
+
+// read datagrams queued in the socket without blocking
+func drain(socket *Socket) []string {
+	received := make([]string, 0)
+	buf := make([]byte, 100)
+	for {
+		select {
+		case d := <-socket.inbox:
+			n := copy(buf, d.data)
+			received = append(received, string(buf[:n]))
+		default:
+			return received
+		}
+	}
+}
+
+func TestClockTimersOrder(t *testing.T) {
+	clock := NewClock(time.Unix(0, 0))
+	fired := make([]string, 0)
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, "b") })
+	clock.AfterFunc(time.Second, func() { fired = append(fired, "a") })
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, "c") })
+	after := clock.After(3 * time.Second)
+
+	clock.Advance(2 * time.Second)
+	if fmt.Sprint(fired) != "[a b c]" {
+		t.Fatalf("unexpected timers order %v", fired)
+	}
+
+	select {
+	case <-after:
+		t.Fatal("timer fired before its time")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	if now := <-after; !now.Equal(time.Unix(3, 0)) {
+		t.Fatalf("unexpected time %v", now)
+	}
+}
+
+func TestDeterministicConditions(t *testing.T) {
+	run := func(seed int64) ([]string, Stats) {
+		clock := NewClock(time.Unix(0, 0))
+		network := NewNetwork(clock, seed)
+		network.SetConditions(Conditions{Latency: 10 * time.Millisecond, Jitter: 10 * time.Millisecond, Loss: 0.2, Duplicate: 0.2, Reorder: 0.3})
+
+		a, _ := network.AddHost("10.0.0.1").ListenUDP(":9000")
+		b, _ := network.AddHost("10.0.0.2").ListenUDP(":9000")
+		for i := 0; i < 100; i++ {
+			a.WriteToUDP([]byte(fmt.Sprint(i)), udpAddr("10.0.0.2:9000"))
+		}
+		clock.Advance(time.Second)
+		return drain(b), network.Stats()
+	}
+
+	first, firstStats := run(7)
+	second, secondStats := run(7)
+	if fmt.Sprint(first) != fmt.Sprint(second) || firstStats != secondStats {
+		t.Fatal("the same seed gives different deliveries")
+	}
+	if firstStats.Lost == 0 || firstStats.Duplicated == 0 {
+		t.Fatalf("conditions were not applied : %+v", firstStats)
+	}
+
+	ordered := true
+	for i := 1; i < len(first); i++ {
+		previous, _ := strconv.Atoi(first[i-1])
+		current, _ := strconv.Atoi(first[i])
+		if current < previous {
+			ordered = false
+		}
+	}
+	if ordered {
+		t.Fatal("datagrams were not reordered")
+	}
+}
+
+func TestNATFiltering(t *testing.T) {
+	clock := NewClock(time.Unix(0, 0))
+	network := NewNetwork(clock, 1)
+
+	server, _ := network.AddHost("10.0.0.1").ListenUDP(":9000")
+	other, _ := network.AddHost("10.0.0.2").ListenUDP(":9000")
+	restricted, _ := network.AddNATHost("192.168.0.2", "20.0.0.2", RestrictedCone).ListenUDP("")
+	symmetric, _ := network.AddNATHost("192.168.0.3", "20.0.0.3", Symmetric).ListenUDP("")
+
+	buf := make([]byte, 100)
+
+	// mapping is created by outgoing datagram, the server sees public address
+	restricted.WriteToUDP([]byte("hello"), udpAddr("10.0.0.1:9000"))
+	clock.Advance(time.Millisecond)
+	_, from, _ := server.ReadFromUDP(buf)
+	if from.IP.String() != "20.0.0.2" {
+		t.Fatalf("unexpected source %s", from)
+	}
+
+	// reply is accepted, unsolicited host is filtered
+	server.WriteToUDP([]byte("reply"), from)
+	other.WriteToUDP([]byte("unsolicited"), from)
+	clock.Advance(time.Millisecond)
+	if received := drain(restricted); fmt.Sprint(received) != "[reply]" {
+		t.Fatalf("unexpected datagrams behind restricted NAT %v", received)
+	}
+
+	// symmetric NAT maps every destination to another port
+	symmetric.WriteToUDP([]byte("a"), udpAddr("10.0.0.1:9000"))
+	symmetric.WriteToUDP([]byte("b"), udpAddr("10.0.0.2:9000"))
+	clock.Advance(time.Millisecond)
+	_, fromServer, _ := server.ReadFromUDP(buf)
+	_, fromOther, _ := other.ReadFromUDP(buf)
+	if fromServer.Port == fromOther.Port {
+		t.Fatal("symmetric NAT used the same mapping for different destinations")
+	}
+
+	// mapping to the server does not accept datagrams of other host
+	other.WriteToUDP([]byte("unsolicited"), fromServer)
+	server.WriteToUDP([]byte("reply"), fromServer)
+	clock.Advance(time.Millisecond)
+	if received := drain(symmetric); fmt.Sprint(received) != "[reply]" {
+		t.Fatalf("unexpected datagrams behind symmetric NAT %v", received)
+	}
+	if network.Stats().Filtered != 2 {
+		t.Fatalf("unexpected filtered datagrams : %+v", network.Stats())
+	}
+}
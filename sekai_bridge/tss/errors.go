@@ -1,7 +1,6 @@
 package tss
 
 import (
-	"encoding/json"
 	"fmt"
 	"time"
 
@@ -17,17 +16,15 @@ const (
 )
 
 type CommunicationError struct {
-	PeerAddr  string    `json:"peer_id"`
-	Operation string    `json:"operation"`
-	Time      time.Time `json:"time"`
+	PeerAddr  string    `json:"peer_id" cbor:"1,keyasint"`
+	Operation string    `json:"operation" cbor:"2,keyasint"`
+	Time      time.Time `json:"time" cbor:"3,keyasint"`
 }
 
-// when we should decide, which operation (keysign, keygen) was failed
-func (t *TssServer) HandleUnmarshalError(p2pMsg *p2p.Message) (*CommunicationError, error) {
-	var operation string
-	if t.KeygenInstance.IsStarted.Load() == true || t.KeysignInstance == nil {
-		operation = KeygenOperation
-	} else {
+// failed operation is the operation of the topic
+func (t *TssServer) HandleUnmarshalError(topic string, p2pMsg *p2p.Message) *CommunicationError {
+	operation := KeygenOperation
+	if topic == KeysignTopic {
 		operation = KeysignOperation
 	}
 
@@ -35,31 +32,31 @@ func (t *TssServer) HandleUnmarshalError(p2pMsg *p2p.Message) (*CommunicationErr
 		PeerAddr:  p2pMsg.From,
 		Operation: operation,
 		Time:      time.Now(),
-	}, nil
+	}
 }
 
 // notify nodes about error
 func (t *TssServer) NotifyAboutError(commError *CommunicationError) error {
-	var msgType string
+	var msgType, topic string
 	switch commError.Operation {
 	case KeygenOperation:
-		msgType = KeygenCancelledMsgType
-	case KeysignCancelledMsgType:
-		msgType = KeysignCancelledMsgType
+		msgType, topic = KeygenCancelledMsgType, KeygenTopic
+	case KeysignOperation:
+		msgType, topic = KeysignCancelledMsgType, KeysignTopic
 	}
 
 	errMsg := P2pMessage{
 		Type:               msgType,
 		CommunicationError: *commError,
 	}
-	data, err := json.Marshal(errMsg)
+	data, err := p2p.Marshal(errMsg)
 	if err != nil {
 		return fmt.Errorf("notifyAboutError : %w", err)
 	}
 
-	err = t.P2p.SendMsg(data, nil, t.P2p.GetRealAddress())
+	err = t.P2p.Publish(topic, data)
 	if err != nil {
-		return fmt.Errorf("SendMsg : %w", err)
+		return fmt.Errorf("Publish : %w", err)
 	}
 	return nil
 }
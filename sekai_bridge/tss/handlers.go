@@ -1,7 +1,7 @@
 package tss
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 
@@ -9,24 +9,50 @@ import (
 	"go.uber.org/zap"
 )
 
-// handle incoming p2p message
-func (t *TssServer) HandleP2Pmessage(p2pMsg *p2p.Message) {
+// Listen - handle messages of the tss topics, every topic is a separate stream of the messages
+func (t *TssServer) Listen(ctx context.Context) {
+	for _, topic := range []string{KeygenTopic, KeysignTopic, HeartbeatTopic} {
+		go t.listen(ctx, t.P2p.Subscribe(topic))
+	}
+}
+
+func (t *TssServer) listen(ctx context.Context, subscription *p2p.Subscription) {
+	for {
+		p2pMsg, err := subscription.Next(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			t.Logger.Error("tss -> listen -> Next", zap.String("topic", subscription.Topic), zap.Error(err))
+			continue
+		}
+
+		if p2pMsg == nil {
+			continue
+		}
+
+		go t.HandleP2Pmessage(subscription.Topic, p2pMsg)
+	}
+}
+
+// handle incoming p2p message of the topic
+func (t *TssServer) HandleP2Pmessage(topic string, p2pMsg *p2p.Message) {
 	msg := P2pMessage{}
 
-	err := json.Unmarshal(p2pMsg.Data, &msg)
+	err := p2p.Unmarshal(p2pMsg.Data, &msg)
 	if err != nil {
-		t.Logger.Error("tss -> HandleP2Pmessage -> Unmarshal", zap.Error(err)) //, zap.Any("msg", p2pMsg))
+		t.Logger.Error("tss -> HandleP2Pmessage -> Unmarshal", zap.String("topic", topic), zap.Error(err))
 
-		// check if this msg was already handled
-		commErr, err := t.HandleUnmarshalError(p2pMsg)
-		if err != nil {
-			t.Logger.Error("tss -> HandleP2Pmessage -> HandleUnmarshalError", zap.Error(err))
+		// heartbeats don't break ceremonies
+		if topic == HeartbeatTopic {
 			return
 		}
 
+		commErr := t.HandleUnmarshalError(topic, p2pMsg)
+
 		switch commErr.Operation {
 		case KeygenOperation:
-			if t.KeygenInstance.IsStarted.Load() == true {
+			if t.KeygenInstance != nil && t.KeygenInstance.IsStarted.Load() == true {
 				t.KeygenInstance.IsStarted.Store(false)
 				t.KeygenInstance.StopChan <- *commErr
 			} else {
@@ -34,7 +60,7 @@ func (t *TssServer) HandleP2Pmessage(p2pMsg *p2p.Message) {
 			}
 
 		case KeysignOperation:
-			if t.KeysignInstance.IsStarted.Load() == true {
+			if t.KeysignInstance != nil && t.KeysignInstance.IsStarted.Load() == true {
 				t.KeysignInstance.IsStarted.Store(false)
 				t.KeysignInstance.StopChan <- *commErr
 			} else {
@@ -43,30 +69,49 @@ func (t *TssServer) HandleP2Pmessage(p2pMsg *p2p.Message) {
 		}
 		err = t.NotifyAboutError(commErr)
 		if err != nil {
-			t.Logger.Error("tss -> HandleP2Pmessage -> NotifyAboutError")
+			t.Logger.Error("tss -> HandleP2Pmessage -> NotifyAboutError", zap.Error(err))
 			return
 		}
 
 		return
 	}
 
-	t.Logger.Info("service -> HandleP2Pmessage - got msg", zap.String("from", p2pMsg.From),
+	t.Logger.Info("service -> HandleP2Pmessage - got msg", zap.String("topic", topic), zap.String("from", p2pMsg.From),
 		zap.Strings("to", p2pMsg.To), zap.String("type", msg.Type))
 
+	switch topic {
+	case KeygenTopic:
+		t.handleKeygen(&msg, p2pMsg)
+	case KeysignTopic:
+		t.handleKeysign(&msg, p2pMsg)
+	case HeartbeatTopic:
+		t.handleHeartbeat(&msg)
+	}
+}
+
+// handshakes and disconnects of the parties
+func (t *TssServer) handleHeartbeat(msg *P2pMessage) {
 	switch msg.Type {
 	case HandshakeMsgType: // for adding peers to map[id]peerAddr
-		err := t.HandleHandshake(&msg)
+		err := t.HandleHandshake(msg)
 		if err != nil {
 			t.Logger.Error("tss -> HandleP2Pmessage -> HandleHandshake", zap.Error(err))
 			return
 		}
 	case DisconnectMsgType: // for remove peer from map[id]peerAddr
-		err := t.HandleDisconnect(&msg)
+		err := t.HandleDisconnect(msg)
 		if err != nil {
 			t.Logger.Error("tss -> HandleP2Pmessage -> HandleDisconnect", zap.Error(err))
 			return
 		}
-	// keygen
+	default:
+		t.Logger.Warn("tss -> handleHeartbeat -> unexpected message type", zap.String("type", msg.Type))
+	}
+}
+
+// keygen ceremony messages
+func (t *TssServer) handleKeygen(msg *P2pMessage, p2pMsg *p2p.Message) {
+	switch msg.Type {
 	case KeygenStartMsgType: // start keygen command
 		if t.KeygenInstance == nil {
 			t.NewTssKeyGen(t.Parties, t.Threshold)
@@ -134,7 +179,14 @@ func (t *TssServer) HandleP2Pmessage(p2pMsg *p2p.Message) {
 			t.Logger.Info("service -> HandleP2Pmessage - error -> keygen error already handled")
 		}
 
-		// keysign
+	default:
+		t.Logger.Warn("tss -> handleKeygen -> unexpected message type", zap.String("type", msg.Type))
+	}
+}
+
+// keysign ceremony messages
+func (t *TssServer) handleKeysign(msg *P2pMessage, p2pMsg *p2p.Message) {
+	switch msg.Type {
 	case KeysignStartMsgType:
 		t.NewTsskeySign(t.Parties, t.Quorum)
 
@@ -204,6 +256,8 @@ func (t *TssServer) HandleP2Pmessage(p2pMsg *p2p.Message) {
 		} else {
 			t.Logger.Info("service -> HandleP2Pmessage - error -> keysign error already handled")
 		}
+	default:
+		t.Logger.Warn("tss -> handleKeysign -> unexpected message type", zap.String("type", msg.Type))
 	}
 }
 
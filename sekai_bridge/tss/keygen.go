@@ -2,15 +2,16 @@ package tss
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/KiraCore/sekai-bridge/metrics"
 	"github.com/KiraCore/sekai-bridge/utils"
 	"github.com/binance-chain/tss-lib/ecdsa/keygen"
 	tsslib "github.com/binance-chain/tss-lib/tss"
+	p2p "github.com/saiset-co/saiP2P-go/core"
 	"go.uber.org/zap"
 )
 
@@ -26,6 +27,8 @@ const (
 )
 
 func (t *TssServer) Keygen(parties, threshold int) (*Response, error) {
+	defer metrics.ObserveSince(metrics.KeygenDuration, time.Now())
+
 	// initialize keygen struct
 	t.NewTssKeyGen(parties, threshold)
 
@@ -34,11 +37,13 @@ func (t *TssServer) Keygen(parties, threshold int) (*Response, error) {
 
 	err := t.KeygenStartNotify()
 	if err != nil {
+		metrics.KeygenFailures.WithLabelValues("notify").Inc()
 		return nil, fmt.Errorf("KeygenStartNotify : %w", err)
 	}
 
 	partiesID, localPartyID, err := t.GetParties(t.Pubkey)
 	if err != nil {
+		metrics.KeygenFailures.WithLabelValues("parties").Inc()
 		return nil, fmt.Errorf("GetParties: %w", err)
 	}
 
@@ -68,6 +73,7 @@ func (t *TssKeyGen) GenerateNewKey(partiesID []*tsslib.PartyID, localPartyID *ts
 	// @TODO: config value
 	preParams, err := keygen.GeneratePreParams(10 * time.Minute)
 	if err != nil {
+		metrics.KeygenFailures.WithLabelValues("pre_params").Inc()
 		return nil, fmt.Errorf("GeneratePreParams: %w", err)
 	}
 
@@ -96,16 +102,19 @@ func (t *TssKeyGen) processKeyGen(parties int, timeStart time.Time) (*keygen.Loc
 	}()
 	t.Logger.Info("tss -> keygen -> keygen process started")
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	for {
 		select {
 		case err := <-t.ErrCh: // when keyGenParty return
 			t.Logger.Error("tss -> keygen -> error from errChan", zap.Error(err))
 			cancel()
+			metrics.KeygenFailures.WithLabelValues("party_error").Inc()
 			return nil, errors.New("error channel closed fail to start local party")
 
 		case stopMsg := <-t.StopChan: // when TSS processor receive signal to quit
 			t.Logger.Error("keygen -> received stop signal", zap.String("operation", stopMsg.Operation), zap.String("peerAddr", stopMsg.PeerAddr), zap.Time("time", stopMsg.Time))
 			cancel()
+			metrics.KeygenFailures.WithLabelValues("stop_signal").Inc()
 			return nil, fmt.Errorf("received stop signal from peerAddr = %s, operation = %s,time = %s", stopMsg.PeerAddr, stopMsg.Operation, stopMsg.Time)
 
 		case msg := <-t.OutCh:
@@ -115,6 +124,7 @@ func (t *TssKeyGen) processKeyGen(parties int, timeStart time.Time) (*keygen.Loc
 			err := t.ProcessOutCh(ctx, msg, parties)
 			if err != nil {
 				t.Logger.Error("tss -> processKeyGen -> ProcessOutCh", zap.Error(err))
+				metrics.KeygenFailures.WithLabelValues("out_channel").Inc()
 				return nil, err
 			}
 
@@ -124,6 +134,7 @@ func (t *TssKeyGen) processKeyGen(parties int, timeStart time.Time) (*keygen.Loc
 			err := utils.SaveKeyFile(&msg)
 			if err != nil {
 				t.Logger.Error("tss -> processKeyGen -> SaveKeyFile", zap.Error(err))
+				metrics.KeygenFailures.WithLabelValues("save_key").Inc()
 				return nil, err
 			}
 			return &msg, nil
@@ -139,14 +150,14 @@ func (t *TssServer) KeygenStartNotify() error {
 		Time: time.Now().Unix(), // to prevent filtering this msg
 	}
 
-	tssKeygenStartMsgData, err := json.Marshal(tssKeygenStartMsg)
+	tssKeygenStartMsgData, err := p2p.Marshal(tssKeygenStartMsg)
 	if err != nil {
 		return fmt.Errorf("marshal : %w", err)
 	}
 
-	err = t.P2p.SendMsg(tssKeygenStartMsgData, nil, t.P2p.GetRealAddress())
+	err = t.P2p.Publish(KeygenTopic, tssKeygenStartMsgData)
 	if err != nil {
-		return fmt.Errorf("sendMsg : %w", err)
+		return fmt.Errorf("Publish : %w", err)
 	}
 
 	return nil
@@ -2,12 +2,12 @@ package tss
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
 	tsslib "github.com/binance-chain/tss-lib/tss"
+	p2p "github.com/saiset-co/saiP2P-go/core"
 	"go.uber.org/zap"
 )
 
@@ -33,7 +33,7 @@ func (t *TssKeyGen) ProcessOutCh(ctx context.Context, msg tsslib.Message, partie
 		Time:   time.Now().UnixNano(),
 	}
 
-	data, err := json.Marshal(p2pMsg)
+	data, err := p2p.Marshal(p2pMsg)
 	if err != nil {
 		return fmt.Errorf("marshal : %w", err)
 	}
@@ -41,15 +41,15 @@ func (t *TssKeyGen) ProcessOutCh(ctx context.Context, msg tsslib.Message, partie
 	// time.Sleep(500 * time.Millisecond)
 
 	if msg.IsBroadcast() { // send to all
-		err = t.P2pComm.SendMsg(data, nil, t.P2pComm.GetRealAddress())
+		err = t.P2pComm.Publish(KeygenTopic, data)
 		if err != nil {
-			return fmt.Errorf("SendMsg : %w", err)
+			return fmt.Errorf("Publish : %w", err)
 		}
 	} else { // send to specified peer
 		addrs := GetPeersAddresses(t.ConnectionStorage)
-		err = t.P2pComm.SendMsg(data, addrs, t.P2pComm.GetRealAddress())
+		err = t.P2pComm.PublishTo(KeygenTopic, data, addrs)
 		if err != nil {
-			return fmt.Errorf("SendMsg : %w", err)
+			return fmt.Errorf("PublishTo : %w", err)
 		}
 	}
 	t.Logger.Info("processOutCh - msg sent",
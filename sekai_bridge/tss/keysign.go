@@ -9,10 +9,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/KiraCore/sekai-bridge/metrics"
 	"github.com/binance-chain/tss-lib/common"
 	"github.com/binance-chain/tss-lib/ecdsa/keygen"
 	"github.com/binance-chain/tss-lib/ecdsa/signing"
 	tsslib "github.com/binance-chain/tss-lib/tss"
+	p2p "github.com/saiset-co/saiP2P-go/core"
 	"go.uber.org/zap"
 )
 
@@ -22,25 +24,30 @@ var (
 
 // keysign
 func (t *TssServer) Sign(req *SignMessageRequest) (*SignMessageResponse, error) {
+	defer metrics.ObserveSince(metrics.KeysignDuration, time.Now())
+
 	t.NewTsskeySign(t.Parties, t.Quorum)
 
 	if t.Key == nil {
+		metrics.KeysignFailures.WithLabelValues("no_key").Inc()
 		return nil, fmt.Errorf("signing key was not generated")
 	}
 
 	err := t.KeysignStartNotify(req)
 	if err != nil {
+		metrics.KeysignFailures.WithLabelValues("notify").Inc()
 		return nil, fmt.Errorf("KeysignStartNotify : %w", err)
 	}
 
 	partiesID, localPartyID, err := t.GetParties(t.Pubkey)
 	if err != nil {
+		metrics.KeysignFailures.WithLabelValues("parties").Inc()
 		return nil, fmt.Errorf("GetParties: %w", err)
 	}
 
 	signature, err := t.KeysignInstance.SignMessage(req, partiesID, localPartyID, t.Key)
 	if err != nil {
-		return nil, fmt.Errorf("KeysignInstance.Sign : %w", nil)
+		return nil, fmt.Errorf("KeysignInstance.Sign : %w", err)
 	}
 
 	// @TODO : remove testing signature
@@ -51,7 +58,7 @@ func (t *TssServer) Sign(req *SignMessageRequest) (*SignMessageResponse, error)
 
 	data, err := json.Marshal(signature)
 	if err != nil {
-		return nil, fmt.Errorf("marshal: %w", nil)
+		return nil, fmt.Errorf("marshal: %w", err)
 	}
 
 	return &SignMessageResponse{
@@ -83,7 +90,7 @@ func (t *TssKeySign) SignMessage(req *SignMessageRequest, partiesID []*tsslib.Pa
 
 	sig, err := t.processKeySign(req, timeStart)
 	if err != nil {
-		return nil, fmt.Errorf("processKeySign : %w", nil)
+		return nil, fmt.Errorf("processKeySign : %w", err)
 	}
 	return sig, nil
 }
@@ -96,16 +103,19 @@ func (t *TssKeySign) processKeySign(req *SignMessageRequest, timeStart time.Time
 	}()
 	t.Logger.Info("tss -> keysign -> keysign process started")
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	for {
 		select {
 		case err := <-t.ErrCh:
 			t.Logger.Error("tss -> keysign -> error from errChan", zap.Error(err))
 			cancel()
+			metrics.KeysignFailures.WithLabelValues("party_error").Inc()
 			return nil, errors.New("error channel closed fail to start local party")
 
 		case stopMsg := <-t.StopChan:
 			t.Logger.Error("keysign -> received stop signal", zap.String("operation", stopMsg.Operation), zap.String("peerAddr", stopMsg.PeerAddr), zap.Time("time", stopMsg.Time))
 			cancel()
+			metrics.KeysignFailures.WithLabelValues("stop_signal").Inc()
 			return nil, fmt.Errorf("received stop signal from peerAddr = %s, operation = %s,time = %s", stopMsg.PeerAddr, stopMsg.Operation, stopMsg.Time)
 
 		case msg := <-t.OutCh:
@@ -115,6 +125,7 @@ func (t *TssKeySign) processKeySign(req *SignMessageRequest, timeStart time.Time
 			err := t.ProcessOutCh(ctx, msg, t.Parties)
 			if err != nil {
 				t.Logger.Error("tss -> processKeyGen -> ProcessOutCh", zap.Error(err))
+				metrics.KeysignFailures.WithLabelValues("out_channel").Inc()
 				return nil, err
 			}
 
@@ -126,6 +137,7 @@ func (t *TssKeySign) processKeySign(req *SignMessageRequest, timeStart time.Time
 				resp, err := t.HandleOneRoundSigning(msg, req)
 				if err != nil {
 					t.Logger.Error("tss -> processKeyGen -> HandleOneRoundSigning", zap.Error(err))
+					metrics.KeysignFailures.WithLabelValues("one_round").Inc()
 					return nil, err
 				}
 				t.Logger.Info("tss -> keysign -> one round signature created", zap.String("signature", resp.String()), zap.Duration("time", time.Since(timeStart)))
@@ -144,14 +156,14 @@ func (t *TssServer) KeysignStartNotify(request *SignMessageRequest) error {
 		KeysignRequest: request,
 	}
 
-	tssKeysignStartMsgData, err := json.Marshal(tssKeysignStartMsg)
+	tssKeysignStartMsgData, err := p2p.Marshal(tssKeysignStartMsg)
 	if err != nil {
 		return fmt.Errorf("marshal : %w", err)
 	}
 
-	err = t.P2p.SendMsg(tssKeysignStartMsgData, nil, t.P2p.GetRealAddress())
+	err = t.P2p.Publish(KeysignTopic, tssKeysignStartMsgData)
 	if err != nil {
-		return fmt.Errorf("sendMsg : %w", err)
+		return fmt.Errorf("Publish : %w", err)
 	}
 
 	return nil
@@ -168,14 +180,14 @@ func (t *TssKeySign) HandleOneRoundSigning(state *signing.SignatureData, req *Si
 		PartyID: t.LocalPartyID,
 	}
 
-	data, err := json.Marshal(msg)
+	data, err := p2p.Marshal(msg)
 	if err != nil {
 		return nil, fmt.Errorf("marshal : %w", err)
 	}
 
-	err = t.P2pComm.SendMsg(data, nil, t.P2pComm.GetRealAddress())
+	err = t.P2pComm.Publish(KeysignTopic, data)
 	if err != nil {
-		return nil, fmt.Errorf("sendMsg : %w", err)
+		return nil, fmt.Errorf("Publish : %w", err)
 	}
 	otherSiMap := make(map[*tsslib.PartyID]*big.Int)
 loop:
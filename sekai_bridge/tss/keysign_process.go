@@ -2,7 +2,6 @@ package tss
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"sort"
@@ -10,6 +9,7 @@ import (
 	"time"
 
 	tsslib "github.com/binance-chain/tss-lib/tss"
+	p2p "github.com/saiset-co/saiP2P-go/core"
 	"go.uber.org/zap"
 )
 
@@ -48,21 +48,21 @@ func (t *TssKeySign) ProcessOutCh(ctx context.Context, msg tsslib.Message, parti
 		Time:   time.Now().Unix(),
 	}
 
-	data, err := json.Marshal(p2pMsg)
+	data, err := p2p.Marshal(p2pMsg)
 	if err != nil {
 		return fmt.Errorf("marshal : %w", err)
 	}
 	// time.Sleep(500 * time.Millisecond)
 	if msg.IsBroadcast() { // send to all
-		err = t.P2pComm.SendMsg(data, nil, t.P2pComm.GetRealAddress())
+		err = t.P2pComm.Publish(KeysignTopic, data)
 		if err != nil {
-			return fmt.Errorf("SendMsg : %w", err)
+			return fmt.Errorf("Publish : %w", err)
 		}
 	} else { // send to specified peer
 		addrs := GetPeersAddresses(t.ConnectionStorage)
-		err = t.P2pComm.SendMsg(data, addrs, t.P2pComm.GetRealAddress())
+		err = t.P2pComm.PublishTo(KeysignTopic, data, addrs)
 		if err != nil {
-			return fmt.Errorf("sendMsg : %w", err)
+			return fmt.Errorf("PublishTo : %w", err)
 		}
 	}
 	t.Logger.Info("processOutCh - msg sent",
@@ -37,8 +37,8 @@ type KeysignMsgsStorage struct { // storage for keygen msgs from another nodes
 }
 
 type SignMessageRequest struct {
-	Msg             string `json:"msg"`
-	OneRoundSigning bool   `json:"one_round_signing"`
+	Msg             string `json:"msg" cbor:"1,keyasint"`
+	OneRoundSigning bool   `json:"one_round_signing" cbor:"2,keyasint"`
 }
 
 type SignMessageResponse struct {
@@ -1,7 +1,6 @@
 package tss
 
 import (
-	"encoding/json"
 	"fmt"
 	"sync"
 
@@ -91,13 +90,13 @@ func (t *TssServer) SendHandshake(addr string) error {
 		PeerAddr: t.P2p.GetRealAddress(),
 	}
 
-	data, err := json.Marshal(handshakeMsg)
+	data, err := p2p.Marshal(handshakeMsg)
 	if err != nil {
 		return fmt.Errorf("marshal : %w", err)
 	}
-	err = t.P2p.SendMsg(data, []string{addr}, t.P2p.GetRealAddress())
+	err = t.P2p.PublishTo(HeartbeatTopic, data, []string{addr})
 	if err != nil {
-		return fmt.Errorf("SendMsg : %w", err)
+		return fmt.Errorf("PublishTo : %w", err)
 	}
 	return nil
 }
@@ -110,7 +109,7 @@ func (t *TssServer) SendDisconnect(errCh chan error, resultCh chan bool) {
 		PeerAddr: t.P2p.GetRealAddress(),
 	}
 
-	data, err := json.Marshal(handshakeMsg)
+	data, err := p2p.Marshal(handshakeMsg)
 	if err != nil {
 		errCh <- fmt.Errorf("marshal : %w", err)
 		resultCh <- false
@@ -125,9 +124,9 @@ func (t *TssServer) SendDisconnect(errCh chan error, resultCh chan bool) {
 	for _, addr := range t.ConnectionStorage {
 		addrs = append(addrs, addr)
 	}
-	err = t.P2p.SendMsg(data, addrs, t.P2p.GetRealAddress())
+	err = t.P2p.PublishTo(HeartbeatTopic, data, addrs)
 	if err != nil {
-		errCh <- fmt.Errorf("SendMsg : %w", err)
+		errCh <- fmt.Errorf("PublishTo : %w", err)
 		resultCh <- false
 	}
 	resultCh <- true
@@ -26,6 +26,13 @@ const (
 	KeysignCancelledMsgType = "tss_keysign_cancel_msg" // for cancelling keygen due error at some peer
 )
 
+// p2p topics of the tss messages, messages of other topics are dropped by p2p before they are unmarshalled
+const (
+	KeygenTopic    = "tss/keygen"    // keygen start, rounds and cancel
+	KeysignTopic   = "tss/keysign"   // keysign start, rounds, one round signatures and cancel
+	HeartbeatTopic = "tss/heartbeat" // handshakes and disconnects of the parties
+)
+
 // main tss struct
 type TssServer struct {
 	LocalPartyID *tsslib.PartyID `json:"local_partyID,omitempty"`
@@ -53,27 +60,27 @@ type TssServer struct {
 
 // tss message struct
 type TssMessage struct {
-	From        *tsslib.PartyID        `json:"from"`
-	To          []*tsslib.PartyID      `json:"to"`
-	IsBroadcast bool                   `json:"is_broadcast"`
-	Bytes       []byte                 `json:"bytes"`
-	Type        string                 `json:"type"`
-	Routing     *tsslib.MessageRouting `json:"routing"`
+	From        *tsslib.PartyID        `json:"from" cbor:"1,keyasint"`
+	To          []*tsslib.PartyID      `json:"to" cbor:"2,keyasint"`
+	IsBroadcast bool                   `json:"is_broadcast" cbor:"3,keyasint"`
+	Bytes       []byte                 `json:"bytes" cbor:"4,keyasint"`
+	Type        string                 `json:"type" cbor:"5,keyasint"`
+	Routing     *tsslib.MessageRouting `json:"routing" cbor:"6,keyasint"`
 }
 
 // message to communicate through p2p
 // for example to register id, send tss messages through p2p, initiate keygen ...
 type P2pMessage struct {
-	TssMsg             *TssMessage         `json:"tss_message,omitempty"`     // tss message
-	Type               string              `json:"type,omitempty"`            // message type
-	PeerAddr           string              `json:"peer_addr,omitempty"`       // tss peerAddr
-	Pubkey             string              `json:"pubkey,omitempty"`          // tss party id
-	Round              string              `json:"round,omitempty"`           // keygen round
-	KeysignRequest     *SignMessageRequest `json:"keysign_request,omitempty"` // message to sign
-	Si                 *big.Int            `json:"si,omitempty"`              // si for one round signing
-	PartyID            *tsslib.PartyID     `json:"party_id,omitempty"`
-	Time               int64               `json:"sent_time,omitempty"`           // sent time, to avoid filtering (for start keygen msg)
-	CommunicationError CommunicationError  `json:"communication_error,omitempty"` // when communication error got
+	TssMsg             *TssMessage         `json:"tss_message,omitempty" cbor:"1,keyasint,omitempty"`     // tss message
+	Type               string              `json:"type,omitempty" cbor:"2,keyasint,omitempty"`            // message type
+	PeerAddr           string              `json:"peer_addr,omitempty" cbor:"3,keyasint,omitempty"`       // tss peerAddr
+	Pubkey             string              `json:"pubkey,omitempty" cbor:"4,keyasint,omitempty"`          // tss party id
+	Round              string              `json:"round,omitempty" cbor:"5,keyasint,omitempty"`           // keygen round
+	KeysignRequest     *SignMessageRequest `json:"keysign_request,omitempty" cbor:"6,keyasint,omitempty"` // message to sign
+	Si                 *big.Int            `json:"si,omitempty" cbor:"7,keyasint,omitempty"`              // si for one round signing
+	PartyID            *tsslib.PartyID     `json:"party_id,omitempty" cbor:"8,keyasint,omitempty"`
+	Time               int64               `json:"sent_time,omitempty" cbor:"9,keyasint,omitempty"`            // sent time, to avoid filtering (for start keygen msg)
+	CommunicationError CommunicationError  `json:"communication_error,omitempty" cbor:"10,keyasint,omitempty"` // when communication error got
 }
 
 // to detect in which operation error was occured
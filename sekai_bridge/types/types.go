@@ -72,6 +72,7 @@ type EthInteractionData struct {
 	Value     string                `json:"value"`
 	Params    []EthInteractionParam `json:"params"`
 	Signature []byte                `json:"signature"`
+	Reference string                `json:"reference"` // transfer id, returned with the receipt status
 }
 
 type EthInteractionRequest struct {
@@ -79,3 +80,94 @@ type EthInteractionRequest struct {
 	Data     EthInteractionData `json:"data"`
 	Metadata interface{}        `json:"metadata"`
 }
+
+// transfer statuses
+const (
+	TransferPending   = "pending"   // transfer is signing or submitting
+	TransferSubmitted = "submitted" // transaction sent to the destination chain
+	TransferConfirmed = "confirmed" // transaction confirmed on the destination chain
+	TransferFailed    = "failed"    // signing, submission or destination transaction failed
+	TransferRetracted = "retracted" // source transaction was in the orphaned block
+)
+
+// Transfer - bridge transfer ledger record
+type Transfer struct {
+	ID             string      `json:"id"`                        // source chain + idempotency key, source tx hash if notification has no key
+	IdempotencyKey string      `json:"idempotency_key,omitempty"` // key of the notified event
+	From           string      `json:"from"`                      // source chain
+	SourceTx       string      `json:"source_tx"`                 // source chain transaction hash
+	Recipient      string      `json:"recipient"`
+	Amount         string      `json:"amount"`
+	Status         string      `json:"status"`
+	DestTx         string      `json:"dest_tx,omitempty"` // destination chain transaction hash
+	Error          string      `json:"error,omitempty"`
+	Tx             interface{} `json:"tx"` // notified transaction, used to retry transfer
+	CreatedAt      int64       `json:"created_at"`
+	UpdatedAt      int64       `json:"updated_at"`
+}
+
+// TxStatus - destination transaction status reported by the interaction services
+type TxStatus struct {
+	Reference   string `json:"reference" validate:"required"`
+	Hash        string `json:"hash"`
+	Nonce       uint64 `json:"nonce"`
+	Status      string `json:"status" validate:"required"`
+	BlockNumber uint64 `json:"block_number"`
+	GasUsed     uint64 `json:"gas_used"`
+}
+
+// ReserveSide - reserves of one chain compared with the transfer ledger, amounts in token base units
+type ReserveSide struct {
+	Balance    string `json:"balance"`    // balance read from the chain
+	Expected   string `json:"expected"`   // initial + locked - released
+	Initial    string `json:"initial"`    // liquidity which was there before the bridge started
+	Locked     string `json:"locked"`     // transfers from this chain
+	Released   string `json:"released"`   // confirmed transfers to this chain
+	InFlight   string `json:"in_flight"`  // transfers to this chain which are not confirmed yet
+	Difference string `json:"difference"` // balance - expected
+	Balanced   bool   `json:"balanced"`
+}
+
+// ReserveReport - signed proof-of-reserves reconciliation result
+type ReserveReport struct {
+	ID        string      `json:"id"`
+	Ethereum  ReserveSide `json:"ethereum"` // bridge contract token balance
+	Cosmos    ReserveSide `json:"cosmos"`   // bridge module escrow balance
+	Transfers int         `json:"transfers"`
+	Balanced  bool        `json:"balanced"`
+	CreatedAt int64       `json:"created_at"`
+	PubKey    string      `json:"pub_key"`   // base64 secp256k1 public key of the node
+	Signature string      `json:"signature"` // base64 signature of the report with empty signature field
+}
+
+// TssSession - state of keygen or keysign ceremony
+type TssSession struct {
+	Initialized bool `json:"initialized"` // ceremony instance was created
+	Started     bool `json:"started"`
+	Messages    int  `json:"messages"` // stored messages from other parties
+}
+
+// TssSessions - ceremonies of the node
+type TssSessions struct {
+	Keygen  TssSession `json:"keygen"`
+	Keysign TssSession `json:"keysign"`
+}
+
+// CommitteeHealth - tss committee as seen by the node
+type CommitteeHealth struct {
+	LocalParty     string            `json:"local_party"`
+	Parties        int               `json:"parties"`
+	Threshold      int               `json:"threshold"`
+	Quorum         int               `json:"quorum"`
+	Connected      map[string]string `json:"connected"` // party pubkey -> peer address
+	P2PConnections int               `json:"p2p_connections"`
+	KeyLoaded      bool              `json:"key_loaded"`
+	CanSign        bool              `json:"can_sign"`   // key is loaded and quorum of parties is connected
+	CanKeygen      bool              `json:"can_keygen"` // all parties are connected
+}
+
+// BridgePubkey - tss public key of the bridge
+type BridgePubkey struct {
+	Uncompressed string `json:"uncompressed"` // hex encoded 65 bytes key
+	Compressed   string `json:"compressed"`   // hex encoded 33 bytes key
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -77,6 +78,11 @@ func SendHttp(url string, data []byte) error {
 }
 
 func SaiQuerySender(body io.Reader, address, token string) ([]byte, error) {
+	return SaiQuerySenderContext(context.Background(), body, address, token)
+}
+
+// SaiQuerySenderContext - query to the sai service which is cancelled with ctx
+func SaiQuerySenderContext(ctx context.Context, body io.Reader, address, token string) ([]byte, error) {
 	const failedResponseStatus = "NOK"
 
 	type responseWrapper struct {
@@ -86,7 +92,7 @@ func SaiQuerySender(body io.Reader, address, token string) ([]byte, error) {
 		Count  int                 `json:"count"`
 	}
 
-	req, err := http.NewRequest(http.MethodPost, address, body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address, body)
 	if err != nil {
 		return nil, err
 	}